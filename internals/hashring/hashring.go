@@ -0,0 +1,48 @@
+// Package hashring implements consistent hashing for backends that spread
+// keys over several nodes.
+package hashring
+
+import (
+	"hash/crc32"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// Ring assigns keys to nodes by consistent hashing. Every node is placed at
+// several points of a hash ring and owns the keys hashing up to its points,
+// so adding or removing a node only moves the keys next to its points.
+// A Ring is immutable and safe for concurrent use.
+type Ring struct {
+	points []uint32          // sorted
+	owners map[uint32]string // point -> node
+}
+
+// New creates a ring placing every node at replicas points. Nodes are
+// identified by name, so a ring built from the same names always assigns
+// keys the same way, whatever their order.
+func New(replicas int, nodes []string) *Ring {
+	r := &Ring{owners: make(map[uint32]string, replicas*len(nodes))}
+	for _, n := range nodes {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + n))
+			r.points = append(r.points, h)
+			r.owners[h] = n
+		}
+	}
+	slices.Sort(r.points)
+	return r
+}
+
+// Owner returns the node owning key, or "" if the ring is empty.
+func (r *Ring) Owner(key string) string {
+	if r == nil || len(r.points) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
@@ -0,0 +1,109 @@
+// Package wire defines the small versioned header prepended to serialized
+// cache entries so that readers can tell how a value was encoded.
+//
+// The layout is fixed at four bytes followed by the payload:
+//
+//	+-------+---------+-------+-------+-----------+
+//	| magic | version | codec | flags | payload...|
+//	+-------+---------+-------+-------+-----------+
+//
+// Entries written before the header existed start with a raw gob stream.
+// Gob never emits Magic as its first byte, so Decode reports ErrNoHeader for
+// those and callers may fall back to the legacy decoding path.
+package wire
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Magic marks the start of a framed entry.
+const Magic byte = 0xA7
+
+// Version is the current header version.
+const Version byte = 1
+
+// HeaderSize is the number of bytes taken by the header.
+const HeaderSize = 4
+
+// CodecID identifies the serialization format of the payload.
+type CodecID byte
+
+const (
+	// CodecGob marks payloads encoded with encoding/gob.
+	CodecGob CodecID = 1
+
+	// CodecJSON marks payloads encoded with encoding/json.
+	CodecJSON CodecID = 2
+
+	// CodecMsgpack marks payloads encoded with MessagePack.
+	CodecMsgpack CodecID = 3
+
+	// CodecProto marks payloads encoded with Protocol Buffers.
+	CodecProto CodecID = 4
+)
+
+// Flags describes transformations applied to the payload after encoding.
+type Flags byte
+
+const (
+	// FlagCompressed marks a compressed payload.
+	FlagCompressed Flags = 1 << iota
+	// FlagEncrypted marks an encrypted payload.
+	FlagEncrypted
+	// FlagSigned marks an entry followed by a signature over the entry.
+	FlagSigned
+)
+
+// Header describes how a payload was produced.
+type Header struct {
+	Version byte
+	Codec   CodecID
+	Flags   Flags
+}
+
+var (
+	// ErrNoHeader is returned when data does not start with Magic.
+	ErrNoHeader = errors.New("wire: missing header")
+
+	// ErrUnknownFormat is returned when the header version is not supported.
+	ErrUnknownFormat = errors.New("wire: unknown format")
+)
+
+// Encode prepends h to payload and returns the framed bytes.
+// A zero Version is replaced by the current Version.
+func Encode(h Header, payload []byte) []byte {
+	if h.Version == 0 {
+		h.Version = Version
+	}
+	out := make([]byte, HeaderSize+len(payload))
+	out[0] = Magic
+	out[1] = h.Version
+	out[2] = byte(h.Codec)
+	out[3] = byte(h.Flags)
+	copy(out[HeaderSize:], payload)
+	return out
+}
+
+// Decode splits framed data into its header and payload.
+// It returns ErrNoHeader for unframed data and ErrUnknownFormat for
+// headers written by a newer, unsupported version.
+func Decode(data []byte) (Header, []byte, error) {
+	if len(data) == 0 || data[0] != Magic {
+		return Header{}, nil, ErrNoHeader
+	}
+	if len(data) < HeaderSize {
+		return Header{}, nil, fmt.Errorf("%w: truncated header", ErrUnknownFormat)
+	}
+
+	h := Header{
+		Version: data[1],
+		Codec:   CodecID(data[2]),
+		Flags:   Flags(data[3]),
+	}
+	if h.Version == 0 || h.Version > Version {
+		return h, nil, fmt.Errorf("%w: version %d", ErrUnknownFormat, h.Version)
+	}
+
+	return h, data[HeaderSize:], nil
+}
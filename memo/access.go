@@ -0,0 +1,51 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import "github.com/ldaidone/gomemo/pkg/backends"
+
+// AccessCount returns how many times the cached entry for key has been read.
+// It requires a backend implementing backends.AccessCounter with access
+// counting enabled, e.g. memory.New(memory.WithAccessCounts()); otherwise, or
+// if key is not cached, it returns false.
+func (m *Memoizer) AccessCount(key string) (uint64, bool) {
+	ac, ok := m.caps.(backends.AccessCounter)
+	if !ok {
+		return 0, false
+	}
+	return ac.AccessCount(m.backendKey(key))
+}
+
+// Len returns the number of entries in the backend. It requires a backend
+// implementing backends.StatsBackend or backends.LenReporter and returns
+// false otherwise. Entries of other memoizers sharing the backend, e.g.
+// under another key prefix, are counted too.
+func (m *Memoizer) Len() (int, bool) {
+	st, ok := m.stats()
+	return int(st.Entries), ok
+}
+
+// Bytes returns the approximate number of bytes the backend's entries
+// occupy. It requires a backend implementing backends.StatsBackend or
+// backends.SizeReporter that measures it, and returns false otherwise.
+func (m *Memoizer) Bytes() (int64, bool) {
+	st, _ := m.stats()
+	return st.Bytes, st.Bytes >= 0
+}
+
+// stats returns the backend's size from whichever of backends.StatsBackend,
+// backends.LenReporter and backends.SizeReporter it implements. ok reports
+// whether Entries is known; Bytes is -1 when unknown.
+func (m *Memoizer) stats() (st backends.Stats, ok bool) {
+	if sb, isStats := m.caps.(backends.StatsBackend); isStats {
+		return sb.Stats(), true
+	}
+	st.Bytes = -1
+	if sr, isSize := m.caps.(backends.SizeReporter); isSize {
+		st.Bytes = sr.Bytes()
+	}
+	if lr, isLen := m.caps.(backends.LenReporter); isLen {
+		st.Entries = int64(lr.Len())
+		ok = true
+	}
+	return st, ok
+}
@@ -0,0 +1,130 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// asyncWrite is a computed value waiting to be written to the backend.
+// A write with a non-nil done channel is a flush marker: the worker closes
+// done once every write queued before it has been applied.
+type asyncWrite struct {
+	key   string
+	value any
+	ttl   time.Duration
+	tags  []string
+	done  chan struct{}
+}
+
+// asyncWriter moves backend writes off the Get path.
+// Computed values are parked in pending until a background worker has
+// written them, so lookups keep finding them (and never recompute) while a
+// slow backend write is still in progress.
+type asyncWriter struct {
+	write   func(key string, value any, ttl time.Duration, tags []string)
+	metrics *Metrics
+	queue   chan *asyncWrite
+	pending sync.Map // key -> *asyncWrite not yet written
+	stop    chan struct{}
+	stopped sync.Once
+}
+
+// newAsyncWriter creates an asyncWriter that applies writes with write, using
+// a bounded queue, and starts its worker.
+func newAsyncWriter(write func(key string, value any, ttl time.Duration, tags []string), metrics *Metrics, size int) *asyncWriter {
+	w := &asyncWriter{
+		write:   write,
+		metrics: metrics,
+		queue:   make(chan *asyncWrite, size),
+		stop:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// enqueue schedules a write. When the queue is full the write is dropped,
+// recorded in metrics, and false is returned.
+func (w *asyncWriter) enqueue(key string, value any, ttl time.Duration, tags []string) bool {
+	pw := &asyncWrite{key: key, value: value, ttl: ttl, tags: tags}
+	w.pending.Store(key, pw)
+
+	select {
+	case w.queue <- pw:
+		return true
+	default:
+		w.pending.CompareAndDelete(key, pw)
+		w.metrics.RecordAsyncSetDrop()
+		return false
+	}
+}
+
+// run applies queued writes in order until the writer is closed. Writes that
+// were superseded by a newer value or cancelled by Delete/Clear in the
+// meantime are skipped.
+func (w *asyncWriter) run() {
+	for {
+		var pw *asyncWrite
+		select {
+		case pw = <-w.queue:
+		case <-w.stop:
+			return
+		}
+		if pw.done != nil {
+			close(pw.done)
+			continue
+		}
+		if cur, ok := w.pending.Load(pw.key); ok && cur == pw {
+			w.write(pw.key, pw.value, pw.ttl, pw.tags)
+			w.pending.CompareAndDelete(pw.key, pw)
+		}
+	}
+}
+
+// flush waits until every write enqueued before the call has been applied,
+// or until ctx is done.
+func (w *asyncWriter) flush(ctx context.Context) error {
+	marker := &asyncWrite{done: make(chan struct{})}
+	select {
+	case w.queue <- marker:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-marker.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close stops the worker. Writes still queued are abandoned, so callers
+// flush first.
+func (w *asyncWriter) close() {
+	w.stopped.Do(func() { close(w.stop) })
+}
+
+// get returns a value that has been computed but not yet written.
+func (w *asyncWriter) get(key string) (any, bool) {
+	if pw, ok := w.pending.Load(key); ok {
+		return pw.(*asyncWrite).value, true
+	}
+	return nil, false
+}
+
+// forget cancels a pending write for key.
+func (w *asyncWriter) forget(key string) {
+	w.pending.Delete(key)
+}
+
+// forgetPrefix cancels the pending writes for keys starting with prefix.
+func (w *asyncWriter) forgetPrefix(prefix string) {
+	deleteByPrefix(&w.pending, prefix)
+}
+
+// clear cancels all pending writes.
+func (w *asyncWriter) clear() {
+	w.pending.Clear()
+}
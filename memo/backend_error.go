@@ -0,0 +1,68 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBackend wraps backend failures returned by Get under BackendErrorFail.
+var ErrBackend = errors.New("memo: backend error")
+
+// ErrReadOnly is returned by invalidations on a memoizer created with
+// WithReadOnly.
+var ErrReadOnly = errors.New("memo: read-only memoizer")
+
+// BackendErrorPolicy decides how Get reacts when reading from the backend fails.
+// Only backends implementing backends.BackendV2, directly or through
+// backends.V2Provider, report errors.
+type BackendErrorPolicy int
+
+const (
+	// BackendErrorAsMiss computes the value as if the key were not cached,
+	// without trying to store it in the failing backend. This is the
+	// default: the application keeps working through a backend outage, at
+	// the cost of computing every key.
+	BackendErrorAsMiss BackendErrorPolicy = iota
+
+	// BackendErrorFail returns the backend error from Get, wrapped in
+	// ErrBackend, without computing. Use it when an outage must not turn
+	// into a flood of expensive computations, e.g. database queries.
+	BackendErrorFail
+)
+
+// BackendFailOpen and BackendFailClosed name the policies after the usual
+// circuit terms.
+const (
+	// BackendFailOpen keeps serving through a backend outage by computing
+	// every key; see BackendErrorAsMiss.
+	BackendFailOpen = BackendErrorAsMiss
+
+	// BackendFailClosed returns backend errors from Get; see
+	// BackendErrorFail.
+	BackendFailClosed = BackendErrorFail
+)
+
+// backendError records a failed backend call of operation op on key and
+// passes it to the OnBackendError hook.
+func (m *Memoizer) backendError(op, key string, err error) {
+	m.metrics.RecordBackendError()
+	if m.opts.OnBackendError != nil {
+		m.opts.OnBackendError(op, key, err)
+	}
+}
+
+// readFailure returns the error Get reports for a failed read under the
+// configured policy, or nil if the read should count as a miss. Either way
+// the degraded read is counted in metrics.
+func (m *Memoizer) readFailure(err error) error {
+	if err == nil {
+		return nil
+	}
+	if m.opts.BackendErrorPolicy != BackendErrorFail {
+		m.metrics.RecordFailOpen()
+		return nil
+	}
+	m.metrics.RecordFailClosed()
+	return fmt.Errorf("%w: %w", ErrBackend, err)
+}
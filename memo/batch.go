@@ -0,0 +1,236 @@
+package memo
+
+import (
+	"context"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// GetForInputs resolves many typed inputs at once. Each input is hashed to a
+// cache key the same way MemoizeFunc derives keys from arguments; cached
+// inputs are served from the backend and all the misses are handed to loader
+// in a single call. Loaded results are cached before being returned.
+//
+// Inputs that loader leaves out of its result are missing from the returned
+// map and are not cached. Duplicate inputs are resolved once. Batched misses
+// are not deduplicated against concurrent callers the way Get is.
+//
+// Example:
+//
+//	users, err := memo.GetForInputs(ctx, m, ids, func(ctx context.Context, missing []int) (map[int]User, error) {
+//	    return db.LoadUsers(ctx, missing)
+//	})
+func GetForInputs[T comparable, R any](ctx context.Context, m *Memoizer, inputs []T, loader func(ctx context.Context, missing []T) (map[T]R, error)) (map[T]R, error) {
+	return getBatch(ctx, m, inputs, func(in T) string { return inputKey(m, in) }, loader)
+}
+
+// GetMany returns the values cached under keys and calls loader once with
+// all the keys that are not cached. Loaded values are cached before being
+// returned; keys that loader leaves out of its result are missing from the
+// returned map and are not cached. If loader fails, its error is returned.
+//
+// This is the usual way to cache "WHERE id IN (...)" queries. Duplicate keys
+// are resolved once. Batched misses are not deduplicated against concurrent
+// callers the way Get is.
+//
+// Example:
+//
+//	vals, err := m.GetMany(ctx, []string{"user:1", "user:2"}, func(missing []string) (map[string]any, error) {
+//	    return db.LoadUsersByKey(missing)
+//	})
+func (m *Memoizer) GetMany(ctx context.Context, keys []string, loader func(missing []string) (map[string]any, error)) (map[string]any, error) {
+	return getBatch(ctx, m, keys, func(key string) string { return key }, func(_ context.Context, missing []string) (map[string]any, error) {
+		return loader(missing)
+	})
+}
+
+// getBatch implements GetForInputs and GetMany: keyOf maps each input to its
+// cache key, cached values of type R are served and the rest are loaded in
+// one call and stored.
+func getBatch[T comparable, R any](ctx context.Context, m *Memoizer, inputs []T, keyOf func(T) string, loader func(ctx context.Context, missing []T) (map[T]R, error)) (map[T]R, error) {
+	result := make(map[T]R, len(inputs))
+	keys := make(map[T]string, len(inputs))
+	var unique []T
+	var keyList []string
+
+	for _, in := range inputs {
+		if _, seen := keys[in]; seen {
+			continue
+		}
+		key := keyOf(in)
+		keys[in] = key
+		unique = append(unique, in)
+		keyList = append(keyList, key)
+	}
+
+	cached, err := m.lookupMany(ctx, keyList)
+	if err := m.readFailure(err); err != nil {
+		return nil, err
+	}
+	degraded := err != nil
+	var missing []T
+	for _, in := range unique {
+		key := keys[in]
+		if val, ok := cached[key]; ok {
+			if r, ok := val.(R); ok {
+				m.metrics.RecordHit()
+				m.hot.record(key, true)
+				m.touch(ctx, key, r)
+				result[in] = r
+				continue
+			}
+		}
+		m.metrics.RecordMiss()
+		m.hot.record(key, false)
+		missing = append(missing, in)
+	}
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	start := time.Now()
+	if err := m.acquireLoad(ctx); err != nil {
+		return nil, err
+	}
+	loadCtx, cancel := m.withComputeTimeout(ctx)
+	loaded, err := loader(loadCtx, missing)
+	cancel()
+	m.loads.release()
+	m.metrics.RecordLatency(time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]backends.BatchItem, 0, len(missing))
+	for _, in := range missing {
+		r, ok := loaded[in]
+		if !ok {
+			continue
+		}
+		if m.opts.AutoGobRegister {
+			registerGobType(r)
+		}
+		items = append(items, backends.BatchItem{Key: keys[in], Value: r, TTL: m.ttlFor(keys[in], r)})
+		result[in] = r
+	}
+	if !degraded {
+		m.storeMany(ctx, items)
+	}
+	return result, nil
+}
+
+// lookupMany reads keys like lookup, using a single GetMulti call when the
+// backend supports batches. Missing keys are absent from the result. A
+// failed backend read is returned along with whatever could be read.
+func (m *Memoizer) lookupMany(ctx context.Context, keys []string) (map[string]any, error) {
+	bb, ok := m.batchBackend()
+	if !ok {
+		out := make(map[string]any, len(keys))
+		var firstErr error
+		for _, key := range keys {
+			val, ok, err := m.lookupErr(ctx, key)
+			if ok {
+				out[key] = val
+			} else if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return out, firstErr
+	}
+
+	start := m.metrics.now()
+	found, err := bb.GetMulti(ctx, m.backendKeys(keys))
+	m.metrics.RecordBackendGetLatency(m.metrics.since(start))
+	if err != nil {
+		m.backendError("get_many", "", err)
+	}
+	out := make(map[string]any, len(found))
+	for _, key := range keys {
+		if val, ok := found[m.backendKey(key)]; ok {
+			out[key] = val
+		}
+	}
+	if m.async != nil {
+		for _, key := range keys {
+			if _, hit := out[key]; hit {
+				continue
+			}
+			if val, ok := m.async.get(key); ok {
+				out[key] = val
+			}
+		}
+	}
+	return out, err
+}
+
+// storeMany writes items like store, using a single SetMulti call when the
+// backend supports batches and writes are not asynchronous.
+func (m *Memoizer) storeMany(ctx context.Context, items []backends.BatchItem) {
+	if m.opts.ReadOnly {
+		return
+	}
+	bb, ok := m.batchBackend()
+	if !ok || m.async != nil || len(items) == 0 {
+		for _, it := range items {
+			m.store(ctx, it.Key, it.Value, it.TTL, nil)
+		}
+		return
+	}
+	if m.opts.KeyPrefix != "" {
+		prefixed := make([]backends.BatchItem, len(items))
+		for i, it := range items {
+			it.Key = m.backendKey(it.Key)
+			prefixed[i] = it
+		}
+		items = prefixed
+	}
+	start := m.metrics.now()
+	err := bb.SetMulti(ctx, items)
+	m.metrics.RecordBackendSetLatency(m.metrics.since(start))
+	if err != nil {
+		m.backendError("set_many", "", err)
+	}
+}
+
+// batchBackend returns the backend as a BatchBackendV2 if it supports
+// batches, either in its context-aware form or, for Backends, through
+// BatchBackend.
+func (m *Memoizer) batchBackend() (backends.BatchBackendV2, bool) {
+	if bb, ok := m.store2.(backends.BatchBackendV2); ok {
+		return bb, true
+	}
+	if m.opts.BackendV2 != nil {
+		return nil, false
+	}
+	if bb, ok := m.backend.(backends.BatchBackend); ok {
+		return batchAdapter{bb}, true
+	}
+	return nil, false
+}
+
+// batchAdapter implements BatchBackendV2 on top of a BatchBackend, which
+// ignores the context and never fails.
+type batchAdapter struct {
+	b backends.BatchBackend
+}
+
+func (a batchAdapter) GetMulti(_ context.Context, keys []string) (map[string]any, error) {
+	return a.b.GetMulti(keys), nil
+}
+
+func (a batchAdapter) SetMulti(_ context.Context, items []backends.BatchItem) error {
+	a.b.SetMulti(items)
+	return nil
+}
+
+func (a batchAdapter) DeleteMulti(_ context.Context, keys []string) error {
+	a.b.DeleteMulti(keys)
+	return nil
+}
+
+// inputKey derives the cache key for a single GetForInputs input.
+func inputKey[T comparable](m *Memoizer, in T) string {
+	return "memoized_input_" + m.argsKey(in)
+}
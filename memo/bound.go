@@ -0,0 +1,92 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"time"
+)
+
+// BoundMemoizer is a view of a Memoizer with a fixed context.
+// It is returned by Memoizer.WithContext and is handy for request-scoped code
+// where the same context would otherwise be passed to every call.
+type BoundMemoizer struct {
+	m   *Memoizer       // underlying memoizer
+	ctx context.Context // context used for every call
+}
+
+// WithContext returns a BoundMemoizer that uses ctx for all of its calls.
+// Cancelling ctx affects every call made through the returned value.
+//
+// Example:
+//
+//	bm := m.WithContext(r.Context())
+//	user, err := bm.Get("user:42", loadUser)
+func (m *Memoizer) WithContext(ctx context.Context) *BoundMemoizer {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &BoundMemoizer{m: m, ctx: ctx}
+}
+
+// Context returns the context bound to this memoizer.
+func (b *BoundMemoizer) Context() context.Context {
+	return b.ctx
+}
+
+// Memoizer returns the underlying Memoizer.
+func (b *BoundMemoizer) Memoizer() *Memoizer {
+	return b.m
+}
+
+// Get retrieves a cached value or computes and stores it using the bound context.
+// See Memoizer.Get for details.
+func (b *BoundMemoizer) Get(key string, fn func() (any, error)) (any, error) {
+	return b.m.Get(b.ctx, key, fn)
+}
+
+// GetWithOptions is Get with per-call options, using the bound context.
+// See Memoizer.GetWithOptions for details.
+func (b *BoundMemoizer) GetWithOptions(key string, fn func() (any, error), opts ...CallOption) (any, error) {
+	return b.m.GetWithOptions(b.ctx, key, fn, opts...)
+}
+
+// Set stores value under key using the bound context.
+// See Memoizer.Set for details.
+func (b *BoundMemoizer) Set(key string, value any) {
+	b.m.Set(b.ctx, key, value)
+}
+
+// Peek returns the cached value for key without side effects, using the
+// bound context for backends that are read through Get.
+// See Memoizer.Peek for details.
+func (b *BoundMemoizer) Peek(key string) (any, bool) {
+	return b.m.peek(b.ctx, key)
+}
+
+// Has reports whether a value is cached for key, like Peek.
+func (b *BoundMemoizer) Has(key string) bool {
+	_, ok := b.m.peek(b.ctx, key)
+	return ok
+}
+
+// Touch resets the expiry of the cached entry for key using the bound context.
+// See Memoizer.Touch for details.
+func (b *BoundMemoizer) Touch(key string, ttl time.Duration) bool {
+	return b.m.touchKey(b.ctx, key, ttl)
+}
+
+// GetLazy returns a thunk that resolves key using the bound context.
+// See Memoizer.GetLazy for details.
+func (b *BoundMemoizer) GetLazy(key string, fn func() (any, error)) func() (any, error) {
+	return b.m.GetLazy(b.ctx, key, fn)
+}
+
+// Delete removes an entry from cache.
+func (b *BoundMemoizer) Delete(key string) {
+	b.m.Delete(key)
+}
+
+// Clear purges all entries from the backend.
+func (b *BoundMemoizer) Clear() {
+	b.m.Clear()
+}
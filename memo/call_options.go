@@ -0,0 +1,90 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"time"
+)
+
+// CallOption configures a single GetWithOptions call.
+type CallOption func(*callOptions)
+
+// callOptions holds the per-call settings applied by CallOption.
+type callOptions struct {
+	ttl          time.Duration
+	forceRefresh bool
+	bypassCache  bool
+	tags         []string
+}
+
+// CallTTL stores the result of this call with ttl instead of the memoizer's TTL.
+func CallTTL(ttl time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.ttl = ttl
+	}
+}
+
+// ForceRefresh ignores any cached value and recomputes it, storing the new
+// result. Concurrent callers of the same key still share one computation.
+func ForceRefresh() CallOption {
+	return func(o *callOptions) {
+		o.forceRefresh = true
+	}
+}
+
+// CallTags attaches the stored result to tags, so that InvalidateTag can
+// remove it together with other entries carrying the same tag.
+func CallTags(tags ...string) CallOption {
+	return func(o *callOptions) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+// BypassCache runs fn without reading or writing the cache, and returns its
+// result as it is. Panic recovery, retries, ComputeTimeout, the load limits
+// and the compute metrics still apply, but the call leaves no trace in the
+// cache's state: its errors are not negatively cached or answered with a
+// stale value, and it neither counts toward nor is held back by the
+// recompute rate limit.
+func BypassCache() CallOption {
+	return func(o *callOptions) {
+		o.bypassCache = true
+	}
+}
+
+// GetWithOptions is like Get, with per-call behavior controlled by opts.
+// This avoids creating separate memoizers for slightly different caching needs.
+//
+// Example:
+//
+//	v, err := m.GetWithOptions(ctx, "report", buildReport, memo.CallTTL(time.Hour))
+//	v, err = m.GetWithOptions(ctx, "report", buildReport, memo.ForceRefresh())
+func (m *Memoizer) GetWithOptions(ctx context.Context, key string, fn func() (any, error), opts ...CallOption) (any, error) {
+	var co callOptions
+	for _, opt := range opts {
+		opt(&co)
+	}
+
+	if co.bypassCache {
+		return m.bypass(ctx, fn)
+	}
+
+	v, _, err := m.get(ctx, key, func() (any, CacheControl, error) {
+		v, err := fn()
+		return v, CacheControl{TTL: co.ttl, Tags: co.tags}, err
+	}, co.forceRefresh)
+	return v, err
+}
+
+// bypass runs fn for BypassCache through the parts of the compute pipeline
+// that protect the caller and the upstream, but none of those that consult
+// or update per-key state.
+func (m *Memoizer) bypass(ctx context.Context, fn func() (any, error)) (any, error) {
+	start := m.metrics.now()
+	v, _, err := m.retry(ctx, m.withTimeout(ctx, m.limited(m.recovered(func() (any, CacheControl, error) {
+		v, err := fn()
+		return v, CacheControl{}, err
+	}))))
+	m.metrics.RecordComputeLatency(m.metrics.since(start))
+	return v, err
+}
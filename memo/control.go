@@ -0,0 +1,40 @@
+package memo
+
+import (
+	"context"
+	"time"
+)
+
+// CacheControl lets a producer decide how its result is cached,
+// similar to an HTTP Cache-Control header.
+type CacheControl struct {
+	// TTL overrides the memoizer's TTL for this result. Zero or negative uses the default.
+	TTL time.Duration
+
+	// NoStore returns the result to the caller without caching it.
+	NoStore bool
+
+	// StaleOK keeps the result around after it expires. If a later recompute
+	// of the key fails, the stale value is returned instead of the error.
+	// Stale values count toward Options.MaxTrackedKeys.
+	StaleOK bool
+
+	// Tags attach the result to tags for InvalidateTag. They require a
+	// backend implementing backends.Tagger and are ignored otherwise.
+	Tags []string
+}
+
+// GetControlled is like Get, but fn also returns a CacheControl that is
+// applied when storing its result. This lets the producer decide per result
+// whether and for how long it may be cached.
+//
+// Example:
+//
+//	resp, err := m.GetControlled(ctx, url, func() (any, memo.CacheControl, error) {
+//	    body, maxAge, err := fetch(url)
+//	    return body, memo.CacheControl{TTL: maxAge, NoStore: maxAge == 0}, err
+//	})
+func (m *Memoizer) GetControlled(ctx context.Context, key string, fn func() (value any, control CacheControl, err error)) (any, error) {
+	v, _, err := m.get(ctx, key, fn, false)
+	return v, err
+}
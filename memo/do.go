@@ -0,0 +1,47 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrUnexpectedType is returned by the typed helpers when the cached value for
+// a key is not of the requested type, e.g. because another caller stored a
+// different type under the same key.
+var ErrUnexpectedType = errors.New("cached value has unexpected type")
+
+// Do is a typed wrapper around Memoizer.Get. It saves call sites from
+// asserting the type of the result, and reports a mismatch as an error
+// wrapping ErrUnexpectedType instead of panicking.
+//
+// Example:
+//
+//	name, err := memo.Do(ctx, m, "user:42:name", func() (string, error) {
+//	    return loadName(42)
+//	})
+func Do[T any](ctx context.Context, m *Memoizer, key string, fn func() (T, error)) (T, error) {
+	var zero T
+
+	v, err := m.Get(ctx, key, func() (any, error) {
+		return fn()
+	})
+	if err != nil {
+		return zero, err
+	}
+	return asType[T](key, v)
+}
+
+// asType converts a cached value to T. A nil value yields the zero T.
+func asType[T any](key string, v any) (T, error) {
+	var zero T
+	if v == nil {
+		return zero, nil
+	}
+	t, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("%w: key %q holds %T, want %T", ErrUnexpectedType, key, v, zero)
+	}
+	return t, nil
+}
@@ -0,0 +1,113 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrorClass tells the memoizer how to handle an error returned by a compute
+// function. See WithErrorPolicy.
+type ErrorClass int
+
+const (
+	// ErrorRetryable errors are transient: they are retried as configured
+	// with WithRetry, then answered with the stale value if one is kept, and
+	// never negatively cached.
+	ErrorRetryable ErrorClass = iota
+
+	// ErrorCacheable errors are answers in their own right, such as "not
+	// found": they are not retried or answered with a stale value, and are
+	// negatively cached as configured with WithErrorTTL.
+	ErrorCacheable
+
+	// ErrorFatal errors are returned to the callers as they are: they are
+	// not retried, answered with a stale value or negatively cached.
+	ErrorFatal
+
+	// errorUnclassified is the class of every error when no policy is set:
+	// errors are retried, answered with a stale value and negatively cached.
+	// ErrComputeTimeout is the exception to retrying: see retryable.
+	errorUnclassified ErrorClass = -1
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorRetryable:
+		return "retryable"
+	case ErrorCacheable:
+		return "cacheable"
+	case ErrorFatal:
+		return "fatal"
+	}
+	return "unknown"
+}
+
+// ErrorPolicy classifies the errors returned by compute functions.
+//
+// Example:
+//
+//	policy := func(err error) memo.ErrorClass {
+//	    switch {
+//	    case errors.Is(err, sql.ErrNoRows):
+//	        return memo.ErrorCacheable
+//	    case errors.Is(err, context.Canceled):
+//	        return memo.ErrorFatal
+//	    }
+//	    return memo.ErrorRetryable
+//	}
+type ErrorPolicy func(err error) ErrorClass
+
+// classify returns the class of err under the configured policy. Panics
+// are always fatal.
+func (m *Memoizer) classify(err error) ErrorClass {
+	var pe *PanicError
+	if errors.As(err, &pe) {
+		return ErrorFatal
+	}
+	if m.opts.ErrorPolicy == nil {
+		return errorUnclassified
+	}
+	return m.opts.ErrorPolicy(err)
+}
+
+// retryable reports whether err may be retried. Without a policy,
+// ErrComputeTimeout is not: the timed out function keeps running in the
+// background, so every retry would add another orphaned load.
+func (m *Memoizer) retryable(err error) bool {
+	switch m.classify(err) {
+	case ErrorRetryable:
+		return true
+	case errorUnclassified:
+		return !errors.Is(err, ErrComputeTimeout)
+	}
+	return false
+}
+
+// uncacheable reports whether err says nothing about key itself and must
+// never be negatively cached: a rejected load, or a context that ended,
+// possibly while the call was still queued for a load slot.
+func uncacheable(err error) bool {
+	return errors.Is(err, ErrTooManyLoads) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// failed handles an error from the compute function for key according to its
+// class, returning the stale value instead if one applies.
+func (m *Memoizer) failed(key string, err error) (any, error) {
+	class := m.classify(err)
+	if class == ErrorFatal {
+		return nil, err
+	}
+	if class != ErrorCacheable {
+		if stale, ok := m.staleValue(key); ok {
+			m.metrics.RecordStaleServed()
+			return stale, nil
+		}
+	}
+	if class != ErrorRetryable && !uncacheable(err) {
+		m.cacheError(key, err)
+	}
+	return nil, err
+}
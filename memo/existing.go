@@ -0,0 +1,45 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// GetExisting returns the cached value for key without ever computing it.
+// If the key is not cached but a Get for it is in progress on this memoizer,
+// GetExisting waits up to maxWait for that computation to finish.
+//
+// It returns (value, true, nil) on a hit or when the in-flight computation
+// completes, and (nil, false, nil) if the key is absent and nothing finished
+// computing it within maxWait. An error is returned if the in-flight
+// computation fails or ctx is done.
+//
+// This suits read-only replicas of a cache that is populated elsewhere.
+func (m *Memoizer) GetExisting(ctx context.Context, key string, maxWait time.Duration) (any, bool, error) {
+	if val, ok := m.lookup(ctx, key); ok {
+		m.metrics.RecordHit()
+		m.touch(ctx, key, val)
+		return val, true, nil
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	val, err, inFlight := m.group.Wait(waitCtx, key)
+	switch {
+	case !inFlight:
+		m.metrics.RecordMiss()
+		return nil, false, nil
+	case err == nil:
+		m.metrics.RecordHit()
+		return val, true, nil
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+		// maxWait elapsed before the computation finished
+		m.metrics.RecordMiss()
+		return nil, false, nil
+	default:
+		return nil, false, err
+	}
+}
@@ -0,0 +1,47 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import "expvar"
+
+// expvarStats is the JSON form of Metrics published by PublishExpvar.
+// Latencies are in microseconds.
+type expvarStats struct {
+	Metrics
+	HitRatio    float64
+	AvgLatency  float64
+	MinLatency  int64
+	MaxLatency  int64
+	LastLatency int64
+	P50Latency  int64
+	P95Latency  int64
+	P99Latency  int64
+}
+
+// PublishExpvar publishes the metrics under name in the expvar package, so
+// that services already serving /debug/vars expose live cache statistics
+// without further wiring. Every read of the variable takes a fresh
+// snapshot. Like expvar.Publish, it panics if name is already in use.
+//
+// Example:
+//
+//	m := memo.New(memo.WithMetrics(true))
+//	m.Metrics().PublishExpvar("cache.users")
+func (m *Metrics) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		s := m.Snapshot()
+		stats := expvarStats{
+			Metrics:     s,
+			HitRatio:    s.HitRatio(),
+			AvgLatency:  s.AvgLatency(),
+			MaxLatency:  s.MaxLatency().Microseconds(),
+			LastLatency: s.LastLatency().Microseconds(),
+			P50Latency:  s.Percentile(0.5).Microseconds(),
+			P95Latency:  s.Percentile(0.95).Microseconds(),
+			P99Latency:  s.Percentile(0.99).Microseconds(),
+		}
+		if s.countLatency > 0 {
+			stats.MinLatency = s.MinLatency().Microseconds()
+		}
+		return stats
+	}))
+}
@@ -0,0 +1,33 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"encoding/gob"
+	"reflect"
+	"sync"
+)
+
+// gobTypes records the concrete types already seen by registerGobType.
+var gobTypes sync.Map // reflect.Type -> struct{}
+
+// registerGobType registers the concrete type of v with encoding/gob the first
+// time it is seen. Serializing backends such as redis store values as
+// interfaces, which gob can only encode and decode for registered types, so
+// doing this for every cached value makes moving from the memory backend to a
+// serializing one transparent.
+//
+// Registration is process-wide and deduplicated. Types gob refuses (or whose
+// name clashes with an existing registration) are silently skipped; they will
+// still work with non-serializing backends.
+func registerGobType(v any) {
+	if v == nil {
+		return
+	}
+	t := reflect.TypeOf(v)
+	if _, seen := gobTypes.LoadOrStore(t, struct{}{}); seen {
+		return
+	}
+
+	defer func() { _ = recover() }()
+	gob.Register(v)
+}
@@ -0,0 +1,68 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// CacheInfo describes how a GetWithInfo result was served. Metadata the
+// backend does not keep is left zero.
+type CacheInfo struct {
+	// Hit is true if the value came from the cache without running fn.
+	Hit bool
+
+	// TTL is the remaining time to live; zero if the entry never expires.
+	TTL time.Duration
+
+	// Version counts writes to the entry, if the backend tracks it.
+	Version uint64
+
+	// Age is the time since the value was stored.
+	Age time.Duration
+}
+
+// GetWithInfo is like Get, but also returns metadata about the cached entry,
+// e.g. to emit an "X-Cache: HIT; age=42" header or to make freshness
+// decisions. Metadata requires a backend implementing backends.EntryBackend;
+// the entry is re-read without counting as an access when the backend
+// implements backends.EntryPeeker.
+//
+// Example:
+//
+//	v, info, err := m.GetWithInfo(ctx, key, load)
+//	if info.Hit {
+//	    w.Header().Set("X-Cache", fmt.Sprintf("HIT; age=%d", int(info.Age.Seconds())))
+//	}
+func (m *Memoizer) GetWithInfo(ctx context.Context, key string, fn func() (any, error)) (any, CacheInfo, error) {
+	v, hit, err := m.get(ctx, key, func() (any, CacheControl, error) {
+		v, err := fn()
+		return v, CacheControl{}, err
+	}, false)
+
+	info := CacheInfo{Hit: hit}
+	if err != nil {
+		return v, info, err
+	}
+	if entry, ok := m.peekEntry(key); ok {
+		info.TTL = entry.TTLRemaining()
+		info.Version = entry.Version()
+		if stored := entry.StoredAt(); !stored.IsZero() {
+			info.Age = max(time.Since(stored), 0)
+		}
+	}
+	return v, info, nil
+}
+
+// peekEntry reads the entry for key with as few side effects as the backend allows.
+func (m *Memoizer) peekEntry(key string) (backends.CacheEntry, bool) {
+	if ep, ok := m.caps.(backends.EntryPeeker); ok {
+		return ep.PeekEntry(m.backendKey(key))
+	}
+	if eb, ok := m.backend.(backends.EntryBackend); ok {
+		return eb.GetEntry(m.backendKey(key))
+	}
+	return backends.CacheEntry{}, false
+}
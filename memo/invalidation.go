@@ -0,0 +1,55 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+
+	"github.com/ldaidone/gomemo/pkg/invalidation"
+)
+
+// subscribe starts applying the invalidations published on the configured
+// bus by other memoizers and external producers.
+func (m *Memoizer) subscribe() error {
+	m.busID = strconv.FormatUint(rand.Uint64(), 36)
+	sub, err := m.opts.InvalidationBus.Subscribe(context.Background(), m.applyInvalidation)
+	if err != nil {
+		return fmt.Errorf("subscribe to invalidation bus: %w", err)
+	}
+	m.busSub = sub
+	return nil
+}
+
+// publish sends an invalidation made on this memoizer to the bus, if one is
+// configured. Failures are reported like backend errors, with op "publish".
+func (m *Memoizer) publish(kind invalidation.Kind, value string) {
+	if m.opts.InvalidationBus == nil || m.opts.ReadOnly {
+		return
+	}
+	msg := invalidation.Message{Kind: kind, Value: value, Origin: m.busID}
+	if err := m.opts.InvalidationBus.Publish(context.Background(), msg); err != nil {
+		m.backendError("publish", value, err)
+	}
+}
+
+// applyInvalidation applies a message received from the bus, unless this
+// memoizer published it.
+func (m *Memoizer) applyInvalidation(msg invalidation.Message) {
+	if msg.Origin != "" && msg.Origin == m.busID {
+		return
+	}
+	switch msg.Kind {
+	case invalidation.Key:
+		m.deleteKey(msg.Value)
+	case invalidation.Prefix:
+		_, _ = m.deletePrefix(msg.Value)
+	case invalidation.Tag:
+		_, _ = m.invalidateTag(msg.Value)
+	case invalidation.Namespace:
+		m.Namespace(msg.Value).invalidate(context.Background())
+	case invalidation.All:
+		m.clear()
+	}
+}
@@ -0,0 +1,170 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LatencySummary describes the latency of one kind of operation, such as
+// cache hits or backend reads. Percentiles are within 1/8 of their exact
+// values.
+type LatencySummary struct {
+	Count uint64
+	Avg   time.Duration
+	Min   time.Duration
+	Max   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+// latencyRecorder accumulates latency samples of one kind of operation.
+// Samples are kept in nanoseconds, since cache hits often take well under a
+// microsecond.
+type latencyRecorder struct {
+	count   uint64
+	total   uint64 // nanoseconds
+	minPlus int64  // smallest sample in nanoseconds plus one; zero if none
+	max     int64  // nanoseconds
+	buckets [percentileBuckets]uint64
+}
+
+// record adds a sample of d.
+func (r *latencyRecorder) record(d time.Duration) {
+	ns := max(d.Nanoseconds(), 0)
+	atomic.AddUint64(&r.count, 1)
+	atomic.AddUint64(&r.total, uint64(ns))
+	atomic.AddUint64(&r.buckets[percentileBucket(ns)], 1)
+
+	for {
+		old := atomic.LoadInt64(&r.minPlus)
+		if old != 0 && ns+1 >= old {
+			break
+		}
+		if atomic.CompareAndSwapInt64(&r.minPlus, old, ns+1) {
+			break
+		}
+	}
+	for {
+		old := atomic.LoadInt64(&r.max)
+		if ns <= old {
+			break
+		}
+		if atomic.CompareAndSwapInt64(&r.max, old, ns) {
+			break
+		}
+	}
+}
+
+// copyFrom loads the samples of src into r, for snapshots.
+func (r *latencyRecorder) copyFrom(src *latencyRecorder) {
+	r.count = atomic.LoadUint64(&src.count)
+	r.total = atomic.LoadUint64(&src.total)
+	r.minPlus = atomic.LoadInt64(&src.minPlus)
+	r.max = atomic.LoadInt64(&src.max)
+	for i := range src.buckets {
+		r.buckets[i] = atomic.LoadUint64(&src.buckets[i])
+	}
+}
+
+// reset drops every sample.
+func (r *latencyRecorder) reset() {
+	atomic.StoreUint64(&r.count, 0)
+	atomic.StoreUint64(&r.total, 0)
+	atomic.StoreInt64(&r.minPlus, 0)
+	atomic.StoreInt64(&r.max, 0)
+	for i := range r.buckets {
+		atomic.StoreUint64(&r.buckets[i], 0)
+	}
+}
+
+// summary describes the samples recorded so far.
+func (r *latencyRecorder) summary() LatencySummary {
+	count := atomic.LoadUint64(&r.count)
+	if count == 0 {
+		return LatencySummary{}
+	}
+	hi := time.Duration(atomic.LoadInt64(&r.max))
+	return LatencySummary{
+		Count: count,
+		Avg:   time.Duration(atomic.LoadUint64(&r.total) / count),
+		Min:   time.Duration(atomic.LoadInt64(&r.minPlus) - 1),
+		Max:   hi,
+		P50:   percentile(&r.buckets, time.Nanosecond, 0.5, hi),
+		P95:   percentile(&r.buckets, time.Nanosecond, 0.95, hi),
+		P99:   percentile(&r.buckets, time.Nanosecond, 0.99, hi),
+	}
+}
+
+// now returns the current time if metrics are enabled, to start timing an
+// operation for one of the Record*Latency methods.
+func (m *Metrics) now() time.Time {
+	if !m.Enabled {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// since returns the time elapsed since start, as returned by now, or zero if
+// metrics are disabled.
+func (m *Metrics) since(start time.Time) time.Duration {
+	if !m.Enabled {
+		return 0
+	}
+	return time.Since(start)
+}
+
+// RecordHitLatency records how long a Get answered from the cache took.
+func (m *Metrics) RecordHitLatency(d time.Duration) {
+	if !m.Enabled {
+		return
+	}
+	m.hitLatency.record(d)
+}
+
+// RecordBackendGetLatency records how long a backend read took.
+func (m *Metrics) RecordBackendGetLatency(d time.Duration) {
+	if !m.Enabled {
+		return
+	}
+	m.backendGetLatency.record(d)
+}
+
+// RecordBackendSetLatency records how long a backend write took.
+func (m *Metrics) RecordBackendSetLatency(d time.Duration) {
+	if !m.Enabled {
+		return
+	}
+	m.backendSetLatency.record(d)
+}
+
+// RecordComputeLatency records how long a compute function took, including
+// any retries.
+func (m *Metrics) RecordComputeLatency(d time.Duration) {
+	if !m.Enabled {
+		return
+	}
+	m.computeLatency.record(d)
+}
+
+// HitLatency describes the latency of Gets answered from the cache.
+func (m *Metrics) HitLatency() LatencySummary {
+	return m.hitLatency.summary()
+}
+
+// BackendGetLatency describes the latency of backend reads, batched or not.
+func (m *Metrics) BackendGetLatency() LatencySummary {
+	return m.backendGetLatency.summary()
+}
+
+// BackendSetLatency describes the latency of backend writes, batched or not.
+func (m *Metrics) BackendSetLatency() LatencySummary {
+	return m.backendSetLatency.summary()
+}
+
+// ComputeLatency describes the latency of compute functions alone, without
+// the backend calls around them.
+func (m *Metrics) ComputeLatency() LatencySummary {
+	return m.computeLatency.summary()
+}
@@ -0,0 +1,35 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"sync"
+)
+
+// GetLazy returns a thunk that resolves key through Get on its first call.
+// Nothing is computed until the thunk is invoked; after that the result
+// (value and error) is memoized inside the thunk itself, so repeated calls
+// are free and never reach the backend again.
+//
+// This makes it easy to wire memoized computations into dependency graphs
+// where a node may or may not end up being evaluated.
+//
+// Example:
+//
+//	user := m.GetLazy(ctx, "user:42", loadUser)
+//	// ... later, only if needed:
+//	v, err := user()
+func (m *Memoizer) GetLazy(ctx context.Context, key string, fn func() (any, error)) func() (any, error) {
+	var (
+		once sync.Once
+		val  any
+		err  error
+	)
+
+	return func() (any, error) {
+		once.Do(func() {
+			val, err = m.Get(ctx, key, fn)
+		})
+		return val, err
+	}
+}
@@ -0,0 +1,86 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrTooManyLoads is returned when a miss cannot be computed because
+// MaxConcurrentLoads compute functions are already running and the queue of
+// misses waiting for them is full.
+var ErrTooManyLoads = errors.New("memo: too many concurrent loads")
+
+// loadLimiter bounds the number of compute functions running at once.
+type loadLimiter struct {
+	slots    chan struct{} // one token per running load
+	queued   atomic.Int64  // loads waiting for a slot
+	maxQueue int           // loads allowed to wait; 0 is unlimited, negative none
+}
+
+// newLoadLimiter returns a limiter for n concurrent loads, or nil if n is
+// not positive.
+func newLoadLimiter(n, maxQueue int) *loadLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return &loadLimiter{slots: make(chan struct{}, n), maxQueue: maxQueue}
+}
+
+// acquire takes a slot, waiting for one while ctx allows unless the queue
+// is full. A nil limiter always succeeds.
+func (l *loadLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if l.maxQueue < 0 {
+		return ErrTooManyLoads
+	}
+	if n := l.queued.Add(1); l.maxQueue > 0 && n > int64(l.maxQueue) {
+		l.queued.Add(-1)
+		return ErrTooManyLoads
+	}
+	defer l.queued.Add(-1)
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release returns a slot taken by acquire.
+func (l *loadLimiter) release() {
+	if l != nil {
+		<-l.slots
+	}
+}
+
+// acquireLoad takes a load slot for a compute function, counting rejected
+// loads in metrics.
+func (m *Memoizer) acquireLoad(ctx context.Context) error {
+	err := m.loads.acquire(ctx)
+	if errors.Is(err, ErrTooManyLoads) {
+		m.metrics.RecordLoadRejection()
+	}
+	return err
+}
+
+// limited wraps fn so that it runs only once a load slot is free.
+func (m *Memoizer) limited(fn func() (any, CacheControl, error)) func(context.Context) (any, CacheControl, error) {
+	return func(ctx context.Context) (any, CacheControl, error) {
+		if err := m.acquireLoad(ctx); err != nil {
+			return nil, CacheControl{}, err
+		}
+		defer m.loads.release()
+		return fn()
+	}
+}
@@ -0,0 +1,157 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrNotLoaded is returned by Loader.Load when the batch function leaves the
+// requested key out of its result.
+var ErrNotLoaded = errors.New("key not returned by batch loader")
+
+// defaultBatchWindow is how long a Loader collects keys before dispatching.
+const defaultBatchWindow = time.Millisecond
+
+// LoaderOption configures a Loader created with NewLoader.
+type LoaderOption func(*Loader)
+
+// BatchWindow sets how long a Loader collects keys after the first one before
+// calling the batch function. Non-positive values are ignored.
+func BatchWindow(d time.Duration) LoaderOption {
+	return func(l *Loader) {
+		if d > 0 {
+			l.window = d
+		}
+	}
+}
+
+// MaxBatchSize dispatches a batch as soon as it holds n keys, without waiting
+// for the window to end. Zero or negative means no limit.
+func MaxBatchSize(n int) LoaderOption {
+	return func(l *Loader) {
+		l.maxBatch = n
+	}
+}
+
+// Loader coalesces Load calls for different keys into batched calls, like a
+// GraphQL dataloader. Cached keys are served by the memoizer; concurrent
+// Loads of the same key share one computation through singleflight; every
+// other miss that arrives within the batch window is handed to the batch
+// function in a single call.
+type Loader struct {
+	m        *Memoizer
+	batchFn  func(ctx context.Context, keys []string) (map[string]any, error)
+	window   time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	pending *loaderBatch // batch collecting keys, nil if none
+}
+
+// loaderBatch is a set of keys dispatched together. done is closed once
+// results and err are set.
+type loaderBatch struct {
+	ctx     context.Context
+	keys    []string
+	timer   *time.Timer
+	done    chan struct{}
+	results map[string]any
+	err     error
+}
+
+// NewLoader creates a Loader that caches through m and loads misses with
+// batch. batch runs with the context of the first caller in the batch,
+// detached from its cancellation, since the other callers still need the
+// results.
+//
+// Example:
+//
+//	users := m.NewLoader(func(ctx context.Context, keys []string) (map[string]any, error) {
+//	    return db.LoadUsersByKey(ctx, keys)
+//	}, memo.BatchWindow(2*time.Millisecond))
+//
+//	// In each resolver:
+//	u, err := users.Load(ctx, "user:"+id)
+func (m *Memoizer) NewLoader(batch func(ctx context.Context, keys []string) (map[string]any, error), opts ...LoaderOption) *Loader {
+	l := &Loader{
+		m:       m,
+		batchFn: batch,
+		window:  defaultBatchWindow,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Load returns the value for key, from the cache if possible and otherwise
+// from the next batch. If the batch function fails, its error is returned to
+// every caller in the batch; if it leaves key out, ErrNotLoaded is returned.
+func (l *Loader) Load(ctx context.Context, key string) (any, error) {
+	return l.m.Get(ctx, key, func() (any, error) {
+		b := l.add(ctx, key)
+		select {
+		case <-b.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if b.err != nil {
+			return nil, b.err
+		}
+		v, ok := b.results[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotLoaded, key)
+		}
+		return v, nil
+	})
+}
+
+// add puts key in the pending batch, starting a new one if needed, and
+// returns the batch it joined.
+func (l *Loader) add(ctx context.Context, key string) *loaderBatch {
+	l.mu.Lock()
+	b := l.pending
+	if b == nil {
+		b = &loaderBatch{ctx: context.WithoutCancel(ctx), done: make(chan struct{})}
+		b.timer = time.AfterFunc(l.window, func() { l.dispatch(b) })
+		l.pending = b
+	}
+	b.keys = append(b.keys, key)
+	full := l.maxBatch > 0 && len(b.keys) >= l.maxBatch
+	l.mu.Unlock()
+
+	if full {
+		go l.dispatch(b)
+	}
+	return b
+}
+
+// dispatch runs the batch function for b, unless b was already dispatched.
+// A panic in the batch function fails every key of the batch with a
+// *PanicError instead of crashing the timer goroutine.
+func (l *Loader) dispatch(b *loaderBatch) {
+	l.mu.Lock()
+	if l.pending != b {
+		l.mu.Unlock()
+		return
+	}
+	l.pending = nil
+	l.mu.Unlock()
+
+	b.timer.Stop()
+	ctx, cancel := l.m.withComputeTimeout(b.ctx)
+	defer func() {
+		if r := recover(); r != nil {
+			l.m.metrics.RecordPanic()
+			b.results, b.err = nil, &PanicError{Value: r, Stack: debug.Stack()}
+		}
+		cancel()
+		close(b.done)
+	}()
+	b.results, b.err = l.batchFn(ctx, b.keys)
+}
@@ -3,7 +3,14 @@ package memo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/invalidation"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -11,10 +18,28 @@ import (
 // It provides thread-safe memoization with automatic deduplication of concurrent calls
 // for the same key, preventing redundant computations.
 type Memoizer struct {
-	backend backends.Backend // cache storage backend
-	opts    Options          // configuration options
-	group   *SingleFlight    // singleflight group for deduplication
-	metrics *Metrics         // metrics collector
+	backend backends.Backend              // cache storage backend
+	store2  backends.BackendV2            // context-aware view of the backend used for reads and writes
+	caps    any                           // value checked for optional backend capabilities
+	opts    Options                       // configuration options
+	group   *SingleFlight                 // singleflight group for deduplication
+	metrics *Metrics                      // metrics collector
+	async   *asyncWriter                  // background writer; nil unless AsyncSet is enabled
+	costs   sync.Map                      // key -> *atomic.Int64 nanoseconds of the last compute, when tracked
+	keyTTLs sync.Map                      // key -> time.Duration overriding opts.TTL
+	stale   *trackedMap[any]              // key -> value kept to serve when a recompute fails
+	errs    *trackedMap[error]            // key -> error kept for negative caching
+	tenants sync.Map                      // tenant id -> *tenantState
+	loads   *loadLimiter                  // bounds concurrent compute functions; nil if unlimited
+	hot     *topKeys                      // tracks the most requested keys; nil unless enabled
+	rates   *trackedMap[*recomputeWindow] // key -> recent computations, when recomputes are rate limited
+	rnd     *rand.Rand                    // random source from options; nil uses the global one
+	rndMu   sync.Mutex                    // protects rnd
+	busID   string                        // identifies this memoizer's messages on the invalidation bus
+	busSub  io.Closer                     // invalidation bus subscription; nil without a bus
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // Validate checks if the Options are properly configured.
@@ -26,28 +51,101 @@ func (o *Options) Validate() error {
 	if o.TTL <= 0 {
 		return errors.New("TTL must be positive")
 	}
+	if o.EarlyExpiryBeta < 0 {
+		return errors.New("early expiry beta cannot be negative")
+	}
+	if o.MetricsWindow < 0 {
+		return errors.New("metrics window cannot be negative")
+	}
+	if o.MaxTrackedKeys < 0 {
+		return errors.New("max tracked keys cannot be negative")
+	}
+	if o.AsyncSet && o.AsyncSetQueueSize <= 0 {
+		return errors.New("async set queue size must be positive")
+	}
 	return nil
 }
 
 // New creates a new Memoizer instance with the provided options.
 // It configures the memoizer with a backend and optional settings.
 // If no backend is provided via options, it defaults to an in-memory backend.
+//
+// New panics if the options are invalid. Use NewWithError when options come
+// from runtime configuration.
 func New(opts ...Option) *Memoizer {
+	m, err := NewWithError(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// NewWithError is like New but returns an error instead of panicking when
+// the options fail validation.
+func NewWithError(opts ...Option) (*Memoizer, error) {
 	cfg := DefaultOptions()
 	for _, opt := range opts {
 		opt(cfg)
 	}
 
 	if err := cfg.Validate(); err != nil {
-		panic(err)
+		return nil, fmt.Errorf("invalid memo options: %w", err)
+	}
+
+	metrics := NewMetrics(cfg.MetricsEnabled)
+	if cfg.ShardedLatency {
+		metrics = NewShardedMetrics(cfg.MetricsEnabled)
+	}
+	if cfg.MetricsEnabled {
+		metrics.window = newRollingWindow(cfg.MetricsWindow)
 	}
 
-	return &Memoizer{
+	m := &Memoizer{
 		backend: cfg.Backend,
 		opts:    *cfg,
-		group:   NewSingleFlight(),
-		metrics: NewMetrics(cfg.MetricsEnabled),
+		group:   NewSingleFlight(WaitTimeout(cfg.SingleFlightWaitTimeout), MaxWaiters(cfg.SingleFlightMaxWaiters), withMetrics(metrics)),
+		metrics: metrics,
+		caps:    cfg.Backend,
+		loads:   newLoadLimiter(cfg.MaxConcurrentLoads, cfg.MaxQueuedLoads),
+		hot:     newTopKeys(cfg.TopKeys),
+		stale:   newTrackedMap[any](cfg.MaxTrackedKeys),
+		errs:    newTrackedMap[error](cfg.MaxTrackedKeys),
+		rates:   newTrackedMap[*recomputeWindow](0),
+	}
+	if cfg.BackendV2 != nil {
+		m.store2 = cfg.BackendV2
+		m.caps = cfg.BackendV2
+	} else {
+		m.store2 = backends.ToV2(cfg.Backend)
+	}
+	if en, ok := m.caps.(backends.EvictionNotifier); ok {
+		en.OnEvict(func(bkey string) {
+			if key, ok := strings.CutPrefix(bkey, cfg.KeyPrefix); ok {
+				m.costs.Delete(key)
+			}
+			metrics.RecordEviction()
+		})
+	}
+	if cn, ok := m.caps.(backends.CorruptionNotifier); ok && cfg.MetricsEnabled {
+		cn.OnCorrupt(func(string) { metrics.RecordCorruptEntry() })
+	}
+	if cfg.RandSource != nil {
+		m.rnd = rand.New(cfg.RandSource)
 	}
+	if cfg.AsyncSet && !cfg.ReadOnly {
+		m.async = newAsyncWriter(func(key string, value any, ttl time.Duration, tags []string) {
+			m.write(context.Background(), key, value, ttl, tags)
+		}, metrics, cfg.AsyncSetQueueSize)
+	}
+	if cfg.InvalidationBus != nil {
+		if err := m.subscribe(); err != nil {
+			if m.async != nil {
+				m.async.close()
+			}
+			return nil, err
+		}
+	}
+	return m, nil
 }
 
 // Get retrieves a cached value or computes and stores it if missing.
@@ -56,6 +154,9 @@ func New(opts ...Option) *Memoizer {
 // The fn parameter should be a function that returns (any, error).
 // If multiple goroutines call Get with the same key simultaneously,
 // only one will execute fn while others wait for the result.
+// If fn panics, the panic is recovered and every caller, including the one
+// that ran fn, returns a *PanicError. Panics are never retried, served stale
+// or negatively cached, and are counted in Metrics.Panics.
 //
 // Example:
 //
@@ -65,54 +166,457 @@ func New(opts ...Option) *Memoizer {
 //	    return expensiveOperation()
 //	})
 func (m *Memoizer) Get(ctx context.Context, key string, fn func() (any, error)) (any, error) {
-	// 1. Attempt to get from cache
-	if val, ok := m.backend.Get(key); ok {
-		m.metrics.RecordHit()
-		return val, nil
+	v, _, err := m.get(ctx, key, func() (any, CacheControl, error) {
+		v, err := fn()
+		return v, CacheControl{}, err
+	}, false)
+	return v, err
+}
+
+// get implements Get and its variants. When refresh is set the cached value
+// is ignored and fn is always run (or joined, if already in flight). hit
+// reports whether the result came from the cache rather than a computation.
+func (m *Memoizer) get(ctx context.Context, key string, fn func() (any, CacheControl, error), refresh bool) (v any, hit bool, err error) {
+	// 1. Attempt to get from cache. A failed read under BackendFailOpen is
+	// computed without using the backend any further.
+	var degraded bool
+	if !refresh {
+		readStart := m.metrics.now()
+		val, ok, early, err := m.read(ctx, key)
+		if err := m.readFailure(err); err != nil {
+			return nil, false, err
+		}
+		degraded = err != nil
+		if ok && !early {
+			m.metrics.RecordHit()
+			m.touch(ctx, key, val)
+			m.metrics.RecordHitLatency(m.metrics.since(readStart))
+			m.hot.record(key, true)
+			return val, true, nil
+		}
+		if early {
+			m.metrics.RecordEarlyRefresh()
+			refresh = true
+		} else if err, ok := m.lookupError(key); ok {
+			m.metrics.RecordNegativeHit()
+			m.hot.record(key, true)
+			return nil, true, err
+		}
 	}
 
 	m.metrics.RecordMiss()
 	start := time.Now()
 
-	// 2. Prevent duplicate calls via singleflight
-	v, err, _ := m.group.Do(ctx, key, func(ctx2 context.Context) (any, error) {
-		// Check cache again after acquiring lock (race condition guard)
-		if val, ok := m.backend.Get(key); ok {
-			m.metrics.RecordHit()
-			return val, nil
+	// 2. Prevent duplicate calls via singleflight, unless this key is known to
+	// compute faster than the coordination would cost
+	if m.bypassSingleFlight(key) {
+		v, err = m.compute(ctx, key, fn, !degraded)
+	} else {
+		do := m.group.Do
+		if m.opts.ComputeTimeout > 0 {
+			do = m.group.doDetached
 		}
-
-		result, err := fn()
-		if err != nil {
-			return nil, err
+		v, err, _ = do(ctx, key, func(ctx2 context.Context) (any, error) {
+			// Check cache again after acquiring lock (race condition guard),
+			// unless the entry is being refreshed deliberately
+			if !refresh && !degraded {
+				if val, ok := m.lookup(ctx2, key); ok {
+					m.metrics.RecordHit()
+					hit = true
+					return val, nil
+				}
+			}
+			return m.compute(ctx2, key, fn, !degraded)
+		})
+		if errors.Is(err, ErrWaitTimeout) || errors.Is(err, ErrTooManyWaiters) {
+			m.metrics.RecordWaitRejection()
+			if stale, ok := m.staleValue(key); ok {
+				m.metrics.RecordStaleServed()
+				v, err = stale, nil
+			}
 		}
-
-		// Store computed value
-		m.backend.Set(key, result, m.opts.TTL)
-		return result, nil
-	})
+	}
 
 	elapsed := time.Since(start)
 	m.metrics.RecordLatency(elapsed)
+	m.hot.record(key, hit)
 
-	return v, err
+	return v, hit, err
+}
+
+// Set stores value under key without calling a compute function, replacing
+// any cached value. The TTL is chosen as for computed values: a SetKeyTTL
+// override, then TTLFunc, then the memoizer's TTL.
+//
+// Use Set for write-through caching, when the fresh value is already at hand
+// after a mutation:
+//
+//	if err := db.SaveUser(ctx, u); err == nil {
+//	    m.Set(ctx, "user:"+u.ID, u)
+//	}
+func (m *Memoizer) Set(ctx context.Context, key string, value any) {
+	if m.opts.AutoGobRegister {
+		registerGobType(value)
+	}
+	m.put(ctx, key, value, m.ttlFor(key, value), CacheControl{})
 }
 
 // Delete removes an entry from cache.
 // It removes the value associated with the given key from the backend.
 func (m *Memoizer) Delete(key string) {
-	m.backend.Delete(key)
+	m.deleteKey(key)
+	m.publish(invalidation.Key, key)
+}
+
+// deleteKey implements Delete without publishing to the invalidation bus.
+func (m *Memoizer) deleteKey(key string) {
+	if m.async != nil {
+		m.async.forget(key)
+	}
+	m.forget(key)
+	if m.opts.ReadOnly {
+		return
+	}
+	if err := m.store2.Delete(context.Background(), m.backendKey(key)); err != nil {
+		m.backendError("delete", key, err)
+	}
+}
+
+// DeleteByPrefix removes every cached entry whose key starts with prefix and
+// returns how many the backend removed. It lets callers that key entries as
+// "user:{id}:{field}" drop everything for a user without tracking the keys.
+// It requires a backend implementing backends.PrefixDeleter and returns an
+// error wrapping errors.ErrUnsupported otherwise.
+func (m *Memoizer) DeleteByPrefix(prefix string) (int, error) {
+	n, err := m.deletePrefix(prefix)
+	if err == nil {
+		m.publish(invalidation.Prefix, prefix)
+	}
+	return n, err
+}
+
+// deletePrefix implements DeleteByPrefix without publishing to the
+// invalidation bus.
+func (m *Memoizer) deletePrefix(prefix string) (int, error) {
+	if m.opts.ReadOnly {
+		return 0, fmt.Errorf("delete by prefix: %w", ErrReadOnly)
+	}
+	pd, ok := m.caps.(backends.PrefixDeleter)
+	if !ok {
+		return 0, fmt.Errorf("delete by prefix: %w", errors.ErrUnsupported)
+	}
+	if m.async != nil {
+		m.async.forgetPrefix(prefix)
+	}
+	m.forgetPrefix(prefix)
+	return pd.DeleteByPrefix(m.backendKey(prefix)), nil
+}
+
+// forget drops the state kept in the memoizer for key alongside its cached
+// value: the stale copy, any negatively cached error and its compute cost.
+func (m *Memoizer) forget(key string) {
+	m.stale.delete(key)
+	m.errs.delete(key)
+	m.costs.Delete(key)
+}
+
+// forgetPrefix is forget for every key starting with prefix.
+func (m *Memoizer) forgetPrefix(prefix string) {
+	m.stale.deletePrefix(prefix)
+	m.errs.deletePrefix(prefix)
+	deleteByPrefix(&m.costs, prefix)
+}
+
+// forgetAll is forget for every key.
+func (m *Memoizer) forgetAll() {
+	m.stale.clear()
+	m.errs.clear()
+	m.costs.Clear()
+}
+
+// deleteByPrefix removes the string keys starting with prefix from sm.
+func deleteByPrefix(sm *sync.Map, prefix string) {
+	sm.Range(func(k, _ any) bool {
+		if strings.HasPrefix(k.(string), prefix) {
+			sm.Delete(k)
+		}
+		return true
+	})
 }
 
 // Clear purges all entries from the backend.
 // It removes all cached values, effectively resetting the cache to empty state.
+// With a key prefix set, only keys under the prefix are removed. That requires
+// a backend implementing backends.PrefixDeleter: other backends are shared
+// with memoizers using other prefixes, so they are left alone and the failure
+// is reported to WithBackendErrorHandler with errors.ErrUnsupported.
 func (m *Memoizer) Clear() {
-	m.backend.Clear()
+	m.clear()
+	m.publish(invalidation.All, "")
+}
+
+// clear implements Clear without publishing to the invalidation bus.
+func (m *Memoizer) clear() {
+	if m.async != nil {
+		m.async.clear()
+	}
+	m.forgetAll()
+	m.resetTenants()
+	if m.opts.ReadOnly {
+		return
+	}
+	if m.opts.KeyPrefix != "" {
+		if pd, ok := m.caps.(backends.PrefixDeleter); ok {
+			pd.DeleteByPrefix(m.opts.KeyPrefix)
+		} else {
+			m.backendError("clear", "", fmt.Errorf("clear key prefix %q: %w", m.opts.KeyPrefix, errors.ErrUnsupported))
+		}
+		return
+	}
+	if err := m.store2.Clear(context.Background()); err != nil {
+		m.backendError("clear", "", err)
+	}
+}
+
+// backendKey returns the key under which key is stored in the backend.
+func (m *Memoizer) backendKey(key string) string {
+	return m.opts.KeyPrefix + key
+}
+
+// backendKeys applies backendKey to every key.
+func (m *Memoizer) backendKeys(keys []string) []string {
+	if m.opts.KeyPrefix == "" {
+		return keys
+	}
+	out := make([]string, len(keys))
+	for i, key := range keys {
+		out[i] = m.backendKey(key)
+	}
+	return out
+}
+
+// Flush blocks until all pending background writes have reached the backend,
+// or until ctx is done, in which case ctx.Err() is returned. It is a no-op
+// when no background writes are enabled.
+//
+// Call Flush during shutdown, before closing the backend, so that values
+// computed just before shutdown are not lost:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	if err := m.Flush(ctx); err != nil {
+//	    log.Printf("cache flush: %v", err)
+//	}
+//	// then call m.Close to close the backend
+func (m *Memoizer) Flush(ctx context.Context) error {
+	var errs []error
+	if m.async != nil {
+		if err := m.async.flush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("async set: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close shuts the memoizer down: it waits for pending background writes to
+// reach the backend, stops the background writer and closes the backend if
+// it implements backends.Closer. Close is safe to call more than once; later
+// calls return the result of the first. The memoizer must not be used after
+// Close.
+//
+// Close waits for pending writes without a deadline. Call Flush with a
+// context first to bound the wait:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	_ = m.Flush(ctx)
+//	if err := m.Close(); err != nil {
+//	    log.Printf("cache close: %v", err)
+//	}
+func (m *Memoizer) Close() error {
+	m.closeOnce.Do(func() {
+		var errs []error
+		if m.busSub != nil {
+			if err := m.busSub.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close invalidation bus subscription: %w", err))
+			}
+		}
+		if err := m.Flush(context.Background()); err != nil {
+			errs = append(errs, err)
+		}
+		if m.async != nil {
+			m.async.close()
+		}
+		if c, ok := m.caps.(backends.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close backend: %w", err))
+			}
+		}
+		m.closeErr = errors.Join(errs...)
+	})
+	return m.closeErr
 }
 
 // Metrics returns the metrics collector for this memoizer.
 // The returned metrics contain statistics about cache hit/miss ratios,
 // request counts, and performance metrics if metrics collection is enabled.
+// For backends that report their size it also refreshes Entries and
+// BytesInUse.
 func (m *Memoizer) Metrics() *Metrics {
+	if !m.metrics.Enabled {
+		return m.metrics
+	}
+	st, ok := m.stats()
+	if ok {
+		m.metrics.SetEntries(st.Entries)
+	}
+	if st.Bytes >= 0 {
+		m.metrics.SetBytesInUse(st.Bytes)
+	}
 	return m.metrics
 }
+
+// compute runs fn and, if store is set, stores its result as directed by
+// the returned CacheControl. When compute costs are tracked, the duration of
+// fn is recorded for the key.
+func (m *Memoizer) compute(ctx context.Context, key string, fn func() (any, CacheControl, error), store bool) (any, error) {
+	if last, hasLast, ok := m.allowRecompute(key); !ok {
+		m.metrics.RecordRateLimited()
+		if hasLast {
+			return last, nil
+		}
+		if stale, ok := m.staleValue(key); ok {
+			m.metrics.RecordStaleServed()
+			return stale, nil
+		}
+		return nil, ErrRateLimited
+	}
+
+	var start time.Time
+	if m.trackCosts() {
+		start = time.Now()
+	}
+
+	computeStart := m.metrics.now()
+	result, control, err := m.retry(ctx, m.withTimeout(ctx, m.limited(m.recovered(fn))))
+	m.metrics.RecordComputeLatency(m.metrics.since(computeStart))
+	if m.trackCosts() {
+		m.recordCost(key, time.Since(start))
+	}
+	if err != nil {
+		return m.failed(key, err)
+	}
+	m.keepLast(key, result)
+	if !store {
+		return result, nil
+	}
+
+	if control.NoStore {
+		m.stale.delete(key)
+		return result, nil
+	}
+
+	if m.opts.AutoGobRegister {
+		registerGobType(result)
+	}
+
+	// Store computed value
+	ttl := control.TTL
+	if ttl <= 0 {
+		ttl = m.ttlFor(key, result)
+	}
+	m.put(ctx, key, result, ttl, control)
+	return result, nil
+}
+
+// put stores a fresh value for key with the tags and stale handling from
+// control, and updates the state kept alongside it: the stale copy and any
+// negatively cached error.
+func (m *Memoizer) put(ctx context.Context, key string, value any, ttl time.Duration, control CacheControl) {
+	m.store(ctx, key, value, ttl, control.Tags)
+	m.keepStale(key, value, ttl, control.StaleOK)
+	if m.opts.ErrorTTL > 0 {
+		m.errs.delete(key)
+	}
+}
+
+// bypassSingleFlight reports whether key's last compute was cheaper than
+// the adaptive threshold, in which case deduplication is not worth it.
+func (m *Memoizer) bypassSingleFlight(key string) bool {
+	if m.opts.AdaptiveThreshold <= 0 {
+		return false
+	}
+	cost, ok := m.costs.Load(key)
+	return ok && time.Duration(cost.(*atomic.Int64).Load()) < m.opts.AdaptiveThreshold
+}
+
+// recordCost remembers how long the last compute for key took.
+func (m *Memoizer) recordCost(key string, d time.Duration) {
+	slot, ok := m.costs.Load(key)
+	if !ok {
+		slot, _ = m.costs.LoadOrStore(key, new(atomic.Int64))
+	}
+	slot.(*atomic.Int64).Store(int64(d))
+}
+
+// lookup reads key from the backend, falling back to values computed but not
+// yet written by the async writer. Backend errors are counted and treated as
+// misses.
+func (m *Memoizer) lookup(ctx context.Context, key string) (any, bool) {
+	val, ok, _ := m.lookupErr(ctx, key)
+	return val, ok
+}
+
+// lookupErr is like lookup but also returns the error of a failed backend
+// read, which is otherwise treated as a miss. Corrupt entries are misses,
+// not errors.
+func (m *Memoizer) lookupErr(ctx context.Context, key string) (any, bool, error) {
+	start := m.metrics.now()
+	val, ok, err := m.store2.Get(ctx, m.backendKey(key))
+	m.metrics.RecordBackendGetLatency(m.metrics.since(start))
+	if errors.Is(err, backends.ErrCorruptEntry) {
+		err = nil
+	} else if err != nil {
+		m.backendError("get", key, err)
+	} else if ok {
+		return val, true, nil
+	}
+	if m.async != nil {
+		if val, ok := m.async.get(key); ok {
+			return val, true, nil
+		}
+	}
+	return nil, false, err
+}
+
+// store writes a computed value, either directly or through the async writer.
+func (m *Memoizer) store(ctx context.Context, key string, value any, ttl time.Duration, tags []string) {
+	if m.async != nil {
+		m.async.enqueue(key, value, ttl, tags)
+		return
+	}
+	m.write(ctx, key, value, ttl, tags)
+}
+
+// write sets key in the backend and tags it, counting failures. Unless
+// CacheOnCancel is set, a done ctx lets context-aware backends skip the write.
+func (m *Memoizer) write(ctx context.Context, key string, value any, ttl time.Duration, tags []string) {
+	if m.opts.ReadOnly {
+		return
+	}
+	if m.opts.CacheOnCancel {
+		ctx = context.WithoutCancel(ctx)
+	}
+	start := m.metrics.now()
+	err := m.store2.Set(ctx, m.backendKey(key), value, ttl)
+	m.metrics.RecordBackendSetLatency(m.metrics.since(start))
+	if err != nil {
+		m.backendError("set", key, err)
+		return
+	}
+	if len(tags) > 0 {
+		if t, ok := m.caps.(backends.Tagger); ok {
+			t.SetTags(m.backendKey(key), m.backendKeys(tags))
+		}
+	}
+}
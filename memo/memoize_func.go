@@ -4,6 +4,7 @@ package memo
 import (
 	"context"
 	"fmt"
+	"reflect"
 )
 
 // MemoizeFunc wraps a function with memoization capabilities.
@@ -25,17 +26,7 @@ import (
 //	result, err := memoized(ctx, 42) // Second call returns cached value
 func (m *Memoizer) MemoizeFunc(fn func(ctx context.Context, args ...any) (any, error)) func(context.Context, ...any) (any, error) {
 	return func(ctx context.Context, args ...any) (any, error) {
-		// Generate a key based on function and arguments
-		key := "memoized_func_" // This could be more sophisticated to include args
-
-		// If we have a key function defined in options, use it
-		if m.opts.KeyFunc != nil {
-			key = m.opts.KeyFunc(args...)
-		} else {
-			// Default key generation - convert args to string representation
-			// For now, using a simple approach - in production we'd hash the args
-			key += fmt.Sprintf("%v", args)
-		}
+		key := m.argsKey(args...)
 
 		// Use the existing Get method which handles singleflight and caching
 		result, err := m.Get(ctx, key, func() (any, error) {
@@ -45,3 +36,37 @@ func (m *Memoizer) MemoizeFunc(fn func(ctx context.Context, args ...any) (any, e
 		return result, err
 	}
 }
+
+// argsKey derives the cache key for a set of function arguments.
+// Arguments are first passed through the Canonicalizer, if any, so that
+// value-equal inputs collapse to the same key, and then to KeyFunc.
+func (m *Memoizer) argsKey(args ...any) string {
+	if m.opts.Canonicalizer != nil {
+		args = m.opts.Canonicalizer(args...)
+	}
+	if m.opts.PointerIdentityKeys {
+		args = pointerIdentities(args)
+	}
+
+	// If we have a key function defined in options, use it
+	if m.opts.KeyFunc != nil {
+		return m.opts.KeyFunc(args...)
+	}
+
+	// Default key generation - convert args to string representation
+	return "memoized_func_" + fmt.Sprintf("%v", args)
+}
+
+// pointerIdentities replaces every non-nil pointer argument with a token
+// identifying the pointer itself (type and address) rather than the value it
+// points to. Other arguments are left untouched.
+func pointerIdentities(args []any) []any {
+	out := make([]any, len(args))
+	for i, a := range args {
+		out[i] = a
+		if v := reflect.ValueOf(a); v.Kind() == reflect.Pointer && !v.IsNil() {
+			out[i] = fmt.Sprintf("%T@%#x", a, v.Pointer())
+		}
+	}
+	return out
+}
@@ -2,6 +2,9 @@
 package memo
 
 import (
+	"math"
+	"math/rand/v2"
+	"runtime"
 	"sync/atomic"
 	"time"
 )
@@ -19,12 +22,81 @@ type Metrics struct {
 	// Misses counts the number of cache misses.
 	Misses uint64
 
-	// Evictions counts the number of entries removed due to expiration or cleanup.
+	// Evictions counts entries the backend evicted to make room, as reported
+	// through backends.EvictionNotifier.
 	Evictions uint64
 
 	// Requests counts the total number of cache requests (hits + misses).
 	Requests uint64
 
+	// EarlyRefreshes counts hits that were recomputed ahead of expiry.
+	EarlyRefreshes uint64
+
+	// BytesInUse is the backend's estimated memory use, refreshed by
+	// Memoizer.Metrics for backends implementing backends.SizeReporter.
+	BytesInUse int64
+
+	// Entries is the backend's number of entries, refreshed by
+	// Memoizer.Metrics for backends implementing backends.StatsBackend or
+	// backends.LenReporter.
+	Entries int64
+
+	// NegativeHits counts Gets answered with a cached error.
+	NegativeHits uint64
+
+	// StaleServed counts failed recomputes answered with a stale value.
+	StaleServed uint64
+
+	// AsyncSetDrops counts async backend writes dropped because the queue was full.
+	AsyncSetDrops uint64
+
+	// BackendErrors counts failed backend calls. Only backends implementing
+	// backends.BackendV2 report errors.
+	BackendErrors uint64
+
+	// CorruptEntries counts entries that failed verification when read back,
+	// as reported through backends.CorruptionNotifier. They are served as misses.
+	CorruptEntries uint64
+
+	// WaitRejections counts duplicate Gets that stopped waiting for an
+	// in-flight computation because of the single-flight wait timeout or
+	// waiter limit.
+	WaitRejections uint64
+
+	// Deduplicated counts Gets that joined another caller's in-flight
+	// computation of the same key instead of computing it again.
+	Deduplicated uint64
+
+	// InFlight is the number of keys being computed right now.
+	InFlight int64
+
+	// MaxWaiters is the largest number of callers seen waiting on a single
+	// in-flight computation.
+	MaxWaiters uint64
+
+	// LoadRejections counts misses that failed with ErrTooManyLoads because
+	// the limit on concurrent loads was reached and the queue was full.
+	LoadRejections uint64
+
+	// RateLimited counts misses whose recomputation was held back by the
+	// recompute rate limit.
+	RateLimited uint64
+
+	// DegradedReads counts Gets that computed their value without the cache
+	// because reading the backend failed under BackendFailOpen.
+	DegradedReads uint64
+
+	// FailedReads counts Gets that returned a backend error because
+	// reading the backend failed under BackendFailClosed.
+	FailedReads uint64
+
+	// Panics counts compute functions that panicked.
+	Panics uint64
+
+	// Retries counts compute functions called again after failing, as
+	// configured with WithRetry.
+	Retries uint64
+
 	// totalLatency is the sum of all recorded latencies (in microseconds).
 	totalLatency uint64
 	// countLatency is the number of latency samples recorded.
@@ -35,8 +107,67 @@ type Metrics struct {
 	maxLatency int64
 	// lastLatency is the duration of the last recorded computation (in microseconds).
 	lastLatency int64
+	// latencyBuckets counts latency samples per LatencyBuckets bound, with
+	// a final bucket for samples above the largest bound.
+	latencyBuckets [len(LatencyBuckets) + 1]uint64
+	// percentiles is a finer histogram of latency samples for Percentile.
+	percentiles [percentileBuckets]uint64
+
+	// Latencies of the parts of a Get, as opposed to the miss latency above.
+	hitLatency        latencyRecorder
+	backendGetLatency latencyRecorder
+	backendSetLatency latencyRecorder
+	computeLatency    latencyRecorder
+
+	// window counts recent requests for Window; nil unless configured with
+	// WithMetricsWindow.
+	window *rollingWindow
+
+	// shards holds striped latency accumulators when sharded recording is enabled.
+	// When nil, miss latencies are aggregated directly in the fields above.
+	shards []latencyShard
+}
+
+// LatencyBuckets are the upper bounds of the latency histogram kept by
+// Metrics. They span fast in-process computations to slow remote calls.
+var LatencyBuckets = [...]time.Duration{
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// latencyShard accumulates the miss latency samples of a subset of
+// recorders, mirroring the latency fields of Metrics. It is padded so that
+// neighbouring shards don't false-share.
+type latencyShard struct {
+	total       uint64
+	count       uint64
+	min         int64
+	max         int64
+	last        int64
+	lastAt      int64 // nanoseconds since shardEpoch at which last was recorded
+	buckets     [len(LatencyBuckets) + 1]uint64
+	percentiles [percentileBuckets]uint64
+	_           [64]byte
 }
 
+// shardEpoch is the origin of latencyShard.lastAt, read from the monotonic
+// clock so that shards can tell which one recorded the last sample.
+var shardEpoch = time.Now()
+
 // NewMetrics creates a new metrics collector.
 func NewMetrics(enabled bool) *Metrics {
 	m := &Metrics{Enabled: enabled}
@@ -46,6 +177,29 @@ func NewMetrics(enabled bool) *Metrics {
 	return m
 }
 
+// NewShardedMetrics creates a metrics collector that spreads miss latency
+// samples over shards, about one per CPU, each sample going to a shard picked
+// at random. The shards are merged when read, so RecordLatency scales with the
+// number of cores instead of contending on shared counters, at the cost of
+// more expensive reads. Min/Max/Avg/Last, the histogram and the percentiles
+// keep their semantics.
+func NewShardedMetrics(enabled bool) *Metrics {
+	m := NewMetrics(enabled)
+	if !enabled {
+		return m
+	}
+
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n <<= 1
+	}
+	m.shards = make([]latencyShard, n)
+	for i := range m.shards {
+		m.shards[i].min = math.MaxInt64
+	}
+	return m
+}
+
 // RecordHit increments hit counters.
 func (m *Metrics) RecordHit() {
 	if !m.Enabled {
@@ -53,6 +207,9 @@ func (m *Metrics) RecordHit() {
 	}
 	atomic.AddUint64(&m.Hits, 1)
 	atomic.AddUint64(&m.Requests, 1)
+	if m.window != nil {
+		m.window.recordHit()
+	}
 }
 
 // RecordMiss increments miss counters.
@@ -62,6 +219,9 @@ func (m *Metrics) RecordMiss() {
 	}
 	atomic.AddUint64(&m.Misses, 1)
 	atomic.AddUint64(&m.Requests, 1)
+	if m.window != nil {
+		m.window.recordMiss()
+	}
 }
 
 // RecordEviction increments eviction counter.
@@ -72,55 +232,370 @@ func (m *Metrics) RecordEviction() {
 	atomic.AddUint64(&m.Evictions, 1)
 }
 
-// RecordLatency tracks compute duration in microseconds.
+// RecordEarlyRefresh increments the early refresh counter.
+func (m *Metrics) RecordEarlyRefresh() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.EarlyRefreshes, 1)
+}
+
+// SetBytesInUse records the backend's current estimated memory use.
+func (m *Metrics) SetBytesInUse(n int64) {
+	if !m.Enabled {
+		return
+	}
+	atomic.StoreInt64(&m.BytesInUse, n)
+}
+
+// SetEntries records the backend's current number of entries.
+func (m *Metrics) SetEntries(n int64) {
+	if !m.Enabled {
+		return
+	}
+	atomic.StoreInt64(&m.Entries, n)
+}
+
+// RecordNegativeHit increments the negative hit counter.
+func (m *Metrics) RecordNegativeHit() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.NegativeHits, 1)
+}
+
+// RecordStaleServed increments the stale served counter.
+func (m *Metrics) RecordStaleServed() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.StaleServed, 1)
+}
+
+// RecordAsyncSetDrop increments the dropped async write counter.
+func (m *Metrics) RecordAsyncSetDrop() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.AsyncSetDrops, 1)
+}
+
+// RecordBackendError increments the failed backend call counter.
+func (m *Metrics) RecordBackendError() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.BackendErrors, 1)
+}
+
+// RecordCorruptEntry increments the corrupt entry counter.
+func (m *Metrics) RecordCorruptEntry() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.CorruptEntries, 1)
+}
+
+// RecordWaitRejection increments the single-flight wait rejection counter.
+func (m *Metrics) RecordWaitRejection() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.WaitRejections, 1)
+}
+
+// RecordDeduplicated increments the deduplicated call counter.
+func (m *Metrics) RecordDeduplicated() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.Deduplicated, 1)
+}
+
+// AddInFlight adjusts the in-flight computation gauge by delta.
+func (m *Metrics) AddInFlight(delta int64) {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddInt64(&m.InFlight, delta)
+}
+
+// RecordWaiters raises MaxWaiters to n if n is higher.
+func (m *Metrics) RecordWaiters(n uint64) {
+	if !m.Enabled {
+		return
+	}
+	for {
+		cur := atomic.LoadUint64(&m.MaxWaiters)
+		if n <= cur || atomic.CompareAndSwapUint64(&m.MaxWaiters, cur, n) {
+			return
+		}
+	}
+}
+
+// RecordRetry increments the retry counter.
+func (m *Metrics) RecordRetry() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.Retries, 1)
+}
+
+// RecordLoadRejection increments the rejected load counter.
+func (m *Metrics) RecordLoadRejection() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.LoadRejections, 1)
+}
+
+// RecordRateLimited increments the rate limited recompute counter.
+func (m *Metrics) RecordRateLimited() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.RateLimited, 1)
+}
+
+// RecordPanic increments the panicked compute function counter.
+func (m *Metrics) RecordPanic() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.Panics, 1)
+}
+
+// RecordFailOpen increments the counter of reads degraded to computes.
+func (m *Metrics) RecordFailOpen() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.DegradedReads, 1)
+}
+
+// RecordFailClosed increments the counter of reads failed with a backend error.
+func (m *Metrics) RecordFailClosed() {
+	if !m.Enabled {
+		return
+	}
+	atomic.AddUint64(&m.FailedReads, 1)
+}
+
+// RecordLatency tracks the duration of a cache miss, from the lookup through
+// the computation, in microseconds. See also RecordComputeLatency.
 func (m *Metrics) RecordLatency(duration time.Duration) {
 	if !m.Enabled {
 		return
 	}
 
 	microseconds := duration.Microseconds()
+	if m.window != nil {
+		m.window.recordLatency(microseconds)
+	}
+
+	if m.shards != nil {
+		s := &m.shards[rand.Uint32()&uint32(len(m.shards)-1)]
+		atomic.StoreInt64(&s.last, microseconds)
+		atomic.StoreInt64(&s.lastAt, int64(time.Since(shardEpoch)))
+		atomic.AddUint64(&s.buckets[latencyBucket(duration)], 1)
+		atomic.AddUint64(&s.percentiles[percentileBucket(microseconds)], 1)
+		recordLatency(&s.total, &s.count, &s.min, &s.max, microseconds)
+		return
+	}
 	atomic.StoreInt64(&m.lastLatency, microseconds)
-	atomic.AddUint64(&m.totalLatency, uint64(microseconds))
-	atomic.AddUint64(&m.countLatency, 1)
+	atomic.AddUint64(&m.latencyBuckets[latencyBucket(duration)], 1)
+	atomic.AddUint64(&m.percentiles[percentileBucket(microseconds)], 1)
+	recordLatency(&m.totalLatency, &m.countLatency, &m.minLatency, &m.maxLatency, microseconds)
+}
+
+// latencyBucket returns the index of the histogram bucket for d.
+func latencyBucket(d time.Duration) int {
+	for i, bound := range LatencyBuckets {
+		if d <= bound {
+			return i
+		}
+	}
+	return len(LatencyBuckets)
+}
+
+// recordLatency folds one sample into a set of latency accumulators.
+func recordLatency(total, count *uint64, lo, hi *int64, microseconds int64) {
+	atomic.AddUint64(total, uint64(microseconds))
+	atomic.AddUint64(count, 1)
 
 	for {
-		oldMin := atomic.LoadInt64(&m.minLatency)
+		oldMin := atomic.LoadInt64(lo)
 		if microseconds >= oldMin {
 			break
 		}
-		if atomic.CompareAndSwapInt64(&m.minLatency, oldMin, microseconds) {
+		if atomic.CompareAndSwapInt64(lo, oldMin, microseconds) {
 			break
 		}
 	}
 
 	for {
-		oldMax := atomic.LoadInt64(&m.maxLatency)
+		oldMax := atomic.LoadInt64(hi)
 		if microseconds <= oldMax {
 			break
 		}
-		if atomic.CompareAndSwapInt64(&m.maxLatency, oldMax, microseconds) {
+		if atomic.CompareAndSwapInt64(hi, oldMax, microseconds) {
 			break
 		}
 	}
 }
 
+// latencyTotals returns the merged latency accumulators.
+func (m *Metrics) latencyTotals() (total, count uint64, lo, hi int64) {
+	if m.shards == nil {
+		return atomic.LoadUint64(&m.totalLatency), atomic.LoadUint64(&m.countLatency),
+			atomic.LoadInt64(&m.minLatency), atomic.LoadInt64(&m.maxLatency)
+	}
+
+	lo = math.MaxInt64
+	for i := range m.shards {
+		s := &m.shards[i]
+		total += atomic.LoadUint64(&s.total)
+		count += atomic.LoadUint64(&s.count)
+		lo = min(lo, atomic.LoadInt64(&s.min))
+		hi = max(hi, atomic.LoadInt64(&s.max))
+	}
+	return total, count, lo, hi
+}
+
+// lastLatencySample returns the last recorded miss latency, in microseconds.
+// With shards, it is the last sample of the shard written to most recently.
+func (m *Metrics) lastLatencySample() int64 {
+	if m.shards == nil {
+		return atomic.LoadInt64(&m.lastLatency)
+	}
+
+	var last, lastAt int64
+	for i := range m.shards {
+		s := &m.shards[i]
+		if at := atomic.LoadInt64(&s.lastAt); at > lastAt {
+			last, lastAt = atomic.LoadInt64(&s.last), at
+		}
+	}
+	return last
+}
+
+// latencyHistograms copies the miss latency histograms into buckets and
+// percentiles, merging the shards if any.
+func (m *Metrics) latencyHistograms(buckets *[len(LatencyBuckets) + 1]uint64, percentiles *[percentileBuckets]uint64) {
+	if m.shards == nil {
+		for i := range buckets {
+			buckets[i] = atomic.LoadUint64(&m.latencyBuckets[i])
+		}
+		for i := range percentiles {
+			percentiles[i] = atomic.LoadUint64(&m.percentiles[i])
+		}
+		return
+	}
+
+	*buckets = [len(LatencyBuckets) + 1]uint64{}
+	*percentiles = [percentileBuckets]uint64{}
+	for i := range m.shards {
+		s := &m.shards[i]
+		for j := range buckets {
+			buckets[j] += atomic.LoadUint64(&s.buckets[j])
+		}
+		for j := range percentiles {
+			percentiles[j] += atomic.LoadUint64(&s.percentiles[j])
+		}
+	}
+}
+
 // Snapshot returns a copy of current metrics safely.
+// Sharded latency accumulators are merged into the copy.
 func (m *Metrics) Snapshot() Metrics {
+	total, count, lo, hi := m.latencyTotals()
 	dupe := Metrics{
-		Enabled:      m.Enabled,
-		Hits:         atomic.LoadUint64(&m.Hits),
-		Misses:       atomic.LoadUint64(&m.Misses),
-		Evictions:    atomic.LoadUint64(&m.Evictions),
-		Requests:     atomic.LoadUint64(&m.Requests),
-		totalLatency: atomic.LoadUint64(&m.totalLatency),
-		countLatency: atomic.LoadUint64(&m.countLatency),
-		minLatency:   atomic.LoadInt64(&m.minLatency),
-		maxLatency:   atomic.LoadInt64(&m.maxLatency),
-		lastLatency:  atomic.LoadInt64(&m.lastLatency),
+		Enabled:        m.Enabled,
+		Hits:           atomic.LoadUint64(&m.Hits),
+		Misses:         atomic.LoadUint64(&m.Misses),
+		Evictions:      atomic.LoadUint64(&m.Evictions),
+		Requests:       atomic.LoadUint64(&m.Requests),
+		EarlyRefreshes: atomic.LoadUint64(&m.EarlyRefreshes),
+		BytesInUse:     atomic.LoadInt64(&m.BytesInUse),
+		Entries:        atomic.LoadInt64(&m.Entries),
+		NegativeHits:   atomic.LoadUint64(&m.NegativeHits),
+		StaleServed:    atomic.LoadUint64(&m.StaleServed),
+		AsyncSetDrops:  atomic.LoadUint64(&m.AsyncSetDrops),
+		BackendErrors:  atomic.LoadUint64(&m.BackendErrors),
+		CorruptEntries: atomic.LoadUint64(&m.CorruptEntries),
+		WaitRejections: atomic.LoadUint64(&m.WaitRejections),
+		Deduplicated:   atomic.LoadUint64(&m.Deduplicated),
+		InFlight:       atomic.LoadInt64(&m.InFlight),
+		MaxWaiters:     atomic.LoadUint64(&m.MaxWaiters),
+		Retries:        atomic.LoadUint64(&m.Retries),
+		LoadRejections: atomic.LoadUint64(&m.LoadRejections),
+		RateLimited:    atomic.LoadUint64(&m.RateLimited),
+		Panics:         atomic.LoadUint64(&m.Panics),
+		DegradedReads:  atomic.LoadUint64(&m.DegradedReads),
+		FailedReads:    atomic.LoadUint64(&m.FailedReads),
+		totalLatency:   total,
+		countLatency:   count,
+		minLatency:     lo,
+		maxLatency:     hi,
+		lastLatency:    m.lastLatencySample(),
 	}
+	m.latencyHistograms(&dupe.latencyBuckets, &dupe.percentiles)
+	dupe.hitLatency.copyFrom(&m.hitLatency)
+	dupe.backendGetLatency.copyFrom(&m.backendGetLatency)
+	dupe.backendSetLatency.copyFrom(&m.backendSetLatency)
+	dupe.computeLatency.copyFrom(&m.computeLatency)
 	return dupe
 }
 
+// Reset zeroes every counter and latency statistic, so that they describe
+// behavior since the reset rather than since the process started. The live
+// gauges InFlight, Entries and BytesInUse are kept. Counters are cleared one
+// at a time, so samples recorded during a Reset may be partially kept.
+// Exporters such as the Prometheus collector see the counters restart from
+// zero, which Prometheus treats as a counter reset.
+func (m *Metrics) Reset() {
+	for _, counter := range []*uint64{
+		&m.Hits, &m.Misses, &m.Evictions, &m.Requests, &m.EarlyRefreshes,
+		&m.NegativeHits, &m.StaleServed, &m.AsyncSetDrops, &m.BackendErrors,
+		&m.CorruptEntries, &m.WaitRejections, &m.Deduplicated, &m.MaxWaiters,
+		&m.LoadRejections, &m.RateLimited, &m.DegradedReads, &m.FailedReads,
+		&m.Panics, &m.Retries, &m.totalLatency, &m.countLatency,
+	} {
+		atomic.StoreUint64(counter, 0)
+	}
+	atomic.StoreInt64(&m.minLatency, math.MaxInt64)
+	atomic.StoreInt64(&m.maxLatency, 0)
+	atomic.StoreInt64(&m.lastLatency, 0)
+	for i := range m.latencyBuckets {
+		atomic.StoreUint64(&m.latencyBuckets[i], 0)
+	}
+	for i := range m.percentiles {
+		atomic.StoreUint64(&m.percentiles[i], 0)
+	}
+	for i := range m.shards {
+		s := &m.shards[i]
+		atomic.StoreUint64(&s.total, 0)
+		atomic.StoreUint64(&s.count, 0)
+		atomic.StoreInt64(&s.min, math.MaxInt64)
+		atomic.StoreInt64(&s.max, 0)
+		atomic.StoreInt64(&s.last, 0)
+		atomic.StoreInt64(&s.lastAt, 0)
+		for j := range s.buckets {
+			atomic.StoreUint64(&s.buckets[j], 0)
+		}
+		for j := range s.percentiles {
+			atomic.StoreUint64(&s.percentiles[j], 0)
+		}
+	}
+	m.hitLatency.reset()
+	m.backendGetLatency.reset()
+	m.backendSetLatency.reset()
+	m.computeLatency.reset()
+	if m.window != nil {
+		m.window.reset()
+	}
+}
+
 // HitRatio returns cache efficiency (hits / total).
 func (m *Metrics) HitRatio() float64 {
 	if !m.Enabled {
@@ -136,17 +611,16 @@ func (m *Metrics) HitRatio() float64 {
 
 // AvgLatency returns the average latency (microseconds).
 func (m *Metrics) AvgLatency() float64 {
-	count := atomic.LoadUint64(&m.countLatency)
+	total, count, _, _ := m.latencyTotals()
 	if count == 0 {
 		return 0.0
 	}
-	total := atomic.LoadUint64(&m.totalLatency)
 	return float64(total) / float64(count)
 }
 
 // MinLatency returns minimum observed latency.
 func (m *Metrics) MinLatency() time.Duration {
-	microseconds := atomic.LoadInt64(&m.minLatency)
+	_, _, microseconds, _ := m.latencyTotals()
 	if microseconds < 0 {
 		return 0
 	}
@@ -155,10 +629,27 @@ func (m *Metrics) MinLatency() time.Duration {
 
 // MaxLatency returns maximum observed latency.
 func (m *Metrics) MaxLatency() time.Duration {
-	return time.Duration(atomic.LoadInt64(&m.maxLatency)) * time.Microsecond
+	_, _, _, microseconds := m.latencyTotals()
+	return time.Duration(microseconds) * time.Microsecond
 }
 
 // LastLatency returns the duration of the last recorded computation.
 func (m *Metrics) LastLatency() time.Duration {
-	return time.Duration(atomic.LoadInt64(&m.lastLatency)) * time.Microsecond
+	return time.Duration(m.lastLatencySample()) * time.Microsecond
+}
+
+// LatencyHistogram returns the number of latency samples in each bucket of
+// LatencyBuckets, followed by the number of samples above the largest
+// bound. Counts are per bucket, not cumulative.
+func (m *Metrics) LatencyHistogram() []uint64 {
+	var buckets [len(LatencyBuckets) + 1]uint64
+	var percentiles [percentileBuckets]uint64
+	m.latencyHistograms(&buckets, &percentiles)
+	return buckets[:]
+}
+
+// TotalLatency returns the sum of all recorded latencies.
+func (m *Metrics) TotalLatency() time.Duration {
+	total, _, _, _ := m.latencyTotals()
+	return time.Duration(total) * time.Microsecond
 }
@@ -0,0 +1,29 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import "time"
+
+// errorTTLJitter is the largest fraction by which a negative cache entry's
+// TTL is shortened, so that keys failing together do not all retry at once.
+const errorTTLJitter = 0.25
+
+// cacheError remembers err for key if negative caching is enabled and the
+// error is selected by the CacheableError predicate.
+func (m *Memoizer) cacheError(key string, err error) {
+	if m.opts.ErrorTTL <= 0 {
+		return
+	}
+	if m.opts.CacheableError != nil && !m.opts.CacheableError(err) {
+		return
+	}
+	ttl := m.opts.ErrorTTL - time.Duration(m.randFloat64()*errorTTLJitter*float64(m.opts.ErrorTTL))
+	m.errs.store(key, err, time.Now().Add(ttl))
+}
+
+// lookupError returns the cached error for key, if any has not yet expired.
+func (m *Memoizer) lookupError(key string) (error, bool) {
+	if m.opts.ErrorTTL <= 0 {
+		return nil, false
+	}
+	return m.errs.load(key)
+}
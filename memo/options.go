@@ -2,10 +2,12 @@ package memo
 
 import (
 	"github.com/ldaidone/gomemo/internals/hashutil"
+	"math/rand/v2"
 	"time"
 
 	"github.com/ldaidone/gomemo/pkg/backends"
 	"github.com/ldaidone/gomemo/pkg/backends/memory"
+	"github.com/ldaidone/gomemo/pkg/invalidation"
 )
 
 // ----------------------------------------------------------------------------
@@ -20,10 +22,23 @@ type Options struct {
 	// Values will be automatically removed from cache after this duration.
 	TTL time.Duration
 
+	// TTLFunc, if set, picks the TTL per stored value, e.g. shorter for empty
+	// results. A zero or negative result falls back to TTL.
+	TTLFunc func(key string, value any) time.Duration
+
 	// KeyFunc is an optional function that generates cache keys from function arguments.
 	// If nil, the default key generation will be used.
 	KeyFunc func(args ...any) string
 
+	// Canonicalizer is an optional function that rewrites function arguments into
+	// a canonical form before KeyFunc runs, so that logically identical inputs
+	// (e.g. differently ordered sets) map to the same cache key.
+	Canonicalizer func(args ...any) []any
+
+	// PointerIdentityKeys makes MemoizeFunc key pointer arguments by their address
+	// instead of the value they point to, giving per-instance caching.
+	PointerIdentityKeys bool
+
 	// CacheOnCancel determines whether to cache results when the context is cancelled.
 	// If true, cancelled requests may still update the cache.
 	CacheOnCancel bool
@@ -36,9 +51,149 @@ type Options struct {
 	// If nil, the default memory backend will be used.
 	Backend backends.Backend
 
+	// KeyPrefix is prepended to every key the memoizer stores in the backend,
+	// so that several memoizers can share one backend without collisions.
+	KeyPrefix string
+
+	// TenantQuota is the quota new tenants start with. See Memoizer.Tenant.
+	TenantQuota TenantQuota
+
+	// BackendErrorPolicy decides whether Get computes or fails when a
+	// backend read fails. The default is BackendErrorAsMiss.
+	BackendErrorPolicy BackendErrorPolicy
+
+	// OnBackendError, if set, is called with every failed backend call, after
+	// it is counted in Metrics.BackendErrors. op names the operation, e.g.
+	// "get", "set" or "delete"; key is empty for Clear.
+	OnBackendError func(op, key string, err error)
+
+	// BackendV2, if set, is used for reads and writes instead of Backend,
+	// with the caller's context and with errors counted in Metrics.
+	// WithBackendV2 sets Backend to an adapter of it.
+	BackendV2 backends.BackendV2
+
 	// MetricsEnabled enables or disables performance metrics collection.
 	// When enabled, cache hit/miss ratios and other statistics will be tracked.
 	MetricsEnabled bool
+
+	// SingleFlightWaitTimeout caps how long a Get waits for the same key's
+	// in-flight computation before giving up. Zero waits as long as the
+	// caller's context allows.
+	SingleFlightWaitTimeout time.Duration
+
+	// SingleFlightMaxWaiters caps how many Gets can wait for one key's
+	// in-flight computation. Zero is unlimited.
+	SingleFlightMaxWaiters int
+
+	// AdaptiveThreshold enables adaptive singleflight: keys whose last compute
+	// took less than this are computed directly without deduplication.
+	// Zero disables the bypass.
+	AdaptiveThreshold time.Duration
+
+	// EarlyExpiryBeta enables probabilistic early expiration (XFetch) when positive.
+	// Larger values refresh entries earlier. Requires a backend implementing
+	// backends.EntryBackend.
+	EarlyExpiryBeta float64
+
+	// RandSource is the random source used for probabilistic decisions.
+	// If nil, the process-wide generator from math/rand/v2 is used.
+	RandSource rand.Source
+
+	// AsyncSet writes computed values to the backend from a background goroutine
+	// so Get can return as soon as the value is computed.
+	AsyncSet bool
+
+	// AsyncSetQueueSize bounds the number of pending async writes.
+	// Writes beyond this are dropped and counted in Metrics.AsyncSetDrops.
+	AsyncSetQueueSize int
+
+	// AutoGobRegister registers the concrete type of every computed value with
+	// encoding/gob, so values keep decoding when switching to a serializing backend.
+	AutoGobRegister bool
+
+	// MetricsWindow, if positive, additionally tracks hits, misses and miss
+	// latency over a rolling window of this length, reported by
+	// Metrics.Window.
+	MetricsWindow time.Duration
+
+	// ShardedLatency spreads miss latency recording over shards, about one
+	// per CPU, to reduce contention when many goroutines record concurrently.
+	ShardedLatency bool
+
+	// SlidingTTL extends an entry's expiry by its TTL on every hit.
+	// Requires a backend implementing backends.Toucher.
+	SlidingTTL bool
+
+	// StaleIfError keeps each stored value around for this long after it
+	// expires, and serves it if recomputing the key fails. Zero disables it.
+	StaleIfError time.Duration
+
+	// ErrorTTL caches errors returned by fn for this long, so a failing
+	// upstream is not called on every Get. Zero disables negative caching.
+	ErrorTTL time.Duration
+
+	// CacheableError selects which errors are cached when ErrorTTL is set.
+	// If nil, all errors are cached.
+	CacheableError func(err error) bool
+
+	// MaxTrackedKeys bounds how many keys the memoizer keeps stale copies
+	// for under StaleIfError, and how many errors it keeps under ErrorTTL.
+	// Zero disables the bound.
+	MaxTrackedKeys int
+
+	// ComputeTimeout bounds how long a compute function may run, regardless
+	// of the callers' contexts. Zero means no limit.
+	ComputeTimeout time.Duration
+
+	// MaxConcurrentLoads bounds how many compute functions run at once
+	// across all keys. Zero or negative means no limit.
+	MaxConcurrentLoads int
+
+	// MaxQueuedLoads bounds how many misses wait for a free load slot when
+	// MaxConcurrentLoads is reached. Zero lets any number wait, as long as
+	// their contexts allow; negative makes misses fail fast instead.
+	MaxQueuedLoads int
+
+	// RecomputeLimit is how many times a key may be computed within
+	// RecomputeInterval. Zero or negative means no limit.
+	RecomputeLimit int
+
+	// RecomputeInterval is the sliding window RecomputeLimit applies to.
+	RecomputeInterval time.Duration
+
+	// ServeLastWhenRateLimited serves a key's last computed value while its
+	// recomputation is rate limited, instead of ErrRateLimited.
+	ServeLastWhenRateLimited bool
+
+	// ErrorPolicy classifies compute function errors to decide which are
+	// retried, served stale and negatively cached. If nil, all of them are.
+	ErrorPolicy ErrorPolicy
+
+	// TopKeys is the number of hottest keys tracked for Memoizer.TopKeys.
+	// Zero disables tracking.
+	TopKeys int
+
+	// RetryAttempts is how many times fn is called for a key before its
+	// error is returned. Values below 2 disable retries.
+	RetryAttempts int
+
+	// RetryBackoff is the delay before the first retry. It doubles with
+	// every further retry and is jittered.
+	RetryBackoff time.Duration
+
+	// InvalidationBus, if set, carries invalidations between memoizers:
+	// Delete, DeleteByPrefix, InvalidateTag, Clear and Namespace.Invalidate
+	// are published to it, and invalidations received from it are applied.
+	InvalidationBus invalidation.Bus
+
+	// ReadOnly makes the memoizer read the backend without ever writing to
+	// it: computed values are returned but not stored, and invalidations
+	// leave the backend alone.
+	ReadOnly bool
+
+	// MaxReaderSize caps how many bytes MemoizeReader buffers from a stream.
+	// Larger streams are rejected and not cached. Zero or negative disables the limit.
+	MaxReaderSize int64
 }
 
 // Option is a function that modifies Options.
@@ -47,12 +202,16 @@ type Option func(*Options)
 // DefaultOptions returns a sane default configuration.
 func DefaultOptions() *Options {
 	return &Options{
-		TTL:             time.Hour,
-		KeyFunc:         hashutil.HashArgs,
-		CacheOnCancel:   false,
-		CleanupInterval: time.Hour,
-		Backend:         memory.New(),
-		MetricsEnabled:  false,
+		TTL:               time.Hour,
+		KeyFunc:           hashutil.HashArgs,
+		CacheOnCancel:     false,
+		CleanupInterval:   time.Hour,
+		Backend:           memory.New(),
+		MetricsEnabled:    false,
+		MaxReaderSize:     32 << 20,
+		AutoGobRegister:   true,
+		AsyncSetQueueSize: 1024,
+		MaxTrackedKeys:    10000,
 	}
 }
 
@@ -68,6 +227,24 @@ func WithTTL(ttl time.Duration) Option {
 	}
 }
 
+// WithTTLFunc sets a policy choosing the TTL from the key and the computed
+// value, for data whose volatility varies:
+//
+//	memo.WithTTLFunc(func(key string, value any) time.Duration {
+//	    if items, ok := value.([]Item); ok && len(items) == 0 {
+//	        return 10 * time.Second
+//	    }
+//	    return 10 * time.Minute
+//	})
+//
+// A zero or negative result falls back to the memoizer's TTL. Per-key
+// overrides from SetKeyTTL take precedence.
+func WithTTLFunc(fn func(key string, value any) time.Duration) Option {
+	return func(o *Options) {
+		o.TTLFunc = fn
+	}
+}
+
 // WithKeyFunc sets a custom function for generating cache keys from function arguments.
 // This allows fine-grained control over key generation for memoized functions.
 func WithKeyFunc(fn func(args ...any) string) Option {
@@ -76,11 +253,83 @@ func WithKeyFunc(fn func(args ...any) string) Option {
 	}
 }
 
+// WithCanonicalizer sets a function that normalizes arguments before key generation.
+// It runs ahead of KeyFunc, letting callers sort or otherwise normalize inputs
+// so that value-equal arguments share one cache entry.
+func WithCanonicalizer(fn func(args ...any) []any) Option {
+	return func(o *Options) {
+		o.Canonicalizer = fn
+	}
+}
+
+// WithPointerIdentityKeys makes MemoizeFunc key pointer arguments by identity.
+// By default HashArgs keys a pointer by the gob encoding of the value it
+// points to, so two pointers to equal values share an entry while a mutated
+// value gets a new one. With identity keys every distinct pointer gets its own entry regardless of its
+// contents. Addresses are only meaningful within a single process and may be
+// reused once an object is garbage collected, so this mode is best suited to
+// in-memory backends and long-lived objects.
+func WithPointerIdentityKeys(enabled bool) Option {
+	return func(o *Options) {
+		o.PointerIdentityKeys = enabled
+	}
+}
+
 // WithBackend sets the storage backend for the cache.
 // Different backends provide different storage characteristics (in-memory, Redis, etc.).
 func WithBackend(b backends.Backend) Option {
 	return func(o *Options) {
 		o.Backend = b
+		o.BackendV2 = nil
+	}
+}
+
+// WithBackendV2 sets a context-aware storage backend. The memoizer passes the
+// caller's context to it, so backend calls honor request deadlines, and
+// counts the errors it returns in Metrics.BackendErrors. A failed read is
+// treated as a miss and a failed write leaves the value uncached.
+// Optional capabilities such as backends.Toucher or backends.Closer are
+// detected on b itself.
+func WithBackendV2(b backends.BackendV2) Option {
+	return func(o *Options) {
+		o.BackendV2 = b
+		o.Backend = nil
+		if b != nil {
+			o.Backend = backends.FromV2(b)
+		}
+	}
+}
+
+// WithTenantQuota sets the quota every tenant returned by Memoizer.Tenant
+// starts with. Tenant.SetQuota overrides it for a single tenant.
+func WithTenantQuota(q TenantQuota) Option {
+	return func(o *Options) {
+		o.TenantQuota = q
+	}
+}
+
+// WithBackendErrorPolicy sets how Get reacts to failed backend reads.
+func WithBackendErrorPolicy(p BackendErrorPolicy) Option {
+	return func(o *Options) {
+		o.BackendErrorPolicy = p
+	}
+}
+
+// WithBackendErrorHandler calls fn with every failed backend call, e.g. to
+// log or trace it.
+func WithBackendErrorHandler(fn func(op, key string, err error)) Option {
+	return func(o *Options) {
+		o.OnBackendError = fn
+	}
+}
+
+// WithKeyPrefix prepends prefix to every key the memoizer stores in the
+// backend. Memoizers with different prefixes can share a backend, e.g. one
+// Redis database, without key collisions, and Clear only removes their own
+// keys. Callers keep using unprefixed keys.
+func WithKeyPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.KeyPrefix = prefix
 	}
 }
 
@@ -107,3 +356,304 @@ func WithMetrics(enabled bool) Option {
 		o.MetricsEnabled = enabled
 	}
 }
+
+// WithAdaptiveSingleFlight skips singleflight deduplication for keys whose
+// last observed compute time is below threshold. For trivial computations the
+// map lock and waiter coordination cost more than an occasional duplicate
+// compute. Keys are always deduplicated until their first compute has been
+// timed. A zero threshold disables the bypass.
+func WithAdaptiveSingleFlight(threshold time.Duration) Option {
+	return func(o *Options) {
+		o.AdaptiveThreshold = threshold
+	}
+}
+
+// WithSingleFlightWaitTimeout makes a Get that finds its key already being
+// computed wait at most d for the result. When the wait times out, the Get
+// returns the key's stale value if stale-if-error has one, and
+// ErrWaitTimeout otherwise. The computation itself keeps running.
+func WithSingleFlightWaitTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.SingleFlightWaitTimeout = d
+	}
+}
+
+// WithSingleFlightMaxWaiters caps how many Gets can wait for the same
+// key's in-flight computation, so one slow loader cannot tie up an
+// unbounded number of goroutines. Further Gets return the key's stale
+// value if stale-if-error has one, and ErrTooManyWaiters otherwise.
+func WithSingleFlightMaxWaiters(n int) Option {
+	return func(o *Options) {
+		o.SingleFlightMaxWaiters = n
+	}
+}
+
+// WithProbabilisticEarlyExpiry enables XFetch-style cache stampede protection.
+// On every hit the memoizer may decide to recompute the entry before it
+// expires, with a probability that grows as expiry approaches and with how
+// long the key took to compute last time. beta scales the eagerness; 1.0 is
+// the usual choice. Only backends implementing backends.EntryBackend expose
+// the expiry needed for this; other backends are unaffected.
+func WithProbabilisticEarlyExpiry(beta float64) Option {
+	return func(o *Options) {
+		o.EarlyExpiryBeta = beta
+	}
+}
+
+// WithEarlyRecompute is an alias for WithProbabilisticEarlyExpiry.
+func WithEarlyRecompute(beta float64) Option {
+	return WithProbabilisticEarlyExpiry(beta)
+}
+
+// WithRandSource sets the random source used for probabilistic decisions
+// such as early expiration and negative cache jitter. It is mostly useful to make tests deterministic.
+func WithRandSource(src rand.Source) Option {
+	return func(o *Options) {
+		o.RandSource = src
+	}
+}
+
+// WithAsyncSet makes Get return computed values immediately while the backend
+// write happens in the background. Until the write lands, the value is served
+// from an in-process pending set, so later calls still see it and do not
+// recompute.
+func WithAsyncSet(enabled bool) Option {
+	return func(o *Options) {
+		o.AsyncSet = enabled
+	}
+}
+
+// WithAsyncSetQueueSize bounds how many async writes may be pending at once.
+// When the queue is full, new writes are dropped and counted in metrics.
+func WithAsyncSetQueueSize(n int) Option {
+	return func(o *Options) {
+		o.AsyncSetQueueSize = n
+	}
+}
+
+// WithAutoGobRegister controls whether computed value types are registered with
+// encoding/gob automatically. It is enabled by default; disable it for
+// applications that manage gob registration themselves.
+func WithAutoGobRegister(enabled bool) Option {
+	return func(o *Options) {
+		o.AutoGobRegister = enabled
+	}
+}
+
+// WithMetricsWindow tracks hit ratio and miss latency over a rolling window
+// of duration d, such as the last five minutes, in addition to the totals
+// since start. Read it with Metrics.Window. Requires WithMetrics(true).
+func WithMetricsWindow(d time.Duration) Option {
+	return func(o *Options) {
+		o.MetricsWindow = d
+	}
+}
+
+// WithShardedLatency enables sharded latency aggregation for the memoizer's metrics.
+// Recording then scales with the number of cores; see NewShardedMetrics.
+func WithShardedLatency(enabled bool) Option {
+	return func(o *Options) {
+		o.ShardedLatency = enabled
+	}
+}
+
+// WithMaxReaderSize sets the maximum number of bytes MemoizeReader will buffer.
+// Streams larger than this are rejected with ErrReaderTooLarge; zero disables the limit.
+func WithMaxReaderSize(n int64) Option {
+	return func(o *Options) {
+		o.MaxReaderSize = n
+	}
+}
+
+// WithStaleIfError serves the last good value when recomputing a key fails,
+// as long as the value expired less than window ago. The memoizer keeps its
+// own reference to each stored value for that purpose, in process and apart
+// from the backend, for at most WithMaxTrackedKeys keys; past the bound, a
+// key's stale copy may be dropped and a failed recompute then returns its
+// error.
+func WithStaleIfError(window time.Duration) Option {
+	return func(o *Options) {
+		o.StaleIfError = window
+	}
+}
+
+// WithMaxTrackedKeys sets how many keys the memoizer keeps stale copies for
+// (see WithStaleIfError), and separately how many keys it keeps cached
+// errors for (see WithErrorTTL). When a bound is reached, entries that have
+// expired are pruned first, then arbitrary ones are dropped. The default is
+// 10000; zero disables the bound.
+func WithMaxTrackedKeys(n int) Option {
+	return func(o *Options) {
+		o.MaxTrackedKeys = n
+	}
+}
+
+// WithErrorTTL enables negative caching: an error returned by fn is
+// remembered for ttl and returned to further callers of the key without
+// calling fn again. Errors are kept in the memoizer, not in the backend,
+// for at most WithMaxTrackedKeys keys.
+// Each error's TTL is shortened by a random amount of up to a quarter, so
+// that keys failing together during an outage are not all retried at the
+// same moment. Use WithCacheableError to restrict which errors are cached.
+// Context errors and ErrTooManyLoads are never cached.
+func WithErrorTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.ErrorTTL = ttl
+	}
+}
+
+// WithCacheableError sets the predicate selecting which errors are cached
+// when negative caching is enabled with WithErrorTTL, e.g. to cache
+// "not found" but not timeouts.
+func WithCacheableError(pred func(err error) bool) Option {
+	return func(o *Options) {
+		o.CacheableError = pred
+	}
+}
+
+// WithComputeTimeout gives up on a compute function after d with
+// ErrComputeTimeout, which is then served stale or negatively cached as
+// configured. Compute functions take no context, so a function that times
+// out keeps running in the background and its result is discarded; for that
+// reason ErrComputeTimeout is not retried unless WithErrorPolicy classifies
+// it as ErrorRetryable. Context-aware loaders, such as those of
+// GetForInputs and NewLoader, get a context that ends after d instead.
+//
+// Setting a compute timeout also detaches computations from the caller that
+// starts them: a caller whose context ends early returns ctx.Err() while the
+// computation carries on, and its result is cached for the next caller.
+func WithComputeTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.ComputeTimeout = d
+	}
+}
+
+// WithMaxConcurrentLoads runs at most n compute functions at once across all
+// keys, to protect the upstream when most Gets miss, e.g. on a cold start.
+// Further misses wait for a free slot as long as their context allows; use
+// WithMaxQueuedLoads to bound how many wait. A miss that cannot be loaded
+// fails with ErrTooManyLoads, or gets the stale value if one is kept.
+// ErrTooManyLoads is never negatively cached.
+//
+// Each attempt made by WithRetry takes its own slot, and time spent waiting
+// for a slot counts against WithComputeTimeout. Each key passed to a
+// Loader's Load holds a slot until its batch completes, while the loaders
+// of GetForInputs and GetMany take one slot per call.
+func WithMaxConcurrentLoads(n int) Option {
+	return func(o *Options) {
+		o.MaxConcurrentLoads = n
+	}
+}
+
+// WithMaxQueuedLoads sets how many misses may wait for a load slot when the
+// WithMaxConcurrentLoads limit is reached; later misses fail fast with
+// ErrTooManyLoads. A negative n makes every miss beyond the limit fail fast.
+func WithMaxQueuedLoads(n int) Option {
+	return func(o *Options) {
+		o.MaxQueuedLoads = n
+	}
+}
+
+// WithRecomputeRateLimit computes each key at most n times per interval,
+// however often it is invalidated or expires, to protect the upstream from
+// invalidation storms caused by chatty writers. A miss beyond the limit gets
+// the stale value if one is kept, and ErrRateLimited otherwise; see
+// WithServeLastWhenRateLimited. ErrRateLimited is never negatively cached.
+//
+// The memoizer keeps a small record of recent computations for every key
+// it computes, which is not dropped when the key is invalidated.
+func WithRecomputeRateLimit(n int, interval time.Duration) Option {
+	return func(o *Options) {
+		o.RecomputeLimit = n
+		o.RecomputeInterval = interval
+	}
+}
+
+// WithServeLastWhenRateLimited serves a key's last computed value, even if
+// it has been invalidated since, while WithRecomputeRateLimit holds back its
+// recomputation. The memoizer then keeps a reference to the last value of
+// every key it computes.
+func WithServeLastWhenRateLimited(enabled bool) Option {
+	return func(o *Options) {
+		o.ServeLastWhenRateLimited = enabled
+	}
+}
+
+// WithErrorPolicy classifies the errors returned by compute functions, so
+// that upstreams with mixed failure modes are handled per error: retryable
+// errors are retried and answered with the stale value, cacheable errors
+// are negatively cached, and fatal errors are returned as they are. Without
+// a policy every error is retried, answered with the stale value and
+// negatively cached, as configured. WithCacheableError still narrows down
+// which cacheable errors are cached.
+func WithErrorPolicy(p ErrorPolicy) Option {
+	return func(o *Options) {
+		o.ErrorPolicy = p
+	}
+}
+
+// WithTopKeys tracks the n most requested keys, with their hits and misses,
+// for Memoizer.TopKeys. Counts are estimated with the space-saving
+// algorithm in a table of n entries, so memory stays bounded however many
+// keys there are; a larger n makes the estimates of less popular keys more
+// accurate. Every Get then takes a short lock on the table.
+func WithTopKeys(n int) Option {
+	return func(o *Options) {
+		o.TopKeys = n
+	}
+}
+
+// WithRetry retries a failing fn up to attempts times in total before its
+// error is returned, served stale or negatively cached, so that transient
+// upstream failures do not reach every caller. The first retry waits about
+// backoff, and each further retry twice as long as the previous one, with
+// jitter. Retries stop early when the computing call's context is done.
+//
+// Duplicate callers wait for the retries like any other computation, so the
+// single-flight wait timeout should allow for them.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(o *Options) {
+		o.RetryAttempts = attempts
+		o.RetryBackoff = backoff
+	}
+}
+
+// WithSlidingTTL makes every cache hit push the entry's expiry forward by
+// the key's TTL, so frequently read entries stay cached. Backends that do not
+// implement backends.Toucher keep fixed expiries. The touch runs on the hit
+// path with the caller's context: on Redis it adds a read and a script call
+// to every hit.
+func WithSlidingTTL(enabled bool) Option {
+	return func(o *Options) {
+		o.SlidingTTL = enabled
+	}
+}
+
+// WithReadOnly makes the memoizer read from the backend but never write to
+// it, for canary processes and replicas that must not pollute a shared
+// cache. Misses are computed on every call and not stored; Delete, Clear
+// and Expire leave the backend alone, and DeleteByPrefix and InvalidateTag
+// return ErrReadOnly. Process-local state such as negative caching and
+// stale-if-error copies still works.
+func WithReadOnly(enabled bool) Option {
+	return func(o *Options) {
+		o.ReadOnly = enabled
+	}
+}
+
+// WithInvalidationBus connects the memoizer to an invalidation bus, so that
+// memoizers in other processes, each with its own local backend, drop the
+// entries this one invalidates, and so that producers outside the
+// application, such as CDC pipelines, can invalidate entries. The memoizer
+// subscribes when created and unsubscribes on Close. Failed publishes are
+// reported like backend errors, with op "publish".
+//
+// Example:
+//
+//	bus := redis.NewBus(client, "")
+//	m := memo.New(memo.WithBackend(memory.New()), memo.WithInvalidationBus(bus))
+func WithInvalidationBus(bus invalidation.Bus) Option {
+	return func(o *Options) {
+		o.InvalidationBus = bus
+	}
+}
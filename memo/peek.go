@@ -0,0 +1,38 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// Peek returns the cached value for key without side effects: no hit or miss
+// is recorded, the sliding TTL is not extended and, for backends implementing
+// backends.Peeker, eviction order and access counts are left alone. It is
+// meant for diagnostics and conditional logic that should not skew stats.
+func (m *Memoizer) Peek(key string) (any, bool) {
+	return m.peek(context.Background(), key)
+}
+
+// peek implements Peek, passing ctx to backends without a Peek method.
+func (m *Memoizer) peek(ctx context.Context, key string) (any, bool) {
+	if p, ok := m.caps.(backends.Peeker); ok {
+		if val, ok := p.Peek(m.backendKey(key)); ok {
+			return val, true
+		}
+	} else if val, ok, err := m.store2.Get(ctx, m.backendKey(key)); err == nil && ok {
+		return val, true
+	}
+	if m.async != nil {
+		return m.async.get(key)
+	}
+	return nil, false
+}
+
+// Has reports whether a value is cached for key, with the same lack of side
+// effects as Peek.
+func (m *Memoizer) Has(key string) bool {
+	_, ok := m.Peek(key)
+	return ok
+}
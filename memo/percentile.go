@@ -0,0 +1,90 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// The percentile histogram has log-linear buckets in the style of HDR
+// histograms: each power of two of the sample unit is split into
+// 1<<percentileSubBits equal buckets, so a percentile is reported within
+// 1/8 of its true value. Samples below 1<<percentileSubBits units get exact
+// buckets, and samples of 1<<percentileMaxExp units or more share the last
+// bucket; that is about 13 days in nanoseconds, the finest unit in use.
+const (
+	percentileSubBits = 3
+	percentileSubs    = 1 << percentileSubBits
+	percentileMaxExp  = 50
+	percentileBuckets = percentileSubs + (percentileMaxExp-percentileSubBits)*percentileSubs
+)
+
+// percentileBucket returns the percentile histogram bucket for a sample of
+// n units.
+func percentileBucket(n int64) int {
+	if n < percentileSubs {
+		return int(max(n, 0))
+	}
+	exp := bits.Len64(uint64(n)) - 1
+	if exp >= percentileMaxExp {
+		return percentileBuckets - 1
+	}
+	sub := int(n>>(exp-percentileSubBits)) & (percentileSubs - 1)
+	return percentileSubs + (exp-percentileSubBits)*percentileSubs + sub
+}
+
+// percentileUpper returns the largest sample, in units, that falls in
+// bucket i.
+func percentileUpper(i int) int64 {
+	if i < percentileSubs {
+		return int64(i)
+	}
+	exp := (i-percentileSubs)/percentileSubs + percentileSubBits
+	sub := int64((i - percentileSubs) % percentileSubs)
+	width := int64(1) << (exp - percentileSubBits)
+	return int64(1)<<exp + (sub+1)*width - 1
+}
+
+// Percentile returns the miss latency below which a fraction q of the
+// samples recorded with RecordLatency fall, e.g. 0.99 for the 99th
+// percentile. A miss is timed from the lookup through the computation and
+// the write, so this includes backend calls; ComputeLatency describes the
+// compute functions alone. The result is within 1/8 of the exact value, and
+// never above MaxLatency. It returns zero when no latency was recorded.
+//
+// Example:
+//
+//	s := m.Metrics().Snapshot()
+//	fmt.Printf("p50=%v p95=%v p99=%v\n", s.Percentile(0.5), s.Percentile(0.95), s.Percentile(0.99))
+func (m *Metrics) Percentile(q float64) time.Duration {
+	var buckets [len(LatencyBuckets) + 1]uint64
+	var percentiles [percentileBuckets]uint64
+	m.latencyHistograms(&buckets, &percentiles)
+	return percentile(&percentiles, time.Microsecond, q, m.MaxLatency())
+}
+
+// percentile returns the q-th percentile of the samples counted in
+// buckets, each sample a number of units, capped at hi.
+func percentile(buckets *[percentileBuckets]uint64, unit time.Duration, q float64, hi time.Duration) time.Duration {
+	var counts [percentileBuckets]uint64
+	var total uint64
+	for i := range buckets {
+		counts[i] = atomic.LoadUint64(&buckets[i])
+		total += counts[i]
+	}
+	if total == 0 {
+		return 0
+	}
+
+	q = min(max(q, 0), 1)
+	rank := max(uint64(q*float64(total)+0.5), 1)
+	var seen uint64
+	for i, n := range counts {
+		seen += n
+		if seen >= rank {
+			return min(time.Duration(percentileUpper(i))*unit, hi)
+		}
+	}
+	return hi
+}
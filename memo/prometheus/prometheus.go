@@ -0,0 +1,130 @@
+// Package prometheus exposes a memoizer's metrics as Prometheus collectors.
+//
+// Example:
+//
+//	m := memo.New(memo.WithMetrics(true))
+//	prometheus.MustRegister(memoprom.NewCollector(m, memoprom.WithName("users")))
+package prometheus
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+const (
+	// DefaultNamespace prefixes every metric name unless WithNamespace is used.
+	DefaultNamespace = "gomemo"
+
+	// NameLabel is the label carrying the memoizer name set with WithName.
+	NameLabel = "memoizer"
+)
+
+// Collector is a prom.Collector reading a memoizer's metrics on every
+// scrape. The memoizer must be created with memo.WithMetrics(true); without
+// it every value reads as zero.
+type Collector struct {
+	m *memo.Memoizer
+
+	namespace string
+	name      string
+
+	hits      *prom.Desc
+	misses    *prom.Desc
+	evictions *prom.Desc
+	requests  *prom.Desc
+	latency   *prom.Desc
+	entries   *prom.Desc
+	bytes     *prom.Desc
+}
+
+var _ prom.Collector = (*Collector)(nil)
+
+// Option configures a Collector.
+type Option func(*Collector)
+
+// WithName labels every metric with memoizer="name", so that several
+// memoizers can be registered into the same registry.
+func WithName(name string) Option {
+	return func(c *Collector) {
+		c.name = name
+	}
+}
+
+// WithNamespace replaces the "gomemo" prefix of the metric names.
+func WithNamespace(namespace string) Option {
+	return func(c *Collector) {
+		c.namespace = namespace
+	}
+}
+
+// NewCollector creates a collector for m's metrics:
+//
+//   - gomemo_hits_total, gomemo_misses_total, gomemo_evictions_total and
+//     gomemo_requests_total counters;
+//   - a gomemo_compute_duration_seconds histogram of compute latencies,
+//     with the bounds in memo.LatencyBuckets;
+//   - gomemo_entries and gomemo_bytes gauges, for backends that report
+//     their size through backends.StatsBackend, backends.LenReporter or
+//     backends.SizeReporter.
+func NewCollector(m *memo.Memoizer, opts ...Option) *Collector {
+	c := &Collector{m: m, namespace: DefaultNamespace}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	var labels prom.Labels
+	if c.name != "" {
+		labels = prom.Labels{NameLabel: c.name}
+	}
+	desc := func(name, help string) *prom.Desc {
+		return prom.NewDesc(prom.BuildFQName(c.namespace, "", name), help, nil, labels)
+	}
+	c.hits = desc("hits_total", "Number of cache hits.")
+	c.misses = desc("misses_total", "Number of cache misses.")
+	c.evictions = desc("evictions_total", "Number of entries evicted by the backend to make room.")
+	c.requests = desc("requests_total", "Number of cache requests, hits and misses.")
+	c.latency = desc("compute_duration_seconds", "Latency of cache misses, including the computation.")
+	c.entries = desc("entries", "Number of entries in the backend.")
+	c.bytes = desc("bytes", "Approximate number of bytes used by the backend's entries.")
+	return c
+}
+
+// Describe implements prom.Collector.
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.evictions
+	ch <- c.requests
+	ch <- c.latency
+	ch <- c.entries
+	ch <- c.bytes
+}
+
+// Collect implements prom.Collector.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	live := c.m.Metrics()
+	s := live.Snapshot()
+
+	ch <- prom.MustNewConstMetric(c.hits, prom.CounterValue, float64(s.Hits))
+	ch <- prom.MustNewConstMetric(c.misses, prom.CounterValue, float64(s.Misses))
+	ch <- prom.MustNewConstMetric(c.evictions, prom.CounterValue, float64(s.Evictions))
+	ch <- prom.MustNewConstMetric(c.requests, prom.CounterValue, float64(s.Requests))
+
+	counts := s.LatencyHistogram()
+	buckets := make(map[float64]uint64, len(memo.LatencyBuckets))
+	var cumulative uint64
+	for i, bound := range memo.LatencyBuckets {
+		cumulative += counts[i]
+		buckets[bound.Seconds()] = cumulative
+	}
+	cumulative += counts[len(counts)-1]
+	ch <- prom.MustNewConstHistogram(c.latency, cumulative, s.TotalLatency().Seconds(), buckets)
+
+	if n, ok := c.m.Len(); ok {
+		ch <- prom.MustNewConstMetric(c.entries, prom.GaugeValue, float64(n))
+	}
+	if n, ok := c.m.Bytes(); ok {
+		ch <- prom.MustNewConstMetric(c.bytes, prom.GaugeValue, float64(n))
+	}
+}
@@ -0,0 +1,64 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a key is due for recomputation but was
+// already recomputed as often as WithRecomputeRateLimit allows, and no
+// previous value is available to serve instead.
+var ErrRateLimited = errors.New("memo: recompute rate limit exceeded")
+
+// recomputeWindow tracks the recent computations of one key.
+type recomputeWindow struct {
+	mu      sync.Mutex
+	times   []time.Time // start of the most recent computations, oldest first
+	last    any         // last computed value, when serving it is enabled
+	hasLast bool
+}
+
+// newRecomputeWindow returns an empty recomputeWindow.
+func newRecomputeWindow() *recomputeWindow {
+	return &recomputeWindow{}
+}
+
+// allowRecompute reports whether key may be computed now under the
+// recompute rate limit, and records the computation if so. When it may not,
+// it also returns the key's last computed value, if one is kept.
+func (m *Memoizer) allowRecompute(key string) (last any, hasLast, ok bool) {
+	if m.opts.RecomputeLimit <= 0 {
+		return nil, false, true
+	}
+	// A window whose computations are all older than the interval no longer
+	// limits anything, so it is dropped once that much time has passed.
+	now := time.Now()
+	w := m.rates.loadOrStore(key, newRecomputeWindow, now.Add(m.opts.RecomputeInterval))
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.times) >= m.opts.RecomputeLimit {
+		if now.Sub(w.times[0]) < m.opts.RecomputeInterval {
+			return w.last, w.hasLast, false
+		}
+		w.times = w.times[1:]
+	}
+	w.times = append(w.times, now)
+	return nil, false, true
+}
+
+// keepLast remembers value as the last computed value for key, to be served
+// while its recomputation is rate limited.
+func (m *Memoizer) keepLast(key string, value any) {
+	if m.opts.RecomputeLimit <= 0 || !m.opts.ServeLastWhenRateLimited {
+		return
+	}
+	w, ok := m.rates.load(key)
+	if !ok {
+		return
+	}
+	w.mu.Lock()
+	w.last, w.hasLast = value, true
+	w.mu.Unlock()
+}
@@ -0,0 +1,66 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrReaderTooLarge is returned by MemoizeReader when a stream exceeds Options.MaxReaderSize.
+var ErrReaderTooLarge = errors.New("reader exceeds max buffer size")
+
+// MemoizeReader memoizes a function producing an io.Reader.
+// Readers can only be consumed once, so on a miss the stream is read fully
+// into memory and the bytes are cached; every call then returns a fresh,
+// independent reader over those bytes. If the reader also implements
+// io.Closer it is closed once drained.
+//
+// Streams larger than Options.MaxReaderSize are rejected with ErrReaderTooLarge
+// and are not cached.
+//
+// Example:
+//
+//	r, err := m.MemoizeReader(ctx, "report:2024", func() (io.Reader, error) {
+//	    return os.Open("report-2024.csv")
+//	})
+func (m *Memoizer) MemoizeReader(ctx context.Context, key string, fn func() (io.Reader, error)) (io.Reader, error) {
+	v, err := m.Get(ctx, key, func() (any, error) {
+		r, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := r.(io.Closer); ok {
+			defer c.Close()
+		}
+		return readAllLimited(r, m.opts.MaxReaderSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cached value for key %q is %T, not []byte", key, v)
+	}
+	return bytes.NewReader(data), nil
+}
+
+// readAllLimited reads r to EOF, failing if more than limit bytes are available.
+// A non-positive limit disables the check.
+func readAllLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrReaderTooLarge
+	}
+	return data, nil
+}
@@ -0,0 +1,17 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import "runtime/debug"
+
+// recovered wraps fn so that a panic in it is returned as a *PanicError.
+func (m *Memoizer) recovered(fn func() (any, CacheControl, error)) func() (any, CacheControl, error) {
+	return func() (v any, control CacheControl, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				m.metrics.RecordPanic()
+				v, control, err = nil, CacheControl{}, &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+		return fn()
+	}
+}
@@ -0,0 +1,51 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"time"
+)
+
+// maxRetryShift caps the exponent of the retry backoff so the delay cannot
+// overflow for large attempt counts.
+const maxRetryShift = 30
+
+// retry calls fn until it succeeds, fails with an error that is not
+// retryable, the configured number of attempts is used up or ctx is done,
+// sleeping with exponential backoff and jitter between attempts. It returns the result of the last call to fn.
+func (m *Memoizer) retry(ctx context.Context, fn func() (any, CacheControl, error)) (any, CacheControl, error) {
+	result, control, err := fn()
+	for attempt := 1; err != nil && m.retryable(err) && attempt < m.opts.RetryAttempts; attempt++ {
+		if !m.sleepBackoff(ctx, attempt) {
+			break
+		}
+		m.metrics.RecordRetry()
+		result, control, err = fn()
+	}
+	return result, control, err
+}
+
+// sleepBackoff waits before retry number attempt and reports whether the
+// retry should go ahead, i.e. ctx was not done in the meantime. The delay
+// doubles with every attempt, starting from RetryBackoff, and is drawn at
+// random from its upper half so that callers failing together do not retry
+// together.
+func (m *Memoizer) sleepBackoff(ctx context.Context, attempt int) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	d := m.opts.RetryBackoff << min(attempt-1, maxRetryShift)
+	if d <= 0 {
+		return true
+	}
+	d = d/2 + time.Duration(m.randFloat64()*float64(d/2))
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -3,28 +3,123 @@ package memo
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
 	"sync"
+	"time"
 )
 
+// ErrWaitTimeout is returned to a duplicate caller that gave up waiting for
+// an in-flight computation after the configured wait timeout.
+var ErrWaitTimeout = errors.New("memo: timed out waiting for in-flight computation")
+
+// ErrTooManyWaiters is returned to a duplicate caller turned away because
+// the configured number of callers were already waiting on the key.
+var ErrTooManyWaiters = errors.New("memo: too many callers waiting for in-flight computation")
+
+// errGoexit is returned to callers waiting on a computation that called
+// runtime.Goexit, e.g. through t.FailNow in a test.
+var errGoexit = errors.New("memo: computation called runtime.Goexit")
+
+// PanicError is returned to callers waiting on a computation that panicked.
+// In a SingleFlight, the goroutine that ran the computation panics again with
+// the same *PanicError, so a recover there sees it instead of the original
+// value. Memoizer.Get returns it to every caller instead.
+type PanicError struct {
+	Value any    // value passed to panic
+	Stack []byte // stack of the panicking goroutine
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("memo: computation panicked: %v\n\n%s", p.Value, p.Stack)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
 // SingleFlight ensures that only one execution is in-flight for a given key at a time.
 // It prevents duplicate work by having concurrent requests for the same key
 // wait for the result of the first request rather than executing multiple times.
 type SingleFlight struct {
-	mu sync.Mutex       // protects m
+	shards [sfShards]sfShard // calls in progress, striped by key hash
+
+	waitTimeout time.Duration // how long duplicates wait; 0 waits as long as ctx allows
+	maxWaiters  int           // duplicates allowed per call; 0 is unlimited
+	metrics     *Metrics      // receives deduplication stats; nil records none
+}
+
+// sfShards is the number of lock stripes in a SingleFlight. Calls for
+// different keys rarely share a stripe, so hot keys do not contend on one
+// lock.
+const sfShards = 32
+
+// sfShard is one stripe of a SingleFlight's call map.
+type sfShard struct {
+	mu sync.Mutex       // protects m and the waiter counts of its calls
 	m  map[string]*call // lazily initialized
 }
 
+// shard returns the stripe holding key, hashed with FNV-1a.
+func (g *SingleFlight) shard(key string) *sfShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &g.shards[h%sfShards]
+}
+
 // call represents a single call to the function with a specific key.
 type call struct {
-	wg  sync.WaitGroup // Used to wait for a singleflight call to complete
-	val any            // The result value
-	err error          // The error result
+	done    chan struct{} // Closed when the call completes
+	val     any           // The result value
+	err     error         // The error result
+	waiters int           // Duplicate callers currently waiting, guarded by the shard's mu
+}
+
+// SingleFlightOption configures a SingleFlight created with NewSingleFlight.
+type SingleFlightOption func(*SingleFlight)
+
+// WaitTimeout makes duplicate callers stop waiting for an in-flight call
+// after d and return ErrWaitTimeout. The call itself keeps running.
+func WaitTimeout(d time.Duration) SingleFlightOption {
+	return func(g *SingleFlight) {
+		if d > 0 {
+			g.waitTimeout = d
+		}
+	}
+}
+
+// MaxWaiters caps how many duplicate callers can wait on one in-flight call;
+// further callers return ErrTooManyWaiters immediately.
+func MaxWaiters(n int) SingleFlightOption {
+	return func(g *SingleFlight) {
+		if n > 0 {
+			g.maxWaiters = n
+		}
+	}
+}
+
+// withMetrics makes the group record deduplicated calls, in-flight keys and
+// waiter counts in m.
+func withMetrics(m *Metrics) SingleFlightOption {
+	return func(g *SingleFlight) {
+		g.metrics = m
+	}
 }
 
 // NewSingleFlight creates a new SingleFlight instance.
 // This is used internally by Memoizer to prevent duplicate executions.
-func NewSingleFlight() *SingleFlight {
-	return &SingleFlight{m: make(map[string]*call)}
+func NewSingleFlight(opts ...SingleFlightOption) *SingleFlight {
+	g := &SingleFlight{}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
 }
 
 // Do executes the function fn once for the given key and returns the result.
@@ -34,39 +129,164 @@ func NewSingleFlight() *SingleFlight {
 // The function takes a context for cancellation and timeout handling.
 // The bool return value indicates whether the function was executed (true) or
 // whether this was a duplicate request that waited for the original (false).
+//
+// If fn panics, callers waiting for it return a *PanicError and the panic is
+// propagated in the goroutine that ran fn only; later calls start afresh.
+//
+// Duplicate callers return ErrWaitTimeout or ErrTooManyWaiters when the
+// WaitTimeout or MaxWaiters limits are exceeded.
 func (g *SingleFlight) Do(ctx context.Context, key string, fn func(context.Context) (any, error)) (any, error, bool) {
-	g.mu.Lock()
-	if c, ok := g.m[key]; ok {
-		// There's already a call in progress for this key
-		g.mu.Unlock()
-		done := make(chan struct{})
-		go func() {
-			c.wg.Wait()
-			close(done)
-		}()
+	sh := g.shard(key)
+	sh.mu.Lock()
+	if c, ok := sh.m[key]; ok {
+		val, err := g.join(ctx, sh, c)
+		return val, err, false
+	}
+	c := g.start(sh, key)
+	if g.metrics != nil {
+		defer g.metrics.AddInFlight(-1)
+	}
+
+	g.doCall(ctx, sh, c, key, fn)
+	return c.val, c.err, true
+}
+
+// doDetached is like Do, except that a new call runs fn in its own goroutine
+// with ctx's values but not its cancellation. Every caller, including the
+// one that started the call, stops waiting when its ctx is done, while fn
+// runs to completion for the callers that remain and for the cache. If fn
+// panics, callers receive a *PanicError and the panic goes no further.
+func (g *SingleFlight) doDetached(ctx context.Context, key string, fn func(context.Context) (any, error)) (any, error, bool) {
+	sh := g.shard(key)
+	sh.mu.Lock()
+	if c, ok := sh.m[key]; ok {
+		val, err := g.join(ctx, sh, c)
+		return val, err, false
+	}
+	c := g.start(sh, key)
 
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err(), false
-		case <-done:
-			return c.val, c.err, false
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(*PanicError); !ok {
+					panic(r)
+				}
+			}
+		}()
+		if g.metrics != nil {
+			defer g.metrics.AddInFlight(-1)
 		}
+		g.doCall(context.WithoutCancel(ctx), sh, c, key, fn)
+	}()
+
+	val, err := c.wait(ctx, 0)
+	return val, err, true
+}
+
+// start registers a new call for key. It is called with sh.mu held and
+// releases it.
+func (g *SingleFlight) start(sh *sfShard, key string) *call {
+	c := &call{done: make(chan struct{})}
+	if sh.m == nil {
+		sh.m = make(map[string]*call)
+	}
+	sh.m[key] = c
+	sh.mu.Unlock()
+	if g.metrics != nil {
+		g.metrics.AddInFlight(1)
+	}
+	return c
+}
+
+// join waits on the call in progress c as a duplicate caller, subject to the
+// wait timeout and waiter limit. It is called with sh.mu held and releases
+// it.
+func (g *SingleFlight) join(ctx context.Context, sh *sfShard, c *call) (any, error) {
+	if g.maxWaiters > 0 && c.waiters >= g.maxWaiters {
+		sh.mu.Unlock()
+		return nil, ErrTooManyWaiters
+	}
+	c.waiters++
+	waiters := c.waiters
+	sh.mu.Unlock()
+	if g.metrics != nil {
+		g.metrics.RecordDeduplicated()
+		g.metrics.RecordWaiters(uint64(waiters))
 	}
 
-	// Start a new call for this key
-	c := new(call)
-	c.wg.Add(1)
-	g.m[key] = c
-	g.mu.Unlock()
+	val, err := c.wait(ctx, g.waitTimeout)
+
+	sh.mu.Lock()
+	c.waiters--
+	sh.mu.Unlock()
+	return val, err
+}
+
+// doCall runs fn for the call c and releases its waiters however fn ends.
+// If fn panics, waiters receive a *PanicError and the panic continues in
+// this goroutine with the same value; if fn calls runtime.Goexit, waiters
+// receive errGoexit.
+func (g *SingleFlight) doCall(ctx context.Context, sh *sfShard, c *call, key string, fn func(context.Context) (any, error)) {
+	normalReturn := false
+	defer func() {
+		var pe *PanicError
+		if !normalReturn {
+			if r := recover(); r != nil {
+				pe = &PanicError{Value: r, Stack: debug.Stack()}
+				c.val, c.err = nil, pe
+			} else {
+				c.val, c.err = nil, errGoexit
+			}
+		}
+		close(c.done)
+
+		// Clean up the call from the map
+		sh.mu.Lock()
+		delete(sh.m, key)
+		sh.mu.Unlock()
+
+		if pe != nil {
+			panic(pe)
+		}
+	}()
 
 	// Execute the function and store the result
 	c.val, c.err = fn(ctx)
-	c.wg.Done()
+	normalReturn = true
+}
 
-	// Clean up the call from the map
-	g.mu.Lock()
-	delete(g.m, key)
-	g.mu.Unlock()
+// Wait waits for the call in progress for key, if any, and returns its result
+// without ever starting a call itself. The bool return value reports whether a
+// call was in progress. If ctx is done first, ctx.Err() is returned.
+func (g *SingleFlight) Wait(ctx context.Context, key string) (any, error, bool) {
+	sh := g.shard(key)
+	sh.mu.Lock()
+	c, ok := sh.m[key]
+	sh.mu.Unlock()
+	if !ok {
+		return nil, nil, false
+	}
 
-	return c.val, c.err, true
+	val, err := c.wait(ctx, 0)
+	return val, err, true
+}
+
+// wait blocks until the call completes, ctx is done or, if timeout is
+// positive, timeout has passed.
+func (c *call) wait(ctx context.Context, timeout time.Duration) (any, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-expired:
+		return nil, ErrWaitTimeout
+	case <-c.done:
+		return c.val, c.err
+	}
 }
@@ -0,0 +1,23 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import "time"
+
+// keepStale remembers a freshly stored value for stale-if-error. Values
+// stored with CacheControl.StaleOK are kept until replaced or evicted; with
+// StaleIfError they are kept until the window after their expiry has passed.
+func (m *Memoizer) keepStale(key string, value any, ttl time.Duration, staleOK bool) {
+	switch {
+	case staleOK:
+		m.stale.store(key, value, time.Time{})
+	case m.opts.StaleIfError > 0:
+		m.stale.store(key, value, time.Now().Add(ttl+m.opts.StaleIfError))
+	default:
+		m.stale.delete(key)
+	}
+}
+
+// staleValue returns the stale value for key if it is still within its window.
+func (m *Memoizer) staleValue(key string) (any, bool) {
+	return m.stale.load(key)
+}
@@ -0,0 +1,64 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/invalidation"
+)
+
+// SetWithTags is like Set, and also attaches the entry to tags so that
+// InvalidateTag can remove it together with other entries carrying the same
+// tag. Tags require a backend implementing backends.Tagger and are ignored
+// otherwise. Computed results can be tagged with CallTags or
+// CacheControl.Tags.
+//
+// Example:
+//
+//	m.SetWithTags(ctx, "order:7", order, "user:42", "orders")
+//	// later, when user 42 changes:
+//	m.InvalidateTag("user:42")
+func (m *Memoizer) SetWithTags(ctx context.Context, key string, value any, tags ...string) {
+	if m.opts.AutoGobRegister {
+		registerGobType(value)
+	}
+	m.put(ctx, key, value, m.ttlFor(key, value), CacheControl{Tags: tags})
+}
+
+// InvalidateTag removes every cached entry carrying tag and returns how many
+// keys were tagged with it. It requires a backend implementing
+// backends.Tagger and returns an error wrapping errors.ErrUnsupported
+// otherwise.
+func (m *Memoizer) InvalidateTag(tag string) (int, error) {
+	n, err := m.invalidateTag(tag)
+	if err == nil {
+		m.publish(invalidation.Tag, tag)
+	}
+	return n, err
+}
+
+// invalidateTag implements InvalidateTag without publishing to the
+// invalidation bus.
+func (m *Memoizer) invalidateTag(tag string) (int, error) {
+	if m.opts.ReadOnly {
+		return 0, fmt.Errorf("invalidate tag: %w", ErrReadOnly)
+	}
+	t, ok := m.caps.(backends.Tagger)
+	if !ok {
+		return 0, fmt.Errorf("invalidate tag: %w", errors.ErrUnsupported)
+	}
+
+	keys := t.InvalidateTag(m.backendKey(tag))
+	for _, bkey := range keys {
+		key := strings.TrimPrefix(bkey, m.opts.KeyPrefix)
+		if m.async != nil {
+			m.async.forget(key)
+		}
+		m.forget(key)
+	}
+	return len(keys), nil
+}
@@ -0,0 +1,256 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// tenantKey is the context key under which ContextWithTenant stores the tenant.
+type tenantKey struct{}
+
+// ContextWithTenant returns a copy of ctx carrying tenant, for use with
+// Memoizer.TenantOf.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant stored in ctx by ContextWithTenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+// TenantQuota limits how much of the cache a single tenant may use.
+// Zero fields are unlimited.
+type TenantQuota struct {
+	// MaxEntries caps the number of entries the tenant keeps cached.
+	MaxEntries int
+
+	// MaxBytes caps the estimated size of the tenant's cached values.
+	MaxBytes int64
+}
+
+// TenantStats is a snapshot of a tenant's cache usage.
+type TenantStats struct {
+	Hits      uint64 // lookups answered from the cache
+	Misses    uint64 // lookups that had to compute
+	Evictions uint64 // entries removed to stay within the quota
+	Entries   int    // entries currently accounted to the tenant
+	Bytes     int64  // estimated size of those entries
+}
+
+// Tenant is a partition of a Memoizer's keys belonging to one tenant.
+// Keys are stored in the backend as "tenant:<len(id)>:<id>:<key>", so tenants
+// never see each other's entries, even when ids contain colons, and each
+// tenant has its own quota and stats.
+//
+// When a tenant goes over its quota, its own least recently used entries are
+// deleted, so a noisy tenant cannot push other tenants out of the cache.
+// Usage is tracked by the memoizer rather than the backend and is therefore
+// approximate: entries the backend expires or evicts on its own are counted
+// until the quota pushes them out, and memoizers sharing a backend keep
+// separate accounts.
+type Tenant struct {
+	m     *Memoizer
+	id    string
+	state *tenantState
+}
+
+// tenantState holds the usage of one tenant, shared by all its Tenant values.
+type tenantState struct {
+	mu      sync.Mutex
+	quota   TenantQuota
+	lru     *list.List               // *tenantEntry, most recently used first
+	entries map[string]*list.Element // key -> element in lru
+	bytes   int64
+	stats   TenantStats
+}
+
+// tenantEntry is one key accounted to a tenant.
+type tenantEntry struct {
+	key  string
+	size int64
+}
+
+// Tenant returns the partition of the cache belonging to id. Tenants are
+// cheap to look up; every call with the same id shares usage and quota.
+// New tenants start with the quota set by WithTenantQuota.
+//
+// Example:
+//
+//	v, err := m.Tenant(customerID).Get(ctx, "report", buildReport)
+func (m *Memoizer) Tenant(id string) *Tenant {
+	s, ok := m.tenants.Load(id)
+	if !ok {
+		s, _ = m.tenants.LoadOrStore(id, &tenantState{
+			quota:   m.opts.TenantQuota,
+			lru:     list.New(),
+			entries: make(map[string]*list.Element),
+		})
+	}
+	return &Tenant{m: m, id: id, state: s.(*tenantState)}
+}
+
+// TenantOf returns the tenant stored in ctx by ContextWithTenant. Contexts
+// without a tenant map to the tenant with the empty id.
+func (m *Memoizer) TenantOf(ctx context.Context) *Tenant {
+	id, _ := TenantFromContext(ctx)
+	return m.Tenant(id)
+}
+
+// ID returns the tenant's id.
+func (t *Tenant) ID() string {
+	return t.id
+}
+
+// Get is like Memoizer.Get for key within the tenant's partition.
+func (t *Tenant) Get(ctx context.Context, key string, fn func() (any, error)) (any, error) {
+	k := t.key(key)
+	v, hit, err := t.m.get(ctx, k, func() (any, CacheControl, error) {
+		v, err := fn()
+		return v, CacheControl{}, err
+	}, false)
+	t.state.record(hit)
+	if err == nil && !(hit && t.state.use(k)) {
+		t.account(k, v)
+	}
+	return v, err
+}
+
+// Set is like Memoizer.Set for key within the tenant's partition.
+func (t *Tenant) Set(ctx context.Context, key string, value any) {
+	k := t.key(key)
+	t.m.Set(ctx, k, value)
+	t.account(k, value)
+}
+
+// Delete removes key from the tenant's partition.
+func (t *Tenant) Delete(key string) {
+	k := t.key(key)
+	t.m.Delete(k)
+	t.state.mu.Lock()
+	t.state.removeLocked(k)
+	t.state.mu.Unlock()
+}
+
+// SetQuota replaces the tenant's quota. Usage above the new quota is
+// trimmed on the tenant's next write.
+func (t *Tenant) SetQuota(q TenantQuota) {
+	t.state.mu.Lock()
+	t.state.quota = q
+	t.state.mu.Unlock()
+}
+
+// Stats returns a snapshot of the tenant's usage.
+func (t *Tenant) Stats() TenantStats {
+	t.state.mu.Lock()
+	defer t.state.mu.Unlock()
+	stats := t.state.stats
+	stats.Entries = t.state.lru.Len()
+	stats.Bytes = t.state.bytes
+	return stats
+}
+
+// key returns the memoizer key for key within the tenant. The id is length
+// prefixed, so that no other tenant's keys can produce the same string.
+func (t *Tenant) key(key string) string {
+	return "tenant:" + strconv.Itoa(len(t.id)) + ":" + t.id + ":" + key
+}
+
+// account records key as the tenant's most recently used entry and deletes
+// the tenant's oldest entries while it is over its quota. Evictions are
+// local to this memoizer's accounting and are not published to the
+// invalidation bus.
+func (t *Tenant) account(key string, value any) {
+	evicted := t.state.add(key, backends.EstimateSize(value))
+	for _, k := range evicted {
+		t.m.deleteKey(k)
+	}
+}
+
+// resetTenants forgets the usage of every tenant, after the cache was cleared.
+func (m *Memoizer) resetTenants() {
+	m.tenants.Range(func(_, v any) bool {
+		s := v.(*tenantState)
+		s.mu.Lock()
+		s.lru.Init()
+		clear(s.entries)
+		s.bytes = 0
+		s.mu.Unlock()
+		return true
+	})
+}
+
+// record counts a hit or a miss.
+func (s *tenantState) record(hit bool) {
+	s.mu.Lock()
+	if hit {
+		s.stats.Hits++
+	} else {
+		s.stats.Misses++
+	}
+	s.mu.Unlock()
+}
+
+// use marks an accounted key as the most recently used, keeping its size.
+// It returns false if key is not accounted to the tenant.
+func (s *tenantState) use(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if ok {
+		s.lru.MoveToFront(el)
+	}
+	return ok
+}
+
+// add accounts key with the given size and returns the keys that have to be
+// deleted to bring the tenant back within its quota.
+func (s *tenantState) add(key string, size int64) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*tenantEntry)
+		s.bytes += size - e.size
+		e.size = size
+		s.lru.MoveToFront(el)
+	} else {
+		s.entries[key] = s.lru.PushFront(&tenantEntry{key: key, size: size})
+		s.bytes += size
+	}
+
+	var evicted []string
+	for s.overLocked() {
+		e := s.lru.Back().Value.(*tenantEntry)
+		s.removeLocked(e.key)
+		s.stats.Evictions++
+		evicted = append(evicted, e.key)
+	}
+	return evicted
+}
+
+// overLocked reports whether the tenant exceeds its quota.
+func (s *tenantState) overLocked() bool {
+	if s.lru.Len() == 0 {
+		return false
+	}
+	return (s.quota.MaxEntries > 0 && s.lru.Len() > s.quota.MaxEntries) ||
+		(s.quota.MaxBytes > 0 && s.bytes > s.quota.MaxBytes)
+}
+
+// removeLocked stops accounting key to the tenant.
+func (s *tenantState) removeLocked(key string) {
+	el, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	s.bytes -= el.Value.(*tenantEntry).size
+	s.lru.Remove(el)
+	delete(s.entries, key)
+}
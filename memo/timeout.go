@@ -0,0 +1,59 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrComputeTimeout is returned when a compute function runs longer than
+// the timeout set with WithComputeTimeout.
+var ErrComputeTimeout = errors.New("memo: compute function timed out")
+
+// computed is the outcome of a compute function run by withTimeout.
+type computed struct {
+	value   any
+	control CacheControl
+	err     error
+}
+
+// withTimeout turns fn into a compute function bounded by the compute
+// timeout, if one is configured. The returned function gives up with
+// ErrComputeTimeout when fn takes longer, and cancels the context it passed
+// to fn. fn keeps running in the background and its result is discarded,
+// since a compute function cannot be cancelled.
+func (m *Memoizer) withTimeout(ctx context.Context, fn func(context.Context) (any, CacheControl, error)) func() (any, CacheControl, error) {
+	if m.opts.ComputeTimeout <= 0 {
+		return func() (any, CacheControl, error) { return fn(ctx) }
+	}
+	return func() (any, CacheControl, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		done := make(chan computed, 1)
+		go func() {
+			var c computed
+			c.value, c.control, c.err = fn(ctx)
+			done <- c
+		}()
+
+		timer := time.NewTimer(m.opts.ComputeTimeout)
+		defer timer.Stop()
+		select {
+		case c := <-done:
+			return c.value, c.control, c.err
+		case <-timer.C:
+			return nil, CacheControl{}, ErrComputeTimeout
+		}
+	}
+}
+
+// withComputeTimeout derives a context for context-aware loaders that ends
+// after the compute timeout, if one is configured.
+func (m *Memoizer) withComputeTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.opts.ComputeTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, m.opts.ComputeTimeout)
+}
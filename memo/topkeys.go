@@ -0,0 +1,145 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"container/heap"
+	"slices"
+	"sync"
+)
+
+// KeyStats describes the traffic of one of the hottest keys reported by
+// TopKeys.
+type KeyStats struct {
+	Key string
+
+	// Count estimates the number of Gets of the key. It may overcount by up
+	// to Error, the count of the key it displaced when it entered the table.
+	Count uint64
+	Error uint64
+
+	// Hits and Misses count the Gets of the key since it entered the table.
+	Hits   uint64
+	Misses uint64
+
+	// Accesses is the backend's read count of the key's current entry, as
+	// reported by AccessCount; zero if the backend does not count accesses.
+	Accesses uint64
+}
+
+// topKeys tracks the most accessed keys with the space-saving algorithm:
+// a table of n counters where a key that is not tracked takes over the
+// counter of the least accessed key, inheriting its count. Keys accessed
+// more than 1/n of the time are guaranteed to be in the table.
+type topKeys struct {
+	mu    sync.Mutex
+	n     int
+	index map[string]*keyCounter
+	heap  keyHeap // min-heap by count
+}
+
+// keyCounter is one entry of the space-saving table.
+type keyCounter struct {
+	KeyStats
+	i int // position in the heap
+}
+
+// newTopKeys returns a tracker for the n hottest keys, or nil if n is not
+// positive.
+func newTopKeys(n int) *topKeys {
+	if n <= 0 {
+		return nil
+	}
+	return &topKeys{n: n, index: make(map[string]*keyCounter, n)}
+}
+
+// record counts a Get of key. A nil tracker records nothing.
+func (t *topKeys) record(key string, hit bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c, ok := t.index[key]
+	if !ok {
+		if len(t.heap) < t.n {
+			c = &keyCounter{KeyStats: KeyStats{Key: key}}
+			heap.Push(&t.heap, c)
+		} else {
+			c = t.heap[0]
+			delete(t.index, c.Key)
+			c.KeyStats = KeyStats{Key: key, Count: c.Count, Error: c.Count}
+		}
+		t.index[key] = c
+	}
+	c.Count++
+	if hit {
+		c.Hits++
+	} else {
+		c.Misses++
+	}
+	heap.Fix(&t.heap, c.i)
+}
+
+// top returns the k keys with the highest counts, hottest first.
+func (t *topKeys) top(k int) []KeyStats {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	stats := make([]KeyStats, len(t.heap))
+	for i, c := range t.heap {
+		stats[i] = c.KeyStats
+	}
+	t.mu.Unlock()
+
+	slices.SortFunc(stats, func(a, b KeyStats) int {
+		switch {
+		case a.Count > b.Count:
+			return -1
+		case a.Count < b.Count:
+			return 1
+		}
+		return 0
+	})
+	if k >= 0 && k < len(stats) {
+		stats = stats[:k]
+	}
+	return stats
+}
+
+// keyHeap implements heap.Interface over key counters, least accessed first.
+type keyHeap []*keyCounter
+
+func (h keyHeap) Len() int           { return len(h) }
+func (h keyHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+
+func (h keyHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].i = i
+	h[j].i = j
+}
+
+func (h *keyHeap) Push(x any) {
+	c := x.(*keyCounter)
+	c.i = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *keyHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// TopKeys returns the k most requested keys, hottest first, to help decide
+// what to pin, pre-warm or shard. A negative k returns every tracked key.
+// Tracking must be enabled with WithTopKeys; otherwise TopKeys returns nil.
+func (m *Memoizer) TopKeys(k int) []KeyStats {
+	stats := m.hot.top(k)
+	for i := range stats {
+		stats[i].Accesses, _ = m.AccessCount(stats[i].Key)
+	}
+	return stats
+}
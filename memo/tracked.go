@@ -0,0 +1,153 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// trackedEntry is a value held by a trackedMap.
+type trackedEntry[V any] struct {
+	value V
+	until time.Time // zero means until replaced, deleted or evicted
+}
+
+// minPruneAt is the size below which a trackedMap is never pruned except
+// to make room under its bound.
+const minPruneAt = 64
+
+// trackedMap holds per-key state the memoizer keeps in process, such as
+// stale copies and negatively cached errors. Entries expire at their own
+// deadline and are pruned whenever the map has doubled in size since the
+// last pruning, so expired entries take up at most as much room as live
+// ones. The map also holds at most max entries: when it is full, expired
+// entries are pruned first, then an arbitrary entry is evicted. A max of
+// zero disables the bound.
+type trackedMap[V any] struct {
+	mu      sync.Mutex
+	max     int
+	items   map[string]trackedEntry[V]
+	next    time.Time // no entry expires before this; zero if none expires
+	pruneAt int       // size at which expired entries are pruned next
+}
+
+// newTrackedMap returns a trackedMap holding at most max entries.
+func newTrackedMap[V any](max int) *trackedMap[V] {
+	return &trackedMap[V]{max: max, items: make(map[string]trackedEntry[V]), pruneAt: minPruneAt}
+}
+
+// store sets key to value until the given deadline, zero meaning no deadline.
+func (t *trackedMap[V]) store(key string, value V, until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.items[key]; !ok {
+		t.makeRoom()
+	}
+	t.setLocked(key, value, until)
+}
+
+// loadOrStore returns the value for key, storing the result of newValue if
+// key is missing or has expired, and moves the deadline of the entry to
+// until.
+func (t *trackedMap[V]) loadOrStore(key string, newValue func() V, until time.Time) V {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.items[key]
+	if !ok || (!e.until.IsZero() && time.Now().After(e.until)) {
+		if !ok {
+			t.makeRoom()
+		}
+		e.value = newValue()
+	}
+	t.setLocked(key, e.value, until)
+	return e.value
+}
+
+// setLocked sets key to value until the given deadline. t.mu must be held.
+func (t *trackedMap[V]) setLocked(key string, value V, until time.Time) {
+	t.items[key] = trackedEntry[V]{value: value, until: until}
+	if !until.IsZero() && (t.next.IsZero() || until.Before(t.next)) {
+		t.next = until
+	}
+}
+
+// makeRoom prepares the map for a new key: it prunes expired entries once
+// the map has doubled since the last pruning, and evicts an arbitrary entry
+// if the map is still full. t.mu must be held.
+func (t *trackedMap[V]) makeRoom() {
+	full := t.max > 0 && len(t.items) >= t.max
+	if !full && len(t.items) < t.pruneAt {
+		return
+	}
+	t.prune(time.Now())
+	t.pruneAt = max(2*len(t.items), minPruneAt)
+	if full && len(t.items) >= t.max {
+		for k := range t.items {
+			delete(t.items, k)
+			break
+		}
+	}
+}
+
+// load returns the value for key, unless it is missing or has expired.
+func (t *trackedMap[V]) load(key string) (V, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if !e.until.IsZero() && time.Now().After(e.until) {
+		delete(t.items, key)
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// delete removes key.
+func (t *trackedMap[V]) delete(key string) {
+	t.mu.Lock()
+	delete(t.items, key)
+	t.mu.Unlock()
+}
+
+// deletePrefix removes every key starting with prefix.
+func (t *trackedMap[V]) deletePrefix(prefix string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k := range t.items {
+		if strings.HasPrefix(k, prefix) {
+			delete(t.items, k)
+		}
+	}
+}
+
+// clear removes every key.
+func (t *trackedMap[V]) clear() {
+	t.mu.Lock()
+	clear(t.items)
+	t.next = time.Time{}
+	t.pruneAt = minPruneAt
+	t.mu.Unlock()
+}
+
+// prune removes the entries expired at now. It scans the map only once the
+// earliest known deadline has passed. t.mu must be held.
+func (t *trackedMap[V]) prune(now time.Time) {
+	if t.next.IsZero() || now.Before(t.next) {
+		return
+	}
+	t.next = time.Time{}
+	for k, e := range t.items {
+		switch {
+		case e.until.IsZero():
+		case now.After(e.until):
+			delete(t.items, k)
+		case t.next.IsZero() || e.until.Before(t.next):
+			t.next = e.until
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// SetKeyTTL overrides the TTL used the next time key is computed and stored.
+// It does not change the expiry of a value already in the cache; it only
+// affects future writes for that key. Other keys keep using the memoizer's
+// TTL. A zero or negative ttl removes the override.
+func (m *Memoizer) SetKeyTTL(key string, ttl time.Duration) {
+	if ttl <= 0 {
+		m.keyTTLs.Delete(key)
+		return
+	}
+	m.keyTTLs.Store(key, ttl)
+}
+
+// ttlFor returns the TTL to store value under key with. A per-key override
+// from SetKeyTTL wins over the TTLFunc policy, which wins over the backend's
+// backends.DefaultTTLProvider TTL, which wins over the memoizer's TTL.
+func (m *Memoizer) ttlFor(key string, value any) time.Duration {
+	if ttl, ok := m.keyTTLs.Load(key); ok {
+		return ttl.(time.Duration)
+	}
+	if m.opts.TTLFunc != nil {
+		if ttl := m.opts.TTLFunc(key, value); ttl > 0 {
+			return ttl
+		}
+	}
+	if p, ok := m.caps.(backends.DefaultTTLProvider); ok {
+		if ttl := p.DefaultTTL(); ttl > 0 {
+			return ttl
+		}
+	}
+	return m.opts.TTL
+}
+
+// Touch resets the expiry of the cached entry for key to ttl from now,
+// without recomputing or rewriting its value. A zero or negative ttl makes
+// the entry permanent. It requires a backend implementing backends.Toucher
+// and returns false otherwise, if key is not cached, or if the memoizer is
+// read-only.
+func (m *Memoizer) Touch(key string, ttl time.Duration) bool {
+	return m.touchKey(context.Background(), key, ttl)
+}
+
+// touchKey implements Touch, passing ctx to the backend.
+func (m *Memoizer) touchKey(ctx context.Context, key string, ttl time.Duration) bool {
+	touch := m.toucher()
+	if touch == nil || m.opts.ReadOnly {
+		return false
+	}
+	ok, err := touch(ctx, m.backendKey(key), ttl)
+	if err != nil {
+		m.backendError("touch", key, err)
+	}
+	return ok
+}
+
+// toucher returns a function extending an entry's expiry in the backend,
+// preferring its context-aware form, or nil if the backend cannot touch
+// entries.
+func (m *Memoizer) toucher() func(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if t, ok := m.store2.(backends.ToucherV2); ok {
+		return t.Touch
+	}
+	if t, ok := m.caps.(backends.Toucher); ok {
+		return func(_ context.Context, key string, ttl time.Duration) (bool, error) {
+			return t.Touch(key, ttl), nil
+		}
+	}
+	return nil
+}
+
+// Expire makes the cached entry for key expire now, so the next Get
+// recomputes it. Unlike Delete, it keeps the memoizer's stale copy of the
+// value, so a failing recompute can still be answered with it when
+// stale-if-error is enabled. Backends that do not implement backends.Expirer
+// have the entry deleted instead.
+func (m *Memoizer) Expire(key string) {
+	if m.async != nil {
+		m.async.forget(key)
+	}
+	if m.opts.ReadOnly {
+		return
+	}
+	if e, ok := m.caps.(backends.Expirer); ok {
+		e.Expire(m.backendKey(key))
+		return
+	}
+	if err := m.store2.Delete(context.Background(), m.backendKey(key)); err != nil {
+		m.backendError("delete", key, err)
+	}
+}
+
+// touch extends the expiry of key after a hit on value when sliding TTLs are
+// enabled. It runs on the caller's ctx, as part of the hit.
+func (m *Memoizer) touch(ctx context.Context, key string, value any) {
+	if !m.opts.SlidingTTL || m.opts.ReadOnly {
+		return
+	}
+	touch := m.toucher()
+	if touch == nil {
+		return
+	}
+	if _, err := touch(ctx, m.backendKey(key), m.ttlFor(key, value)); err != nil {
+		m.backendError("touch", key, err)
+	}
+}
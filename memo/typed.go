@@ -0,0 +1,54 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import "context"
+
+// TypedMemoizer is a Memoizer with compile-time key and value types.
+// It shares the backend, singleflight and metrics machinery of Memoizer and
+// only adds typing on top.
+//
+// String keys are used as cache keys verbatim; other key types are derived
+// the same way MemoizeFunc derives keys from arguments.
+type TypedMemoizer[K comparable, V any] struct {
+	m *Memoizer
+}
+
+// NewTyped creates a TypedMemoizer with the provided options.
+// Like New, it panics if the options are invalid.
+//
+// Example:
+//
+//	users := memo.NewTyped[int, User](memo.WithTTL(time.Minute))
+//	u, err := users.Get(ctx, 42, func() (User, error) { return loadUser(42) })
+func NewTyped[K comparable, V any](opts ...Option) *TypedMemoizer[K, V] {
+	return &TypedMemoizer[K, V]{m: New(opts...)}
+}
+
+// Get retrieves the cached value for key or computes and stores it if missing.
+// See Memoizer.Get for the caching semantics and Do for type mismatches.
+func (t *TypedMemoizer[K, V]) Get(ctx context.Context, key K, fn func() (V, error)) (V, error) {
+	return Do(ctx, t.m, t.keyFor(key), fn)
+}
+
+// Delete removes the entry for key from the cache.
+func (t *TypedMemoizer[K, V]) Delete(key K) {
+	t.m.Delete(t.keyFor(key))
+}
+
+// Clear purges all entries from the backend.
+func (t *TypedMemoizer[K, V]) Clear() {
+	t.m.Clear()
+}
+
+// Memoizer returns the underlying untyped Memoizer.
+func (t *TypedMemoizer[K, V]) Memoizer() *Memoizer {
+	return t.m
+}
+
+// keyFor derives the cache key for key.
+func (t *TypedMemoizer[K, V]) keyFor(key K) string {
+	if s, ok := any(key).(string); ok {
+		return s
+	}
+	return t.m.argsKey(key)
+}
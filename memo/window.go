@@ -0,0 +1,130 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// windowSlots is the number of slots a rolling window is divided into. The
+// window advances one slot at a time, so it covers between
+// (windowSlots-1)/windowSlots of its duration and all of it.
+const windowSlots = 10
+
+// WindowStats describes the requests recorded during the most recent
+// rolling window, as configured with WithMetricsWindow.
+type WindowStats struct {
+	// Window is the configured duration of the window.
+	Window time.Duration
+
+	Requests uint64
+	Hits     uint64
+	Misses   uint64
+
+	// AvgLatency is the average miss latency within the window.
+	AvgLatency time.Duration
+}
+
+// HitRatio returns the share of requests within the window that were hits.
+func (s WindowStats) HitRatio() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Requests)
+}
+
+// windowSlot holds the samples of one slice of a rolling window.
+type windowSlot struct {
+	epoch   int64 // slot number since the Unix epoch, in slot widths
+	hits    uint64
+	misses  uint64
+	total   uint64 // microseconds
+	samples uint64
+}
+
+// rollingWindow counts requests over a ring of time slots. A slot is
+// cleared when it is first reused; samples recorded concurrently with the
+// clearing may be lost, which keeps recording lock-free at the cost of
+// being approximate.
+type rollingWindow struct {
+	width int64 // nanoseconds
+	slots [windowSlots]windowSlot
+}
+
+// newRollingWindow returns a window covering d, or nil if d is not positive.
+func newRollingWindow(d time.Duration) *rollingWindow {
+	if d <= 0 {
+		return nil
+	}
+	return &rollingWindow{width: max(int64(d)/windowSlots, 1)}
+}
+
+// slot returns the slot for now, clearing it if it last held older samples.
+func (w *rollingWindow) slot(now time.Time) *windowSlot {
+	epoch := now.UnixNano() / w.width
+	s := &w.slots[epoch%windowSlots]
+	if old := atomic.LoadInt64(&s.epoch); old != epoch && atomic.CompareAndSwapInt64(&s.epoch, old, epoch) {
+		atomic.StoreUint64(&s.hits, 0)
+		atomic.StoreUint64(&s.misses, 0)
+		atomic.StoreUint64(&s.total, 0)
+		atomic.StoreUint64(&s.samples, 0)
+	}
+	return s
+}
+
+// recordHit counts a hit.
+func (w *rollingWindow) recordHit() {
+	atomic.AddUint64(&w.slot(time.Now()).hits, 1)
+}
+
+// recordMiss counts a miss.
+func (w *rollingWindow) recordMiss() {
+	atomic.AddUint64(&w.slot(time.Now()).misses, 1)
+}
+
+// recordLatency adds a miss latency sample in microseconds.
+func (w *rollingWindow) recordLatency(microseconds int64) {
+	s := w.slot(time.Now())
+	atomic.AddUint64(&s.total, uint64(max(microseconds, 0)))
+	atomic.AddUint64(&s.samples, 1)
+}
+
+// stats sums the slots that fall within the window ending at now.
+func (w *rollingWindow) stats(now time.Time) WindowStats {
+	stats := WindowStats{Window: time.Duration(w.width * windowSlots)}
+	epoch := now.UnixNano() / w.width
+	var total, samples uint64
+	for i := range w.slots {
+		s := &w.slots[i]
+		if e := atomic.LoadInt64(&s.epoch); e <= epoch-windowSlots || e > epoch {
+			continue
+		}
+		stats.Hits += atomic.LoadUint64(&s.hits)
+		stats.Misses += atomic.LoadUint64(&s.misses)
+		total += atomic.LoadUint64(&s.total)
+		samples += atomic.LoadUint64(&s.samples)
+	}
+	stats.Requests = stats.Hits + stats.Misses
+	if samples > 0 {
+		stats.AvgLatency = time.Duration(total/samples) * time.Microsecond
+	}
+	return stats
+}
+
+// reset drops every sample.
+func (w *rollingWindow) reset() {
+	for i := range w.slots {
+		atomic.StoreInt64(&w.slots[i].epoch, 0)
+	}
+}
+
+// Window returns the hit ratio and miss latency over the most recent
+// rolling window, so dashboards can follow current behavior rather than
+// averages since the process started. It returns zero stats unless the
+// memoizer was created with WithMetricsWindow.
+func (m *Metrics) Window() WindowStats {
+	if m.window == nil {
+		return WindowStats{}
+	}
+	return m.window.stats(time.Now())
+}
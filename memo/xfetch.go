@@ -0,0 +1,94 @@
+// Package memo provides generic memoization functionality with pluggable backends.
+package memo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sync/atomic"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// read looks key up like lookup, and additionally reports whether a hit
+// should be treated as expired ahead of time (probabilistic early expiry).
+func (m *Memoizer) read(ctx context.Context, key string) (val any, ok bool, early bool, err error) {
+	getEntry := m.entryReader()
+	if m.opts.EarlyExpiryBeta <= 0 || getEntry == nil {
+		val, ok, err = m.lookupErr(ctx, key)
+		return val, ok, false, err
+	}
+
+	start := m.metrics.now()
+	entry, ok, err := getEntry(ctx, m.backendKey(key))
+	m.metrics.RecordBackendGetLatency(m.metrics.since(start))
+	if errors.Is(err, backends.ErrCorruptEntry) {
+		err = nil
+	} else if err != nil {
+		m.backendError("get", key, err)
+	} else if ok {
+		return entry.Value, true, m.expireEarly(key, &entry), nil
+	}
+	if m.async != nil {
+		if val, ok := m.async.get(key); ok {
+			return val, true, false, nil
+		}
+	}
+	return nil, false, false, err
+}
+
+// entryReader returns a function reading entries with their metadata from
+// the backend, preferring its context-aware form, or nil if the backend
+// cannot return entries.
+func (m *Memoizer) entryReader() func(ctx context.Context, key string) (backends.CacheEntry, bool, error) {
+	if eb, ok := m.store2.(backends.EntryBackendV2); ok {
+		return eb.GetEntry
+	}
+	if eb, ok := m.caps.(backends.EntryBackend); ok {
+		return func(_ context.Context, key string) (backends.CacheEntry, bool, error) {
+			entry, ok := eb.GetEntry(key)
+			return entry, ok, nil
+		}
+	}
+	return nil
+}
+
+// expireEarly implements the XFetch decision: recompute now if
+//
+//	-delta * beta * ln(rand()) >= remaining TTL
+//
+// where delta is the last observed compute time for the key. Expensive
+// computations and entries close to expiry are refreshed more eagerly, which
+// spreads recomputation out instead of letting every caller miss at once.
+func (m *Memoizer) expireEarly(key string, entry *backends.CacheEntry) bool {
+	if entry.ExpiresAt().IsZero() {
+		return false
+	}
+	cost, ok := m.costs.Load(key)
+	if !ok {
+		return false
+	}
+	delta := float64(cost.(*atomic.Int64).Load())
+	remaining := float64(entry.TTLRemaining())
+
+	// 1-rand is in (0, 1], keeping the logarithm finite
+	gap := -delta * m.opts.EarlyExpiryBeta * math.Log(1-m.randFloat64())
+	return gap >= remaining
+}
+
+// randFloat64 returns a pseudo-random number in [0, 1) from the memoizer's
+// random source, or from the process-wide generator if none was configured.
+func (m *Memoizer) randFloat64() float64 {
+	if m.rnd == nil {
+		return rand.Float64()
+	}
+	m.rndMu.Lock()
+	defer m.rndMu.Unlock()
+	return m.rnd.Float64()
+}
+
+// trackCosts reports whether compute durations need to be recorded per key.
+func (m *Memoizer) trackCosts() bool {
+	return m.opts.AdaptiveThreshold > 0 || m.opts.EarlyExpiryBeta > 0
+}
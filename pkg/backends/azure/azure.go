@@ -0,0 +1,292 @@
+// Package azure provides a cache backend storing entries as blobs in an Azure
+// Blob Storage container.
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/ldaidone/gomemo/internals/wire"
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// ExpiresMetadata is the blob metadata key holding a blob's expiry in unix
+// nanoseconds. Blobs without it never expire.
+const ExpiresMetadata = "gomemo_expires"
+
+// Azure is a cache backend storing each entry as a block blob below a name
+// prefix, for services hosted on Azure that do not run Redis. Blob storage
+// has high latency and per-request cost, so it suits large or expensive
+// values more than hot keys.
+//
+// Expiry is recorded in the blob's metadata and checked on every read.
+// Azure does not delete blobs by metadata; expired blobs stay until they are
+// overwritten, deleted, or removed by a lifecycle management rule on the
+// prefix, e.g. daysAfterModificationGreaterThan set to the longest TTL.
+type Azure struct {
+	client       Client
+	container    string
+	prefix       string                     // prepended to every key
+	codec        backends.Codec             // serializes entries; gob unless WithCodec is given
+	errorHandler func(op string, err error) // receives errors that cannot be returned; nil logs them
+}
+
+var (
+	_ backends.EntryBackend  = (*Azure)(nil)
+	_ backends.PrefixDeleter = (*Azure)(nil)
+	_ backends.V2Provider    = (*Azure)(nil)
+)
+
+// Option configures an Azure backend.
+type Option func(*Azure)
+
+// WithPrefix stores blobs below prefix instead of "gomemo/", so several
+// caches can share a container.
+func WithPrefix(prefix string) Option {
+	return func(a *Azure) {
+		a.prefix = prefix
+	}
+}
+
+// WithCodec selects how entries are serialized. The default is
+// backends.GobCodec. Entries written with gob stay readable after switching
+// to another codec.
+func WithCodec(c backends.Codec) Option {
+	return func(a *Azure) {
+		if c != nil {
+			a.codec = c
+		}
+	}
+}
+
+// WithErrorHandler passes errors the Backend interface cannot return, such as
+// failed uploads, to fn instead of logging them with the standard log
+// package. op names the failed operation, e.g. "set". The context-aware form
+// returned by V2 returns errors instead.
+func WithErrorHandler(fn func(op string, err error)) Option {
+	return func(a *Azure) {
+		a.errorHandler = fn
+	}
+}
+
+// New creates a backend storing entries in container through client. The
+// memoizer uses the backend's context-aware form automatically, so the
+// context passed to Memoizer.Get bounds the requests made for it.
+//
+// Example:
+//
+//	// client adapts an Azure SDK container client to azure.Client
+//	backend := azure.New(client, "cache", azure.WithPrefix("reports/"))
+//	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Hour))
+func New(client Client, container string, opts ...Option) *Azure {
+	a := &Azure{
+		client:    client,
+		container: container,
+		prefix:    "gomemo/",
+		codec:     backends.GobCodec(),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// V2 returns the context-aware form of the backend.
+func (a *Azure) V2() backends.BackendV2 {
+	return contextBackend{a}
+}
+
+// -----------------------------------------------------------------------------
+// Backend interface
+// -----------------------------------------------------------------------------
+
+func (a *Azure) Get(key string) (any, bool) {
+	entry, ok := a.GetEntry(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// GetEntry retrieves the entry stored under key. Expired blobs are misses.
+func (a *Azure) GetEntry(key string) (backends.CacheEntry, bool) {
+	entry, ok, err := a.get(context.Background(), key)
+	if err != nil {
+		a.onError("get", err)
+		return backends.CacheEntry{}, false
+	}
+	return entry, ok
+}
+
+func (a *Azure) Set(key string, value any, ttl time.Duration) {
+	if err := a.set(context.Background(), key, value, ttl); err != nil {
+		a.onError("set", err)
+	}
+}
+
+func (a *Azure) Delete(key string) {
+	if err := a.client.DeleteBlob(context.Background(), a.container, a.prefixed(key)); err != nil {
+		a.onError("delete", err)
+	}
+}
+
+// Clear removes every blob below the backend's prefix.
+func (a *Azure) Clear() {
+	if _, err := a.deleteByPrefix(context.Background(), ""); err != nil {
+		a.onError("clear", err)
+	}
+}
+
+// DeleteByPrefix removes every entry whose key starts with prefix and returns
+// how many were removed.
+func (a *Azure) DeleteByPrefix(prefix string) int {
+	n, err := a.deleteByPrefix(context.Background(), prefix)
+	if err != nil {
+		a.onError("delete by prefix", err)
+	}
+	return n
+}
+
+// -----------------------------------------------------------------------------
+// Operations
+// -----------------------------------------------------------------------------
+
+// get returns the entry stored under key if it is present and not expired.
+func (a *Azure) get(ctx context.Context, key string) (backends.CacheEntry, bool, error) {
+	blob, err := a.client.GetBlob(ctx, a.container, a.prefixed(key))
+	if errors.Is(err, ErrNotFound) {
+		return backends.CacheEntry{}, false, nil
+	} else if err != nil {
+		return backends.CacheEntry{}, false, err
+	}
+
+	// Check the metadata first to skip decoding expired blobs
+	if exp, err := strconv.ParseInt(blob.Metadata[ExpiresMetadata], 10, 64); err == nil && exp <= time.Now().UnixNano() {
+		return backends.CacheEntry{}, false, nil
+	}
+	entry, err := a.decode(blob.Body)
+	if err != nil {
+		return backends.CacheEntry{}, false, fmt.Errorf("decode %s: %w", key, err)
+	}
+	if entry.IsExpired() {
+		return backends.CacheEntry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+// set uploads value as the blob of key.
+func (a *Azure) set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	entry := backends.NewEntry(value, ttl, 0)
+	payload, err := a.codec.Encode(backends.NewRecord(entry))
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
+	blob := Blob{
+		Name:     a.prefixed(key),
+		Body:     wire.Encode(wire.Header{Codec: a.codec.ID()}, payload),
+		Metadata: map[string]string{},
+	}
+	if exp := entry.ExpiresAt(); !exp.IsZero() {
+		blob.Metadata[ExpiresMetadata] = strconv.FormatInt(exp.UnixNano(), 10)
+	}
+	return a.client.UploadBlob(ctx, a.container, blob)
+}
+
+// deleteByPrefix implements DeleteByPrefix and Clear.
+func (a *Azure) deleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	removed := 0
+	err := a.client.ListBlobs(ctx, a.container, a.prefixed(prefix), func(names []string) error {
+		for _, name := range names {
+			if err := a.client.DeleteBlob(ctx, a.container, name); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// -----------------------------------------------------------------------------
+// Serialization
+// -----------------------------------------------------------------------------
+
+func (a *Azure) prefixed(key string) string {
+	return a.prefix + key
+}
+
+// decode deserializes an encoded entry.
+func (a *Azure) decode(data []byte) (backends.CacheEntry, error) {
+	hdr, payload, err := wire.Decode(data)
+	if err != nil {
+		return backends.CacheEntry{}, err
+	}
+	if hdr.Flags != 0 {
+		return backends.CacheEntry{}, fmt.Errorf("%w: unsupported flags %#x", wire.ErrUnknownFormat, hdr.Flags)
+	}
+	codec := a.codec
+	if hdr.Codec != codec.ID() {
+		if hdr.Codec != wire.CodecGob {
+			return backends.CacheEntry{}, fmt.Errorf("%w: unsupported codec %d", wire.ErrUnknownFormat, hdr.Codec)
+		}
+		codec = backends.GobCodec()
+	}
+
+	var rec backends.Record
+	if err := codec.Decode(payload, &rec); err != nil {
+		return backends.CacheEntry{}, err
+	}
+	return rec.Entry(), nil
+}
+
+// onError reports an error that cannot be returned to the caller.
+func (a *Azure) onError(op string, err error) {
+	if a.errorHandler != nil {
+		a.errorHandler(op, err)
+		return
+	}
+	log.Printf("[gomemo][azure] %s error: %v\n", op, err)
+}
+
+// -----------------------------------------------------------------------------
+// BackendV2 interface
+// -----------------------------------------------------------------------------
+
+// contextBackend exposes an Azure backend through backends.BackendV2.
+type contextBackend struct {
+	*Azure
+}
+
+var (
+	_ backends.BackendV2      = contextBackend{}
+	_ backends.EntryBackendV2 = contextBackend{}
+)
+
+func (c contextBackend) Get(ctx context.Context, key string) (any, bool, error) {
+	entry, ok, err := c.get(ctx, key)
+	if !ok || err != nil {
+		return nil, false, err
+	}
+	return entry.Value, true, nil
+}
+
+func (c contextBackend) GetEntry(ctx context.Context, key string) (backends.CacheEntry, bool, error) {
+	return c.get(ctx, key)
+}
+
+func (c contextBackend) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return c.set(ctx, key, value, ttl)
+}
+
+func (c contextBackend) Delete(ctx context.Context, key string) error {
+	return c.client.DeleteBlob(ctx, c.container, c.prefixed(key))
+}
+
+func (c contextBackend) Clear(ctx context.Context) error {
+	_, err := c.deleteByPrefix(ctx, "")
+	return err
+}
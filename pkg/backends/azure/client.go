@@ -0,0 +1,42 @@
+package azure
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by Client.GetBlob for missing blobs. Adapters map
+// the BlobNotFound error code to it.
+var ErrNotFound = errors.New("blob not found")
+
+// Client is the part of Azure Blob Storage the backend uses. It is kept small
+// so the backend does not pin an Azure SDK version: an adapter over the SDK's
+// container.Client is a few lines per method, and tests can use an in-memory
+// fake.
+type Client interface {
+	// GetBlob downloads the blob stored under name, with its metadata, or
+	// returns ErrNotFound.
+	GetBlob(ctx context.Context, container, name string) (*Blob, error)
+
+	// UploadBlob stores blob as a block blob, replacing any blob under the
+	// same name.
+	UploadBlob(ctx context.Context, container string, blob Blob) error
+
+	// DeleteBlob removes the blob stored under name. Deleting a missing blob
+	// is not an error.
+	DeleteBlob(ctx context.Context, container, name string) error
+
+	// ListBlobs calls fn with pages of the names starting with prefix, as
+	// returned by List Blobs.
+	ListBlobs(ctx context.Context, container, prefix string, fn func(names []string) error) error
+}
+
+// Blob is a cache entry as stored in Azure.
+type Blob struct {
+	Name string
+	Body []byte // encoded entry
+
+	// Metadata is stored as blob metadata (x-ms-meta-*). The backend records
+	// the expiry in it. Azure requires keys to be valid C# identifiers.
+	Metadata map[string]string
+}
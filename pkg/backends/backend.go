@@ -2,6 +2,7 @@
 package backends
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -26,6 +27,156 @@ type Backend interface {
 	Clear()
 }
 
+// EntryBackend is implemented by backends that can return a stored entry
+// together with its metadata (expiry, version) instead of just the value.
+// It lets callers make freshness decisions without a second round trip.
+type EntryBackend interface {
+	Backend
+
+	// GetEntry retrieves the entry stored under key.
+	// Returns the entry and true if found and not expired, a zero entry and false otherwise.
+	GetEntry(key string) (entry CacheEntry, ok bool)
+}
+
+// AccessCounter is implemented by backends that can report how often an
+// entry has been read, e.g. to find hot keys.
+type AccessCounter interface {
+	// AccessCount returns the number of reads of the entry stored under key.
+	// Returns false if the key is not present or access counting is disabled.
+	// It does not count as an access itself.
+	AccessCount(key string) (count uint64, ok bool)
+}
+
+// Peeker is implemented by backends whose Get has side effects, such as
+// updating eviction order or access counts, and that can also read without them.
+type Peeker interface {
+	// Peek retrieves the value stored under key like Get, but without
+	// counting as an access.
+	Peek(key string) (value any, ok bool)
+}
+
+// EntryPeeker is the EntryBackend counterpart of Peeker.
+type EntryPeeker interface {
+	// PeekEntry retrieves the entry stored under key like GetEntry, but
+	// without counting as an access.
+	PeekEntry(key string) (entry CacheEntry, ok bool)
+}
+
+// Toucher is implemented by backends that can extend the expiry of an
+// existing entry without rewriting its value, e.g. for sliding TTLs.
+type Toucher interface {
+	// Touch resets the TTL of the entry stored under key to ttl from now.
+	// Returns false if the key is not present or already expired.
+	Touch(key string, ttl time.Duration) bool
+}
+
+// AtomicBackend is implemented by backends that can read and write entries
+// atomically, so that processes sharing the backend do not overwrite each
+// other's updates. Versions are those of CacheEntry, as returned by
+// EntryBackend.GetEntry; entries written with Set have version 0.
+type AtomicBackend interface {
+	// CompareAndSet stores value under key if the stored entry has the given
+	// version, giving the new entry the next version. Returns false if the
+	// key is missing or another write got there first.
+	CompareAndSet(key string, value any, ttl time.Duration, version uint64) bool
+
+	// SetIfAbsent stores value under key unless an entry is already present.
+	// Returns false if it was.
+	SetIfAbsent(key string, value any, ttl time.Duration) bool
+
+	// GetAndTouch retrieves the value stored under key and resets its TTL
+	// to ttl from now in one step, so the entry cannot expire or be
+	// replaced between the two.
+	GetAndTouch(key string, ttl time.Duration) (value any, ok bool)
+}
+
+// DefaultTTLProvider is implemented by backends configured with a TTL of
+// their own, e.g. a short one for an in-process cache and a longer one for
+// Redis. The memoizer stores values with it instead of its own TTL, and
+// tiered backends apply each tier's TTL to the values they write to it.
+type DefaultTTLProvider interface {
+	// DefaultTTL returns the TTL values should be written with when no more
+	// specific one applies. Zero means the backend has no default.
+	DefaultTTL() time.Duration
+}
+
+// Expirer is implemented by backends that can expire an entry ahead of its
+// TTL, e.g. to force a recompute on the next read.
+type Expirer interface {
+	// Expire makes the entry stored under key expire immediately.
+	// Returns false if the key is not present or already expired.
+	Expire(key string) bool
+}
+
+// EvictionNotifier is implemented by bounded backends that drop entries to
+// make room for new ones.
+type EvictionNotifier interface {
+	// OnEvict registers fn to be called with the key of every entry evicted
+	// for capacity. Expired entries are not reported.
+	OnEvict(fn func(key string))
+}
+
+// CorruptionNotifier is implemented by backends that verify entries they
+// read back, such as a Redis backend with a signing key.
+type CorruptionNotifier interface {
+	// OnCorrupt registers fn to be called with the key of every entry that
+	// failed verification. Such entries are treated as misses.
+	OnCorrupt(fn func(key string))
+}
+
+// ErrCorruptEntry is returned for entries that fail verification because
+// they were tampered with or truncated.
+var ErrCorruptEntry = errors.New("corrupt cache entry")
+
+// BatchItem is a value to store with BatchBackend.SetMulti.
+type BatchItem struct {
+	Key   string
+	Value any
+	TTL   time.Duration
+}
+
+// BatchBackend is implemented by backends that can read and write many keys
+// at once, e.g. with a single network round trip.
+type BatchBackend interface {
+	// GetMulti retrieves the values stored under keys. Keys that are missing
+	// or expired are absent from the result.
+	GetMulti(keys []string) map[string]any
+
+	// SetMulti stores all items, each with its own TTL.
+	SetMulti(items []BatchItem)
+
+	// DeleteMulti removes the values stored under keys.
+	DeleteMulti(keys []string)
+}
+
+// PrefixDeleter is implemented by backends that can remove all keys sharing
+// a prefix, e.g. every "user:42:" entry, without the caller tracking them.
+type PrefixDeleter interface {
+	// DeleteByPrefix removes every entry whose key starts with prefix and
+	// returns how many were removed.
+	DeleteByPrefix(prefix string) int
+}
+
+// Tagger is implemented by backends that can group entries under tags and
+// invalidate a whole group at once, e.g. everything derived from "user:42".
+type Tagger interface {
+	// SetTags associates tags with the entry stored under key, replacing the
+	// tags it had. Rewriting the entry keeps its tags; removing it drops them.
+	SetTags(key string, tags []string)
+
+	// InvalidateTag removes every entry carrying tag and returns their keys.
+	InvalidateTag(tag string) []string
+}
+
+// Closer is implemented by backends that hold resources which must be
+// released on shutdown, such as connections, file handles or background
+// goroutines. It has the same shape as io.Closer.
+type Closer interface {
+	// Close releases the backend's resources. The backend must not be used
+	// afterwards unless its documentation says otherwise.
+	Close() error
+}
+
 // BackendFactory is a function that creates a new backend instance.
 // It is used by the registration system to dynamically create backends.
 type BackendFactory func() Backend
@@ -0,0 +1,651 @@
+// Package bolt provides a persistent cache backend stored in a bbolt file.
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ldaidone/gomemo/internals/wire"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Bolt is a cache backend persisting entries in a single bbolt file, so the
+// cache survives restarts. It suits CLI tools and single-node services that
+// want a durable cache without running Redis.
+//
+// Entries live in one bucket, each value prefixed with its expiry so that
+// expired entries are recognized without decoding them. A second bucket
+// indexes keys by expiry, which lets the periodic cleanup remove expired
+// entries without scanning the whole file.
+//
+// bbolt never shrinks its file on its own; Compact, or WithCompactInterval,
+// rewrites it to reclaim the space of removed entries.
+type Bolt struct {
+	mu   sync.RWMutex // guards db; Compact replaces it
+	db   *bbolt.DB
+	path string
+
+	bucket       []byte                     // bucket holding the entries
+	expiryBucket []byte                     // bucket indexing keys by expiry
+	codec        backends.Codec             // serializes entries; gob unless WithCodec is given
+	timeout      time.Duration              // how long Open waits for the file lock
+	cleanupEvery time.Duration              // 0 disables the cleanup loop
+	compactEvery time.Duration              // 0 disables periodic compaction
+	errorHandler func(op string, err error) // receives errors that cannot be returned; nil logs them
+	stop         chan struct{}
+	done         chan struct{}
+	closeOnce    sync.Once
+	closeErr     error
+}
+
+var (
+	_ backends.EntryBackend  = (*Bolt)(nil)
+	_ backends.Toucher       = (*Bolt)(nil)
+	_ backends.Expirer       = (*Bolt)(nil)
+	_ backends.BatchBackend  = (*Bolt)(nil)
+	_ backends.PrefixDeleter = (*Bolt)(nil)
+	_ backends.Closer        = (*Bolt)(nil)
+	_ backends.StatsBackend  = (*Bolt)(nil)
+)
+
+const (
+	// defaultCleanupInterval is how often expired entries are removed unless
+	// WithCleanupInterval is given.
+	defaultCleanupInterval = time.Minute
+
+	// expirySize is the size of the expiry prefix of stored values and of
+	// index keys: unix nanoseconds, big endian, 0 for no expiry.
+	expirySize = 8
+
+	// compactTxSize bounds the size of the transactions Compact copies in.
+	compactTxSize = 64 << 20
+)
+
+// Option configures a Bolt backend.
+type Option func(*Bolt)
+
+// WithBucket stores entries in the named bucket instead of "gomemo", so
+// several caches can share a file.
+func WithBucket(name string) Option {
+	return func(b *Bolt) {
+		if name != "" {
+			b.bucket = []byte(name)
+			b.expiryBucket = []byte(name + ".expiry")
+		}
+	}
+}
+
+// WithCodec selects how entries are serialized. The default is
+// backends.GobCodec. Entries written with gob stay readable after switching
+// to another codec.
+func WithCodec(c backends.Codec) Option {
+	return func(b *Bolt) {
+		if c != nil {
+			b.codec = c
+		}
+	}
+}
+
+// WithTimeout bounds how long New waits for the file lock held by another
+// process using the same file. The default is one second.
+func WithTimeout(d time.Duration) Option {
+	return func(b *Bolt) {
+		b.timeout = d
+	}
+}
+
+// WithCleanupInterval sets how often expired entries are removed from the
+// file. Zero or negative disables the background cleanup; expired entries
+// are still never returned. The default is one minute.
+func WithCleanupInterval(d time.Duration) Option {
+	return func(b *Bolt) {
+		b.cleanupEvery = max(d, 0)
+	}
+}
+
+// WithCompactInterval compacts the file every d, see Compact. Compaction
+// blocks all cache operations while it runs, so pick an interval matching
+// how fast the cache churns. By default the file is never compacted.
+func WithCompactInterval(d time.Duration) Option {
+	return func(b *Bolt) {
+		b.compactEvery = max(d, 0)
+	}
+}
+
+// WithErrorHandler passes errors the Backend interface cannot return, such as
+// failed writes, to fn instead of logging them with the standard log package.
+// op names the failed operation, e.g. "set".
+func WithErrorHandler(fn func(op string, err error)) Option {
+	return func(b *Bolt) {
+		b.errorHandler = fn
+	}
+}
+
+// New opens, or creates, the cache file at path. Only one process can open
+// a file at a time; New waits up to the WithTimeout duration for others to
+// close it. Close must be called to release the file.
+//
+// Example:
+//
+//	backend, err := bolt.New(filepath.Join(cacheDir, "cache.db"))
+//	if err != nil {
+//	    return err
+//	}
+//	defer backend.Close()
+//	m := memo.New(memo.WithBackend(backend))
+func New(path string, opts ...Option) (*Bolt, error) {
+	b := &Bolt{
+		path:         path,
+		bucket:       []byte("gomemo"),
+		expiryBucket: []byte("gomemo.expiry"),
+		codec:        backends.GobCodec(),
+		timeout:      time.Second,
+		cleanupEvery: defaultCleanupInterval,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	db, err := b.open(path)
+	if err != nil {
+		return nil, err
+	}
+	b.db = db
+
+	go b.maintain()
+	return b, nil
+}
+
+// open opens the file at path and creates the backend's buckets in it.
+func (b *Bolt) open(path string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: b.timeout})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(b.bucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(b.expiryBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create buckets: %w", err)
+	}
+	return db, nil
+}
+
+// maintain runs the periodic cleanup and compaction until Close.
+func (b *Bolt) maintain() {
+	defer close(b.done)
+	var cleanup, compact <-chan time.Time
+	if b.cleanupEvery > 0 {
+		t := time.NewTicker(b.cleanupEvery)
+		defer t.Stop()
+		cleanup = t.C
+	}
+	if b.compactEvery > 0 {
+		t := time.NewTicker(b.compactEvery)
+		defer t.Stop()
+		compact = t.C
+	}
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-cleanup:
+			b.Cleanup()
+		case <-compact:
+			if err := b.Compact(); err != nil {
+				b.onError("compact", err)
+			}
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Backend interface
+// -----------------------------------------------------------------------------
+
+func (b *Bolt) Get(key string) (any, bool) {
+	entry, ok := b.GetEntry(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// GetEntry retrieves the entry stored under key. Expired entries are misses
+// even before the cleanup removes them.
+func (b *Bolt) GetEntry(key string) (backends.CacheEntry, bool) {
+	var entry backends.CacheEntry
+	var found bool
+	err := b.view(func(tx *bbolt.Tx) error {
+		var err error
+		entry, found, err = b.read(tx, key, time.Now())
+		return err
+	})
+	if err != nil {
+		b.onError("get", err)
+		return backends.CacheEntry{}, false
+	}
+	return entry, found
+}
+
+func (b *Bolt) Set(key string, value any, ttl time.Duration) {
+	data, err := b.encode(backends.NewEntry(value, ttl, 0))
+	if err != nil {
+		b.onError("encode", err)
+		return
+	}
+	err = b.update(func(tx *bbolt.Tx) error {
+		return b.put(tx, key, data)
+	})
+	if err != nil {
+		b.onError("set", err)
+	}
+}
+
+func (b *Bolt) Delete(key string) {
+	err := b.update(func(tx *bbolt.Tx) error {
+		_, err := b.remove(tx, key)
+		return err
+	})
+	if err != nil {
+		b.onError("delete", err)
+	}
+}
+
+// Clear removes every entry by recreating the backend's buckets.
+func (b *Bolt) Clear() {
+	err := b.update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{b.bucket, b.expiryBucket} {
+			if err := tx.DeleteBucket(name); err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.onError("clear", err)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Optional capabilities
+// -----------------------------------------------------------------------------
+
+// Touch resets the TTL of an existing entry to ttl from now.
+// If TTL is 0 or negative, the entry will no longer expire.
+// Returns false if the key is missing or expired.
+func (b *Bolt) Touch(key string, ttl time.Duration) bool {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	var touched bool
+	err := b.update(func(tx *bbolt.Tx) error {
+		entry, ok, err := b.read(tx, key, time.Now())
+		if err != nil || !ok {
+			return err
+		}
+		entry.SetExpiresAt(expiresAt)
+		data, err := b.encode(entry)
+		if err != nil {
+			return err
+		}
+		touched = true
+		return b.put(tx, key, data)
+	})
+	if err != nil {
+		b.onError("touch", err)
+		return false
+	}
+	return touched
+}
+
+// Expire removes the entry stored under key, which for a persistent cache is
+// the same as letting it expire now.
+func (b *Bolt) Expire(key string) bool {
+	var expired bool
+	err := b.update(func(tx *bbolt.Tx) error {
+		_, ok, err := b.read(tx, key, time.Now())
+		if err != nil || !ok {
+			return err
+		}
+		expired, err = b.remove(tx, key)
+		return err
+	})
+	if err != nil {
+		b.onError("expire", err)
+		return false
+	}
+	return expired
+}
+
+// GetMulti retrieves the live values stored under keys in one transaction.
+func (b *Bolt) GetMulti(keys []string) map[string]any {
+	out := make(map[string]any, len(keys))
+	now := time.Now()
+	err := b.view(func(tx *bbolt.Tx) error {
+		for _, key := range keys {
+			entry, ok, err := b.read(tx, key, now)
+			if err != nil {
+				b.onError("decode", err)
+				continue
+			}
+			if ok {
+				out[key] = entry.Value
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.onError("get", err)
+	}
+	return out
+}
+
+// SetMulti stores all items in one transaction. Items that cannot be
+// encoded are skipped.
+func (b *Bolt) SetMulti(items []backends.BatchItem) {
+	err := b.update(func(tx *bbolt.Tx) error {
+		for _, it := range items {
+			data, err := b.encode(backends.NewEntry(it.Value, it.TTL, 0))
+			if err != nil {
+				b.onError("encode", err)
+				continue
+			}
+			if err := b.put(tx, it.Key, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.onError("set", err)
+	}
+}
+
+// DeleteMulti removes the values stored under keys in one transaction.
+func (b *Bolt) DeleteMulti(keys []string) {
+	err := b.update(func(tx *bbolt.Tx) error {
+		for _, key := range keys {
+			if _, err := b.remove(tx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.onError("delete", err)
+	}
+}
+
+// DeleteByPrefix removes every entry whose key starts with prefix. Keys are
+// sorted in the file, so only the matching range is visited.
+func (b *Bolt) DeleteByPrefix(prefix string) int {
+	var removed int
+	err := b.update(func(tx *bbolt.Tx) error {
+		var keys []string
+		c := tx.Bucket(b.bucket).Cursor()
+		for k, _ := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = c.Next() {
+			keys = append(keys, string(k))
+		}
+		for _, key := range keys {
+			if _, err := b.remove(tx, key); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		b.onError("delete by prefix", err)
+		return 0
+	}
+	return removed
+}
+
+// Stats returns the number of entries, including expired entries not yet
+// cleaned up, and the bytes of the database pages holding them. It walks
+// the bucket's pages, so its cost grows with the size of the file.
+func (b *Bolt) Stats() backends.Stats {
+	st := backends.Stats{Bytes: -1}
+	err := b.view(func(tx *bbolt.Tx) error {
+		bs := tx.Bucket(b.bucket).Stats()
+		st.Entries = int64(bs.KeyN)
+		st.Bytes = int64(bs.BranchInuse + bs.LeafInuse + bs.InlineBucketInuse)
+		return nil
+	})
+	if err != nil {
+		b.onError("stats", err)
+	}
+	return st
+}
+
+// Cleanup removes all expired entries and returns how many were removed.
+// It runs automatically, see WithCleanupInterval.
+func (b *Bolt) Cleanup() int {
+	var removed int
+	now := uint64(time.Now().UnixNano())
+	err := b.update(func(tx *bbolt.Tx) error {
+		var keys []string
+		c := tx.Bucket(b.expiryBucket).Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= now; k, _ = c.Next() {
+			keys = append(keys, string(k[expirySize:]))
+		}
+		for _, key := range keys {
+			if _, err := b.remove(tx, key); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return nil
+	})
+	if err != nil {
+		b.onError("cleanup", err)
+		return 0
+	}
+	return removed
+}
+
+// Compact rewrites the file without the space freed by removed entries, which
+// bbolt otherwise keeps for reuse. All other operations wait while it runs.
+func (b *Bolt) Compact() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.db == nil {
+		return bbolt.ErrDatabaseNotOpen
+	}
+
+	tmp := b.path + ".compact"
+	dst, err := bbolt.Open(tmp, 0o600, &bbolt.Options{Timeout: b.timeout})
+	if err != nil {
+		return fmt.Errorf("open %s: %w", tmp, err)
+	}
+	if err := bbolt.Compact(dst, b.db, compactTxSize); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := b.db.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	b.db = nil
+	if err := os.Rename(tmp, b.path); err != nil {
+		return errors.Join(err, b.reopenLocked())
+	}
+	return b.reopenLocked()
+}
+
+// reopenLocked reopens the file after Compact closed it.
+func (b *Bolt) reopenLocked() error {
+	db, err := b.open(b.path)
+	if err != nil {
+		return err
+	}
+	b.db = db
+	return nil
+}
+
+// Close stops the background cleanup and closes the file. Close is safe to
+// call more than once; the backend must not be used afterwards.
+func (b *Bolt) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.db != nil {
+			b.closeErr = b.db.Close()
+			b.db = nil
+		}
+	})
+	return b.closeErr
+}
+
+// -----------------------------------------------------------------------------
+// Storage
+// -----------------------------------------------------------------------------
+
+// view runs fn in a read-only transaction.
+func (b *Bolt) view(fn func(tx *bbolt.Tx) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.db == nil {
+		return bbolt.ErrDatabaseNotOpen
+	}
+	return b.db.View(fn)
+}
+
+// update runs fn in a read-write transaction.
+func (b *Bolt) update(fn func(tx *bbolt.Tx) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.db == nil {
+		return bbolt.ErrDatabaseNotOpen
+	}
+	return b.db.Update(fn)
+}
+
+// read returns the entry stored under key if it has not expired at now.
+func (b *Bolt) read(tx *bbolt.Tx, key string, now time.Time) (backends.CacheEntry, bool, error) {
+	data := tx.Bucket(b.bucket).Get([]byte(key))
+	if len(data) < expirySize {
+		return backends.CacheEntry{}, false, nil
+	}
+	if exp := binary.BigEndian.Uint64(data); exp != 0 && exp <= uint64(now.UnixNano()) {
+		return backends.CacheEntry{}, false, nil
+	}
+	entry, err := b.decode(data[expirySize:])
+	if err != nil {
+		return backends.CacheEntry{}, false, fmt.Errorf("decode %s: %w", key, err)
+	}
+	return entry, true, nil
+}
+
+// put stores data, as returned by encode, under key and indexes its expiry.
+func (b *Bolt) put(tx *bbolt.Tx, key string, data []byte) error {
+	if _, err := b.remove(tx, key); err != nil {
+		return err
+	}
+	if err := tx.Bucket(b.bucket).Put([]byte(key), data); err != nil {
+		return err
+	}
+	if exp := data[:expirySize]; binary.BigEndian.Uint64(exp) != 0 {
+		return tx.Bucket(b.expiryBucket).Put(expiryKey(exp, key), nil)
+	}
+	return nil
+}
+
+// remove deletes the entry stored under key and its expiry index entry.
+// Returns whether there was an entry.
+func (b *Bolt) remove(tx *bbolt.Tx, key string) (bool, error) {
+	entries := tx.Bucket(b.bucket)
+	old := entries.Get([]byte(key))
+	if old == nil {
+		return false, nil
+	}
+	if len(old) >= expirySize && binary.BigEndian.Uint64(old) != 0 {
+		if err := tx.Bucket(b.expiryBucket).Delete(expiryKey(old[:expirySize], key)); err != nil {
+			return false, err
+		}
+	}
+	return true, entries.Delete([]byte(key))
+}
+
+// expiryKey returns the index key of key expiring at exp, the big endian
+// expiry prefix of its value. Index keys sort by expiry.
+func expiryKey(exp []byte, key string) []byte {
+	k := make([]byte, 0, expirySize+len(key))
+	k = append(k, exp...)
+	return append(k, key...)
+}
+
+// encode serializes entry with the backend's codec, framed with a wire
+// header naming the codec and prefixed with the entry's expiry.
+func (b *Bolt) encode(entry backends.CacheEntry) ([]byte, error) {
+	payload, err := b.codec.Encode(backends.NewRecord(entry))
+	if err != nil {
+		return nil, err
+	}
+	var exp uint64
+	if t := entry.ExpiresAt(); !t.IsZero() {
+		exp = uint64(t.UnixNano())
+	}
+	data := binary.BigEndian.AppendUint64(make([]byte, 0, expirySize+wire.HeaderSize+len(payload)), exp)
+	return append(data, wire.Encode(wire.Header{Codec: b.codec.ID()}, payload)...), nil
+}
+
+// decode reverses encode, without the expiry prefix.
+func (b *Bolt) decode(data []byte) (backends.CacheEntry, error) {
+	hdr, payload, err := wire.Decode(data)
+	if err != nil {
+		return backends.CacheEntry{}, err
+	}
+	if hdr.Flags != 0 {
+		return backends.CacheEntry{}, fmt.Errorf("%w: unsupported flags %#x", wire.ErrUnknownFormat, hdr.Flags)
+	}
+	codec := b.codec
+	if hdr.Codec != codec.ID() {
+		if hdr.Codec != wire.CodecGob {
+			return backends.CacheEntry{}, fmt.Errorf("%w: unsupported codec %d", wire.ErrUnknownFormat, hdr.Codec)
+		}
+		codec = backends.GobCodec()
+	}
+
+	var rec backends.Record
+	if err := codec.Decode(payload, &rec); err != nil {
+		return backends.CacheEntry{}, err
+	}
+	return rec.Entry(), nil
+}
+
+// onError reports an error that cannot be returned to the caller.
+func (b *Bolt) onError(op string, err error) {
+	if b.errorHandler != nil {
+		b.errorHandler(op, err)
+		return
+	}
+	log.Printf("[gomemo][bolt] %s error: %v\n", op, err)
+}
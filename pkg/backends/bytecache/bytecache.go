@@ -0,0 +1,313 @@
+// Package bytecache provides an in-process cache backend that keeps entries
+// serialized in large preallocated byte slabs instead of a map of values.
+package bytecache
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+const (
+	// defaultCapacity is the total size of the slabs.
+	defaultCapacity = 64 << 20
+
+	// defaultShards is the number of independently locked slabs.
+	defaultShards = 64
+)
+
+// ByteCache is an in-process backend for very large caches. A map holding
+// millions of values gives the garbage collector millions of pointers to
+// trace, and GC pauses grow with it. ByteCache serializes every entry into
+// one of a fixed number of preallocated byte slabs and indexes them by key
+// hash, so the heap it adds holds no pointers and costs the collector
+// almost nothing to scan, in the manner of BigCache and freecache.
+//
+// The price is CPU: values are encoded on every write and decoded on every
+// read, so a fast codec such as backends.MsgpackCodec helps. Each slab is a
+// ring buffer: when it is full, new entries overwrite the oldest ones, so
+// the cache evicts in insertion order rather than by recency. Expired
+// entries are misses and their space is reclaimed the same way; there is no
+// sweep.
+type ByteCache struct {
+	shards    []*shard
+	mask      uint64 // len(shards)-1; the shard count is a power of two
+	capacity  int
+	codec     backends.Codec
+	evictions atomic.Uint64
+
+	errorHandler func(op string, err error) // receives encode and decode errors; nil logs them
+}
+
+var (
+	_ backends.EntryBackend  = (*ByteCache)(nil)
+	_ backends.Toucher       = (*ByteCache)(nil)
+	_ backends.Expirer       = (*ByteCache)(nil)
+	_ backends.PrefixDeleter = (*ByteCache)(nil)
+	_ backends.SizeReporter  = (*ByteCache)(nil)
+	_ backends.LenReporter   = (*ByteCache)(nil)
+	_ backends.StatsBackend  = (*ByteCache)(nil)
+)
+
+// Option configures a ByteCache backend.
+type Option func(*ByteCache)
+
+// WithCapacity sets the total size of the slabs in bytes, 64 MiB by default.
+// The memory is allocated up front. An entry larger than one shard's slab,
+// capacity divided by the shard count, is not stored.
+func WithCapacity(bytes int) Option {
+	return func(c *ByteCache) {
+		if bytes > 0 {
+			c.capacity = bytes
+		}
+	}
+}
+
+// WithShards sets how many independently locked slabs the capacity is split
+// into, rounded up to a power of two; the default is 64. More shards reduce
+// lock contention but lower the largest entry that fits.
+func WithShards(n int) Option {
+	return func(c *ByteCache) {
+		if n > 0 {
+			c.shards = make([]*shard, nextPowerOfTwo(n))
+		}
+	}
+}
+
+// WithCodec selects how entries are serialized. The default is
+// backends.GobCodec.
+func WithCodec(codec backends.Codec) Option {
+	return func(c *ByteCache) {
+		if codec != nil {
+			c.codec = codec
+		}
+	}
+}
+
+// WithErrorHandler passes values that fail to encode or decode to fn instead
+// of logging them with the standard log package. op is "set" or "get".
+func WithErrorHandler(fn func(op string, err error)) Option {
+	return func(c *ByteCache) {
+		c.errorHandler = fn
+	}
+}
+
+// New creates a byte cache and allocates its slabs.
+func New(opts ...Option) *ByteCache {
+	c := &ByteCache{
+		shards:   make([]*shard, defaultShards),
+		capacity: defaultCapacity,
+		codec:    backends.GobCodec(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	size := max(c.capacity/len(c.shards), headerSize)
+	for i := range c.shards {
+		c.shards[i] = newShard(size)
+	}
+	c.mask = uint64(len(c.shards) - 1)
+	return c
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// shardFor returns the hash of key and the shard holding it.
+func (c *ByteCache) shardFor(key string) (uint64, *shard) {
+	h := hashKey(key)
+	return h, c.shards[h&c.mask]
+}
+
+// hashKey is 64-bit FNV-1a, inlined to avoid allocating a hash.Hash per call.
+func hashKey(key string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+	return h
+}
+
+// Get retrieves a value from the cache by key.
+func (c *ByteCache) Get(key string) (any, bool) {
+	entry, ok := c.GetEntry(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// GetEntry retrieves the entry stored under key. Entries whose value cannot
+// be decoded are misses.
+func (c *ByteCache) GetEntry(key string) (backends.CacheEntry, bool) {
+	h, s := c.shardFor(key)
+	s.mu.RLock()
+	expiry, payload, ok := s.get(h, key)
+	s.mu.RUnlock()
+	if !ok || (expiry != 0 && expiry <= time.Now().UnixNano()) {
+		return backends.CacheEntry{}, false
+	}
+
+	var rec backends.Record
+	if err := c.codec.Decode(payload, &rec); err != nil {
+		c.onError("get", fmt.Errorf("decode %s: %w", key, err))
+		return backends.CacheEntry{}, false
+	}
+	// Touch and Expire rewrite the header, which makes it authoritative
+	rec.Expiry = expiry
+	return rec.Entry(), true
+}
+
+// Set stores a value in the cache with the given TTL. If TTL is 0 or
+// negative, the value does not expire, though it is still overwritten once
+// its slab fills up. Values the codec cannot encode are not stored.
+func (c *ByteCache) Set(key string, value any, ttl time.Duration) {
+	entry := backends.NewEntry(value, ttl, 0)
+	payload, err := c.codec.Encode(backends.NewRecord(entry))
+	if err != nil {
+		c.onError("set", fmt.Errorf("encode %s: %w", key, err))
+		c.Delete(key)
+		return
+	}
+	var expiry int64
+	if exp := entry.ExpiresAt(); !exp.IsZero() {
+		expiry = exp.UnixNano()
+	}
+
+	h, s := c.shardFor(key)
+	s.mu.Lock()
+	evicted, _ := s.set(h, key, expiry, payload)
+	s.mu.Unlock()
+	c.evictions.Add(uint64(evicted))
+}
+
+// Delete removes a value from the cache. Its space is reclaimed when the
+// slab wraps around.
+func (c *ByteCache) Delete(key string) {
+	h, s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.find(h, key); ok {
+		delete(s.index, h)
+	}
+}
+
+// Clear removes all values from the cache. The slabs stay allocated.
+func (c *ByteCache) Clear() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.reset()
+		s.mu.Unlock()
+	}
+}
+
+// Touch resets the TTL of an existing entry to ttl from now, in place.
+// If TTL is 0 or negative, the entry will no longer expire.
+// Returns false if the key is missing or expired.
+func (c *ByteCache) Touch(key string, ttl time.Duration) bool {
+	var expiry int64
+	if ttl > 0 {
+		expiry = time.Now().Add(ttl).UnixNano()
+	}
+	return c.rewriteExpiry(key, expiry)
+}
+
+// Expire makes an existing entry expire now.
+// Returns false if the key is missing or already expired.
+func (c *ByteCache) Expire(key string) bool {
+	return c.rewriteExpiry(key, time.Now().UnixNano())
+}
+
+// rewriteExpiry sets the expiry of a live entry.
+func (c *ByteCache) rewriteExpiry(key string, expiry int64) bool {
+	h, s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pos, ok := s.find(h, key)
+	if !ok {
+		return false
+	}
+	if exp := s.expiryAt(pos); exp != 0 && exp <= time.Now().UnixNano() {
+		return false
+	}
+	s.setExpiry(pos, expiry)
+	return true
+}
+
+// DeleteByPrefix removes every entry whose key starts with prefix.
+// It reads the key of every entry, one shard at a time.
+func (c *ByteCache) DeleteByPrefix(prefix string) int {
+	removed := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for h, pos := range s.index {
+			if strings.HasPrefix(s.keyAt(pos), prefix) {
+				delete(s.index, h)
+				removed++
+			}
+		}
+		s.mu.Unlock()
+	}
+	return removed
+}
+
+// Len returns the number of entries, including expired entries whose space
+// has not been reused yet.
+func (c *ByteCache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.RLock()
+		n += len(s.index)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Bytes returns how many bytes of the slabs are in use, including space
+// held by deleted and overwritten entries until it is reused.
+func (c *ByteCache) Bytes() int64 {
+	var n int64
+	for _, s := range c.shards {
+		s.mu.RLock()
+		n += int64(s.tail - s.head)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Stats returns the number of entries and the bytes of ring buffer they
+// occupy, as Len and Bytes do.
+func (c *ByteCache) Stats() backends.Stats {
+	return backends.Stats{Entries: int64(c.Len()), Bytes: c.Bytes()}
+}
+
+// Evictions returns how many live entries have been overwritten to make room
+// for new ones.
+func (c *ByteCache) Evictions() uint64 {
+	return c.evictions.Load()
+}
+
+// onError reports an error that cannot be returned to the caller.
+func (c *ByteCache) onError(op string, err error) {
+	if c.errorHandler != nil {
+		c.errorHandler(op, err)
+		return
+	}
+	log.Printf("[gomemo][bytecache] %s error: %v\n", op, err)
+}
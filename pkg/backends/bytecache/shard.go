@@ -0,0 +1,172 @@
+package bytecache
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// Every entry in a ring starts with a fixed header:
+//
+//	size    uint32  length of the whole entry, header included
+//	hash    uint64  hash of the key
+//	expiry  int64   unix nanoseconds; 0 means no expiration
+//	keyLen  uint32  length of the key that follows
+//
+// followed by the key and the encoded value.
+const (
+	offSize   = 0
+	offHash   = 4
+	offExpiry = 12
+	offKeyLen = 20
+
+	headerSize = 24
+)
+
+// shard is one lock domain of the cache. Entries are appended to a
+// fixed-size ring buffer; when it is full the oldest entries are overwritten.
+// Positions are absolute byte offsets that only grow, so an entry at pos
+// lives at ring[pos%len(ring)], possibly wrapping around the end.
+//
+// Neither the ring nor the index contain pointers, so the garbage collector
+// does not scan them however many entries they hold.
+type shard struct {
+	mu    sync.RWMutex
+	index map[uint64]uint64 // key hash -> position of its latest entry
+	ring  []byte
+	head  uint64 // position of the oldest entry
+	tail  uint64 // position of the next write
+}
+
+func newShard(size int) *shard {
+	return &shard{
+		index: make(map[uint64]uint64),
+		ring:  make([]byte, size),
+	}
+}
+
+// get returns the expiry and encoded value of key. Callers must hold s.mu.
+func (s *shard) get(h uint64, key string) (expiry int64, payload []byte, ok bool) {
+	pos, ok := s.find(h, key)
+	if !ok {
+		return 0, nil, false
+	}
+	var hdr [headerSize]byte
+	s.read(pos, hdr[:])
+	size := binary.LittleEndian.Uint32(hdr[offSize:])
+	payload = make([]byte, int(size)-headerSize-len(key))
+	s.read(pos+headerSize+uint64(len(key)), payload)
+	return int64(binary.LittleEndian.Uint64(hdr[offExpiry:])), payload, true
+}
+
+// find returns the position of key's entry. Keys whose hash collides with
+// another key's are misses. Callers must hold s.mu.
+func (s *shard) find(h uint64, key string) (uint64, bool) {
+	pos, ok := s.index[h]
+	if !ok {
+		return 0, false
+	}
+	var hdr [headerSize]byte
+	s.read(pos, hdr[:])
+	if int(binary.LittleEndian.Uint32(hdr[offKeyLen:])) != len(key) {
+		return 0, false
+	}
+	stored := make([]byte, len(key))
+	s.read(pos+headerSize, stored)
+	if string(stored) != key {
+		return 0, false
+	}
+	return pos, true
+}
+
+// keyAt returns the key of the entry at pos. Callers must hold s.mu.
+func (s *shard) keyAt(pos uint64) string {
+	var hdr [headerSize]byte
+	s.read(pos, hdr[:])
+	key := make([]byte, binary.LittleEndian.Uint32(hdr[offKeyLen:]))
+	s.read(pos+headerSize, key)
+	return string(key)
+}
+
+// set appends an entry for key, overwriting the oldest entries if the ring is
+// full, and returns how many live entries were overwritten. Entries larger
+// than the ring are not stored, and drop any previous entry for key.
+// Callers must hold s.mu for writing.
+func (s *shard) set(h uint64, key string, expiry int64, payload []byte) (evicted int, stored bool) {
+	size := headerSize + len(key) + len(payload)
+	if size > len(s.ring) {
+		if _, ok := s.find(h, key); ok {
+			delete(s.index, h)
+		}
+		return 0, false
+	}
+
+	for s.tail+uint64(size)-s.head > uint64(len(s.ring)) {
+		if s.evictOldest() {
+			evicted++
+		}
+	}
+
+	entry := make([]byte, size)
+	binary.LittleEndian.PutUint32(entry[offSize:], uint32(size))
+	binary.LittleEndian.PutUint64(entry[offHash:], h)
+	binary.LittleEndian.PutUint64(entry[offExpiry:], uint64(expiry))
+	binary.LittleEndian.PutUint32(entry[offKeyLen:], uint32(len(key)))
+	copy(entry[headerSize:], key)
+	copy(entry[headerSize+len(key):], payload)
+	s.write(s.tail, entry)
+
+	s.index[h] = s.tail
+	s.tail += uint64(size)
+	return evicted, true
+}
+
+// evictOldest drops the entry at the head of the ring and reports whether it
+// was still live rather than overwritten or deleted. Callers must hold s.mu
+// for writing.
+func (s *shard) evictOldest() bool {
+	var hdr [headerSize]byte
+	s.read(s.head, hdr[:])
+	h := binary.LittleEndian.Uint64(hdr[offHash:])
+	live := false
+	if pos, ok := s.index[h]; ok && pos == s.head {
+		delete(s.index, h)
+		live = true
+	}
+	s.head += uint64(binary.LittleEndian.Uint32(hdr[offSize:]))
+	return live
+}
+
+// setExpiry rewrites the expiry of the entry at pos in place. Callers must
+// hold s.mu for writing.
+func (s *shard) setExpiry(pos uint64, expiry int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(expiry))
+	s.write(pos+offExpiry, b[:])
+}
+
+// expiryAt returns the expiry of the entry at pos. Callers must hold s.mu.
+func (s *shard) expiryAt(pos uint64) int64 {
+	var b [8]byte
+	s.read(pos+offExpiry, b[:])
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// reset drops every entry. Callers must hold s.mu for writing.
+func (s *shard) reset() {
+	clear(s.index)
+	s.head, s.tail = 0, 0
+}
+
+// read copies len(dst) bytes starting at pos out of the ring.
+func (s *shard) read(pos uint64, dst []byte) {
+	off := int(pos % uint64(len(s.ring)))
+	n := copy(dst, s.ring[off:])
+	copy(dst[n:], s.ring)
+}
+
+// write copies src into the ring starting at pos.
+func (s *shard) write(pos uint64, src []byte) {
+	off := int(pos % uint64(len(s.ring)))
+	n := copy(s.ring[off:], src)
+	copy(s.ring, src[n:])
+}
@@ -16,6 +16,13 @@ type CacheEntry struct {
 
 	// version is a monotonic counter incremented on writes (useful for CAS/diffs).
 	version uint64
+
+	// stored is when the value was written, in unix nanoseconds; 0 if unknown.
+	stored int64
+
+	// accesses counts reads of the entry; nil unless access tracking is enabled.
+	// It is shared by copies of the entry.
+	accesses *atomic.Uint64
 }
 
 // NewEntry creates a CacheEntry with optional ttl.
@@ -28,7 +35,50 @@ func NewEntry(v any, ttl time.Duration, ver uint64) CacheEntry {
 		Value:   v,
 		expiry:  exp,
 		version: ver,
+		stored:  time.Now().UnixNano(),
+	}
+}
+
+// NewEntryAt creates a CacheEntry expiring at an absolute point in time.
+// A zero expiresAt means no expiration. It is used by backends restoring
+// entries from serialized form.
+func NewEntryAt(v any, expiresAt time.Time, ver uint64) CacheEntry {
+	var exp int64
+	if !expiresAt.IsZero() {
+		exp = expiresAt.UnixNano()
+	}
+	return CacheEntry{
+		Value:   v,
+		expiry:  exp,
+		version: ver,
+		stored:  time.Now().UnixNano(),
+	}
+}
+
+// StoredAt returns when the value was written, or the zero time if unknown.
+func (e *CacheEntry) StoredAt() time.Time {
+	if e.stored == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, e.stored)
+}
+
+// SetStoredAt replaces the write time, e.g. when restoring a serialized entry
+// or when the backend keeps its own clock. A zero storedAt means unknown.
+func (e *CacheEntry) SetStoredAt(storedAt time.Time) {
+	e.stored = 0
+	if !storedAt.IsZero() {
+		e.stored = storedAt.UnixNano()
+	}
+}
+
+// ExpiresAt returns the absolute expiration time, or the zero time if the entry never expires.
+func (e *CacheEntry) ExpiresAt() time.Time {
+	exp := atomic.LoadInt64(&e.expiry)
+	if exp == 0 {
+		return time.Time{}
 	}
+	return time.Unix(0, exp)
 }
 
 // IsExpired returns true if the entry's TTL has elapsed.
@@ -40,6 +90,16 @@ func (e *CacheEntry) IsExpired() bool {
 	return time.Now().UnixNano() > exp
 }
 
+// ExpiredAt reports whether the entry is expired at the given time.
+// It lets backends with their own notion of time (e.g. a fake clock) check expiry.
+func (e *CacheEntry) ExpiredAt(now time.Time) bool {
+	exp := atomic.LoadInt64(&e.expiry)
+	if exp == 0 {
+		return false
+	}
+	return now.UnixNano() > exp
+}
+
 // TTLRemaining returns the remaining duration until expiration, or zero if expired or no TTL.
 func (e *CacheEntry) TTLRemaining() time.Duration {
 	exp := atomic.LoadInt64(&e.expiry)
@@ -63,6 +123,16 @@ func (e *CacheEntry) SetExpiry(ttl time.Duration) {
 	atomic.StoreInt64(&e.expiry, exp)
 }
 
+// SetExpiresAt replaces the expiry with an absolute point in time atomically.
+// A zero expiresAt means no expiration.
+func (e *CacheEntry) SetExpiresAt(expiresAt time.Time) {
+	var exp int64
+	if !expiresAt.IsZero() {
+		exp = expiresAt.UnixNano()
+	}
+	atomic.StoreInt64(&e.expiry, exp)
+}
+
 // Version returns the entry's version.
 func (e *CacheEntry) Version() uint64 {
 	return atomic.LoadUint64(&e.version)
@@ -72,3 +142,27 @@ func (e *CacheEntry) Version() uint64 {
 func (e *CacheEntry) BumpVersion() uint64 {
 	return atomic.AddUint64(&e.version, 1)
 }
+
+// TrackAccesses enables access counting for the entry.
+// Backends call it on write when access tracking is enabled.
+func (e *CacheEntry) TrackAccesses() {
+	if e.accesses == nil {
+		e.accesses = new(atomic.Uint64)
+	}
+}
+
+// RecordAccess increments the access counter if access tracking is enabled.
+func (e *CacheEntry) RecordAccess() {
+	if e.accesses != nil {
+		e.accesses.Add(1)
+	}
+}
+
+// Accesses returns how many times the entry has been read,
+// or zero if access tracking is not enabled.
+func (e *CacheEntry) Accesses() uint64 {
+	if e.accesses == nil {
+		return 0
+	}
+	return e.accesses.Load()
+}
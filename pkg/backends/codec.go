@@ -0,0 +1,81 @@
+package backends
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/ldaidone/gomemo/internals/wire"
+)
+
+// Codec serializes cache entries for backends that store bytes, such as Redis.
+type Codec interface {
+	// ID identifies the codec in the wire header of every entry it encodes,
+	// so readers can tell which codec wrote an entry. IDs below 64 are
+	// reserved for codecs shipped with gomemo.
+	ID() wire.CodecID
+
+	// Encode serializes v, which is a Record.
+	Encode(v any) ([]byte, error)
+
+	// Decode deserializes data into v, which is a *Record.
+	Decode(data []byte, v any) error
+}
+
+// Record is the form in which serializing backends hand entries to a Codec.
+// CacheEntry keeps its metadata unexported, so it is copied here to make
+// sure the logical expiry survives the round trip.
+type Record struct {
+	Value   any    `json:"value" msgpack:"value"`
+	Expiry  int64  `json:"expiry,omitempty" msgpack:"expiry,omitempty"`   // unix nanoseconds; 0 means no expiration
+	Version uint64 `json:"version,omitempty" msgpack:"version,omitempty"` // see CacheEntry.Version
+	Stored  int64  `json:"stored,omitempty" msgpack:"stored,omitempty"`   // unix nanoseconds; 0 if unknown
+}
+
+// NewRecord copies entry into a Record.
+func NewRecord(entry CacheEntry) Record {
+	return Record{
+		Value:   entry.Value,
+		Expiry:  entry.expiry,
+		Version: entry.version,
+		Stored:  entry.stored,
+	}
+}
+
+// Entry converts the record back into a CacheEntry.
+func (r Record) Entry() CacheEntry {
+	var expiresAt time.Time
+	if r.Expiry != 0 {
+		expiresAt = time.Unix(0, r.Expiry)
+	}
+	entry := NewEntryAt(r.Value, expiresAt, r.Version)
+	entry.stored = r.Stored
+	return entry
+}
+
+// GobCodec returns the codec encoding entries with encoding/gob. It is the
+// default of the Redis backend. Gob only decodes values stored in an
+// interface if their concrete type was registered with gob.Register, which
+// memo.WithAutoGobRegister does for computed values, and it is Go-only.
+func GobCodec() Codec {
+	return gobCodec{}
+}
+
+// gobCodec implements GobCodec.
+type gobCodec struct{}
+
+func (gobCodec) ID() wire.CodecID {
+	return wire.CodecGob
+}
+
+func (gobCodec) Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
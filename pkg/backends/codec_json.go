@@ -0,0 +1,85 @@
+package backends
+
+import (
+	"encoding/json"
+
+	"github.com/ldaidone/gomemo/internals/wire"
+)
+
+// JSONOption configures JSONCodec.
+type JSONOption func(*jsonCodec)
+
+// WithJSONType decodes cached values into T instead of the generic types
+// encoding/json produces for an any (map[string]any, []any, float64, ...).
+// Use it when a backend only holds values of one type, e.g. a Redis prefix
+// dedicated to one kind of object.
+func WithJSONType[T any]() JSONOption {
+	return func(c *jsonCodec) {
+		c.decodeValue = func(data []byte) (any, error) {
+			var v T
+			err := json.Unmarshal(data, &v)
+			return v, err
+		}
+	}
+}
+
+// JSONCodec returns a codec encoding entries with encoding/json, so values
+// can be shared with services written in other languages.
+//
+// An entry is stored as the four-byte wire header (0xA7, 0x01, 0x02, 0x00)
+// followed by an object such as
+//
+//	{"value": {...}, "expiry": 1700000000000000000, "version": 1, "stored": 1690000000000000000}
+//
+// where expiry and stored are unix nanoseconds and may be omitted. Foreign
+// writers must prepend the same header. Without WithJSONType, values decode
+// into the generic types encoding/json uses for an any.
+func JSONCodec(opts ...JSONOption) Codec {
+	c := &jsonCodec{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// jsonCodec implements JSONCodec.
+type jsonCodec struct {
+	decodeValue func(data []byte) (any, error) // nil decodes into any
+}
+
+// jsonRecord is a Record whose value is decoded separately.
+type jsonRecord struct {
+	Value   json.RawMessage `json:"value"`
+	Expiry  int64           `json:"expiry,omitempty"`
+	Version uint64          `json:"version,omitempty"`
+	Stored  int64           `json:"stored,omitempty"`
+}
+
+func (c *jsonCodec) ID() wire.CodecID {
+	return wire.CodecJSON
+}
+
+func (c *jsonCodec) Encode(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *jsonCodec) Decode(data []byte, v any) error {
+	rec, ok := v.(*Record)
+	if !ok || c.decodeValue == nil {
+		return json.Unmarshal(data, v)
+	}
+
+	var raw jsonRecord
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Value == nil {
+		raw.Value = json.RawMessage("null")
+	}
+	value, err := c.decodeValue(raw.Value)
+	if err != nil {
+		return err
+	}
+	*rec = Record{Value: value, Expiry: raw.Expiry, Version: raw.Version, Stored: raw.Stored}
+	return nil
+}
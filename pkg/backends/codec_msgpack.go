@@ -0,0 +1,77 @@
+package backends
+
+import (
+	"bytes"
+
+	"github.com/ldaidone/gomemo/internals/wire"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgpackOption configures MsgpackCodec.
+type MsgpackOption func(*msgpackCodec)
+
+// WithMsgpackType decodes cached values into T instead of the generic types
+// MessagePack produces for an any (map[string]any, []any, int64, ...).
+func WithMsgpackType[T any]() MsgpackOption {
+	return func(c *msgpackCodec) {
+		c.decodeValue = func(data []byte) (any, error) {
+			var v T
+			err := msgpack.Unmarshal(data, &v)
+			return v, err
+		}
+	}
+}
+
+// MsgpackCodec returns a codec encoding entries with MessagePack, which is
+// more compact and faster than gob or JSON and also readable from other
+// languages. Entries use the same field names as JSONCodec. Without
+// WithMsgpackType, values decode into generic types, with integers as int64
+// or uint64 and floats as float64.
+func MsgpackCodec(opts ...MsgpackOption) Codec {
+	c := &msgpackCodec{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// msgpackCodec implements MsgpackCodec.
+type msgpackCodec struct {
+	decodeValue func(data []byte) (any, error) // nil decodes into any
+}
+
+// msgpackRecord is a Record whose value is decoded separately.
+type msgpackRecord struct {
+	Value   msgpack.RawMessage `msgpack:"value"`
+	Expiry  int64              `msgpack:"expiry,omitempty"`
+	Version uint64             `msgpack:"version,omitempty"`
+	Stored  int64              `msgpack:"stored,omitempty"`
+}
+
+func (c *msgpackCodec) ID() wire.CodecID {
+	return wire.CodecMsgpack
+}
+
+func (c *msgpackCodec) Encode(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (c *msgpackCodec) Decode(data []byte, v any) error {
+	rec, ok := v.(*Record)
+	if !ok || c.decodeValue == nil {
+		dec := msgpack.NewDecoder(bytes.NewReader(data))
+		dec.UseLooseInterfaceDecoding(true)
+		return dec.Decode(v)
+	}
+
+	var raw msgpackRecord
+	if err := msgpack.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	value, err := c.decodeValue(raw.Value)
+	if err != nil {
+		return err
+	}
+	*rec = Record{Value: value, Expiry: raw.Expiry, Version: raw.Version, Stored: raw.Stored}
+	return nil
+}
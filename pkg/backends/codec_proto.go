@@ -0,0 +1,143 @@
+package backends
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ldaidone/gomemo/internals/wire"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// ErrNotProto is returned by ProtoCodec for values that are not proto.Message.
+var ErrNotProto = errors.New("value is not a proto.Message")
+
+// ProtoOption configures ProtoCodec.
+type ProtoOption func(*protoCodec)
+
+// WithProtoTypes resolves type URLs with types instead of
+// protoregistry.GlobalTypes, where generated code registers its messages.
+func WithProtoTypes(types protoregistry.MessageTypeResolver) ProtoOption {
+	return func(c *protoCodec) {
+		c.types = types
+	}
+}
+
+// ProtoCodec returns a codec for proto.Message values. Each value is stored
+// with its type URL, like google.protobuf.Any, and restored to its concrete
+// message type by looking the URL up in a type registry. Values that are not
+// proto.Message fail to encode with ErrNotProto and are not cached.
+func ProtoCodec(opts ...ProtoOption) Codec {
+	c := &protoCodec{types: protoregistry.GlobalTypes}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// protoCodec implements ProtoCodec.
+type protoCodec struct {
+	types protoregistry.MessageTypeResolver
+}
+
+// Field numbers of the envelope protoCodec writes for a Record.
+const (
+	protoTypeURL protowire.Number = 1
+	protoValue   protowire.Number = 2
+	protoExpiry  protowire.Number = 3
+	protoVersion protowire.Number = 4
+	protoStored  protowire.Number = 5
+)
+
+// protoURLPrefix is the type URL prefix used by google.protobuf.Any.
+const protoURLPrefix = "type.googleapis.com/"
+
+func (c *protoCodec) ID() wire.CodecID {
+	return wire.CodecProto
+}
+
+func (c *protoCodec) Encode(v any) ([]byte, error) {
+	rec, ok := v.(Record)
+	if !ok {
+		return nil, fmt.Errorf("proto codec: cannot encode %T", v)
+	}
+	msg, ok := rec.Value.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotProto, rec.Value)
+	}
+	value, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	b = protowire.AppendTag(b, protoTypeURL, protowire.BytesType)
+	b = protowire.AppendString(b, protoURLPrefix+string(msg.ProtoReflect().Descriptor().FullName()))
+	b = protowire.AppendTag(b, protoValue, protowire.BytesType)
+	b = protowire.AppendBytes(b, value)
+	for _, f := range []struct {
+		num protowire.Number
+		val uint64
+	}{{protoExpiry, uint64(rec.Expiry)}, {protoVersion, rec.Version}, {protoStored, uint64(rec.Stored)}} {
+		if f.val != 0 {
+			b = protowire.AppendTag(b, f.num, protowire.VarintType)
+			b = protowire.AppendVarint(b, f.val)
+		}
+	}
+	return b, nil
+}
+
+func (c *protoCodec) Decode(data []byte, v any) error {
+	rec, ok := v.(*Record)
+	if !ok {
+		return fmt.Errorf("proto codec: cannot decode into %T", v)
+	}
+
+	var out Record
+	var typeURL string
+	var value []byte
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch {
+		case num == protoTypeURL && typ == protowire.BytesType:
+			typeURL, n = protowire.ConsumeString(data)
+		case num == protoValue && typ == protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		case typ == protowire.VarintType:
+			var x uint64
+			x, n = protowire.ConsumeVarint(data)
+			switch num {
+			case protoExpiry:
+				out.Expiry = int64(x)
+			case protoVersion:
+				out.Version = x
+			case protoStored:
+				out.Stored = int64(x)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+
+	mt, err := c.types.FindMessageByURL(typeURL)
+	if err != nil {
+		return fmt.Errorf("proto codec: %s: %w", typeURL, err)
+	}
+	msg := mt.New().Interface()
+	if err := proto.Unmarshal(value, msg); err != nil {
+		return err
+	}
+	out.Value = msg
+	*rec = out
+	return nil
+}
@@ -0,0 +1,172 @@
+package backends
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// MaxDecompressedSize caps the size an entry may decompress to, so that a
+// small corrupt or hostile payload cannot expand into gigabytes in memory.
+const MaxDecompressedSize = 256 << 20
+
+// ErrDecompressedTooLarge is returned by the built-in compressors when a
+// payload would decompress to more than MaxDecompressedSize bytes.
+var ErrDecompressedTooLarge = errors.New("decompressed entry too large")
+
+// Compressor compresses serialized entries before a backend stores them.
+type Compressor interface {
+	// ID identifies the algorithm in front of every compressed payload, so
+	// readers can tell how an entry was compressed. IDs below 64 are
+	// reserved for compressors shipped with gomemo.
+	ID() byte
+
+	// Compress returns the compressed form of data.
+	Compress(data []byte) ([]byte, error)
+
+	// Decompress reverses Compress.
+	Decompress(data []byte) ([]byte, error)
+}
+
+// IDs of the compressors shipped with gomemo.
+const (
+	CompressorGzip   byte = 1 // GzipCompressor
+	CompressorSnappy byte = 2 // SnappyCompressor
+	CompressorZstd   byte = 3 // ZstdCompressor
+)
+
+// BuiltinCompressor returns a compressor able to decompress payloads
+// written by the built-in compressor with the given ID, or nil if there is
+// none. Backends use it to read entries compressed with another algorithm
+// than the one they are configured with.
+func BuiltinCompressor(id byte) Compressor {
+	switch id {
+	case CompressorGzip:
+		return GzipCompressor(gzip.DefaultCompression)
+	case CompressorSnappy:
+		return SnappyCompressor()
+	case CompressorZstd:
+		return ZstdCompressor(0)
+	}
+	return nil
+}
+
+// GzipCompressor returns a Compressor using compress/gzip at the given level,
+// e.g. gzip.BestSpeed. Invalid levels fall back to gzip.DefaultCompression.
+func GzipCompressor(level int) Compressor {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	return gzipCompressor{level: level}
+}
+
+// gzipCompressor implements GzipCompressor.
+type gzipCompressor struct {
+	level int
+}
+
+func (gzipCompressor) ID() byte {
+	return CompressorGzip
+}
+
+func (g gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, g.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > MaxDecompressedSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrDecompressedTooLarge, MaxDecompressedSize)
+	}
+	return out, nil
+}
+
+// SnappyCompressor returns a Compressor using snappy, which compresses less
+// than gzip but is several times faster in both directions.
+func SnappyCompressor() Compressor {
+	return snappyCompressor{}
+}
+
+// snappyCompressor implements SnappyCompressor.
+type snappyCompressor struct{}
+
+func (snappyCompressor) ID() byte {
+	return CompressorSnappy
+}
+
+func (snappyCompressor) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (snappyCompressor) Decompress(data []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if n > MaxDecompressedSize {
+		return nil, fmt.Errorf("%w: %d > %d bytes", ErrDecompressedTooLarge, n, MaxDecompressedSize)
+	}
+	return snappy.Decode(nil, data)
+}
+
+// ZstdCompressor returns a Compressor using zstd at the given level, from 1
+// (fastest) to 22 (smallest) as with the zstd command; levels outside that
+// range use the default level. zstd compresses about as well as gzip at a
+// fraction of its cost.
+func ZstdCompressor(level int) Compressor {
+	encLevel := zstd.SpeedDefault
+	if level >= 1 && level <= 22 {
+		encLevel = zstd.EncoderLevelFromZstd(level)
+	}
+	// Neither constructor fails with these options.
+	enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(encLevel), zstd.WithEncoderConcurrency(1))
+	dec, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(MaxDecompressedSize))
+	return zstdCompressor{enc: enc, dec: dec}
+}
+
+// zstdCompressor implements ZstdCompressor. EncodeAll and DecodeAll are safe
+// for concurrent use, so one encoder and decoder serve every call.
+type zstdCompressor struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+}
+
+func (zstdCompressor) ID() byte {
+	return CompressorZstd
+}
+
+func (z zstdCompressor) Compress(data []byte) ([]byte, error) {
+	return z.enc.EncodeAll(data, nil), nil
+}
+
+func (z zstdCompressor) Decompress(data []byte) ([]byte, error) {
+	out, err := z.dec.DecodeAll(data, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, fmt.Errorf("%w: %w", ErrDecompressedTooLarge, err)
+	}
+	return out, err
+}
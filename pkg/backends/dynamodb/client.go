@@ -0,0 +1,75 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrConditionFailed is returned by Client.PutItem when the item does not
+// meet the write's Condition. Adapters map DynamoDB's
+// ConditionalCheckFailedException to it.
+var ErrConditionFailed = errors.New("condition failed")
+
+// Client is the part of DynamoDB the backend uses. It is kept small so the
+// backend does not pin an AWS SDK version: an adapter over the SDK's
+// dynamodb.Client is a few lines per method, and tests can use an in-memory
+// fake. Every call names the table and attributes to use in Table.
+type Client interface {
+	// GetItem returns the item stored under key, or nil if there is none.
+	// Reads are strongly consistent if t.ConsistentRead is set.
+	GetItem(ctx context.Context, t Table, key string) (*Item, error)
+
+	// PutItem stores item if it meets cond, e.g. with a
+	// ConditionExpression, and returns ErrConditionFailed otherwise.
+	PutItem(ctx context.Context, t Table, item Item, cond Condition) error
+
+	// DeleteItem removes the item stored under key. Deleting a missing
+	// item is not an error.
+	DeleteItem(ctx context.Context, t Table, key string) error
+
+	// BatchGetItems returns the items stored under keys, in any order,
+	// leaving out missing ones. The backend passes at most 100 keys, the
+	// BatchGetItem limit; adapters retry unprocessed keys.
+	BatchGetItems(ctx context.Context, t Table, keys []string) ([]Item, error)
+
+	// BatchWriteItems stores puts and removes deletes. The backend passes
+	// at most 25 writes, the BatchWriteItem limit; adapters retry
+	// unprocessed items.
+	BatchWriteItems(ctx context.Context, t Table, puts []Item, deletes []string) error
+
+	// ScanKeys calls fn with pages of the keys starting with prefix, e.g.
+	// from a Scan with a begins_with filter projecting only the key.
+	ScanKeys(ctx context.Context, t Table, prefix string, fn func(keys []string) error) error
+}
+
+// Table names the table and attributes the backend stores entries in.
+type Table struct {
+	Name             string // table name
+	PartitionKey     string // string partition key holding the cache key
+	ValueAttribute   string // binary attribute holding the encoded entry
+	TTLAttribute     string // number attribute configured as the table's TTL, in unix seconds
+	VersionAttribute string // number attribute holding the entry's version
+	ConsistentRead   bool   // use strongly consistent reads
+}
+
+// Item is a cache entry as stored in DynamoDB.
+type Item struct {
+	Key     string // partition key value, including the backend's prefix
+	Value   []byte // encoded entry
+	Expires int64  // unix seconds at which DynamoDB may delete the item; 0 for never
+	Version uint64 // see backends.AtomicBackend
+}
+
+// Condition restricts PutItem. The zero Condition always matches.
+type Condition struct {
+	// Absent requires that there is no item under the key, or that its TTL
+	// attribute lies in the past: DynamoDB deletes expired items only
+	// eventually, e.g. attribute_not_exists(pk) OR ttl < :now.
+	Absent bool
+
+	// Version, if not nil, requires the stored item to have this version.
+	Version *uint64
+
+	// Value, if not nil, requires the stored item to hold exactly these bytes.
+	Value []byte
+}
@@ -0,0 +1,570 @@
+// Package dynamodb provides an AWS DynamoDB cache backend.
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ldaidone/gomemo/internals/wire"
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// DynamoDB is a cache backend storing entries as items of a DynamoDB table,
+// for serverless deployments that want a managed, multi-AZ cache store.
+//
+// Each entry is one item: the cache key, prefixed, in the partition key, the
+// encoded entry in a binary attribute, a version number and the expiry in
+// the attribute configured as the table's TTL, so DynamoDB deletes expired
+// items on its own. Since that deletion can lag by days, expiry is also
+// checked on every read. Versions and conditional writes make the backend a
+// backends.AtomicBackend.
+//
+// The backend talks to DynamoDB through Client, which an application
+// implements on top of the AWS SDK.
+type DynamoDB struct {
+	client       Client
+	table        Table
+	prefix       string                     // prepended to every key
+	codec        backends.Codec             // serializes entries; gob unless WithCodec is given
+	errorHandler func(op string, err error) // receives errors that cannot be returned; nil logs them
+}
+
+var (
+	_ backends.EntryBackend  = (*DynamoDB)(nil)
+	_ backends.Toucher       = (*DynamoDB)(nil)
+	_ backends.BatchBackend  = (*DynamoDB)(nil)
+	_ backends.PrefixDeleter = (*DynamoDB)(nil)
+	_ backends.AtomicBackend = (*DynamoDB)(nil)
+	_ backends.V2Provider    = (*DynamoDB)(nil)
+)
+
+const (
+	// maxBatchGet is the most keys one BatchGetItem call accepts.
+	maxBatchGet = 100
+
+	// maxBatchWrite is the most writes one BatchWriteItem call accepts.
+	maxBatchWrite = 25
+
+	// touchAttempts bounds how often Touch retries when the item keeps
+	// changing between reading and rewriting it.
+	touchAttempts = 3
+)
+
+// Option configures a DynamoDB backend.
+type Option func(*DynamoDB)
+
+// WithPartitionKey sets the name of the table's string partition key.
+// The default is "key".
+func WithPartitionKey(name string) Option {
+	return func(d *DynamoDB) {
+		d.table.PartitionKey = name
+	}
+}
+
+// WithValueAttribute sets the name of the binary attribute holding entries.
+// The default is "value".
+func WithValueAttribute(name string) Option {
+	return func(d *DynamoDB) {
+		d.table.ValueAttribute = name
+	}
+}
+
+// WithTTLAttribute sets the name of the attribute holding the expiry, which
+// should be enabled as the table's time to live attribute. The default is
+// "expires_at".
+func WithTTLAttribute(name string) Option {
+	return func(d *DynamoDB) {
+		d.table.TTLAttribute = name
+	}
+}
+
+// WithVersionAttribute sets the name of the attribute holding entry versions.
+// The default is "version".
+func WithVersionAttribute(name string) Option {
+	return func(d *DynamoDB) {
+		d.table.VersionAttribute = name
+	}
+}
+
+// WithConsistentRead makes reads strongly consistent, so a read always sees
+// the latest write, at twice the read capacity cost.
+func WithConsistentRead() Option {
+	return func(d *DynamoDB) {
+		d.table.ConsistentRead = true
+	}
+}
+
+// WithPrefix prepends prefix to every key, so several caches can share a
+// table. Clear only removes keys under the prefix.
+func WithPrefix(prefix string) Option {
+	return func(d *DynamoDB) {
+		d.prefix = prefix
+	}
+}
+
+// WithCodec selects how entries are serialized. The default is
+// backends.GobCodec. Entries written with gob stay readable after switching
+// to another codec.
+func WithCodec(c backends.Codec) Option {
+	return func(d *DynamoDB) {
+		if c != nil {
+			d.codec = c
+		}
+	}
+}
+
+// WithErrorHandler passes errors the Backend interface cannot return, such as
+// failed writes, to fn instead of logging them with the standard log package.
+// op names the failed operation, e.g. "set". The context-aware form returned
+// by V2 returns errors instead.
+func WithErrorHandler(fn func(op string, err error)) Option {
+	return func(d *DynamoDB) {
+		d.errorHandler = fn
+	}
+}
+
+// New creates a backend storing entries in table through client. The
+// memoizer uses the backend's context-aware form automatically, so the
+// context passed to Memoizer.Get bounds the DynamoDB calls made for it.
+//
+// Example:
+//
+//	// client adapts the AWS SDK's DynamoDB client to dynamodb.Client
+//	backend := dynamodb.New(client, "cache", dynamodb.WithPrefix("reports:"))
+//	m := memo.New(memo.WithBackend(backend))
+func New(client Client, table string, opts ...Option) *DynamoDB {
+	d := &DynamoDB{
+		client: client,
+		table: Table{
+			Name:             table,
+			PartitionKey:     "key",
+			ValueAttribute:   "value",
+			TTLAttribute:     "expires_at",
+			VersionAttribute: "version",
+		},
+		codec: backends.GobCodec(),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// V2 returns the context-aware form of the backend, which also implements
+// backends.BatchBackendV2.
+func (d *DynamoDB) V2() backends.BackendV2 {
+	return contextBackend{d}
+}
+
+// -----------------------------------------------------------------------------
+// Backend interface
+// -----------------------------------------------------------------------------
+
+func (d *DynamoDB) Get(key string) (any, bool) {
+	entry, ok := d.GetEntry(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// GetEntry retrieves the entry stored under key. Items past their expiry
+// are misses even if DynamoDB has not deleted them yet.
+func (d *DynamoDB) GetEntry(key string) (backends.CacheEntry, bool) {
+	_, entry, ok, err := d.getItem(context.Background(), key)
+	if err != nil {
+		d.onError("get", err)
+		return backends.CacheEntry{}, false
+	}
+	return entry, ok
+}
+
+func (d *DynamoDB) Set(key string, value any, ttl time.Duration) {
+	if err := d.set(context.Background(), key, value, ttl); err != nil {
+		d.onError("set", err)
+	}
+}
+
+func (d *DynamoDB) Delete(key string) {
+	if err := d.client.DeleteItem(context.Background(), d.table, d.prefixed(key)); err != nil {
+		d.onError("delete", err)
+	}
+}
+
+// Clear removes every item under the backend's prefix. It scans the table,
+// which reads every item, so it is meant for maintenance rather than as part
+// of request handling.
+func (d *DynamoDB) Clear() {
+	if _, err := d.deleteByPrefix(context.Background(), ""); err != nil {
+		d.onError("clear", err)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Optional capabilities
+// -----------------------------------------------------------------------------
+
+// Touch resets the TTL of an existing entry to ttl from now. The item is
+// rewritten only if it is unchanged, so a concurrent Set or Delete is never
+// undone. Returns false if the key is missing or expired.
+func (d *DynamoDB) Touch(key string, ttl time.Duration) bool {
+	_, ok, err := d.touch(context.Background(), key, ttl)
+	if err != nil {
+		d.onError("touch", err)
+	}
+	return ok
+}
+
+// GetAndTouch retrieves the value stored under key and resets its TTL to ttl
+// from now, like Touch.
+func (d *DynamoDB) GetAndTouch(key string, ttl time.Duration) (any, bool) {
+	entry, ok, err := d.touch(context.Background(), key, ttl)
+	if err != nil {
+		d.onError("touch", err)
+	}
+	return entry.Value, ok
+}
+
+// CompareAndSet stores value under key with a conditional write that only
+// succeeds if the stored item still has the given version.
+func (d *DynamoDB) CompareAndSet(key string, value any, ttl time.Duration, version uint64) bool {
+	err := d.put(context.Background(), key, backends.NewEntry(value, ttl, version+1), Condition{Version: &version})
+	if err != nil && !errors.Is(err, ErrConditionFailed) {
+		d.onError("compare and set", err)
+	}
+	return err == nil
+}
+
+// SetIfAbsent stores value under key with a conditional write that only
+// succeeds if there is no live item under key.
+func (d *DynamoDB) SetIfAbsent(key string, value any, ttl time.Duration) bool {
+	ok, err := d.setIfAbsent(context.Background(), key, value, ttl)
+	if err != nil {
+		d.onError("set if absent", err)
+	}
+	return ok
+}
+
+// GetMulti retrieves the values stored under keys with BatchGetItem calls of
+// up to 100 keys.
+func (d *DynamoDB) GetMulti(keys []string) map[string]any {
+	out, err := d.getMulti(context.Background(), keys)
+	if err != nil {
+		d.onError("get", err)
+	}
+	return out
+}
+
+// SetMulti stores all items with BatchWriteItem calls of up to 25 items.
+func (d *DynamoDB) SetMulti(items []backends.BatchItem) {
+	if err := d.setMulti(context.Background(), items); err != nil {
+		d.onError("set", err)
+	}
+}
+
+// DeleteMulti removes the values stored under keys with BatchWriteItem calls
+// of up to 25 keys.
+func (d *DynamoDB) DeleteMulti(keys []string) {
+	if err := d.deleteMulti(context.Background(), keys); err != nil {
+		d.onError("delete", err)
+	}
+}
+
+// DeleteByPrefix removes every entry whose key starts with prefix and returns
+// how many were removed. Like Clear, it scans the table.
+func (d *DynamoDB) DeleteByPrefix(prefix string) int {
+	n, err := d.deleteByPrefix(context.Background(), prefix)
+	if err != nil {
+		d.onError("delete by prefix", err)
+	}
+	return n
+}
+
+// -----------------------------------------------------------------------------
+// Operations
+// -----------------------------------------------------------------------------
+
+// getItem returns the item stored under key and its decoded entry, if it is
+// present and not expired.
+func (d *DynamoDB) getItem(ctx context.Context, key string) (*Item, backends.CacheEntry, bool, error) {
+	item, err := d.client.GetItem(ctx, d.table, d.prefixed(key))
+	if err != nil || item == nil {
+		return nil, backends.CacheEntry{}, false, err
+	}
+	entry, err := d.decode(item.Value)
+	if err != nil {
+		return nil, backends.CacheEntry{}, false, fmt.Errorf("decode %s: %w", key, err)
+	}
+	if entry.IsExpired() {
+		return nil, backends.CacheEntry{}, false, nil
+	}
+	return item, entry, true, nil
+}
+
+// set stores value under key unconditionally.
+func (d *DynamoDB) set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return d.put(ctx, key, backends.NewEntry(value, ttl, 0), Condition{})
+}
+
+// put stores entry under key if cond holds.
+func (d *DynamoDB) put(ctx context.Context, key string, entry backends.CacheEntry, cond Condition) error {
+	item, err := d.item(key, entry)
+	if err != nil {
+		return err
+	}
+	return d.client.PutItem(ctx, d.table, item, cond)
+}
+
+// setIfAbsent implements SetIfAbsent, reporting failures instead of logging
+// them.
+func (d *DynamoDB) setIfAbsent(ctx context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	entry := backends.NewEntry(value, ttl, 0)
+	err := d.put(ctx, key, entry, Condition{Absent: true})
+	if !errors.Is(err, ErrConditionFailed) {
+		return err == nil, err
+	}
+
+	// The TTL attribute has a resolution of a second, so the item may have
+	// expired without DynamoDB's condition seeing it; replace it if so
+	item, err := d.client.GetItem(ctx, d.table, d.prefixed(key))
+	if err != nil || item == nil {
+		return false, err
+	}
+	if old, err := d.decode(item.Value); err == nil && !old.IsExpired() {
+		return false, nil
+	}
+	err = d.put(ctx, key, entry, Condition{Value: item.Value})
+	if errors.Is(err, ErrConditionFailed) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// touch implements Touch and GetAndTouch.
+func (d *DynamoDB) touch(ctx context.Context, key string, ttl time.Duration) (backends.CacheEntry, bool, error) {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	for range touchAttempts {
+		old, entry, ok, err := d.getItem(ctx, key)
+		if err != nil || !ok {
+			return backends.CacheEntry{}, false, err
+		}
+		entry.SetExpiresAt(expiresAt)
+		err = d.put(ctx, key, entry, Condition{Value: old.Value})
+		if err == nil {
+			return entry, true, nil
+		}
+		if !errors.Is(err, ErrConditionFailed) {
+			return backends.CacheEntry{}, false, err
+		}
+	}
+	return backends.CacheEntry{}, false, nil
+}
+
+// getMulti implements GetMulti, returning failures instead of logging them.
+// Entries that fail to decode are reported and skipped.
+func (d *DynamoDB) getMulti(ctx context.Context, keys []string) (map[string]any, error) {
+	out := make(map[string]any, len(keys))
+	byItem := make(map[string]string, len(keys))
+	for _, key := range keys {
+		byItem[d.prefixed(key)] = key
+	}
+	prefixed := make([]string, 0, len(byItem))
+	for k := range byItem {
+		prefixed = append(prefixed, k)
+	}
+
+	for start := 0; start < len(prefixed); start += maxBatchGet {
+		items, err := d.client.BatchGetItems(ctx, d.table, prefixed[start:min(start+maxBatchGet, len(prefixed))])
+		if err != nil {
+			return out, err
+		}
+		for _, item := range items {
+			key, ok := byItem[item.Key]
+			if !ok {
+				continue
+			}
+			entry, err := d.decode(item.Value)
+			if err != nil {
+				d.onError("decode", fmt.Errorf("decode %s: %w", key, err))
+				continue
+			}
+			if !entry.IsExpired() {
+				out[key] = entry.Value
+			}
+		}
+	}
+	return out, nil
+}
+
+// setMulti implements SetMulti, returning failures instead of logging them.
+// Items that cannot be encoded are skipped and reported in the error.
+func (d *DynamoDB) setMulti(ctx context.Context, items []backends.BatchItem) error {
+	var errs []error
+	puts := make([]Item, 0, len(items))
+	for _, it := range items {
+		item, err := d.item(it.Key, backends.NewEntry(it.Value, it.TTL, 0))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		puts = append(puts, item)
+	}
+	for start := 0; start < len(puts); start += maxBatchWrite {
+		if err := d.client.BatchWriteItems(ctx, d.table, puts[start:min(start+maxBatchWrite, len(puts))], nil); err != nil {
+			errs = append(errs, err)
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deleteMulti implements DeleteMulti, returning failures instead of logging them.
+func (d *DynamoDB) deleteMulti(ctx context.Context, keys []string) error {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = d.prefixed(key)
+	}
+	return d.deleteItems(ctx, prefixed)
+}
+
+// deleteItems removes the items with the given partition keys in batches.
+func (d *DynamoDB) deleteItems(ctx context.Context, keys []string) error {
+	for start := 0; start < len(keys); start += maxBatchWrite {
+		if err := d.client.BatchWriteItems(ctx, d.table, nil, keys[start:min(start+maxBatchWrite, len(keys))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteByPrefix implements DeleteByPrefix and Clear.
+func (d *DynamoDB) deleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	removed := 0
+	err := d.client.ScanKeys(ctx, d.table, d.prefixed(prefix), func(keys []string) error {
+		if err := d.deleteItems(ctx, keys); err != nil {
+			return err
+		}
+		removed += len(keys)
+		return nil
+	})
+	return removed, err
+}
+
+// -----------------------------------------------------------------------------
+// Serialization
+// -----------------------------------------------------------------------------
+
+func (d *DynamoDB) prefixed(key string) string {
+	return d.prefix + key
+}
+
+// item converts entry into the item stored under key.
+func (d *DynamoDB) item(key string, entry backends.CacheEntry) (Item, error) {
+	payload, err := d.codec.Encode(backends.NewRecord(entry))
+	if err != nil {
+		return Item{}, fmt.Errorf("encode %s: %w", key, err)
+	}
+	item := Item{
+		Key:     d.prefixed(key),
+		Value:   wire.Encode(wire.Header{Codec: d.codec.ID()}, payload),
+		Version: entry.Version(),
+	}
+	if t := entry.ExpiresAt(); !t.IsZero() {
+		// Round up so DynamoDB never deletes an item before it expires
+		item.Expires = t.Add(time.Second - 1).Unix()
+	}
+	return item, nil
+}
+
+// decode deserializes an encoded entry.
+func (d *DynamoDB) decode(data []byte) (backends.CacheEntry, error) {
+	hdr, payload, err := wire.Decode(data)
+	if err != nil {
+		return backends.CacheEntry{}, err
+	}
+	if hdr.Flags != 0 {
+		return backends.CacheEntry{}, fmt.Errorf("%w: unsupported flags %#x", wire.ErrUnknownFormat, hdr.Flags)
+	}
+	codec := d.codec
+	if hdr.Codec != codec.ID() {
+		if hdr.Codec != wire.CodecGob {
+			return backends.CacheEntry{}, fmt.Errorf("%w: unsupported codec %d", wire.ErrUnknownFormat, hdr.Codec)
+		}
+		codec = backends.GobCodec()
+	}
+
+	var rec backends.Record
+	if err := codec.Decode(payload, &rec); err != nil {
+		return backends.CacheEntry{}, err
+	}
+	return rec.Entry(), nil
+}
+
+// onError reports an error that cannot be returned to the caller.
+func (d *DynamoDB) onError(op string, err error) {
+	if d.errorHandler != nil {
+		d.errorHandler(op, err)
+		return
+	}
+	log.Printf("[gomemo][dynamodb] %s error: %v\n", op, err)
+}
+
+// -----------------------------------------------------------------------------
+// BackendV2 interface
+// -----------------------------------------------------------------------------
+
+// contextBackend exposes a DynamoDB backend through backends.BackendV2 and
+// backends.BatchBackendV2.
+type contextBackend struct {
+	*DynamoDB
+}
+
+var (
+	_ backends.BackendV2      = contextBackend{}
+	_ backends.BatchBackendV2 = contextBackend{}
+	_ backends.EntryBackendV2 = contextBackend{}
+)
+
+func (c contextBackend) Get(ctx context.Context, key string) (any, bool, error) {
+	_, entry, ok, err := c.getItem(ctx, key)
+	if !ok || err != nil {
+		return nil, false, err
+	}
+	return entry.Value, true, nil
+}
+
+func (c contextBackend) GetEntry(ctx context.Context, key string) (backends.CacheEntry, bool, error) {
+	_, entry, ok, err := c.getItem(ctx, key)
+	return entry, ok, err
+}
+
+func (c contextBackend) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return c.set(ctx, key, value, ttl)
+}
+
+func (c contextBackend) Delete(ctx context.Context, key string) error {
+	return c.client.DeleteItem(ctx, c.table, c.prefixed(key))
+}
+
+func (c contextBackend) Clear(ctx context.Context) error {
+	_, err := c.deleteByPrefix(ctx, "")
+	return err
+}
+
+func (c contextBackend) GetMulti(ctx context.Context, keys []string) (map[string]any, error) {
+	return c.getMulti(ctx, keys)
+}
+
+func (c contextBackend) SetMulti(ctx context.Context, items []backends.BatchItem) error {
+	return c.setMulti(ctx, items)
+}
+
+func (c contextBackend) DeleteMulti(ctx context.Context, keys []string) error {
+	return c.deleteMulti(ctx, keys)
+}
@@ -0,0 +1,85 @@
+package backends
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Encrypter encrypts serialized entries before a backend stores them.
+// additionalData is authenticated but not encrypted; backends pass the
+// entry's key so that ciphertexts cannot be swapped between keys.
+type Encrypter interface {
+	// Encrypt returns the encrypted form of plaintext.
+	Encrypt(plaintext, additionalData []byte) ([]byte, error)
+
+	// Decrypt reverses Encrypt, failing if the data was tampered with.
+	Decrypt(ciphertext, additionalData []byte) ([]byte, error)
+}
+
+// ErrDecrypt is returned when an entry cannot be decrypted, because it was
+// written under an unknown key or does not authenticate.
+var ErrDecrypt = errors.New("cannot decrypt entry")
+
+// NewAESGCM returns an Encrypter using AES-GCM. keys maps key versions to
+// 16, 24 or 32 byte AES keys. New entries are encrypted with the key of
+// version current, and entries written under any other version in keys can
+// still be decrypted. To rotate, add a new key, make it current, and drop
+// the old one once entries written under it have expired.
+func NewAESGCM(current byte, keys map[byte][]byte) (Encrypter, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("aes-gcm: no key for current version %d", current)
+	}
+	a := &aesGCM{current: current, aeads: make(map[byte]cipher.AEAD, len(keys))}
+	for version, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("aes-gcm: key version %d: %w", version, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("aes-gcm: key version %d: %w", version, err)
+		}
+		a.aeads[version] = aead
+	}
+	return a, nil
+}
+
+// aesGCM implements NewAESGCM. Ciphertexts are laid out as the key version,
+// the nonce and the sealed data.
+type aesGCM struct {
+	current byte
+	aeads   map[byte]cipher.AEAD
+}
+
+func (a *aesGCM) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	aead := a.aeads[a.current]
+	out := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out[0] = a.current
+	if _, err := rand.Read(out[1:]); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out[1:], plaintext, additionalData), nil
+}
+
+func (a *aesGCM) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return nil, fmt.Errorf("%w: empty ciphertext", ErrDecrypt)
+	}
+	aead, ok := a.aeads[ciphertext[0]]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key version %d", ErrDecrypt, ciphertext[0])
+	}
+	ciphertext = ciphertext[1:]
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: truncated ciphertext", ErrDecrypt)
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return plaintext, nil
+}
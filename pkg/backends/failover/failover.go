@@ -0,0 +1,345 @@
+// Package failover provides a cache backend that falls back to a secondary
+// backend while its primary is failing.
+package failover
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+const (
+	// defaultThreshold is how many consecutive primary failures open the circuit.
+	defaultThreshold = 5
+
+	// defaultCooldown is how long the circuit stays open before probing.
+	defaultCooldown = 5 * time.Second
+)
+
+// State is the state of a Failover's circuit breaker.
+type State int
+
+const (
+	// Closed routes calls to the primary. This is the normal state.
+	Closed State = iota
+
+	// Open routes calls to the secondary after the primary failed too often.
+	Open
+
+	// HalfOpen lets a single probe call through to the primary to test
+	// whether it has recovered; other calls still use the secondary.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Failover routes calls to a primary backend, typically Redis, and to a
+// secondary, typically in-process memory, while the primary is down.
+//
+// A circuit breaker watches the primary's errors. After a number of
+// consecutive failures the circuit opens and every call goes to the
+// secondary, so an outage degrades to a local cache instead of turning every
+// read into a miss and every write into a timeout. Once the cooldown has
+// passed, one call probes the primary: if it succeeds the circuit closes and
+// the secondary is cleared, since it may hold values the primary never saw.
+//
+// Failures are only visible through the primary's context-aware form, so
+// the primary should implement backends.V2Provider, as the Redis backend
+// does. Deletes made during an outage do not reach the primary.
+type Failover struct {
+	primary   backends.BackendV2
+	secondary backends.BackendV2
+	closers   []backends.Backend // the wrapped backends, for Close
+
+	mu        sync.Mutex
+	state     State
+	failures  int       // consecutive primary failures while closed
+	openedAt  time.Time // when the circuit last opened
+	threshold int
+	cooldown  time.Duration
+	onChange  []func(from, to State)
+}
+
+var (
+	_ backends.V2Provider = (*Failover)(nil)
+	_ backends.Closer     = (*Failover)(nil)
+)
+
+// Option configures a Failover backend.
+type Option func(*Failover)
+
+// WithFailureThreshold opens the circuit after n consecutive primary
+// failures instead of 5.
+func WithFailureThreshold(n int) Option {
+	return func(f *Failover) {
+		if n > 0 {
+			f.threshold = n
+		}
+	}
+}
+
+// WithCooldown sets how long the circuit stays open before the primary is
+// probed again; the default is 5 seconds.
+func WithCooldown(d time.Duration) Option {
+	return func(f *Failover) {
+		if d > 0 {
+			f.cooldown = d
+		}
+	}
+}
+
+// OnStateChange calls fn whenever the circuit changes state, e.g. to log or
+// alert on failovers. fn runs synchronously on the calling goroutine.
+func OnStateChange(fn func(from, to State)) Option {
+	return func(f *Failover) {
+		f.onChange = append(f.onChange, fn)
+	}
+}
+
+// New creates a backend using primary and failing over to secondary.
+//
+// Example:
+//
+//	backend := failover.New(redis.New("localhost:6379", "app:", 0), memory.New(memory.WithMaxEntries(10_000)),
+//	    failover.OnStateChange(func(from, to failover.State) {
+//	        log.Printf("cache circuit %s -> %s", from, to)
+//	    }))
+//	m := memo.New(memo.WithBackend(backend))
+func New(primary, secondary backends.Backend, opts ...Option) *Failover {
+	f := &Failover{
+		primary:   backends.ToV2(primary),
+		secondary: backends.ToV2(secondary),
+		closers:   []backends.Backend{primary, secondary},
+		threshold: defaultThreshold,
+		cooldown:  defaultCooldown,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// State returns the current state of the circuit.
+func (f *Failover) State() State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+// Close closes both backends if they have a Close method.
+func (f *Failover) Close() error {
+	var errs []error
+	for _, b := range f.closers {
+		if c, ok := b.(backends.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// V2 returns the context-aware form of the backend. It reports errors only
+// from the secondary; primary errors cause a failover instead.
+func (f *Failover) V2() backends.BackendV2 {
+	return contextBackend{f}
+}
+
+// -----------------------------------------------------------------------------
+// Circuit breaker
+// -----------------------------------------------------------------------------
+
+// allow reports whether a call may go to the primary. An open circuit whose
+// cooldown has passed lets the caller through as the probe.
+func (f *Failover) allow() bool {
+	f.mu.Lock()
+	var from State
+	switch f.state {
+	case Closed:
+		f.mu.Unlock()
+		return true
+	case Open:
+		if time.Since(f.openedAt) < f.cooldown {
+			f.mu.Unlock()
+			return false
+		}
+		from = f.setLocked(HalfOpen)
+	default:
+		f.mu.Unlock()
+		return false
+	}
+	listeners := f.onChange
+	f.mu.Unlock()
+
+	notify(listeners, from, HalfOpen)
+	return true
+}
+
+// record updates the circuit with the outcome of a primary call.
+func (f *Failover) record(ctx context.Context, err error) {
+	// Calls abandoned by the caller say nothing about the primary
+	if err != nil && ctx.Err() != nil {
+		f.mu.Lock()
+		probing := f.state == HalfOpen
+		if probing {
+			f.openedAt = time.Now().Add(-f.cooldown) // let the next call probe
+			f.setLocked(Open)
+		}
+		listeners := f.onChange
+		f.mu.Unlock()
+		if probing {
+			notify(listeners, HalfOpen, Open)
+		}
+		return
+	}
+
+	f.mu.Lock()
+	from, to := f.state, f.state
+	switch {
+	case err == nil:
+		f.failures = 0
+		if f.state == HalfOpen {
+			to = Closed
+		}
+	case f.state == HalfOpen:
+		to = Open
+	default:
+		f.failures++
+		if f.failures >= f.threshold {
+			to = Open
+		}
+	}
+	if to != from {
+		f.setLocked(to)
+		if to == Open {
+			f.openedAt = time.Now()
+			f.failures = 0
+		}
+	}
+	listeners := f.onChange
+	f.mu.Unlock()
+
+	if to == from {
+		return
+	}
+	if to == Closed {
+		// Values written during the outage never reached the primary
+		_ = f.secondary.Clear(context.Background())
+	}
+	notify(listeners, from, to)
+}
+
+// setLocked moves the circuit to s and returns the previous state. Callers
+// must hold f.mu.
+func (f *Failover) setLocked(s State) State {
+	from := f.state
+	f.state = s
+	return from
+}
+
+func notify(listeners []func(from, to State), from, to State) {
+	for _, fn := range listeners {
+		fn(from, to)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Operations
+// -----------------------------------------------------------------------------
+
+func (f *Failover) get(ctx context.Context, key string) (any, bool, error) {
+	if f.allow() {
+		v, ok, err := f.primary.Get(ctx, key)
+		f.record(ctx, err)
+		if err == nil {
+			return v, ok, nil
+		}
+	}
+	return f.secondary.Get(ctx, key)
+}
+
+func (f *Failover) set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if f.allow() {
+		err := f.primary.Set(ctx, key, value, ttl)
+		f.record(ctx, err)
+		if err == nil {
+			return nil
+		}
+	}
+	return f.secondary.Set(ctx, key, value, ttl)
+}
+
+// delete removes key from both backends, so the secondary holds no stale
+// copy for the next outage.
+func (f *Failover) delete(ctx context.Context, key string) error {
+	if f.allow() {
+		f.record(ctx, f.primary.Delete(ctx, key))
+	}
+	return f.secondary.Delete(ctx, key)
+}
+
+func (f *Failover) clear(ctx context.Context) error {
+	if f.allow() {
+		f.record(ctx, f.primary.Clear(ctx))
+	}
+	return f.secondary.Clear(ctx)
+}
+
+// -----------------------------------------------------------------------------
+// Backend interface
+// -----------------------------------------------------------------------------
+
+func (f *Failover) Get(key string) (any, bool) {
+	v, ok, _ := f.get(context.Background(), key)
+	return v, ok
+}
+
+func (f *Failover) Set(key string, value any, ttl time.Duration) {
+	_ = f.set(context.Background(), key, value, ttl)
+}
+
+func (f *Failover) Delete(key string) {
+	_ = f.delete(context.Background(), key)
+}
+
+func (f *Failover) Clear() {
+	_ = f.clear(context.Background())
+}
+
+// -----------------------------------------------------------------------------
+// BackendV2 interface
+// -----------------------------------------------------------------------------
+
+// contextBackend exposes a Failover backend through backends.BackendV2.
+type contextBackend struct {
+	*Failover
+}
+
+var _ backends.BackendV2 = contextBackend{}
+
+func (c contextBackend) Get(ctx context.Context, key string) (any, bool, error) {
+	return c.get(ctx, key)
+}
+
+func (c contextBackend) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return c.set(ctx, key, value, ttl)
+}
+
+func (c contextBackend) Delete(ctx context.Context, key string) error {
+	return c.delete(ctx, key)
+}
+
+func (c contextBackend) Clear(ctx context.Context) error {
+	return c.clear(ctx)
+}
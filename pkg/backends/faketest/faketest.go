@@ -0,0 +1,275 @@
+// Package faketest provides a scriptable fake cache backend for tests.
+//
+// The fake stores values like a regular in-memory backend, but individual
+// keys can be forced to hit, miss or fail, and every call is recorded so
+// tests can assert on how a Memoizer used its backend.
+package faketest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// Op identifies a backend operation.
+type Op string
+
+// Backend operations recorded by the fake.
+const (
+	OpGet    Op = "get"
+	OpSet    Op = "set"
+	OpDelete Op = "delete"
+	OpClear  Op = "clear"
+)
+
+// Call is a single recorded backend call.
+type Call struct {
+	Op    Op
+	Key   string
+	Value any
+	TTL   time.Duration
+}
+
+// Counts holds the number of calls made per operation.
+type Counts struct {
+	Gets    int
+	Sets    int
+	Deletes int
+	Clears  int
+}
+
+// outcome is a scripted result for a key.
+type outcome struct {
+	hit   bool
+	value any
+	err   error
+}
+
+// Backend is a fake cache backend with scriptable outcomes.
+// The zero value is not usable; create one with New.
+type Backend struct {
+	mu      sync.Mutex
+	entries map[string]backends.CacheEntry
+	forced  map[string]outcome
+	calls   []Call
+	counts  Counts
+	errs    []error
+
+	// GetHook, if set, decides the result of every Get that is not scripted
+	// through ForceHit, ForceMiss or ForceError, bypassing stored values.
+	GetHook func(key string) (value any, ok bool)
+
+	// SetHook, if set, is called for every Set that is not dropped by
+	// ForceError. The value is still stored.
+	SetHook func(key string, value any, ttl time.Duration)
+}
+
+var (
+	_ backends.Backend    = (*Backend)(nil)
+	_ backends.V2Provider = (*Backend)(nil)
+)
+
+// New creates an empty fake backend.
+func New() *Backend {
+	return &Backend{
+		entries: make(map[string]backends.CacheEntry),
+		forced:  make(map[string]outcome),
+	}
+}
+
+// ForceHit makes every Get for key return value, regardless of what is stored.
+func (b *Backend) ForceHit(key string, value any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.forced[key] = outcome{hit: true, value: value}
+}
+
+// ForceMiss makes every Get for key report a miss, regardless of what is stored.
+func (b *Backend) ForceMiss(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.forced[key] = outcome{}
+}
+
+// ForceError simulates a failing backend for key. Through V2, Get and Set
+// for key return err, which drives the memoizer's backend error handling; a
+// memoizer created with WithBackend uses V2 too. The Backend interface has
+// no error path, so there a failing Get reports a miss and a failing Set
+// drops the write, as real backends do. Each failure records err, which can
+// be inspected with Errors.
+func (b *Backend) ForceError(key string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.forced[key] = outcome{err: err}
+}
+
+// Unforce removes any scripted outcome for key.
+func (b *Backend) Unforce(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.forced, key)
+}
+
+// V2 returns the context-aware form of the fake, which shares its storage,
+// scripts and call log and returns the errors scripted with ForceError.
+func (b *Backend) V2() backends.BackendV2 {
+	return contextBackend{b}
+}
+
+// Get retrieves a value from the fake, applying any scripted outcome.
+func (b *Backend) Get(key string) (value any, ok bool) {
+	value, ok, _ = b.get(key)
+	return value, ok
+}
+
+// get implements Get, returning the error scripted for key.
+func (b *Backend) get(key string) (any, bool, error) {
+	b.mu.Lock()
+	b.record(Call{Op: OpGet, Key: key})
+	b.counts.Gets++
+
+	if o, forced := b.forced[key]; forced {
+		if o.err != nil {
+			b.errs = append(b.errs, o.err)
+		}
+		b.mu.Unlock()
+		return o.value, o.hit, o.err
+	}
+
+	hook := b.GetHook
+	entry, exists := b.entries[key]
+	if exists && entry.IsExpired() {
+		delete(b.entries, key)
+		exists = false
+	}
+	b.mu.Unlock()
+
+	if hook != nil {
+		value, ok := hook(key)
+		return value, ok, nil
+	}
+	if !exists {
+		return nil, false, nil
+	}
+	return entry.Value, true, nil
+}
+
+// Set stores a value in the fake with the given TTL.
+func (b *Backend) Set(key string, value any, ttl time.Duration) {
+	_ = b.set(key, value, ttl)
+}
+
+// set implements Set, returning the error scripted for key.
+func (b *Backend) set(key string, value any, ttl time.Duration) error {
+	b.mu.Lock()
+	b.record(Call{Op: OpSet, Key: key, Value: value, TTL: ttl})
+	b.counts.Sets++
+
+	if o, forced := b.forced[key]; forced && o.err != nil {
+		b.errs = append(b.errs, o.err)
+		b.mu.Unlock()
+		return o.err
+	}
+
+	b.entries[key] = backends.NewEntry(value, ttl, 0)
+	hook := b.SetHook
+	b.mu.Unlock()
+
+	if hook != nil {
+		hook(key, value, ttl)
+	}
+	return nil
+}
+
+// Delete removes a value from the fake.
+func (b *Backend) Delete(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record(Call{Op: OpDelete, Key: key})
+	b.counts.Deletes++
+	delete(b.entries, key)
+}
+
+// Clear removes all stored values. Scripted outcomes are kept.
+func (b *Backend) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.record(Call{Op: OpClear})
+	b.counts.Clears++
+	clear(b.entries)
+}
+
+// Calls returns a copy of all recorded calls, oldest first.
+func (b *Backend) Calls() []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Call(nil), b.calls...)
+}
+
+// Counts returns the number of calls made per operation.
+func (b *Backend) Counts() Counts {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.counts
+}
+
+// Errors returns the errors produced by keys scripted with ForceError, oldest first.
+func (b *Backend) Errors() []error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]error(nil), b.errs...)
+}
+
+// Reset clears stored values, scripted outcomes, recorded calls and errors.
+// Hooks are kept.
+func (b *Backend) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	clear(b.entries)
+	clear(b.forced)
+	b.calls = nil
+	b.counts = Counts{}
+	b.errs = nil
+}
+
+// record appends a call to the log. Callers must hold b.mu.
+func (b *Backend) record(c Call) {
+	b.calls = append(b.calls, c)
+}
+
+// contextBackend exposes a fake through backends.BackendV2.
+type contextBackend struct {
+	*Backend
+}
+
+var _ backends.BackendV2 = contextBackend{}
+
+func (c contextBackend) Get(_ context.Context, key string) (any, bool, error) {
+	return c.get(key)
+}
+
+func (c contextBackend) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	return c.set(key, value, ttl)
+}
+
+func (c contextBackend) Delete(_ context.Context, key string) error {
+	c.Backend.Delete(key)
+	return nil
+}
+
+func (c contextBackend) Clear(_ context.Context) error {
+	c.Backend.Clear()
+	return nil
+}
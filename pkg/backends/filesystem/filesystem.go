@@ -0,0 +1,539 @@
+// Package filesystem provides a cache backend storing one file per key.
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ldaidone/gomemo/internals/wire"
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// Filesystem is a durable cache backend writing every entry to its own file
+// below a root directory. It is the simplest persistent option and suits
+// large values such as rendered reports, which are read with a single file
+// read instead of passing through a database.
+//
+// File names are the SHA-256 of the key, spread over nested shard
+// directories so no directory grows too large: with the default depth of 2,
+// key "report" is stored in <root>/d3/a1/d3a1.... Each file starts with a
+// small header holding the entry's expiry and key, followed by the encoded
+// entry. Writes go to a temporary file that is renamed into place, so
+// readers never see a partially written entry.
+//
+// Several processes may share a root directory. Expired files are removed
+// when read and by a periodic sweep.
+type Filesystem struct {
+	root         string
+	depth        int                        // number of shard directory levels
+	codec        backends.Codec             // serializes entries; gob unless WithCodec is given
+	sync         bool                       // fsync files before renaming them into place
+	sweepEvery   time.Duration              // 0 disables the sweep loop
+	errorHandler func(op string, err error) // receives errors that cannot be returned; nil logs them
+	stop         chan struct{}
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+var (
+	_ backends.EntryBackend  = (*Filesystem)(nil)
+	_ backends.Toucher       = (*Filesystem)(nil)
+	_ backends.Expirer       = (*Filesystem)(nil)
+	_ backends.PrefixDeleter = (*Filesystem)(nil)
+	_ backends.Closer        = (*Filesystem)(nil)
+)
+
+const (
+	// defaultSweepInterval is how often expired files are removed unless
+	// WithSweepInterval is given.
+	defaultSweepInterval = 10 * time.Minute
+
+	// headerSize is the size of the fixed part of the file header: the
+	// expiry in unix nanoseconds (0 for none) and the key length, both big
+	// endian. The key follows.
+	headerSize = 8 + 4
+
+	// maxKeySize bounds the key length read from file headers, so a corrupt
+	// header cannot cause a huge allocation.
+	maxKeySize = 1 << 20
+
+	// tempPrefix starts the names of files still being written.
+	tempPrefix = ".tmp-"
+
+	// staleTempAge is how old a temporary file must be for the sweep to
+	// consider it left behind by a crashed writer.
+	staleTempAge = time.Hour
+)
+
+// Option configures a Filesystem backend.
+type Option func(*Filesystem)
+
+// WithShardDepth sets how many levels of shard directories, each named after
+// the next two hex digits of the key hash, sit between the root and the
+// files. The default of 2 gives 65536 directories, enough for many millions
+// of entries. Zero stores all files directly in the root.
+func WithShardDepth(n int) Option {
+	return func(f *Filesystem) {
+		f.depth = min(max(n, 0), sha256.Size)
+	}
+}
+
+// WithCodec selects how entries are serialized. The default is
+// backends.GobCodec. Entries written with gob stay readable after switching
+// to another codec.
+func WithCodec(c backends.Codec) Option {
+	return func(f *Filesystem) {
+		if c != nil {
+			f.codec = c
+		}
+	}
+}
+
+// WithSync flushes every file to disk before it replaces the previous entry.
+// Without it, entries written shortly before a power loss may be lost or
+// found corrupt and treated as misses, which is usually fine for a cache.
+func WithSync() Option {
+	return func(f *Filesystem) {
+		f.sync = true
+	}
+}
+
+// WithSweepInterval sets how often the directory tree is walked to remove
+// expired files. Zero or negative disables the sweep; expired entries are
+// still never returned. The default is ten minutes.
+func WithSweepInterval(d time.Duration) Option {
+	return func(f *Filesystem) {
+		f.sweepEvery = max(d, 0)
+	}
+}
+
+// WithErrorHandler passes errors the Backend interface cannot return, such as
+// failed writes, to fn instead of logging them with the standard log package.
+// op names the failed operation, e.g. "set".
+func WithErrorHandler(fn func(op string, err error)) Option {
+	return func(f *Filesystem) {
+		f.errorHandler = fn
+	}
+}
+
+// New creates a backend storing entries below root, creating the directory
+// if needed. Close stops the background sweep.
+//
+// Example:
+//
+//	backend, err := filesystem.New(filepath.Join(cacheDir, "reports"))
+//	if err != nil {
+//	    return err
+//	}
+//	m := memo.New(memo.WithBackend(backend))
+func New(root string, opts ...Option) (*Filesystem, error) {
+	f := &Filesystem{
+		root:       root,
+		depth:      2,
+		codec:      backends.GobCodec(),
+		sweepEvery: defaultSweepInterval,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	go f.sweepLoop()
+	return f, nil
+}
+
+// sweepLoop runs Sweep on the sweep interval until Close.
+func (f *Filesystem) sweepLoop() {
+	defer close(f.done)
+	if f.sweepEvery == 0 {
+		<-f.stop
+		return
+	}
+	t := time.NewTicker(f.sweepEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-t.C:
+			f.Sweep()
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Backend interface
+// -----------------------------------------------------------------------------
+
+func (f *Filesystem) Get(key string) (any, bool) {
+	entry, ok := f.GetEntry(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// GetEntry retrieves the entry stored under key. Expired and unreadable
+// files are removed and reported as misses.
+func (f *Filesystem) GetEntry(key string) (backends.CacheEntry, bool) {
+	entry, ok, err := f.read(key)
+	if err != nil {
+		f.onError("get", err)
+		return backends.CacheEntry{}, false
+	}
+	return entry, ok
+}
+
+func (f *Filesystem) Set(key string, value any, ttl time.Duration) {
+	if err := f.write(key, backends.NewEntry(value, ttl, 0)); err != nil {
+		f.onError("set", err)
+	}
+}
+
+func (f *Filesystem) Delete(key string) {
+	if err := f.remove(f.path(key)); err != nil {
+		f.onError("delete", err)
+	}
+}
+
+// Clear removes every entry, along with the shard directories.
+func (f *Filesystem) Clear() {
+	dirents, err := os.ReadDir(f.root)
+	if err != nil {
+		f.onError("clear", err)
+		return
+	}
+	for _, d := range dirents {
+		if !f.owns(d.Name()) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(f.root, d.Name())); err != nil {
+			f.onError("clear", err)
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Optional capabilities
+// -----------------------------------------------------------------------------
+
+// Touch resets the TTL of an existing entry to ttl from now by rewriting its
+// file. If TTL is 0 or negative, the entry will no longer expire.
+// Returns false if the key is missing or expired.
+func (f *Filesystem) Touch(key string, ttl time.Duration) bool {
+	entry, ok := f.GetEntry(key)
+	if !ok {
+		return false
+	}
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	entry.SetExpiresAt(expiresAt)
+	if err := f.write(key, entry); err != nil {
+		f.onError("touch", err)
+		return false
+	}
+	return true
+}
+
+// Expire removes the file of the entry stored under key.
+func (f *Filesystem) Expire(key string) bool {
+	if _, ok := f.GetEntry(key); !ok {
+		return false
+	}
+	f.Delete(key)
+	return true
+}
+
+// DeleteByPrefix removes every entry whose key starts with prefix. File names
+// are hashes, so every file's header has to be read.
+func (f *Filesystem) DeleteByPrefix(prefix string) int {
+	removed := 0
+	err := f.walk(func(path string, hdr header) error {
+		if !strings.HasPrefix(hdr.key, prefix) {
+			return nil
+		}
+		if err := f.remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		f.onError("delete by prefix", err)
+	}
+	return removed
+}
+
+// Sweep removes the files of expired entries and of writes that never
+// finished, and returns how many entries were removed. It runs
+// automatically, see WithSweepInterval.
+func (f *Filesystem) Sweep() int {
+	removed := 0
+	now := time.Now()
+	err := f.walk(func(path string, hdr header) error {
+		if !hdr.expired(now) {
+			return nil
+		}
+		if err := f.remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		f.onError("sweep", err)
+	}
+	return removed
+}
+
+// Close stops the background sweep. The files stay on disk and the backend
+// stays usable. Close is safe to call more than once and always returns nil.
+func (f *Filesystem) Close() error {
+	f.closeOnce.Do(func() {
+		close(f.stop)
+		<-f.done
+	})
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Files
+// -----------------------------------------------------------------------------
+
+// header is the start of an entry's file.
+type header struct {
+	expiry int64 // unix nanoseconds; 0 means no expiration
+	key    string
+}
+
+func (h header) expired(now time.Time) bool {
+	return h.expiry != 0 && h.expiry <= now.UnixNano()
+}
+
+// path returns the file of key.
+func (f *Filesystem) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	parts := make([]string, 0, f.depth+2)
+	parts = append(parts, f.root)
+	for i := range f.depth {
+		parts = append(parts, name[2*i:2*i+2])
+	}
+	return filepath.Join(append(parts, name)...)
+}
+
+// owns reports whether name, a directory entry of the root, was created by
+// the backend.
+func (f *Filesystem) owns(name string) bool {
+	n := 2 * sha256.Size // a file name at depth 0
+	if f.depth > 0 {
+		n = 2
+	}
+	if strings.HasPrefix(name, tempPrefix) {
+		return f.depth == 0
+	}
+	if len(name) != n {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// read returns the entry stored under key if it has not expired.
+func (f *Filesystem) read(key string) (backends.CacheEntry, bool, error) {
+	path := f.path(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return backends.CacheEntry{}, false, nil
+	} else if err != nil {
+		return backends.CacheEntry{}, false, err
+	}
+
+	hdr, payload, err := parseHeader(data)
+	if err == nil && hdr.key != key {
+		err = fmt.Errorf("%w: file holds key %q", wire.ErrUnknownFormat, hdr.key)
+	}
+	var entry backends.CacheEntry
+	if err == nil {
+		if hdr.expired(time.Now()) {
+			return backends.CacheEntry{}, false, f.remove(path)
+		}
+		entry, err = f.decode(payload)
+	}
+	if err != nil {
+		// Drop the unreadable file so the entry is recomputed
+		_ = f.remove(path)
+		return backends.CacheEntry{}, false, fmt.Errorf("decode %s: %w", key, err)
+	}
+	return entry, true, nil
+}
+
+// write stores entry under key through a temporary file renamed into place.
+func (f *Filesystem) write(key string, entry backends.CacheEntry) error {
+	payload, err := f.codec.Encode(backends.NewRecord(entry))
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
+	var expiry int64
+	if t := entry.ExpiresAt(); !t.IsZero() {
+		expiry = t.UnixNano()
+	}
+	data := make([]byte, 0, headerSize+len(key)+wire.HeaderSize+len(payload))
+	data = binary.BigEndian.AppendUint64(data, uint64(expiry))
+	data = binary.BigEndian.AppendUint32(data, uint32(len(key)))
+	data = append(data, key...)
+	data = append(data, wire.Encode(wire.Header{Codec: f.codec.ID()}, payload)...)
+
+	path := f.path(key)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil && f.sync {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// remove deletes the file at path. A missing file is not an error.
+func (f *Filesystem) remove(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// walk calls fn with the path and header of every entry file. Temporary
+// files left behind by crashed writers are removed on the way; files that
+// disappear or cannot be parsed are skipped.
+func (f *Filesystem) walk(fn func(path string, hdr header) error) error {
+	return filepath.WalkDir(f.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), tempPrefix) {
+			if info, err := d.Info(); err == nil && time.Since(info.ModTime()) > staleTempAge {
+				_ = os.Remove(path)
+			}
+			return nil
+		}
+		if len(d.Name()) != 2*sha256.Size {
+			return nil // not an entry file
+		}
+		hdr, err := readHeader(path)
+		if err != nil {
+			return nil
+		}
+		return fn(path, hdr)
+	})
+}
+
+// readHeader reads only the header of the file at path.
+func readHeader(path string) (header, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return header{}, err
+	}
+	defer file.Close()
+
+	fixed := make([]byte, headerSize)
+	if _, err := io.ReadFull(file, fixed); err != nil {
+		return header{}, err
+	}
+	n := binary.BigEndian.Uint32(fixed[8:])
+	if n > maxKeySize {
+		return header{}, wire.ErrUnknownFormat
+	}
+	key := make([]byte, n)
+	if _, err := io.ReadFull(file, key); err != nil {
+		return header{}, err
+	}
+	return header{expiry: int64(binary.BigEndian.Uint64(fixed)), key: string(key)}, nil
+}
+
+// parseHeader splits the contents of an entry file into its header and the
+// encoded entry.
+func parseHeader(data []byte) (header, []byte, error) {
+	if len(data) < headerSize {
+		return header{}, nil, wire.ErrUnknownFormat
+	}
+	n := int(binary.BigEndian.Uint32(data[8:]))
+	if len(data) < headerSize+n {
+		return header{}, nil, wire.ErrUnknownFormat
+	}
+	hdr := header{
+		expiry: int64(binary.BigEndian.Uint64(data)),
+		key:    string(data[headerSize : headerSize+n]),
+	}
+	return hdr, data[headerSize+n:], nil
+}
+
+// decode deserializes an encoded entry.
+func (f *Filesystem) decode(data []byte) (backends.CacheEntry, error) {
+	hdr, payload, err := wire.Decode(data)
+	if err != nil {
+		return backends.CacheEntry{}, err
+	}
+	if hdr.Flags != 0 {
+		return backends.CacheEntry{}, fmt.Errorf("%w: unsupported flags %#x", wire.ErrUnknownFormat, hdr.Flags)
+	}
+	codec := f.codec
+	if hdr.Codec != codec.ID() {
+		if hdr.Codec != wire.CodecGob {
+			return backends.CacheEntry{}, fmt.Errorf("%w: unsupported codec %d", wire.ErrUnknownFormat, hdr.Codec)
+		}
+		codec = backends.GobCodec()
+	}
+
+	var rec backends.Record
+	if err := codec.Decode(payload, &rec); err != nil {
+		return backends.CacheEntry{}, err
+	}
+	return rec.Entry(), nil
+}
+
+// onError reports an error that cannot be returned to the caller.
+func (f *Filesystem) onError(op string, err error) {
+	if f.errorHandler != nil {
+		f.errorHandler(op, err)
+		return
+	}
+	log.Printf("[gomemo][filesystem] %s error: %v\n", op, err)
+}
@@ -0,0 +1,44 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by Client.GetObject for missing objects.
+var ErrNotFound = errors.New("object not found")
+
+// Client is the part of Cloud Storage the backend uses. HTTPClient
+// implements it over the JSON API; applications using the official
+// cloud.google.com/go/storage package can adapt its BucketHandle instead, and
+// tests can use an in-memory fake.
+type Client interface {
+	// GetObject returns the object stored under name, or ErrNotFound.
+	GetObject(ctx context.Context, bucket, name string) (*Object, error)
+
+	// PutObject stores obj, replacing any object under the same name.
+	PutObject(ctx context.Context, bucket string, obj Object) error
+
+	// DeleteObject removes the object stored under name. Deleting a missing
+	// object is not an error.
+	DeleteObject(ctx context.Context, bucket, name string) error
+
+	// ListObjects calls fn with pages of the names starting with prefix.
+	ListObjects(ctx context.Context, bucket, prefix string, fn func(names []string) error) error
+}
+
+// Object is a cache entry as stored in Cloud Storage.
+type Object struct {
+	Name string
+	Body []byte // encoded entry
+
+	// Metadata is stored as custom object metadata. The backend records the
+	// expiry in it.
+	Metadata map[string]string
+
+	// CustomTime is the object's Custom-Time, set to the expiry. A bucket
+	// lifecycle rule with the daysSinceCustomTime condition deletes expired
+	// objects; GCS never deletes them by itself. Zero for none.
+	CustomTime time.Time
+}
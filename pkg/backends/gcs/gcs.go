@@ -0,0 +1,306 @@
+// Package gcs provides a cache backend storing entries as objects in a
+// Google Cloud Storage bucket.
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/ldaidone/gomemo/internals/wire"
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// ExpiresMetadata is the custom metadata key holding an object's expiry in
+// unix nanoseconds. Objects without it never expire.
+const ExpiresMetadata = "gomemo-expires"
+
+// GCS is a cache backend for large values that are expensive to recompute and
+// read rarely, such as ML artifacts or big reports. Each entry is one object
+// below a name prefix. Object storage has high latency and per-request cost,
+// so put a faster cache in front of it for hot keys.
+//
+// Expiry is recorded in the object's metadata and checked on every read. The
+// object's Custom-Time is set to the expiry too, so a lifecycle rule with
+// daysSinceCustomTime set to 0 deletes expired objects from the bucket.
+type GCS struct {
+	client       Client
+	bucket       string
+	prefix       string                     // prepended to every key
+	codec        backends.Codec             // serializes entries; gob unless WithCodec is given
+	errorHandler func(op string, err error) // receives errors that cannot be returned; nil logs them
+}
+
+var (
+	_ backends.EntryBackend  = (*GCS)(nil)
+	_ backends.PrefixDeleter = (*GCS)(nil)
+	_ backends.V2Provider    = (*GCS)(nil)
+)
+
+// Option configures a GCS backend.
+type Option func(*GCS)
+
+// WithPrefix stores objects below prefix instead of "gomemo/", so several
+// caches can share a bucket and lifecycle rules can be scoped to the cache.
+func WithPrefix(prefix string) Option {
+	return func(g *GCS) {
+		g.prefix = prefix
+	}
+}
+
+// WithCodec selects how entries are serialized. The default is
+// backends.GobCodec. Entries written with gob stay readable after switching
+// to another codec.
+func WithCodec(c backends.Codec) Option {
+	return func(g *GCS) {
+		if c != nil {
+			g.codec = c
+		}
+	}
+}
+
+// WithErrorHandler passes errors the Backend interface cannot return, such as
+// failed uploads, to fn instead of logging them with the standard log
+// package. op names the failed operation, e.g. "set". The context-aware form
+// returned by V2 returns errors instead.
+func WithErrorHandler(fn func(op string, err error)) Option {
+	return func(g *GCS) {
+		g.errorHandler = fn
+	}
+}
+
+// New creates a backend storing entries in bucket through client. The
+// memoizer uses the backend's context-aware form automatically, so the
+// context passed to Memoizer.Get bounds the requests made for it.
+//
+// Example:
+//
+//	hc, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/devstorage.read_write")
+//	if err != nil {
+//	    return err
+//	}
+//	backend := gcs.New(gcs.NewHTTPClient(hc), "ml-artifacts", gcs.WithPrefix("models/"))
+//	m := memo.New(memo.WithBackend(backend), memo.WithTTL(7*24*time.Hour))
+func New(client Client, bucket string, opts ...Option) *GCS {
+	g := &GCS{
+		client: client,
+		bucket: bucket,
+		prefix: "gomemo/",
+		codec:  backends.GobCodec(),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// init registers the backend as "gcs", using the bucket "gomemo" through an
+// HTTPClient without credentials. That suits an emulator named by
+// STORAGE_EMULATOR_HOST; real buckets need New with an authenticated client.
+func init() {
+	backends.RegisterBackend("gcs", func() backends.Backend {
+		return New(NewHTTPClient(nil), "gomemo")
+	})
+}
+
+// V2 returns the context-aware form of the backend.
+func (g *GCS) V2() backends.BackendV2 {
+	return contextBackend{g}
+}
+
+// -----------------------------------------------------------------------------
+// Backend interface
+// -----------------------------------------------------------------------------
+
+func (g *GCS) Get(key string) (any, bool) {
+	entry, ok := g.GetEntry(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// GetEntry retrieves the entry stored under key. Expired objects are misses
+// even before a lifecycle rule deletes them.
+func (g *GCS) GetEntry(key string) (backends.CacheEntry, bool) {
+	entry, ok, err := g.get(context.Background(), key)
+	if err != nil {
+		g.onError("get", err)
+		return backends.CacheEntry{}, false
+	}
+	return entry, ok
+}
+
+func (g *GCS) Set(key string, value any, ttl time.Duration) {
+	if err := g.set(context.Background(), key, value, ttl); err != nil {
+		g.onError("set", err)
+	}
+}
+
+func (g *GCS) Delete(key string) {
+	if err := g.client.DeleteObject(context.Background(), g.bucket, g.prefixed(key)); err != nil {
+		g.onError("delete", err)
+	}
+}
+
+// Clear removes every object below the backend's prefix.
+func (g *GCS) Clear() {
+	if _, err := g.deleteByPrefix(context.Background(), ""); err != nil {
+		g.onError("clear", err)
+	}
+}
+
+// DeleteByPrefix removes every entry whose key starts with prefix and returns
+// how many were removed. Cloud Storage deletes objects one request at a time,
+// so removing many entries is slow.
+func (g *GCS) DeleteByPrefix(prefix string) int {
+	n, err := g.deleteByPrefix(context.Background(), prefix)
+	if err != nil {
+		g.onError("delete by prefix", err)
+	}
+	return n
+}
+
+// -----------------------------------------------------------------------------
+// Operations
+// -----------------------------------------------------------------------------
+
+// get returns the entry stored under key if it is present and not expired.
+func (g *GCS) get(ctx context.Context, key string) (backends.CacheEntry, bool, error) {
+	obj, err := g.client.GetObject(ctx, g.bucket, g.prefixed(key))
+	if errors.Is(err, ErrNotFound) {
+		return backends.CacheEntry{}, false, nil
+	} else if err != nil {
+		return backends.CacheEntry{}, false, err
+	}
+
+	// Check the metadata first to skip decoding expired objects
+	if exp, err := strconv.ParseInt(obj.Metadata[ExpiresMetadata], 10, 64); err == nil && exp <= time.Now().UnixNano() {
+		return backends.CacheEntry{}, false, nil
+	}
+	entry, err := g.decode(obj.Body)
+	if err != nil {
+		return backends.CacheEntry{}, false, fmt.Errorf("decode %s: %w", key, err)
+	}
+	if entry.IsExpired() {
+		return backends.CacheEntry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+// set uploads value as the object of key.
+func (g *GCS) set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	entry := backends.NewEntry(value, ttl, 0)
+	payload, err := g.codec.Encode(backends.NewRecord(entry))
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
+	obj := Object{
+		Name:     g.prefixed(key),
+		Body:     wire.Encode(wire.Header{Codec: g.codec.ID()}, payload),
+		Metadata: map[string]string{},
+	}
+	if exp := entry.ExpiresAt(); !exp.IsZero() {
+		obj.Metadata[ExpiresMetadata] = strconv.FormatInt(exp.UnixNano(), 10)
+		obj.CustomTime = exp
+	}
+	return g.client.PutObject(ctx, g.bucket, obj)
+}
+
+// deleteByPrefix implements DeleteByPrefix and Clear.
+func (g *GCS) deleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	removed := 0
+	err := g.client.ListObjects(ctx, g.bucket, g.prefixed(prefix), func(names []string) error {
+		for _, name := range names {
+			if err := g.client.DeleteObject(ctx, g.bucket, name); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// -----------------------------------------------------------------------------
+// Serialization
+// -----------------------------------------------------------------------------
+
+func (g *GCS) prefixed(key string) string {
+	return g.prefix + key
+}
+
+// decode deserializes an encoded entry.
+func (g *GCS) decode(data []byte) (backends.CacheEntry, error) {
+	hdr, payload, err := wire.Decode(data)
+	if err != nil {
+		return backends.CacheEntry{}, err
+	}
+	if hdr.Flags != 0 {
+		return backends.CacheEntry{}, fmt.Errorf("%w: unsupported flags %#x", wire.ErrUnknownFormat, hdr.Flags)
+	}
+	codec := g.codec
+	if hdr.Codec != codec.ID() {
+		if hdr.Codec != wire.CodecGob {
+			return backends.CacheEntry{}, fmt.Errorf("%w: unsupported codec %d", wire.ErrUnknownFormat, hdr.Codec)
+		}
+		codec = backends.GobCodec()
+	}
+
+	var rec backends.Record
+	if err := codec.Decode(payload, &rec); err != nil {
+		return backends.CacheEntry{}, err
+	}
+	return rec.Entry(), nil
+}
+
+// onError reports an error that cannot be returned to the caller.
+func (g *GCS) onError(op string, err error) {
+	if g.errorHandler != nil {
+		g.errorHandler(op, err)
+		return
+	}
+	log.Printf("[gomemo][gcs] %s error: %v\n", op, err)
+}
+
+// -----------------------------------------------------------------------------
+// BackendV2 interface
+// -----------------------------------------------------------------------------
+
+// contextBackend exposes a GCS backend through backends.BackendV2.
+type contextBackend struct {
+	*GCS
+}
+
+var (
+	_ backends.BackendV2      = contextBackend{}
+	_ backends.EntryBackendV2 = contextBackend{}
+)
+
+func (c contextBackend) Get(ctx context.Context, key string) (any, bool, error) {
+	entry, ok, err := c.get(ctx, key)
+	if !ok || err != nil {
+		return nil, false, err
+	}
+	return entry.Value, true, nil
+}
+
+func (c contextBackend) GetEntry(ctx context.Context, key string) (backends.CacheEntry, bool, error) {
+	return c.get(ctx, key)
+}
+
+func (c contextBackend) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return c.set(ctx, key, value, ttl)
+}
+
+func (c contextBackend) Delete(ctx context.Context, key string) error {
+	return c.client.DeleteObject(ctx, c.bucket, c.prefixed(key))
+}
+
+func (c contextBackend) Clear(ctx context.Context) error {
+	_, err := c.deleteByPrefix(ctx, "")
+	return err
+}
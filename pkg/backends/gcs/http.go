@@ -0,0 +1,328 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultChunkSize is the resumable upload chunk size.
+	defaultChunkSize = 16 << 20
+
+	// chunkAlign is the multiple of which every chunk but the last must be.
+	chunkAlign = 256 << 10
+
+	// statusResumeIncomplete is the status of a resumable upload chunk that
+	// did not finish the upload.
+	statusResumeIncomplete = 308
+)
+
+// HTTPClient is a Client speaking the Cloud Storage JSON API over net/http.
+// Objects up to the chunk size are uploaded in one multipart request; larger
+// ones use a resumable upload sent in chunks, so a failed request does not
+// restart a multi-gigabyte upload from the beginning.
+//
+// HTTPClient does not authenticate requests itself: pass an *http.Client
+// whose transport adds credentials, such as one from
+// golang.org/x/oauth2/google.DefaultClient.
+type HTTPClient struct {
+	hc        *http.Client
+	endpoint  string // scheme and host, without a trailing slash
+	chunkSize int    // resumable upload chunk size; larger objects use resumable uploads
+}
+
+var _ Client = (*HTTPClient)(nil)
+
+// ClientOption configures an HTTPClient.
+type ClientOption func(*HTTPClient)
+
+// WithEndpoint sends requests to endpoint instead of
+// https://storage.googleapis.com, e.g. to a private endpoint or an emulator.
+func WithEndpoint(endpoint string) ClientOption {
+	return func(c *HTTPClient) {
+		c.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithChunkSize sets the size of the chunks larger objects are uploaded in.
+// Objects up to n bytes are uploaded in a single request. n is rounded up to
+// a multiple of 256 KiB, as Cloud Storage requires; the default is 16 MiB.
+func WithChunkSize(n int) ClientOption {
+	return func(c *HTTPClient) {
+		if n > 0 {
+			c.chunkSize = (n + chunkAlign - 1) / chunkAlign * chunkAlign
+		}
+	}
+}
+
+// NewHTTPClient creates a Client sending requests with hc, or
+// http.DefaultClient if hc is nil. Requests go to the emulator named by the
+// STORAGE_EMULATOR_HOST environment variable if it is set.
+func NewHTTPClient(hc *http.Client, opts ...ClientOption) *HTTPClient {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	c := &HTTPClient{
+		hc:        hc,
+		endpoint:  defaultEndpoint(),
+		chunkSize: defaultChunkSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// defaultEndpoint returns the emulator named by STORAGE_EMULATOR_HOST, as the
+// official client libraries do, or the public endpoint.
+func defaultEndpoint() string {
+	host := os.Getenv("STORAGE_EMULATOR_HOST")
+	if host == "" {
+		return "https://storage.googleapis.com"
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return strings.TrimSuffix(host, "/")
+}
+
+// objectResource is the JSON form of an object's metadata.
+type objectResource struct {
+	Name       string            `json:"name"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	CustomTime string            `json:"customTime,omitempty"`
+}
+
+// GetObject downloads the object stored under name. Metadata is filled from
+// the x-goog-meta-* response headers.
+func (c *HTTPClient) GetObject(ctx context.Context, bucket, name string) (*Object, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.objectURL(bucket, name)+"?alt=media", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	obj := &Object{Name: name, Body: body, Metadata: map[string]string{}}
+	for k, v := range resp.Header {
+		if meta, ok := strings.CutPrefix(k, "X-Goog-Meta-"); ok && len(v) > 0 {
+			obj.Metadata[strings.ToLower(meta)] = v[0]
+		}
+	}
+	return obj, nil
+}
+
+// PutObject uploads obj, using a resumable upload if it is larger than the
+// chunk size.
+func (c *HTTPClient) PutObject(ctx context.Context, bucket string, obj Object) error {
+	res := objectResource{Name: obj.Name, Metadata: obj.Metadata}
+	if !obj.CustomTime.IsZero() {
+		res.CustomTime = obj.CustomTime.UTC().Format(time.RFC3339Nano)
+	}
+	meta, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	if len(obj.Body) > c.chunkSize {
+		return c.putResumable(ctx, bucket, meta, obj.Body)
+	}
+	return c.putMultipart(ctx, bucket, meta, obj.Body)
+}
+
+// putMultipart uploads an object's metadata and body in one request.
+func (c *HTTPClient) putMultipart(ctx context.Context, bucket string, meta, body []byte) error {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, part := range []struct {
+		contentType string
+		data        []byte
+	}{
+		{"application/json; charset=UTF-8", meta},
+		{"application/octet-stream", body},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(part.data); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"multipart/related; boundary=" + mw.Boundary()}}
+	resp, err := c.do(ctx, http.MethodPost, c.uploadURL(bucket, "multipart"), buf.Bytes(), header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+	return nil
+}
+
+// putResumable starts a resumable upload session and sends body in chunks.
+// Chunks the server did not persist are sent again.
+func (c *HTTPClient) putResumable(ctx context.Context, bucket string, meta, body []byte) error {
+	header := http.Header{
+		"Content-Type":            {"application/json; charset=UTF-8"},
+		"X-Upload-Content-Length": {strconv.Itoa(len(body))},
+	}
+	resp, err := c.do(ctx, http.MethodPost, c.uploadURL(bucket, "resumable"), meta, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+	session := resp.Header.Get("Location")
+	if session == "" {
+		return errors.New("gcs: resumable upload returned no session URI")
+	}
+
+	for offset := 0; offset < len(body); {
+		end := min(offset+c.chunkSize, len(body))
+		header := http.Header{"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", offset, end-1, len(body))}}
+		resp, err := c.do(ctx, http.MethodPut, session, body[offset:end], header)
+		if err != nil {
+			return err
+		}
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusCreated:
+			resp.Body.Close()
+			return nil
+		case statusResumeIncomplete:
+			resp.Body.Close()
+			next := persisted(resp.Header.Get("Range"))
+			if next <= offset {
+				return fmt.Errorf("gcs: resumable upload made no progress at byte %d", offset)
+			}
+			offset = next
+		default:
+			err := apiError(resp)
+			resp.Body.Close()
+			return err
+		}
+	}
+	return errors.New("gcs: resumable upload did not complete")
+}
+
+// persisted returns how many bytes a resumable upload has stored according
+// to the Range header of a 308 response, e.g. "bytes=0-1048575".
+func persisted(rng string) int {
+	_, last, ok := strings.Cut(strings.TrimPrefix(rng, "bytes="), "-")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(last)
+	if err != nil {
+		return 0
+	}
+	return n + 1
+}
+
+// DeleteObject removes the object stored under name.
+func (c *HTTPClient) DeleteObject(ctx context.Context, bucket, name string) error {
+	resp, err := c.do(ctx, http.MethodDelete, c.objectURL(bucket, name), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return apiError(resp)
+	}
+	return nil
+}
+
+// ListObjects pages through the names of the objects starting with prefix.
+func (c *HTTPClient) ListObjects(ctx context.Context, bucket, prefix string, fn func(names []string) error) error {
+	query := url.Values{"prefix": {prefix}, "fields": {"items(name),nextPageToken"}}
+	for {
+		resp, err := c.do(ctx, http.MethodGet, c.endpoint+"/storage/v1/b/"+url.PathEscape(bucket)+"/o?"+query.Encode(), nil, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := apiError(resp)
+			resp.Body.Close()
+			return err
+		}
+		var page struct {
+			Items         []objectResource `json:"items"`
+			NextPageToken string           `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		if len(page.Items) > 0 {
+			names := make([]string, len(page.Items))
+			for i, item := range page.Items {
+				names[i] = item.Name
+			}
+			if err := fn(names); err != nil {
+				return err
+			}
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+func (c *HTTPClient) objectURL(bucket, name string) string {
+	return c.endpoint + "/storage/v1/b/" + url.PathEscape(bucket) + "/o/" + url.PathEscape(name)
+}
+
+func (c *HTTPClient) uploadURL(bucket, uploadType string) string {
+	return c.endpoint + "/upload/storage/v1/b/" + url.PathEscape(bucket) + "/o?uploadType=" + uploadType
+}
+
+// do sends a request with an optional body and headers.
+func (c *HTTPClient) do(ctx context.Context, method, u string, body []byte, header http.Header) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return c.hc.Do(req)
+}
+
+// apiError describes a failed response, including the start of its body.
+func apiError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("gcs: %s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, bytes.TrimSpace(msg))
+}
@@ -0,0 +1,274 @@
+// Package layered provides a cache backend that stacks a fast local layer in
+// front of a shared remote one.
+package layered
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// defaultL1TTL is how long values stay in the local layer by default.
+const defaultL1TTL = time.Minute
+
+// Layered is a two-level backend, typically an in-process memory backend
+// (L1) in front of Redis (L2). Reads check L1 first and fall back to L2;
+// L2 hits are copied into L1 so the next read stays local. Writes and
+// deletes go to both layers.
+//
+// L1 keeps values for at most the L1 TTL: the one set with WithL1TTL, else
+// L1's own default TTL (see backends.DefaultTTLProvider), else one minute.
+// Other processes update only L2, so that TTL bounds how stale an L1 copy
+// can get; keep it short, or pair the layers with an invalidation mechanism
+// such as redis.Invalidate. Values are written to L2 with the TTL they are
+// given, or L2's default TTL if they are given none; Layered reports that
+// default as its own, so a memoizer without a more specific TTL uses it.
+type Layered struct {
+	l1, l2 backends.Backend
+	l1TTL  time.Duration
+
+	l1Hits atomic.Uint64
+	l2Hits atomic.Uint64
+	misses atomic.Uint64
+}
+
+var (
+	_ backends.V2Provider         = (*Layered)(nil)
+	_ backends.Closer             = (*Layered)(nil)
+	_ backends.DefaultTTLProvider = (*Layered)(nil)
+)
+
+// Stats counts where reads were served from.
+type Stats struct {
+	L1Hits uint64 // reads served by the local layer
+	L2Hits uint64 // reads served by the remote layer and promoted into L1
+	Misses uint64 // reads neither layer could serve
+}
+
+// Option configures a Layered backend.
+type Option func(*Layered)
+
+// WithL1TTL sets the longest time a value stays in L1, whether written or
+// promoted from L2. Values whose own TTL is shorter keep it; L2 hits keep
+// what is left of their L2 TTL when L2 implements backends.EntryBackend.
+func WithL1TTL(d time.Duration) Option {
+	return func(l *Layered) {
+		if d > 0 {
+			l.l1TTL = d
+		}
+	}
+}
+
+// New creates a backend reading l1 before l2.
+//
+// Example:
+//
+//	backend := layered.New(memory.New(memory.WithMaxEntries(10_000)), redis.New("localhost:6379", "app:", 0),
+//	    layered.WithL1TTL(10*time.Second))
+//	m := memo.New(memo.WithBackend(backend))
+func New(l1, l2 backends.Backend, opts ...Option) *Layered {
+	l := &Layered{l1: l1, l2: l2}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.l1TTL == 0 {
+		l.l1TTL = tierTTL(l1, defaultL1TTL)
+	}
+	return l
+}
+
+// Stats returns the per-layer read counts.
+func (l *Layered) Stats() Stats {
+	return Stats{
+		L1Hits: l.l1Hits.Load(),
+		L2Hits: l.l2Hits.Load(),
+		Misses: l.misses.Load(),
+	}
+}
+
+// L1 returns the local layer.
+func (l *Layered) L1() backends.Backend {
+	return l.l1
+}
+
+// L2 returns the remote layer.
+func (l *Layered) L2() backends.Backend {
+	return l.l2
+}
+
+// localTTL returns the L1 TTL for a value written with ttl.
+func (l *Layered) localTTL(ttl time.Duration) time.Duration {
+	if ttl > 0 && ttl < l.l1TTL {
+		return ttl
+	}
+	return l.l1TTL
+}
+
+// promotedTTL returns the L1 TTL for an L2 hit expiring at expiresAt: the
+// L1 TTL, or less if the L2 entry expires sooner, so that L1 never serves a
+// value past its L2 expiry. A zero expiresAt means the entry does not expire.
+func (l *Layered) promotedTTL(expiresAt time.Time) time.Duration {
+	if expiresAt.IsZero() {
+		return l.l1TTL
+	}
+	return min(l.l1TTL, time.Until(expiresAt))
+}
+
+// remoteTTL returns the L2 TTL for a value written with ttl: ttl itself, or
+// L2's default TTL if ttl is not positive.
+func (l *Layered) remoteTTL(ttl time.Duration) time.Duration {
+	if ttl > 0 {
+		return ttl
+	}
+	return tierTTL(l.l2, ttl)
+}
+
+// DefaultTTL returns L2's default TTL, or zero if it has none, so that the
+// memoizer stores values with it unless a more specific TTL applies.
+func (l *Layered) DefaultTTL() time.Duration {
+	return tierTTL(l.l2, 0)
+}
+
+// tierTTL returns b's default TTL if it has one, ttl otherwise.
+func tierTTL(b backends.Backend, ttl time.Duration) time.Duration {
+	if p, ok := b.(backends.DefaultTTLProvider); ok {
+		if d := p.DefaultTTL(); d > 0 {
+			return d
+		}
+	}
+	return ttl
+}
+
+// Close closes both layers if they have a Close method.
+func (l *Layered) Close() error {
+	var errs []error
+	for _, b := range []backends.Backend{l.l1, l.l2} {
+		if c, ok := b.(backends.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// V2 returns the context-aware form of the backend, which passes the
+// caller's context to both layers and reports L2 errors.
+func (l *Layered) V2() backends.BackendV2 {
+	return contextBackend{l, backends.ToV2(l.l1), backends.ToV2(l.l2)}
+}
+
+// -----------------------------------------------------------------------------
+// Backend interface
+// -----------------------------------------------------------------------------
+
+func (l *Layered) Get(key string) (any, bool) {
+	if v, ok := l.l1.Get(key); ok {
+		l.l1Hits.Add(1)
+		return v, true
+	}
+	var (
+		v   any
+		ok  bool
+		ttl = l.l1TTL
+	)
+	if eb, isEntries := l.l2.(backends.EntryBackend); isEntries {
+		var entry backends.CacheEntry
+		if entry, ok = eb.GetEntry(key); ok {
+			v, ttl = entry.Value, l.promotedTTL(entry.ExpiresAt())
+		}
+	} else {
+		v, ok = l.l2.Get(key)
+	}
+	if !ok {
+		l.misses.Add(1)
+		return nil, false
+	}
+	l.l2Hits.Add(1)
+	if ttl > 0 {
+		l.l1.Set(key, v, ttl)
+	}
+	return v, true
+}
+
+func (l *Layered) Set(key string, value any, ttl time.Duration) {
+	l.l2.Set(key, value, l.remoteTTL(ttl))
+	l.l1.Set(key, value, l.localTTL(ttl))
+}
+
+func (l *Layered) Delete(key string) {
+	l.l2.Delete(key)
+	l.l1.Delete(key)
+}
+
+func (l *Layered) Clear() {
+	l.l2.Clear()
+	l.l1.Clear()
+}
+
+// -----------------------------------------------------------------------------
+// BackendV2 interface
+// -----------------------------------------------------------------------------
+
+// contextBackend exposes a Layered backend through backends.BackendV2.
+type contextBackend struct {
+	*Layered
+	l1, l2 backends.BackendV2
+}
+
+var _ backends.BackendV2 = contextBackend{}
+
+// Get reads L1, then L2. L1 errors count as misses, since L2 can still serve
+// the read; L2 errors are returned.
+func (c contextBackend) Get(ctx context.Context, key string) (any, bool, error) {
+	if v, ok, err := c.l1.Get(ctx, key); ok && err == nil {
+		c.l1Hits.Add(1)
+		return v, true, nil
+	}
+	var (
+		v   any
+		ok  bool
+		err error
+		ttl = c.l1TTL
+	)
+	if eb, isEntries := c.l2.(backends.EntryBackendV2); isEntries {
+		var entry backends.CacheEntry
+		if entry, ok, err = eb.GetEntry(ctx, key); ok {
+			v, ttl = entry.Value, c.promotedTTL(entry.ExpiresAt())
+		}
+	} else {
+		v, ok, err = c.l2.Get(ctx, key)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		c.misses.Add(1)
+		return nil, false, nil
+	}
+	c.l2Hits.Add(1)
+	if ttl > 0 {
+		_ = c.l1.Set(ctx, key, v, ttl)
+	}
+	return v, true, nil
+}
+
+// Set writes L2, then L1. If the L2 write fails, L1 is left alone so the
+// process does not serve a value other processes never see.
+func (c contextBackend) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if err := c.l2.Set(ctx, key, value, c.remoteTTL(ttl)); err != nil {
+		return err
+	}
+	return c.l1.Set(ctx, key, value, c.localTTL(ttl))
+}
+
+// Delete removes key from both layers, even if L2 fails.
+func (c contextBackend) Delete(ctx context.Context, key string) error {
+	return errors.Join(c.l2.Delete(ctx, key), c.l1.Delete(ctx, key))
+}
+
+// Clear clears both layers, even if L2 fails.
+func (c contextBackend) Clear(ctx context.Context) error {
+	return errors.Join(c.l2.Clear(ctx), c.l1.Clear(ctx))
+}
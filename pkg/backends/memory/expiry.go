@@ -0,0 +1,38 @@
+package memory
+
+import "container/heap"
+
+// expiryItem records that key was stored to expire at the given unix nanoseconds.
+type expiryItem struct {
+	key string
+	at  int64
+}
+
+// expiryHeap is a min-heap of expiry times. It may hold stale items for keys
+// that were since rewritten, touched or deleted; the sweep skips items that
+// no longer match the stored entry, and compact drops them in bulk.
+type expiryHeap []expiryItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].at < h[j].at }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(expiryItem)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+// push schedules key to expire at at.
+func (h *expiryHeap) push(key string, at int64) {
+	heap.Push(h, expiryItem{key: key, at: at})
+}
+
+// popBefore removes and returns the earliest item if it expires before now.
+func (h *expiryHeap) popBefore(now int64) (expiryItem, bool) {
+	if len(*h) == 0 || (*h)[0].at >= now {
+		return expiryItem{}, false
+	}
+	return heap.Pop(h).(expiryItem), true
+}
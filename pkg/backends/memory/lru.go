@@ -0,0 +1,63 @@
+package memory
+
+import "container/list"
+
+// evictionPolicy decides which entry to drop when a bounded Memory is full.
+// Implementations are not safe for concurrent use; Memory calls them with
+// its write lock held.
+type evictionPolicy interface {
+	// added records that key was inserted.
+	added(key string)
+
+	// accessed records that key was read or rewritten.
+	accessed(key string)
+
+	// removed records that key left the cache for any reason.
+	removed(key string)
+
+	// victim returns the key to evict next.
+	victim() (key string, ok bool)
+}
+
+// lruPolicy evicts the least recently used entry.
+type lruPolicy struct {
+	order *list.List               // front is most recently used
+	elems map[string]*list.Element // key -> element in order
+}
+
+// newLRUPolicy creates an empty lruPolicy.
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{
+		order: list.New(),
+		elems: make(map[string]*list.Element),
+	}
+}
+
+func (p *lruPolicy) added(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+		return
+	}
+	p.elems[key] = p.order.PushFront(key)
+}
+
+func (p *lruPolicy) accessed(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+	}
+}
+
+func (p *lruPolicy) removed(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.Remove(e)
+		delete(p.elems, key)
+	}
+}
+
+func (p *lruPolicy) victim() (string, bool) {
+	e := p.order.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
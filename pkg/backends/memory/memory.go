@@ -2,42 +2,256 @@
 package memory
 
 import (
+	"container/heap"
 	"github.com/ldaidone/gomemo/pkg/backends"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// defaultSweepInterval is how often expired entries are removed when no
+// sweep bounds are configured.
+const defaultSweepInterval = time.Minute
+
 // Memory is an in-memory cache backend implementation.
 // It stores values in a map and automatically removes expired entries.
 type Memory struct {
 	entries map[string]backends.CacheEntry
 	mu      sync.RWMutex
+	clock   Clock
+	sweeper *sweeper
+	expiry  expiryHeap // pending expirations, guarded by mu
+	noSweep bool       // WithCleanupInterval disabled the sweep goroutine
+	stop    chan struct{}
+	closed  sync.Once
+
+	trackAccesses bool
+	defaultTTL    time.Duration // reported by DefaultTTL; 0 means none
+
+	readMap    sync.Map // key -> backends.CacheEntry mirror of entries for lock-free reads
+	lockFree   bool     // reads use readMap instead of taking mu
+	mutexReads bool     // WithMutexReads was set
+
+	maxEntries int                // 0 means unbounded
+	policyKind Policy             // eviction policy used when bounded
+	policy     evictionPolicy     // nil when unbounded
+	evictions  atomic.Uint64      // entries evicted for capacity
+	onEvict    []func(key string) // eviction listeners, guarded by mu
+
+	maxBytes int64             // 0 means no byte budget
+	sizeOf   func(v any) int64 // size estimator used with maxBytes
+	sizes    map[string]int64  // key -> estimated size, when maxBytes is set
+	bytes    int64             // sum of sizes, guarded by mu
+
+	tagged  map[string]map[string]struct{} // tag -> keys carrying it, guarded by mu
+	keyTags map[string][]string            // key -> its tags, guarded by mu
+}
+
+var (
+	_ backends.EntryBackend     = (*Memory)(nil)
+	_ backends.AccessCounter    = (*Memory)(nil)
+	_ backends.Toucher          = (*Memory)(nil)
+	_ backends.EvictionNotifier = (*Memory)(nil)
+	_ backends.SizeReporter     = (*Memory)(nil)
+	_ backends.LenReporter      = (*Memory)(nil)
+	_ backends.StatsBackend     = (*Memory)(nil)
+	_ backends.Closer           = (*Memory)(nil)
+	_ backends.BatchBackend     = (*Memory)(nil)
+	_ backends.Peeker           = (*Memory)(nil)
+	_ backends.EntryPeeker      = (*Memory)(nil)
+	_ backends.Expirer          = (*Memory)(nil)
+	_ backends.PrefixDeleter    = (*Memory)(nil)
+	_ backends.Tagger           = (*Memory)(nil)
+
+	_ backends.DefaultTTLProvider = (*Memory)(nil)
+)
+
+// Clock is the time source used by the memory backend for expiry and sweeping.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Option configures a Memory backend.
+type Option func(*Memory)
+
+// WithSweepBounds enables self-tuning of the expired entry sweep.
+// The sweep interval starts at min and adapts to the observed eviction rate:
+// sweeps that keep evicting nothing back off towards max, sweeps that evict a
+// large share of the entries run more often, down to min.
+// Bounds that are non-positive or inverted are ignored.
+func WithSweepBounds(min, max time.Duration) Option {
+	return func(m *Memory) {
+		if min <= 0 || max < min {
+			return
+		}
+		m.sweeper = newSweeper(min, max)
+	}
+}
+
+// WithCleanupInterval sets a fixed interval for the expired entry sweep,
+// replacing the default of once a minute. A non-positive interval disables the
+// background sweep; expired entries are then still hidden from reads and can be
+// removed by calling Sweep.
+func WithCleanupInterval(d time.Duration) Option {
+	return func(m *Memory) {
+		if d <= 0 {
+			m.noSweep = true
+			return
+		}
+		m.noSweep = false
+		m.sweeper = newSweeper(d, d)
+	}
+}
+
+// WithClock sets the time source used for expiry and sweeping.
+// It is mostly useful to drive the backend with a fake clock in tests.
+func WithClock(c Clock) Option {
+	return func(m *Memory) {
+		if c != nil {
+			m.clock = c
+		}
+	}
+}
+
+// WithAccessCounts enables counting reads per entry, reported by AccessCount
+// and CacheEntry.Accesses. It is off by default to keep reads free of the
+// extra atomic increment.
+func WithAccessCounts() Option {
+	return func(m *Memory) {
+		m.trackAccesses = true
+	}
+}
+
+// WithMutexReads makes reads take the backend's read lock instead of using
+// the lock-free read path. It exists mostly to compare the two.
+func WithMutexReads() Option {
+	return func(m *Memory) {
+		m.mutexReads = true
+	}
+}
+
+// WithDefaultTTL sets the TTL reported by DefaultTTL, which the memoizer and
+// tiered backends use for values written to this backend, e.g. to keep an
+// in-process layer shorter lived than the shared one behind it. Set itself
+// still stores values with the TTL it is given.
+func WithDefaultTTL(d time.Duration) Option {
+	return func(m *Memory) {
+		m.defaultTTL = max(d, 0)
+	}
+}
+
+// WithMaxEntries bounds the backend to n entries. When a new key would
+// exceed the bound, an entry is evicted according to the eviction policy,
+// LRU by default. Zero or negative means unbounded.
+func WithMaxEntries(n int) Option {
+	return func(m *Memory) {
+		m.maxEntries = max(n, 0)
+	}
+}
+
+// WithMaxBytes sets a byte budget for the backend. When stored values exceed
+// it, entries are evicted according to the eviction policy until usage is
+// back under the budget. A single value larger than the budget is not stored.
+// Sizes are estimated with backends.EstimateSize unless WithSizeEstimator is
+// used. Zero or negative means no budget.
+func WithMaxBytes(n int64) Option {
+	return func(m *Memory) {
+		m.maxBytes = max(n, 0)
+	}
+}
+
+// WithSizeEstimator replaces the size estimator used with WithMaxBytes.
+func WithSizeEstimator(fn func(v any) int64) Option {
+	return func(m *Memory) {
+		if fn != nil {
+			m.sizeOf = fn
+		}
+	}
+}
+
+// Policy selects how a bounded memory backend picks entries to evict.
+type Policy int
+
+const (
+	// LRU evicts the least recently used entry. This is the default.
+	LRU Policy = iota
+
+	// TinyLFU uses W-TinyLFU: new keys must be estimated to be read more
+	// often than the entry they would displace to be kept, so a burst of
+	// one-off keys does not flush out hot entries.
+	TinyLFU
+)
+
+// WithEvictionPolicy selects the eviction policy used with WithMaxEntries.
+// TinyLFU sizes its segments in entries, so with only WithMaxBytes set the
+// backend uses LRU.
+func WithEvictionPolicy(p Policy) Option {
+	return func(m *Memory) {
+		m.policyKind = p
+	}
 }
 
 // New creates a new in-memory cache backend.
-// It starts a cleanup goroutine that periodically removes expired entries.
-func New() *Memory {
+// It starts a cleanup goroutine that periodically removes expired entries
+// until Close is called.
+func New(opts ...Option) *Memory {
 	m := &Memory{
 		entries: make(map[string]backends.CacheEntry),
+		clock:   realClock{},
+		sizeOf:  backends.EstimateSize,
+		sweeper: newSweeper(defaultSweepInterval, defaultSweepInterval),
+		stop:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+	m.policy = m.newPolicy()
+	if m.maxBytes > 0 {
+		m.sizes = make(map[string]int64)
+	}
+	// Bounded backends update their eviction policy on every read, which
+	// needs the write lock anyway
+	m.lockFree = m.policy == nil && !m.mutexReads
 
 	// Start cleanup goroutine to remove expired entries periodically
-	go func() {
-		ticker := time.NewTicker(1 * time.Minute) // Cleanup every minute
-		defer ticker.Stop()
-
-		for range ticker.C {
-			for key, entry := range m.entries {
-				if entry.IsExpired() {
-					delete(m.entries, key)
-				}
-			}
-		}
-	}()
+	if !m.noSweep {
+		go m.sweepLoop()
+	}
 
 	return m
 }
 
+// sweepLoop runs Sweep on the sweep interval until the backend is closed.
+func (m *Memory) sweepLoop() {
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-m.clock.After(m.sweeper.current()):
+			m.Sweep()
+		}
+	}
+}
+
+// Close stops the cleanup goroutine. The backend stays usable afterwards,
+// but expired entries are no longer swept in the background.
+// Close is safe to call more than once and always returns nil.
+func (m *Memory) Close() error {
+	m.closed.Do(func() { close(m.stop) })
+	return nil
+}
+
 // init registers the memory backend with the factory
 func init() {
 	backends.RegisterBackend("memory", func() backends.Backend {
@@ -45,35 +259,368 @@ func init() {
 	})
 }
 
+// Sweep removes all expired entries and returns how many were evicted.
+// It also feeds the eviction count to the sweep interval tuning.
+// Expirations are kept in a min-heap, so a sweep only visits entries that
+// have actually expired rather than scanning the whole map.
+// Sweeps run automatically; calling Sweep directly is rarely needed.
+func (m *Memory) Sweep() int {
+	m.mu.Lock()
+	now := m.clock.Now()
+	total := len(m.entries)
+	evicted := 0
+	for {
+		it, ok := m.expiry.popBefore(now.UnixNano())
+		if !ok {
+			break
+		}
+		// Skip items superseded by a rewrite, touch or delete
+		entry, exists := m.entries[it.key]
+		if !exists || entry.ExpiresAt().UnixNano() != it.at {
+			continue
+		}
+		m.removeLocked(it.key)
+		evicted++
+	}
+	m.mu.Unlock()
+
+	m.sweeper.observe(evicted, total)
+	return evicted
+}
+
+// SweepInterval returns the current interval between automatic sweeps.
+func (m *Memory) SweepInterval() time.Duration {
+	return m.sweeper.current()
+}
+
 // Get retrieves a value from the cache by key.
 // Returns the value and true if found and not expired, nil and false otherwise.
 func (m *Memory) Get(key string) (value any, ok bool) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	entry, ok := m.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// lookup returns the live entry for key and records the access.
+// Unbounded backends read from the lock-free mirror; bounded backends take
+// the write lock to update the eviction policy. Expired entries are left for
+// the sweep unless the write lock is held.
+func (m *Memory) lookup(key string) (backends.CacheEntry, bool) {
+	if m.lockFree {
+		v, ok := m.readMap.Load(key)
+		if !ok {
+			return backends.CacheEntry{}, false
+		}
+		entry := v.(backends.CacheEntry)
+		if entry.ExpiredAt(m.clock.Now()) {
+			return backends.CacheEntry{}, false
+		}
+		entry.RecordAccess()
+		return entry, true
+	}
+
+	if m.policy == nil {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+	} else {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+	}
 
 	entry, exists := m.entries[key]
 	if !exists {
-		return nil, false
+		return backends.CacheEntry{}, false
 	}
 
-	if entry.IsExpired() {
-		delete(m.entries, key) // Clean up expired entry
-		return nil, false
+	if entry.ExpiredAt(m.clock.Now()) {
+		if m.policy != nil {
+			m.removeLocked(key) // Clean up expired entry
+		}
+		return backends.CacheEntry{}, false
 	}
 
+	if m.policy != nil {
+		m.policy.accessed(key)
+	}
+	entry.RecordAccess()
+	return entry, true
+}
+
+// GetEntry retrieves the entry stored under key, including its metadata.
+// Returns the entry and true if found and not expired, a zero entry and false otherwise.
+func (m *Memory) GetEntry(key string) (backends.CacheEntry, bool) {
+	return m.lookup(key)
+}
+
+// Peek retrieves a value like Get, but leaves the eviction order and access
+// counts untouched.
+func (m *Memory) Peek(key string) (any, bool) {
+	entry, ok := m.PeekEntry(key)
+	if !ok {
+		return nil, false
+	}
 	return entry.Value, true
 }
 
+// PeekEntry retrieves an entry like GetEntry, but leaves the eviction order
+// and access counts untouched.
+func (m *Memory) PeekEntry(key string) (backends.CacheEntry, bool) {
+	var entry backends.CacheEntry
+	var ok bool
+	if m.lockFree {
+		var v any
+		if v, ok = m.readMap.Load(key); ok {
+			entry = v.(backends.CacheEntry)
+		}
+	} else {
+		m.mu.RLock()
+		entry, ok = m.entries[key]
+		m.mu.RUnlock()
+	}
+	if !ok || entry.ExpiredAt(m.clock.Now()) {
+		return backends.CacheEntry{}, false
+	}
+	return entry, true
+}
+
+// AccessCount returns the number of reads of the entry stored under key.
+// Returns false if the key is missing or expired, or if access counting is
+// not enabled with WithAccessCounts.
+func (m *Memory) AccessCount(key string) (uint64, bool) {
+	if !m.trackAccesses {
+		return 0, false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, exists := m.entries[key]
+	if !exists || entry.ExpiredAt(m.clock.Now()) {
+		return 0, false
+	}
+
+	return entry.Accesses(), true
+}
+
 // Set stores a value in the cache with the given TTL (time-to-live).
 // If TTL is 0 or negative, the value will not expire.
 func (m *Memory) Set(key string, value any, ttl time.Duration) {
+	var size int64
+	if m.maxBytes > 0 {
+		size = m.sizeOf(value)
+	}
+
+	m.mu.Lock()
+	if m.maxBytes > 0 && size > m.maxBytes {
+		// Can never fit; drop any previous value rather than serve it stale
+		m.removeLocked(key)
+		m.mu.Unlock()
+		return
+	}
+
+	now := m.clock.Now()
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+
+	// Versions count writes to the key, so readers can tell rewrites apart
+	prev := m.entries[key]
+	entry := backends.NewEntryAt(value, expiresAt, prev.Version()+1)
+	entry.SetStoredAt(now)
+	if m.trackAccesses {
+		entry.TrackAccesses()
+	}
+	m.entries[key] = entry
+	if m.lockFree {
+		m.readMap.Store(key, entry)
+	}
+	m.scheduleLocked(key, expiresAt)
+	if m.sizes != nil {
+		m.bytes += size - m.sizes[key]
+		m.sizes[key] = size
+	}
+
+	var evicted []string
+	if m.policy != nil {
+		m.policy.added(key)
+		evicted = m.evictLocked()
+	}
+	listeners := m.onEvict
+	m.mu.Unlock()
+
+	for _, k := range evicted {
+		for _, fn := range listeners {
+			fn(k)
+		}
+	}
+}
+
+// evictLocked drops entries chosen by the eviction policy until the backend
+// is within its bounds, and returns the evicted keys. Callers must hold m.mu.
+func (m *Memory) evictLocked() []string {
+	var evicted []string
+	for m.overLocked() {
+		key, ok := m.policy.victim()
+		if !ok {
+			break
+		}
+		m.removeLocked(key)
+		evicted = append(evicted, key)
+	}
+	m.evictions.Add(uint64(len(evicted)))
+	return evicted
+}
+
+// scheduleLocked records when key expires so that Sweep can find it.
+// The heap is rebuilt from the live entries when stale items pile up.
+// Callers must hold m.mu.
+func (m *Memory) scheduleLocked(key string, expiresAt time.Time) {
+	if expiresAt.IsZero() {
+		return
+	}
+	m.expiry.push(key, expiresAt.UnixNano())
+
+	if len(m.expiry) > 2*len(m.entries)+64 {
+		m.expiry = m.expiry[:0]
+		for k, e := range m.entries {
+			if at := e.ExpiresAt(); !at.IsZero() {
+				m.expiry = append(m.expiry, expiryItem{key: k, at: at.UnixNano()})
+			}
+		}
+		heap.Init(&m.expiry)
+	}
+}
+
+// overLocked reports whether the backend exceeds its entry or byte bounds.
+// Callers must hold m.mu.
+func (m *Memory) overLocked() bool {
+	return (m.maxEntries > 0 && len(m.entries) > m.maxEntries) ||
+		(m.maxBytes > 0 && m.bytes > m.maxBytes)
+}
+
+// removeLocked deletes key from the map and the eviction policy.
+// Callers must hold m.mu.
+func (m *Memory) removeLocked(key string) {
+	delete(m.entries, key)
+	if m.lockFree {
+		m.readMap.Delete(key)
+	}
+	if m.sizes != nil {
+		m.bytes -= m.sizes[key]
+		delete(m.sizes, key)
+	}
+	if m.policy != nil {
+		m.policy.removed(key)
+	}
+	m.untagLocked(key)
+}
+
+// DefaultTTL returns the TTL set with WithDefaultTTL, or zero.
+func (m *Memory) DefaultTTL() time.Duration {
+	return m.defaultTTL
+}
+
+// Len returns the current number of entries, including expired entries
+// that have not been swept yet.
+func (m *Memory) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.entries)
+}
+
+// Bytes returns the estimated number of bytes used by stored values.
+// It is only tracked when a byte budget is set with WithMaxBytes.
+func (m *Memory) Bytes() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.bytes
+}
+
+// Stats returns the number of entries and, when a byte budget is set with
+// WithMaxBytes, the estimated bytes they use; otherwise Bytes is -1.
+func (m *Memory) Stats() backends.Stats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	st := backends.Stats{Entries: int64(len(m.entries)), Bytes: -1}
+	if m.maxBytes > 0 {
+		st.Bytes = m.bytes
+	}
+	return st
+}
+
+// Evictions returns how many entries have been evicted to stay within
+// WithMaxEntries. Expired entries removed by sweeps are not counted.
+func (m *Memory) Evictions() uint64 {
+	return m.evictions.Load()
+}
+
+// OnEvict registers fn to be called with the key of every entry evicted to
+// stay within the backend's bounds. fn runs after the backend lock is
+// released and must be safe for concurrent use.
+func (m *Memory) OnEvict(fn func(key string)) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	var entry backends.CacheEntry
+	m.onEvict = append(m.onEvict, fn)
+}
 
-	entry = backends.NewEntry(value, ttl, entry.BumpVersion())
+// Touch resets the TTL of an existing entry to ttl from now.
+// If TTL is 0 or negative, the entry will no longer expire.
+// Returns false if the key is missing or expired.
+func (m *Memory) Touch(key string, ttl time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	entry, exists := m.entries[key]
+	if !exists || entry.ExpiredAt(now) {
+		return false
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+	entry.SetExpiresAt(expiresAt)
 	m.entries[key] = entry
+	if m.lockFree {
+		m.readMap.Store(key, entry)
+	}
+	m.scheduleLocked(key, expiresAt)
+	if m.policy != nil {
+		m.policy.accessed(key)
+	}
+	return true
+}
+
+// Expire makes an existing entry expire now. Reads miss from then on and the
+// entry is removed by the next sweep.
+// Returns false if the key is missing or already expired.
+func (m *Memory) Expire(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	entry, exists := m.entries[key]
+	if !exists || entry.ExpiredAt(now) {
+		return false
+	}
+
+	// Entries count as expired only once the clock is past their expiry
+	expiresAt := now.Add(-time.Nanosecond)
+	entry.SetExpiresAt(expiresAt)
+	m.entries[key] = entry
+	if m.lockFree {
+		m.readMap.Store(key, entry)
+	}
+	m.scheduleLocked(key, expiresAt)
+	return true
 }
 
 // Delete removes a value from the cache.
@@ -81,7 +628,52 @@ func (m *Memory) Delete(key string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	delete(m.entries, key)
+	m.removeLocked(key)
+}
+
+// GetMulti retrieves the live values stored under keys.
+func (m *Memory) GetMulti(keys []string) map[string]any {
+	out := make(map[string]any, len(keys))
+	for _, key := range keys {
+		if entry, ok := m.lookup(key); ok {
+			out[key] = entry.Value
+		}
+	}
+	return out
+}
+
+// SetMulti stores all items. Items are written one by one, with the same
+// size checks and evictions as Set.
+func (m *Memory) SetMulti(items []backends.BatchItem) {
+	for _, it := range items {
+		m.Set(it.Key, it.Value, it.TTL)
+	}
+}
+
+// DeleteMulti removes the values stored under keys under a single lock.
+func (m *Memory) DeleteMulti(keys []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		m.removeLocked(key)
+	}
+}
+
+// DeleteByPrefix removes every entry whose key starts with prefix.
+// It scans all keys under the write lock.
+func (m *Memory) DeleteByPrefix(prefix string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := 0
+	for key := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			m.removeLocked(key)
+			removed++
+		}
+	}
+	return removed
 }
 
 // Clear removes all values from the cache.
@@ -90,4 +682,23 @@ func (m *Memory) Clear() {
 	defer m.mu.Unlock()
 
 	clear(m.entries)
+	m.expiry = nil
+	m.readMap.Clear()
+	clear(m.sizes)
+	m.bytes = 0
+	m.tagged = nil
+	m.keyTags = nil
+	m.policy = m.newPolicy()
+}
+
+// newPolicy creates an empty eviction policy for the configured bound,
+// or nil if the backend is unbounded.
+func (m *Memory) newPolicy() evictionPolicy {
+	if m.maxEntries == 0 && m.maxBytes == 0 {
+		return nil
+	}
+	if m.policyKind == TinyLFU && m.maxEntries > 0 {
+		return newTinyLFUPolicy(m.maxEntries)
+	}
+	return newLRUPolicy()
 }
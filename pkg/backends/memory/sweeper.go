@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// idleSweepsBeforeBackoff is how many consecutive sweeps must evict
+	// nothing before the interval is doubled.
+	idleSweepsBeforeBackoff = 3
+
+	// heavyEvictionRatio is the share of entries a sweep must evict for the
+	// interval to be halved.
+	heavyEvictionRatio = 0.25
+)
+
+// sweeper tunes the interval between sweeps from recent eviction counts,
+// keeping it within [min, max].
+type sweeper struct {
+	mu       sync.Mutex
+	min, max time.Duration
+	interval time.Duration
+	idle     int // consecutive sweeps that evicted nothing
+}
+
+// newSweeper creates a sweeper starting at the min interval.
+func newSweeper(min, max time.Duration) *sweeper {
+	return &sweeper{min: min, max: max, interval: min}
+}
+
+// current returns the interval until the next sweep.
+func (s *sweeper) current() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.interval
+}
+
+// observe adjusts the interval after a sweep that evicted evicted of total entries.
+func (s *sweeper) observe(evicted, total int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case evicted == 0:
+		s.idle++
+		if s.idle >= idleSweepsBeforeBackoff {
+			s.idle = 0
+			s.interval = min(s.interval*2, s.max)
+		}
+	case float64(evicted) >= heavyEvictionRatio*float64(total):
+		s.idle = 0
+		s.interval = max(s.interval/2, s.min)
+	default:
+		s.idle = 0
+	}
+}
@@ -0,0 +1,61 @@
+package memory
+
+// SetTags associates tags with the entry stored under key, replacing the tags
+// it had. It does nothing if the key is not present.
+func (m *Memory) SetTags(key string, tags []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.entries[key]; !ok {
+		return
+	}
+	m.untagLocked(key)
+	if len(tags) == 0 {
+		return
+	}
+
+	if m.tagged == nil {
+		m.tagged = make(map[string]map[string]struct{})
+		m.keyTags = make(map[string][]string)
+	}
+	for _, tag := range tags {
+		keys := m.tagged[tag]
+		if keys == nil {
+			keys = make(map[string]struct{})
+			m.tagged[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	m.keyTags[key] = append([]string(nil), tags...)
+}
+
+// InvalidateTag removes every entry carrying tag and returns their keys.
+func (m *Memory) InvalidateTag(tag string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]string, 0, len(m.tagged[tag]))
+	for key := range m.tagged[tag] {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		m.removeLocked(key)
+	}
+	return keys
+}
+
+// untagLocked drops key from the tag index. Callers must hold m.mu.
+func (m *Memory) untagLocked(key string) {
+	tags, ok := m.keyTags[key]
+	if !ok {
+		return
+	}
+	for _, tag := range tags {
+		keys := m.tagged[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(m.tagged, tag)
+		}
+	}
+	delete(m.keyTags, key)
+}
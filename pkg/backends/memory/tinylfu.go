@@ -0,0 +1,209 @@
+package memory
+
+import (
+	"container/list"
+	"hash/maphash"
+)
+
+// Segments of the W-TinyLFU policy.
+const (
+	segWindow = iota
+	segProbation
+	segProtected
+)
+
+// tlfuItem is a key tracked by tinyLFUPolicy.
+type tlfuItem struct {
+	key string
+	seg int
+}
+
+// tinyLFUPolicy implements W-TinyLFU: new keys enter a small LRU window, and
+// only move on to the main segmented LRU if the frequency sketch rates them
+// higher than the entry they would displace. This keeps one-hit wonders from
+// pushing out entries that are read often.
+type tinyLFUPolicy struct {
+	window, probation, protected *list.List
+	windowCap, protectedCap      int
+	mainCap                      int
+	elems                        map[string]*list.Element
+	sketch                       *countMinSketch
+}
+
+// newTinyLFUPolicy creates a W-TinyLFU policy for capacity entries, using a
+// 1% window and a main area split 20/80 between probation and protected.
+func newTinyLFUPolicy(capacity int) *tinyLFUPolicy {
+	windowCap := max(1, capacity/100)
+	mainCap := max(0, capacity-windowCap)
+	return &tinyLFUPolicy{
+		window:       list.New(),
+		probation:    list.New(),
+		protected:    list.New(),
+		windowCap:    windowCap,
+		mainCap:      mainCap,
+		protectedCap: mainCap * 8 / 10,
+		elems:        make(map[string]*list.Element),
+		sketch:       newCountMinSketch(capacity),
+	}
+}
+
+func (p *tinyLFUPolicy) segment(seg int) *list.List {
+	switch seg {
+	case segWindow:
+		return p.window
+	case segProbation:
+		return p.probation
+	default:
+		return p.protected
+	}
+}
+
+func (p *tinyLFUPolicy) added(key string) {
+	if _, ok := p.elems[key]; ok {
+		p.accessed(key)
+		return
+	}
+	p.sketch.increment(key)
+	p.elems[key] = p.window.PushFront(&tlfuItem{key: key, seg: segWindow})
+}
+
+func (p *tinyLFUPolicy) accessed(key string) {
+	e, ok := p.elems[key]
+	if !ok {
+		return
+	}
+	p.sketch.increment(key)
+
+	it := e.Value.(*tlfuItem)
+	switch it.seg {
+	case segWindow, segProtected:
+		p.segment(it.seg).MoveToFront(e)
+	case segProbation:
+		// A second hit in probation earns a protected slot
+		p.move(e, segProtected)
+		for p.protected.Len() > p.protectedCap {
+			p.move(p.protected.Back(), segProbation)
+		}
+	}
+}
+
+func (p *tinyLFUPolicy) removed(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.segment(e.Value.(*tlfuItem).seg).Remove(e)
+		delete(p.elems, key)
+	}
+}
+
+func (p *tinyLFUPolicy) victim() (string, bool) {
+	if p.window.Len() > p.windowCap {
+		// The window overflowed: its oldest key asks to be admitted to the
+		// main area and competes with the main area's own eviction candidate
+		e := p.window.Back()
+		candidate := e.Value.(*tlfuItem).key
+		p.move(e, segProbation)
+
+		if p.probation.Len()+p.protected.Len() <= p.mainCap {
+			return p.victim()
+		}
+		incumbent, ok := p.mainVictim(candidate)
+		if ok && p.sketch.estimate(candidate) > p.sketch.estimate(incumbent) {
+			return incumbent, true
+		}
+		return candidate, true
+	}
+
+	if key, ok := p.mainVictim(""); ok {
+		return key, true
+	}
+	if e := p.window.Back(); e != nil {
+		return e.Value.(*tlfuItem).key, true
+	}
+	return "", false
+}
+
+// mainVictim returns the main area's eviction candidate other than skip,
+// preferring the oldest probation entry.
+func (p *tinyLFUPolicy) mainVictim(skip string) (string, bool) {
+	for _, l := range []*list.List{p.probation, p.protected} {
+		for e := l.Back(); e != nil; e = e.Prev() {
+			if key := e.Value.(*tlfuItem).key; key != skip {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// move transfers e to the front of segment seg.
+func (p *tinyLFUPolicy) move(e *list.Element, seg int) {
+	it := e.Value.(*tlfuItem)
+	p.segment(it.seg).Remove(e)
+	it.seg = seg
+	p.elems[it.key] = p.segment(seg).PushFront(it)
+}
+
+// countMinSketch estimates key frequencies in a fixed amount of memory.
+// Counters saturate at 15 and are halved periodically so that the sketch
+// follows changes in popularity.
+type countMinSketch struct {
+	rows      [4][]uint8
+	mask      uint64
+	seed      maphash.Seed
+	additions int
+	resetAt   int
+}
+
+// newCountMinSketch sizes the sketch for roughly capacity distinct keys.
+// Rows are several times wider than capacity so that the many one-off keys
+// seen between resets rarely collide with the keys being tracked.
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := 16
+	for width < 8*capacity {
+		width <<= 1
+	}
+	s := &countMinSketch{
+		mask:    uint64(width - 1),
+		seed:    maphash.MakeSeed(),
+		resetAt: 10 * max(capacity, 1),
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// indexes returns the counter index of key in each row.
+func (s *countMinSketch) indexes(key string) [4]uint64 {
+	h := maphash.String(s.seed, key)
+	var idx [4]uint64
+	for i := range idx {
+		idx[i] = (h >> (16 * i)) & s.mask
+		h = h*0x9E3779B97F4A7C15 + uint64(i)
+	}
+	return idx
+}
+
+func (s *countMinSketch) increment(key string) {
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < 15 {
+			s.rows[i][j]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.additions = 0
+		for i := range s.rows {
+			for j := range s.rows[i] {
+				s.rows[i][j] >>= 1
+			}
+		}
+	}
+}
+
+func (s *countMinSketch) estimate(key string) uint8 {
+	est := uint8(15)
+	for i, j := range s.indexes(key) {
+		est = min(est, s.rows[i][j])
+	}
+	return est
+}
@@ -0,0 +1,577 @@
+// Package peer provides a distributed in-process cache backend in the style
+// of groupcache: nodes form a ring of HTTP peers and every key is held by
+// the one node that owns it.
+package peer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ldaidone/gomemo/internals/hashring"
+	"github.com/ldaidone/gomemo/internals/wire"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+const (
+	// DefaultBasePath is the URL path below which nodes serve each other.
+	DefaultBasePath = "/_gomemo/"
+
+	// defaultReplicas is how many points every peer gets on the hash ring.
+	defaultReplicas = 50
+)
+
+// ErrGetterPanic is returned for a key whose Getter panicked.
+var ErrGetterPanic = errors.New("peer: getter panicked")
+
+// Getter loads the value of a key on the node that owns it. ttl is how long
+// the owner keeps the value. ctx carries the values of the first caller's
+// context but not its cancellation, since other callers share the load.
+type Getter func(ctx context.Context, key string) (value any, ttl time.Duration, err error)
+
+// Node is one member of a peer ring. Every key is owned by one node, chosen
+// by consistent hashing over the peer URLs: the owner stores the value in
+// its local backend and the other nodes read and write it over HTTP. The
+// cache scales horizontally with the number of nodes and needs no external
+// store, at the cost of a network round trip for keys owned elsewhere and of
+// losing a node's keys when it leaves.
+//
+// With a Getter, the owner also computes values: a miss for a key it owns,
+// whether local or requested by a peer, runs the Getter once on the owner
+// and every node receives the result. Without one, the node asking computes
+// the value and stores it on the owner.
+//
+// A Node must be mounted as an http.Handler at its base path on the address
+// it announces, and told about its peers with SetPeers.
+type Node struct {
+	self         string           // this node's base URL
+	basePath     string           // path below which peers are served
+	local        backends.Backend // values owned by this node
+	client       *http.Client
+	codec        backends.Codec
+	getter       Getter
+	replicas     int
+	errorHandler func(op string, err error) // receives errors that cannot be returned; nil logs them
+
+	mu    sync.RWMutex
+	ring  *hashring.Ring
+	peers []string
+
+	loadMu sync.Mutex
+	loads  map[string]*load // Getter calls in progress, by key
+}
+
+// load is a Getter call in progress. done is closed once entry and err are set.
+type load struct {
+	done  chan struct{}
+	entry backends.CacheEntry
+	err   error
+}
+
+var (
+	_ backends.Backend    = (*Node)(nil)
+	_ backends.V2Provider = (*Node)(nil)
+	_ backends.Closer     = (*Node)(nil)
+	_ http.Handler        = (*Node)(nil)
+)
+
+// Option configures a Node.
+type Option func(*Node)
+
+// WithLocal stores the values this node owns in b instead of an unbounded
+// memory backend.
+func WithLocal(b backends.Backend) Option {
+	return func(n *Node) {
+		if b != nil {
+			n.local = b
+		}
+	}
+}
+
+// WithGetter makes the node compute the values of keys it owns with fn when
+// they are missing, so each value is computed once across the ring.
+func WithGetter(fn Getter) Option {
+	return func(n *Node) {
+		n.getter = fn
+	}
+}
+
+// WithHTTPClient sends requests to peers with c instead of
+// http.DefaultClient, e.g. to set timeouts or TLS configuration.
+func WithHTTPClient(c *http.Client) Option {
+	return func(n *Node) {
+		if c != nil {
+			n.client = c
+		}
+	}
+}
+
+// WithBasePath serves and reaches peers below path instead of
+// DefaultBasePath. Every node of a ring must use the same path.
+func WithBasePath(path string) Option {
+	return func(n *Node) {
+		if !strings.HasSuffix(path, "/") {
+			path += "/"
+		}
+		n.basePath = path
+	}
+}
+
+// WithReplicas sets how many points every peer gets on the hash ring; the
+// default is 50. More points spread keys more evenly. Every node of a ring
+// must use the same number.
+func WithReplicas(r int) Option {
+	return func(n *Node) {
+		if r > 0 {
+			n.replicas = r
+		}
+	}
+}
+
+// WithCodec selects how values are serialized between peers. The default is
+// backends.GobCodec. Every node of a ring must use the same codec.
+func WithCodec(c backends.Codec) Option {
+	return func(n *Node) {
+		if c != nil {
+			n.codec = c
+		}
+	}
+}
+
+// WithErrorHandler passes errors the Backend interface cannot return, such as
+// unreachable peers, to fn instead of logging them with the standard log
+// package. op names the failed operation, e.g. "get". The context-aware form
+// returned by V2 returns errors instead.
+func WithErrorHandler(fn func(op string, err error)) Option {
+	return func(n *Node) {
+		n.errorHandler = fn
+	}
+}
+
+// New creates the node announced at self, a base URL such as
+// "http://10.0.0.1:8080". Until SetPeers is called the node owns every key.
+//
+// Example:
+//
+//	node := peer.New("http://10.0.0.1:8080")
+//	node.SetPeers("http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://10.0.0.3:8080")
+//	http.Handle(peer.DefaultBasePath, node)
+//	m := memo.New(memo.WithBackend(node))
+func New(self string, opts ...Option) *Node {
+	n := &Node{
+		self:     strings.TrimSuffix(self, "/"),
+		basePath: DefaultBasePath,
+		client:   http.DefaultClient,
+		codec:    backends.GobCodec(),
+		replicas: defaultReplicas,
+		ring:     &hashring.Ring{},
+		loads:    make(map[string]*load),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	if n.local == nil {
+		n.local = memory.New()
+	}
+	return n
+}
+
+// SetPeers replaces the ring's members with peers, base URLs that should
+// include this node's own. Keys whose owner changes become misses until they
+// are stored again.
+func (n *Node) SetPeers(peers ...string) {
+	trimmed := make([]string, len(peers))
+	for i, p := range peers {
+		trimmed[i] = strings.TrimSuffix(p, "/")
+	}
+	r := hashring.New(n.replicas, trimmed)
+
+	n.mu.Lock()
+	n.ring, n.peers = r, trimmed
+	n.mu.Unlock()
+}
+
+// Owner returns the base URL of the node owning key.
+func (n *Node) Owner(key string) string {
+	n.mu.RLock()
+	owner := n.ring.Owner(key)
+	n.mu.RUnlock()
+	if owner == "" {
+		return n.self
+	}
+	return owner
+}
+
+// Close closes the local backend if it has a Close method.
+func (n *Node) Close() error {
+	if c, ok := n.local.(backends.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// V2 returns the context-aware form of the node.
+func (n *Node) V2() backends.BackendV2 {
+	return contextBackend{n}
+}
+
+// -----------------------------------------------------------------------------
+// Backend interface
+// -----------------------------------------------------------------------------
+
+func (n *Node) Get(key string) (any, bool) {
+	v, ok, err := n.get(context.Background(), key)
+	if err != nil {
+		n.onError("get", err)
+		return nil, false
+	}
+	return v, ok
+}
+
+func (n *Node) Set(key string, value any, ttl time.Duration) {
+	if err := n.set(context.Background(), key, value, ttl); err != nil {
+		n.onError("set", err)
+	}
+}
+
+func (n *Node) Delete(key string) {
+	if err := n.delete(context.Background(), key); err != nil {
+		n.onError("delete", err)
+	}
+}
+
+// Clear removes every value from this node and its peers.
+func (n *Node) Clear() {
+	if err := n.clear(context.Background()); err != nil {
+		n.onError("clear", err)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Operations
+// -----------------------------------------------------------------------------
+
+func (n *Node) get(ctx context.Context, key string) (any, bool, error) {
+	if owner := n.Owner(key); owner != n.self {
+		return n.fetch(ctx, owner, key)
+	}
+	entry, ok, err := n.getLocal(ctx, key)
+	if !ok || err != nil {
+		return nil, false, err
+	}
+	return entry.Value, true, nil
+}
+
+func (n *Node) set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	owner := n.Owner(key)
+	if owner == n.self {
+		n.local.Set(key, value, ttl)
+		return nil
+	}
+	body, err := n.encode(backends.NewEntry(value, ttl, 0))
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
+	return n.send(ctx, http.MethodPut, n.keyURL(owner, key), body)
+}
+
+func (n *Node) delete(ctx context.Context, key string) error {
+	owner := n.Owner(key)
+	if owner == n.self {
+		n.local.Delete(key)
+		return nil
+	}
+	return n.send(ctx, http.MethodDelete, n.keyURL(owner, key), nil)
+}
+
+// clear clears the local backend and asks every peer to clear its own.
+func (n *Node) clear(ctx context.Context) error {
+	n.local.Clear()
+
+	n.mu.RLock()
+	peers := n.peers
+	n.mu.RUnlock()
+
+	var errs []error
+	for _, p := range peers {
+		if p == n.self {
+			continue
+		}
+		if err := n.send(ctx, http.MethodDelete, p+n.basePath, nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("clear %d of %d peers failed: %w", len(errs), len(peers)-1, errs[0])
+	}
+	return nil
+}
+
+// getLocal reads a key this node owns, loading it with the Getter on a miss.
+func (n *Node) getLocal(ctx context.Context, key string) (backends.CacheEntry, bool, error) {
+	if e, ok := n.local.(backends.EntryBackend); ok {
+		if entry, ok := e.GetEntry(key); ok {
+			return entry, true, nil
+		}
+	} else if v, ok := n.local.Get(key); ok {
+		return backends.NewEntry(v, 0, 0), true, nil
+	}
+	if n.getter == nil {
+		return backends.CacheEntry{}, false, nil
+	}
+	return n.load(ctx, key)
+}
+
+// load runs the Getter for key and stores the result, sharing one call
+// between concurrent misses. The Getter runs without the caller's
+// cancellation, since the other callers waiting for it may still want the
+// result, and a panic in it fails the callers with ErrGetterPanic.
+func (n *Node) load(ctx context.Context, key string) (backends.CacheEntry, bool, error) {
+	n.loadMu.Lock()
+	if l, ok := n.loads[key]; ok {
+		n.loadMu.Unlock()
+		select {
+		case <-l.done:
+			return l.entry, l.err == nil, l.err
+		case <-ctx.Done():
+			return backends.CacheEntry{}, false, ctx.Err()
+		}
+	}
+	l := &load{done: make(chan struct{})}
+	n.loads[key] = l
+	n.loadMu.Unlock()
+
+	n.runGetter(context.WithoutCancel(ctx), key, l)
+	return l.entry, l.err == nil, l.err
+}
+
+// runGetter runs the Getter for key, fills in l and releases its waiters,
+// even if the Getter panics.
+func (n *Node) runGetter(ctx context.Context, key string, l *load) {
+	defer func() {
+		if r := recover(); r != nil {
+			l.entry, l.err = backends.CacheEntry{}, fmt.Errorf("%w: %v\n%s", ErrGetterPanic, r, debug.Stack())
+		}
+		close(l.done)
+		n.loadMu.Lock()
+		delete(n.loads, key)
+		n.loadMu.Unlock()
+	}()
+
+	v, ttl, err := n.getter(ctx, key)
+	if err == nil {
+		n.local.Set(key, v, ttl)
+		l.entry = backends.NewEntry(v, ttl, 0)
+	}
+	l.err = err
+}
+
+// -----------------------------------------------------------------------------
+// HTTP
+// -----------------------------------------------------------------------------
+
+// ServeHTTP serves peers' requests for keys this node owns:
+//
+//	GET    <base>/<key>  returns the value, 404 if missing
+//	PUT    <base>/<key>  stores the value in the body
+//	DELETE <base>/<key>  removes the value
+//	DELETE <base>/       clears the local backend
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutPrefix(r.URL.EscapedPath(), n.basePath)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	key, err := url.PathUnescape(rest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && key != "":
+		entry, ok, err := n.getLocal(r.Context(), key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		body, err := n.encode(entry)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(body)
+
+	case r.Method == http.MethodPut && key != "":
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		entry, err := n.decode(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var ttl time.Duration
+		if exp := entry.ExpiresAt(); !exp.IsZero() {
+			if ttl = time.Until(exp); ttl <= 0 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		n.local.Set(key, entry.Value, ttl)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodDelete:
+		if key == "" {
+			n.local.Clear()
+		} else {
+			n.local.Delete(key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// fetch reads key from its owner.
+func (n *Node) fetch(ctx context.Context, owner, key string) (any, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.keyURL(owner, key), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, false, nil
+	default:
+		return nil, false, peerError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	entry, err := n.decode(body)
+	if err != nil {
+		return nil, false, fmt.Errorf("decode %s: %w", key, err)
+	}
+	if entry.IsExpired() {
+		return nil, false, nil
+	}
+	return entry.Value, true, nil
+}
+
+// send issues a write request to a peer.
+func (n *Node) send(ctx context.Context, method, u string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return peerError(resp)
+	}
+	return nil
+}
+
+func (n *Node) keyURL(owner, key string) string {
+	return owner + n.basePath + url.PathEscape(key)
+}
+
+// peerError describes a failed response, including the start of its body.
+func peerError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("peer %s: %s: %s", resp.Request.URL.Host, resp.Status, bytes.TrimSpace(msg))
+}
+
+// -----------------------------------------------------------------------------
+// Serialization
+// -----------------------------------------------------------------------------
+
+// encode serializes an entry for a peer.
+func (n *Node) encode(entry backends.CacheEntry) ([]byte, error) {
+	payload, err := n.codec.Encode(backends.NewRecord(entry))
+	if err != nil {
+		return nil, err
+	}
+	return wire.Encode(wire.Header{Codec: n.codec.ID()}, payload), nil
+}
+
+// decode deserializes an entry sent by a peer.
+func (n *Node) decode(data []byte) (backends.CacheEntry, error) {
+	hdr, payload, err := wire.Decode(data)
+	if err != nil {
+		return backends.CacheEntry{}, err
+	}
+	if hdr.Flags != 0 || hdr.Codec != n.codec.ID() {
+		return backends.CacheEntry{}, fmt.Errorf("%w: codec %d, flags %#x", wire.ErrUnknownFormat, hdr.Codec, hdr.Flags)
+	}
+	var rec backends.Record
+	if err := n.codec.Decode(payload, &rec); err != nil {
+		return backends.CacheEntry{}, err
+	}
+	return rec.Entry(), nil
+}
+
+// onError reports an error that cannot be returned to the caller.
+func (n *Node) onError(op string, err error) {
+	if n.errorHandler != nil {
+		n.errorHandler(op, err)
+		return
+	}
+	log.Printf("[gomemo][peer] %s error: %v\n", op, err)
+}
+
+// -----------------------------------------------------------------------------
+// BackendV2 interface
+// -----------------------------------------------------------------------------
+
+// contextBackend exposes a Node through backends.BackendV2.
+type contextBackend struct {
+	*Node
+}
+
+var _ backends.BackendV2 = contextBackend{}
+
+func (c contextBackend) Get(ctx context.Context, key string) (any, bool, error) {
+	return c.get(ctx, key)
+}
+
+func (c contextBackend) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return c.set(ctx, key, value, ttl)
+}
+
+func (c contextBackend) Delete(ctx context.Context, key string) error {
+	return c.delete(ctx, key)
+}
+
+func (c contextBackend) Clear(ctx context.Context) error {
+	return c.clear(ctx)
+}
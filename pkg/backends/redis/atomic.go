@@ -0,0 +1,138 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Entries are opaque to Redis: their version and logical expiry are inside
+// the encoded, possibly encrypted payload. Atomic updates therefore decode
+// the entry here and swap the bytes in Redis with swapScript, which only
+// writes if the key still holds the bytes that were read.
+
+// swapScript sets KEYS[1] to ARGV[2] with a TTL of ARGV[3] milliseconds (none
+// if 0) if it currently holds ARGV[1]. Returns 1 if it wrote.
+var swapScript = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// touchAttempts bounds how often GetAndTouch retries when the entry keeps
+// changing between reading and rewriting it. The last version read is then
+// returned untouched: whoever changed it has set its expiry already.
+const touchAttempts = 3
+
+// CompareAndSet stores value under key if the stored entry has the given
+// version. The check and the write run in one Lua script, so of several
+// processes updating the same version exactly one succeeds.
+func (r *redisBackend) CompareAndSet(key string, value any, ttl time.Duration, version uint64) bool {
+	ok, err := r.compareAndSet(r.ctx, key, value, ttl, version)
+	if err != nil {
+		r.onError("compare and set", err)
+	}
+	return ok
+}
+
+// compareAndSet implements CompareAndSet, reporting failures instead of
+// logging them.
+func (r *redisBackend) compareAndSet(ctx context.Context, key string, value any, ttl time.Duration, version uint64) (bool, error) {
+	old, entry, ok, err := r.getRaw(ctx, key)
+	if err != nil || !ok || entry.Version() != version {
+		return false, err
+	}
+	data, err := r.encodeEntry(key, backends.NewEntry(value, ttl, version+1))
+	if err != nil {
+		return false, fmt.Errorf("encode %s: %w", key, err)
+	}
+	if r.maxSize > 0 && len(data) > r.maxSize {
+		return false, fmt.Errorf("%w: %s (%d > %d bytes)", ErrValueTooLarge, key, len(data), r.maxSize)
+	}
+	return r.swap(ctx, key, old, data, ttl)
+}
+
+// SetIfAbsent stores value under key with SET NX, unless the key exists.
+func (r *redisBackend) SetIfAbsent(key string, value any, ttl time.Duration) bool {
+	ok, err := r.setIfAbsent(r.ctx, key, value, ttl)
+	if err != nil {
+		r.onError("set if absent", err)
+	}
+	return ok
+}
+
+// setIfAbsent implements SetIfAbsent, reporting failures instead of logging them.
+func (r *redisBackend) setIfAbsent(ctx context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	data, err := r.encodeEntry(key, backends.NewEntry(value, ttl, 0))
+	if err != nil {
+		return false, fmt.Errorf("encode %s: %w", key, err)
+	}
+	if r.maxSize > 0 && len(data) > r.maxSize {
+		return false, fmt.Errorf("%w: %s (%d > %d bytes)", ErrValueTooLarge, key, len(data), r.maxSize)
+	}
+	return r.client.SetNX(ctx, r.prefixed(key), data, ttl).Result()
+}
+
+// GetAndTouch retrieves the value stored under key and resets its TTL to ttl
+// from now. The entry is rewritten with its new logical expiry only if it is
+// unchanged, so a concurrent Set or Delete is never undone; if the entry
+// keeps changing, GetAndTouch gives up after a few attempts and returns the
+// value it last read without touching it. A touch costs a GET, decoding and
+// re-encoding the entry, and a script call, where a plain Get costs the GET
+// and the decoding only.
+func (r *redisBackend) GetAndTouch(key string, ttl time.Duration) (any, bool) {
+	entry, ok, err := r.touch(r.ctx, key, ttl)
+	if err != nil {
+		r.onError("touch", err)
+	}
+	return entry.Value, ok
+}
+
+// touch implements GetAndTouch and Touch, reporting failures instead of
+// logging them.
+func (r *redisBackend) touch(ctx context.Context, key string, ttl time.Duration) (backends.CacheEntry, bool, error) {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	var last backends.CacheEntry
+	for range touchAttempts {
+		old, entry, ok, err := r.getRaw(ctx, key)
+		if err != nil || !ok {
+			return backends.CacheEntry{}, false, err
+		}
+		last = entry
+		entry.SetExpiresAt(expiresAt)
+		data, err := r.encodeEntry(key, entry)
+		if err != nil {
+			return backends.CacheEntry{}, false, fmt.Errorf("encode %s: %w", key, err)
+		}
+		swapped, err := r.swap(ctx, key, old, data, ttl)
+		if err != nil {
+			return backends.CacheEntry{}, false, err
+		}
+		if swapped {
+			return entry, true, nil
+		}
+	}
+	return last, true, nil
+}
+
+// swap replaces the bytes old stored under key with data, see swapScript.
+func (r *redisBackend) swap(ctx context.Context, key string, old, data []byte, ttl time.Duration) (bool, error) {
+	ms := ttl.Milliseconds()
+	if ttl > 0 && ms == 0 {
+		ms = 1
+	}
+	n, err := swapScript.Run(ctx, r.client, []string{r.prefixed(key)}, old, data, ms).Int()
+	return n == 1, err
+}
@@ -0,0 +1,83 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/ldaidone/gomemo/pkg/invalidation"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// DefaultBusChannel is the pub/sub channel NewBus uses unless given another.
+const DefaultBusChannel = "gomemo:invalidations"
+
+// Bus is an invalidation.Bus over Redis pub/sub. Unlike Invalidate, which
+// mirrors deletions of keys stored in Redis, it carries explicit
+// invalidation messages, so it also works for processes that only cache
+// locally and for producers outside the application.
+type Bus struct {
+	client  goredis.UniversalClient
+	channel string
+}
+
+var _ invalidation.Bus = (*Bus)(nil)
+
+// NewBus creates a bus publishing on channel, or on DefaultBusChannel if
+// channel is empty.
+//
+// Example:
+//
+//	bus := redis.NewBus(client, "")
+//	m := memo.New(memo.WithBackend(memory.New()), memo.WithInvalidationBus(bus))
+func NewBus(client goredis.UniversalClient, channel string) *Bus {
+	if channel == "" {
+		channel = DefaultBusChannel
+	}
+	return &Bus{client: client, channel: channel}
+}
+
+// Publish sends msg on the bus channel.
+func (b *Bus) Publish(ctx context.Context, msg invalidation.Message) error {
+	data, err := invalidation.Encode(msg)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe calls fn with every message on the bus channel until the
+// subscription is closed or ctx is done. Messages published while the
+// connection is being re-established are missed.
+func (b *Bus) Subscribe(ctx context.Context, fn func(invalidation.Message)) (io.Closer, error) {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("subscribe to %s: %w", b.channel, err)
+	}
+
+	sub := &Invalidator{pubsub: pubsub, done: make(chan struct{})}
+	msgs := pubsub.Channel()
+	go func() {
+		defer close(sub.done)
+		for {
+			select {
+			case <-ctx.Done():
+				sub.unsubscribe()
+				return
+			case m, ok := <-msgs:
+				if !ok {
+					return
+				}
+				msg, err := invalidation.Decode([]byte(m.Payload))
+				if err != nil {
+					log.Printf("[gomemo][redis] bus decode error: %v\n", err)
+					continue
+				}
+				fn(msg)
+			}
+		}
+	}()
+	return sub, nil
+}
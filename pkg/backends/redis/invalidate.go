@@ -0,0 +1,138 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ldaidone/gomemo/pkg/backends"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// ErrNotRedis is returned by Invalidate for backends not created by this package.
+var ErrNotRedis = errors.New("not a gomemo redis backend")
+
+// invalidatingEvents are the keyspace events after which a key's value is gone.
+// UNLINK is reported as "del".
+var invalidatingEvents = map[string]bool{
+	"del":     true,
+	"expired": true,
+	"evicted": true,
+}
+
+// Invalidator keeps an in-process cache in front of a Redis backend from
+// serving values that were removed centrally. It is created by Invalidate,
+// and also ends the subscriptions returned by Bus.Subscribe.
+type Invalidator struct {
+	pubsub *goredis.PubSub
+	done   chan struct{}
+	once   sync.Once
+	err    error // result of closing pubsub
+}
+
+// Invalidate subscribes to Redis keyspace notifications for the keys of
+// remote, a backend created by this package, and deletes every key that is
+// deleted, expires or is evicted in Redis from local. This lets each process
+// keep a fast local copy of hot entries, e.g. in a memory backend, while a
+// Delete, Clear or tag invalidation on any process still reaches all of them.
+//
+// Redis only publishes these notifications when configured to, e.g. with
+//
+//	CONFIG SET notify-keyspace-events Kgxe
+//
+// Notifications are fire-and-forget: events published while the
+// subscription is being re-established after a connection loss are missed,
+// so local entries should still carry a TTL. Writes are not reported, since
+// they would also evict the values a process just wrote itself.
+//
+// The subscription lasts until Close is called or ctx is done.
+//
+// Example:
+//
+//	inv, err := redis.Invalidate(ctx, remote, local)
+//	if err != nil {
+//	    return err
+//	}
+//	defer inv.Close()
+func Invalidate(ctx context.Context, remote any, local backends.Backend) (*Invalidator, error) {
+	var r *redisBackend
+	switch b := remote.(type) {
+	case *redisBackend:
+		r = b
+	case contextBackend:
+		r = b.redisBackend
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrNotRedis, remote)
+	}
+
+	client, db, err := r.notificationClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	channelPrefix := fmt.Sprintf("__keyspace@%d__:", db) + r.prefix
+	pubsub := client.PSubscribe(ctx, escapeGlob(channelPrefix)+"*")
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("subscribe to keyspace notifications: %w", err)
+	}
+
+	inv := &Invalidator{pubsub: pubsub, done: make(chan struct{})}
+	msgs := pubsub.Channel()
+	go func() {
+		defer close(inv.done)
+		for {
+			select {
+			case <-ctx.Done():
+				inv.unsubscribe()
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				if !invalidatingEvents[msg.Payload] {
+					continue
+				}
+				key, ok := strings.CutPrefix(msg.Channel, channelPrefix)
+				if !ok || strings.HasPrefix(key, "__tag") {
+					continue // tag bookkeeping, see tags.go
+				}
+				local.Delete(key)
+			}
+		}
+	}()
+	return inv, nil
+}
+
+// Close ends the subscription and waits for pending invalidations to finish.
+func (inv *Invalidator) Close() error {
+	inv.unsubscribe()
+	<-inv.done
+	return inv.err
+}
+
+// unsubscribe closes the subscription once.
+func (inv *Invalidator) unsubscribe() {
+	inv.once.Do(func() {
+		inv.err = inv.pubsub.Close()
+	})
+}
+
+// notificationClient returns the client to subscribe with and the database
+// whose notifications to receive. On a cluster, notifications are only
+// published on the node holding a key, which for the hash-tagged prefix is
+// the master of its slot.
+func (r *redisBackend) notificationClient(ctx context.Context) (goredis.UniversalClient, int, error) {
+	switch c := r.client.(type) {
+	case *goredis.ClusterClient:
+		node, err := c.MasterForKey(ctx, r.prefix)
+		if err != nil {
+			return nil, 0, err
+		}
+		return node, 0, nil
+	case *goredis.Client:
+		return c, c.Options().DB, nil
+	}
+	return r.client, r.conn.DB, nil
+}
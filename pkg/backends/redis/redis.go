@@ -2,46 +2,388 @@
 package redis
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ldaidone/gomemo/internals/wire"
 	"github.com/ldaidone/gomemo/pkg/backends"
 	goredis "github.com/redis/go-redis/v9"
 )
 
 // redisBackend implements the backends.Backend interface for Redis.
-// It stores values in Redis with serialization using gob encoding
+// It stores values in Redis serialized with a backends.Codec (gob by default)
 // and manages expiration times using Redis TTL.
 type redisBackend struct {
-	client *goredis.Client // Redis client connection
-	prefix string          // Key prefix to namespace gomemo keys
-	ctx    context.Context // Context for Redis operations
+	client        goredis.UniversalClient    // Redis client connection
+	ownsClient    bool                       // Whether Close closes client
+	prefix        string                     // Key prefix to namespace gomemo keys
+	ctx           context.Context            // Context for Redis operations
+	consistency   ExpiryConsistency          // How strictly logical TTLs are enforced on reads
+	maxSize       int                        // Maximum serialized entry size in bytes; 0 means unlimited
+	codec         backends.Codec             // Serializes entries; gob unless WithCodec is given
+	compressor    backends.Compressor        // Compresses large entries; nil disables compression
+	compressAbove int                        // Minimum encoded size in bytes before compressing
+	encrypter     backends.Encrypter         // Encrypts entries at rest; nil stores them in the clear
+	signKey       []byte                     // HMAC key signing entries; nil disables signing
+	conn          goredis.UniversalOptions   // Settings for clients the backend creates itself
+	errorHandler  func(op string, err error) // Receives errors that cannot be returned; nil logs them
+	scanCount     int                        // COUNT hint for SCAN when clearing keys
+	defaultTTL    time.Duration              // Reported by DefaultTTL; 0 means none
+
+	corruptMu sync.Mutex         // guards onCorrupt
+	onCorrupt []func(key string) // listeners for entries failing verification
+}
+
+var (
+	_ backends.EntryBackend       = (*redisBackend)(nil)
+	_ backends.Toucher            = (*redisBackend)(nil)
+	_ backends.Closer             = (*redisBackend)(nil)
+	_ backends.BatchBackend       = (*redisBackend)(nil)
+	_ backends.Expirer            = (*redisBackend)(nil)
+	_ backends.AtomicBackend      = (*redisBackend)(nil)
+	_ backends.PrefixDeleter      = (*redisBackend)(nil)
+	_ backends.Tagger             = (*redisBackend)(nil)
+	_ backends.CorruptionNotifier = (*redisBackend)(nil)
+	_ backends.V2Provider         = (*redisBackend)(nil)
+	_ backends.DefaultTTLProvider = (*redisBackend)(nil)
+)
+
+// ErrValueTooLarge is returned by the context-aware backend when an encoded
+// entry exceeds the WithMaxValueSize limit.
+var ErrValueTooLarge = errors.New("value too large")
+
+// ExpiryConsistency controls how strictly an entry's logical TTL is enforced on reads.
+type ExpiryConsistency int
+
+const (
+	// Strong checks the entry's logical expiry on every read, treating expired
+	// entries as absent and deleting them eagerly, even if Redis has not yet
+	// reclaimed the key. This is the default.
+	Strong ExpiryConsistency = iota
+
+	// Lazy trusts Redis' native TTL and returns whatever is still stored,
+	// saving the expiry check and the extra DEL round trip.
+	Lazy
+)
+
+// Option configures a Redis backend.
+type Option func(*redisBackend)
+
+// The connection options below configure the clients created by New,
+// NewCluster and NewSentinel. They are ignored by NewWithClient, whose
+// client is already configured.
+
+// WithUsername sets the ACL user used to authenticate with Redis.
+func WithUsername(username string) Option {
+	return func(r *redisBackend) {
+		r.conn.Username = username
+	}
+}
+
+// WithPassword sets the password used to authenticate with Redis.
+func WithPassword(password string) Option {
+	return func(r *redisBackend) {
+		r.conn.Password = password
+	}
+}
+
+// WithSentinelPassword sets the password used to authenticate with the
+// sentinels of NewSentinel, if it differs from the data nodes'.
+func WithSentinelPassword(password string) Option {
+	return func(r *redisBackend) {
+		r.conn.SentinelPassword = password
+	}
+}
+
+// WithTLS connects to Redis over TLS using cfg.
+func WithTLS(cfg *tls.Config) Option {
+	return func(r *redisBackend) {
+		r.conn.TLSConfig = cfg
+	}
+}
+
+// WithDialTimeout bounds how long establishing a connection may take.
+func WithDialTimeout(d time.Duration) Option {
+	return func(r *redisBackend) {
+		r.conn.DialTimeout = d
+	}
+}
+
+// WithReadTimeout bounds how long reading a reply may take.
+func WithReadTimeout(d time.Duration) Option {
+	return func(r *redisBackend) {
+		r.conn.ReadTimeout = d
+	}
+}
+
+// WithWriteTimeout bounds how long writing a command may take.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(r *redisBackend) {
+		r.conn.WriteTimeout = d
+	}
+}
+
+// WithPoolSize sets the maximum number of connections per node.
+func WithPoolSize(n int) Option {
+	return func(r *redisBackend) {
+		r.conn.PoolSize = n
+	}
+}
+
+// WithMinIdleConns keeps at least n idle connections open per node.
+func WithMinIdleConns(n int) Option {
+	return func(r *redisBackend) {
+		r.conn.MinIdleConns = n
+	}
+}
+
+// WithClientOptions edits the go-redis options directly, for settings
+// without a dedicated option. Addresses, database and master name are set
+// by the constructor afterwards.
+func WithClientOptions(fn func(*goredis.UniversalOptions)) Option {
+	return func(r *redisBackend) {
+		fn(&r.conn)
+	}
+}
+
+// WithErrorHandler sends the errors of calls that cannot return them, such
+// as Get, Set and the batch methods of the Backend interface, to fn instead
+// of the standard logger. op names the failed operation, e.g. "get" or "set".
+// The context-aware methods of NewV2 return their errors instead.
+//
+// Example:
+//
+//	redis.WithErrorHandler(func(op string, err error) {
+//	    slog.Warn("cache backend error", "op", op, "err", err)
+//	})
+func WithErrorHandler(fn func(op string, err error)) Option {
+	return func(r *redisBackend) {
+		r.errorHandler = fn
+	}
+}
+
+// WithExpiryConsistency selects between Strong and Lazy expiry enforcement.
+func WithExpiryConsistency(c ExpiryConsistency) Option {
+	return func(r *redisBackend) {
+		r.consistency = c
+	}
+}
+
+// WithMaxValueSize skips storing entries whose serialized form exceeds n bytes.
+// The size is measured on the encoded bytes actually sent to Redis, including
+// the wire header and entry metadata, since that is what consumes Redis memory
+// and network bandwidth. Oversized values are not cached, and any previous
+// value under the key is deleted so it is not served in their place. Zero
+// or negative means no limit.
+func WithMaxValueSize(n int) Option {
+	return func(r *redisBackend) {
+		r.maxSize = n
+	}
+}
+
+// WithDefaultTTL sets the TTL reported by DefaultTTL, which the memoizer and
+// tiered backends use for values written to Redis, e.g. to keep them longer
+// than in an in-process layer. Set itself still stores values with the TTL
+// it is given.
+func WithDefaultTTL(d time.Duration) Option {
+	return func(r *redisBackend) {
+		r.defaultTTL = max(d, 0)
+	}
+}
+
+// defaultScanCount is the SCAN COUNT hint used unless WithScanCount is given.
+const defaultScanCount = 1000
+
+// unlinkDepth is how many UNLINK commands are queued in a pipeline before
+// it is sent to Redis.
+const unlinkDepth = 16
+
+// WithScanCount sets the COUNT hint of the SCAN calls Clear and
+// DeleteByPrefix use to walk the keyspace, i.e. roughly how many keys each
+// round trip examines and unlinks. Larger batches need fewer round trips but
+// keep Redis busy longer per call. Zero or negative keeps the default of 1000.
+func WithScanCount(n int) Option {
+	return func(r *redisBackend) {
+		if n > 0 {
+			r.scanCount = n
+		}
+	}
+}
+
+// WithCodec selects how entries are serialized. The default is
+// backends.GobCodec. Entries written with gob stay readable after switching
+// to another codec, so a codec can be changed without flushing Redis.
+func WithCodec(c backends.Codec) Option {
+	return func(r *redisBackend) {
+		if c != nil {
+			r.codec = c
+		}
+	}
+}
+
+// WithCompression compresses entries whose encoded form is at least
+// threshold bytes with c before they are sent to Redis, e.g.
+// backends.GzipCompressor(gzip.BestSpeed). Entries that do not shrink are
+// stored as they are. Compressed entries are marked in the wire header and
+// decompressed transparently on read; entries compressed with any built-in
+// compressor stay readable after switching to another compressor or turning
+// compression off.
+func WithCompression(c backends.Compressor, threshold int) Option {
+	return func(r *redisBackend) {
+		r.compressor = c
+		r.compressAbove = threshold
+	}
+}
+
+// WithEncryption encrypts every entry with e before it is sent to Redis,
+// e.g. with backends.NewAESGCM. Encryption is applied after compression.
+// Entries are bound to their key, so a ciphertext copied to another key
+// fails to decrypt. Unencrypted entries written before encryption was
+// enabled are still read.
+func WithEncryption(e backends.Encrypter) Option {
+	return func(r *redisBackend) {
+		r.encrypter = e
+	}
 }
 
-var _ backends.Backend = (*redisBackend)(nil)
+// WithSigningKey signs every entry with HMAC-SHA256 under key and verifies
+// entries on read. Unsigned, truncated and tampered entries are treated as
+// misses, deleted, and reported to OnCorrupt listeners, which memo counts in
+// Metrics.CorruptEntries. Use it when the Redis instance is shared with
+// less trusted writers. Entries written before signing was enabled are
+// rejected as unsigned.
+func WithSigningKey(key []byte) Option {
+	return func(r *redisBackend) {
+		r.signKey = key
+	}
+}
 
 // New creates a new Redis backend with the specified address, prefix, and database.
 // If prefix is empty, it defaults to "gomemo:".
 // The backend automatically registers itself with the backend factory system.
-func New(addr, prefix string, db int) backends.Backend {
+func New(addr, prefix string, db int, opts ...Option) backends.Backend {
+	r := newBackend(prefix, opts)
+	r.conn.Addrs = []string{addr}
+	r.conn.DB = db
+	return r.use(goredis.NewClient(r.conn.Simple()), true)
+}
+
+// NewV2 creates a Redis backend implementing backends.BackendV2. It takes the
+// same arguments as New; its calls use the caller's context and return Redis
+// errors instead of logging them.
+func NewV2(addr, prefix string, db int, opts ...Option) backends.BackendV2 {
+	return contextBackend{New(addr, prefix, db, opts...).(*redisBackend)}
+}
+
+// V2 returns the context-aware form of the backend. The memoizer uses it
+// automatically, so the context passed to Memoizer.Get bounds the Redis
+// calls made for it even when the backend was created with New.
+func (r *redisBackend) V2() backends.BackendV2 {
+	return contextBackend{r}
+}
+
+// NewWithClient creates a Redis backend on top of an existing client, so the
+// backend shares the application's connection pool, instrumentation and
+// settings instead of opening its own. The caller keeps ownership of client:
+// closing the backend does not close it. With a *goredis.ClusterClient the
+// prefix is hash-tagged as described for NewCluster.
+//
+// Example:
+//
+//	rdb := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", PoolSize: 50})
+//	backend := redis.NewWithClient(rdb, "myapp:")
+func NewWithClient(client goredis.UniversalClient, prefix string, opts ...Option) backends.Backend {
+	return newBackend(prefix, opts).use(client, false)
+}
+
+// NewV2WithClient is like NewWithClient but returns a backends.BackendV2.
+func NewV2WithClient(client goredis.UniversalClient, prefix string, opts ...Option) backends.BackendV2 {
+	return contextBackend{newBackend(prefix, opts).use(client, false)}
+}
+
+// NewCluster creates a Redis Cluster backend connected through the given
+// seed nodes.
+//
+// A prefix without a hash tag is wrapped in one, e.g. "orders:" becomes
+// "{orders:}". All keys of the backend then share a slot, so the multi-key
+// commands used by batches, tags and Clear never span slots, and Clear and
+// DeleteByPrefix scan every master. To spread data over the cluster, use
+// several backends with different prefixes, e.g. one per memoizer.
+//
+// Example:
+//
+//	backend := redis.NewCluster([]string{"10.0.0.1:6379", "10.0.0.2:6379"}, "orders:")
+func NewCluster(addrs []string, prefix string, opts ...Option) backends.Backend {
+	r := newBackend(prefix, opts)
+	r.conn.Addrs = addrs
+	return r.use(goredis.NewClusterClient(r.conn.Cluster()), true)
+}
+
+// NewClusterV2 is like NewCluster but returns a backends.BackendV2.
+func NewClusterV2(addrs []string, prefix string, opts ...Option) backends.BackendV2 {
+	return contextBackend{NewCluster(addrs, prefix, opts...).(*redisBackend)}
+}
+
+// NewSentinel creates a backend for a Sentinel-managed Redis deployment.
+// The client asks the sentinels at sentinelAddrs for the current primary of
+// masterName and follows failovers, so the backend survives a primary
+// switch without application changes. Use WithPassword for the data nodes
+// and WithSentinelPassword if the sentinels require their own password.
+//
+// Example:
+//
+//	backend := redis.NewSentinel("mymaster", []string{"10.0.0.1:26379", "10.0.0.2:26379"}, "gomemo:", 0,
+//	    redis.WithPassword(os.Getenv("REDIS_PASSWORD")))
+func NewSentinel(masterName string, sentinelAddrs []string, prefix string, db int, opts ...Option) backends.Backend {
+	r := newBackend(prefix, opts)
+	r.conn.MasterName = masterName
+	r.conn.Addrs = sentinelAddrs
+	r.conn.DB = db
+	return r.use(goredis.NewFailoverClient(r.conn.Failover()), true)
+}
+
+// NewSentinelV2 is like NewSentinel but returns a backends.BackendV2.
+func NewSentinelV2(masterName string, sentinelAddrs []string, prefix string, db int, opts ...Option) backends.BackendV2 {
+	return contextBackend{NewSentinel(masterName, sentinelAddrs, prefix, db, opts...).(*redisBackend)}
+}
+
+// newBackend creates a backend with opts applied. Its client is attached
+// with use, so that options can configure the client first.
+func newBackend(prefix string, opts []Option) *redisBackend {
 	if prefix == "" {
 		prefix = "gomemo:"
 	}
-	client := goredis.NewClient(&goredis.Options{
-		Addr: addr,
-		DB:   db,
-	})
+	r := &redisBackend{
+		prefix:      prefix,
+		ctx:         context.Background(),
+		consistency: Strong,
+		codec:       backends.GobCodec(),
+		scanCount:   defaultScanCount,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
 
-	return &redisBackend{
-		client: client,
-		prefix: prefix,
-		ctx:    context.Background(),
+// use attaches client to the backend; owned clients are closed by Close.
+// Prefixes on cluster clients are hash-tagged, see NewCluster.
+func (r *redisBackend) use(client goredis.UniversalClient, owned bool) *redisBackend {
+	if _, ok := client.(*goredis.ClusterClient); ok && !strings.Contains(r.prefix, "{") {
+		r.prefix = "{" + r.prefix + "}"
 	}
+	r.client = client
+	r.ownsClient = owned
+	return r
 }
 
 func init() {
@@ -55,86 +397,574 @@ func init() {
 // -----------------------------------------------------------------------------
 
 func (r *redisBackend) Get(key string) (any, bool) {
-	var err error
-	var data []byte
+	entry, ok := r.GetEntry(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// GetEntry retrieves the entry stored under key, including its logical expiry and version.
+func (r *redisBackend) GetEntry(key string) (backends.CacheEntry, bool) {
+	entry, ok, err := r.getEntry(r.ctx, key)
+	if err != nil {
+		r.onError("get", err)
+		return backends.CacheEntry{}, false
+	}
+	return entry, ok
+}
+
+// getEntry implements GetEntry, reporting failures instead of logging them.
+func (r *redisBackend) getEntry(ctx context.Context, key string) (backends.CacheEntry, bool, error) {
+	_, entry, ok, err := r.getRaw(ctx, key)
+	return entry, ok, err
+}
 
-	data, err = r.client.Get(r.ctx, r.prefixed(key)).Bytes()
+// getRaw is getEntry also returning the stored bytes, for operations that
+// must only overwrite the entry they read.
+func (r *redisBackend) getRaw(ctx context.Context, key string) ([]byte, backends.CacheEntry, bool, error) {
+	data, err := r.client.Get(ctx, r.prefixed(key)).Bytes()
 	if err != nil {
 		if errors.Is(err, goredis.Nil) {
-			log.Printf("[gomemo][redis] data error: %v\n", err)
-			return nil, false
+			return nil, backends.CacheEntry{}, false, nil
 		}
-		return nil, false
+		return nil, backends.CacheEntry{}, false, err
 	}
 
-	var entry backends.CacheEntry
-	if err = gob.NewDecoder(bytes.NewBuffer(data)).Decode(&entry); err != nil {
-		log.Printf("[gomemo][redis] decode error: %v\n", err)
-		return nil, false
+	entry, err := r.decodeEntry(key, data)
+	if errors.Is(err, backends.ErrCorruptEntry) {
+		r.reportCorrupt(ctx, key, err)
+		return nil, backends.CacheEntry{}, false, fmt.Errorf("decode %s: %w", key, err)
+	}
+	if err != nil {
+		// An entry this backend cannot read, e.g. one written by a newer
+		// version, is a miss, so that the caller recomputes and replaces it
+		r.onError("decode", fmt.Errorf("decode %s: %w", key, err))
+		return nil, backends.CacheEntry{}, false, nil
 	}
 
-	// Check if expired (using entry.IsExpired())
-	if entry.IsExpired() {
+	// Check if expired (using entry.IsExpired()); Lazy mode trusts the native TTL
+	if r.consistency == Strong && entry.IsExpired() {
 		// proactive cleanup
-		if err = r.client.Del(r.ctx, r.prefixed(key)).Err(); err != nil {
-			log.Printf("[gomemo][redis] expiry error: %v\n", err)
+		if err = r.client.Del(ctx, r.prefixed(key)).Err(); err != nil {
+			r.onError("expiry", err)
 		}
-		log.Printf("[gomemo][redis] expired entry: %s\n", key)
-		return nil, false
+		return nil, backends.CacheEntry{}, false, nil
 	}
 
-	return entry.Value, true
+	return data, entry, true, nil
 }
 
 func (r *redisBackend) Set(key string, value any, ttl time.Duration) {
-	var entry backends.CacheEntry
-	var buf bytes.Buffer
-	var err error
+	if err := r.set(r.ctx, key, value, ttl); err != nil {
+		r.onError("set", err)
+	}
+}
 
-	entry = backends.NewEntry(value, ttl, 0)
+// set implements Set, reporting failures instead of logging them.
+func (r *redisBackend) set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := r.encodeEntry(key, backends.NewEntry(value, ttl, 0))
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
 
-	if err = gob.NewEncoder(&buf).Encode(entry); err != nil {
-		log.Printf("[gomemo][redis] encode error: %v\n", err)
-		return
+	if r.maxSize > 0 && len(data) > r.maxSize {
+		// Drop the previous value, which this write was meant to replace
+		tooLarge := fmt.Errorf("%w: %s (%d > %d bytes)", ErrValueTooLarge, key, len(data), r.maxSize)
+		return errors.Join(tooLarge, r.client.Del(ctx, r.prefixed(key)).Err())
+	}
+
+	return r.client.Set(ctx, r.prefixed(key), data, ttl).Err()
+}
+
+// Touch resets the TTL of an existing entry to ttl from now.
+// The entry is rewritten so that its logical expiry moves along with the
+// native Redis TTL, only if it was not changed in the meantime; see
+// GetAndTouch. Each touch costs a GET, decoding and re-encoding the entry,
+// and a script call.
+func (r *redisBackend) Touch(key string, ttl time.Duration) bool {
+	_, ok, err := r.touch(r.ctx, key, ttl)
+	if err != nil {
+		r.onError("touch", err)
 	}
+	return ok
+}
 
-	err = r.client.Set(r.ctx, r.prefixed(key), buf.Bytes(), ttl).Err()
+// Expire makes an existing entry expire now with PEXPIREAT, so Redis drops
+// it just as if its TTL had run out.
+func (r *redisBackend) Expire(key string) bool {
+	ok, err := r.client.PExpireAt(r.ctx, r.prefixed(key), time.Now()).Result()
 	if err != nil {
-		log.Printf("[gomemo][redis] set error: %v\n", err)
+		r.onError("expire", err)
+		return false
 	}
+	return ok
 }
 
 func (r *redisBackend) Delete(key string) {
-	var err error
-	if err = r.client.Del(r.ctx, r.prefixed(key)).Err(); err != nil {
-		log.Printf("[gomemo][redis] delete error: %v\n", err)
+	if err := r.del(r.ctx, key); err != nil {
+		r.onError("delete", err)
 	}
 }
 
+// del implements Delete, reporting failures instead of logging them.
+func (r *redisBackend) del(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.prefixed(key)).Err()
+}
+
 func (r *redisBackend) Clear() {
+	if err := r.clear(r.ctx); err != nil {
+		r.onError("clear", err)
+	}
+}
+
+// clear implements Clear, reporting failures instead of logging them.
+func (r *redisBackend) clear(ctx context.Context) error {
+	_, err := r.unlinkMatching(ctx, escapeGlob(r.prefix)+"*")
+	return err
+}
+
+// unlinkMatching removes every key matching pattern and returns how many
+// were removed. Keys are walked with SCAN and removed with UNLINK, which
+// frees memory in the background instead of blocking Redis, and the UNLINK
+// calls are pipelined so the scan does not wait for each batch. On a cluster
+// every master is processed, concurrently, since SCAN only covers the node
+// it runs on.
+func (r *redisBackend) unlinkMatching(ctx context.Context, pattern string) (int, error) {
+	var removed atomic.Int64
+	unlink := func(ctx context.Context, node goredis.Cmdable) error {
+		pipe := node.Pipeline()
+		var cmds []*goredis.IntCmd
+		flush := func() error {
+			if len(cmds) == 0 {
+				return nil
+			}
+			_, err := pipe.Exec(ctx)
+			for _, cmd := range cmds {
+				removed.Add(cmd.Val())
+			}
+			cmds = cmds[:0]
+			return err
+		}
+		err := scanNode(ctx, node, pattern, r.scanCount, func(keys []string) error {
+			cmds = append(cmds, pipe.Unlink(ctx, keys...))
+			if len(cmds) >= unlinkDepth {
+				return flush()
+			}
+			return nil
+		})
+		if ferr := flush(); err == nil {
+			err = ferr
+		}
+		return err
+	}
+
 	var err error
-	var cursor, next uint64
-	var keys []string
+	if cc, ok := r.client.(*goredis.ClusterClient); ok {
+		err = cc.ForEachMaster(ctx, func(ctx context.Context, node *goredis.Client) error {
+			return unlink(ctx, node)
+		})
+	} else {
+		err = unlink(ctx, r.client)
+	}
+	return int(removed.Load()), err
+}
 
+// scanNode calls fn with every batch of keys matching pattern on a single
+// node, asking SCAN for about count keys per call.
+func scanNode(ctx context.Context, c goredis.Cmdable, pattern string, count int, fn func(keys []string) error) error {
+	var cursor uint64
 	for {
-		keys, next, err = r.client.Scan(r.ctx, cursor, r.prefix+"*", 100).Result()
+		keys, next, err := c.Scan(ctx, cursor, pattern, int64(count)).Result()
 		if err != nil {
-			fmt.Printf("[gomemo][redis] scan error: %v\n", err)
-			return
+			return fmt.Errorf("scan: %w", err)
 		}
 		if len(keys) > 0 {
-			if err = r.client.Del(r.ctx, keys...).Err(); err != nil {
-				log.Printf("[gomemo][redis] clear error: %v\n", err)
+			if err = fn(keys); err != nil {
+				return err
 			}
-
 		}
 		if next == 0 {
-			break
+			return nil
 		}
 		cursor = next
 	}
 }
 
+// GetMulti retrieves the values stored under keys with a single MGET.
+// Entries that fail to decode are skipped; in Strong mode expired entries
+// are skipped and deleted.
+func (r *redisBackend) GetMulti(keys []string) map[string]any {
+	out, err := r.getMulti(r.ctx, keys)
+	if err != nil {
+		r.onError("mget", err)
+	}
+	return out
+}
+
+// getMulti implements GetMulti, returning the MGET failure instead of
+// logging it. Failures of individual entries are still reported through
+// the error handler, since the other entries are usable.
+func (r *redisBackend) getMulti(ctx context.Context, keys []string) (map[string]any, error) {
+	out := make(map[string]any, len(keys))
+	if len(keys) == 0 {
+		return out, nil
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.prefixed(key)
+	}
+	vals, err := r.client.MGet(ctx, prefixed...).Result()
+	if err != nil {
+		return out, err
+	}
+
+	var expired []string
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue // missing key
+		}
+		entry, err := r.decodeEntry(keys[i], []byte(s))
+		if err != nil {
+			r.reportCorrupt(ctx, keys[i], err)
+			r.onError("decode", err)
+			continue
+		}
+		if r.consistency == Strong && entry.IsExpired() {
+			expired = append(expired, prefixed[i])
+			continue
+		}
+		out[keys[i]] = entry.Value
+	}
+	if len(expired) > 0 {
+		if err = r.client.Del(ctx, expired...).Err(); err != nil {
+			r.onError("expiry", err)
+		}
+	}
+	return out, nil
+}
+
+// SetMulti stores all items in one pipelined round trip. Items that cannot
+// be encoded are skipped; items exceeding the size limit are skipped and
+// their keys deleted.
+func (r *redisBackend) SetMulti(items []backends.BatchItem) {
+	if err := r.setMulti(r.ctx, items); err != nil {
+		r.onError("set", err)
+	}
+}
+
+// setMulti implements SetMulti, returning failures instead of logging them.
+// Skipped items and a failed pipeline are joined into one error.
+func (r *redisBackend) setMulti(ctx context.Context, items []backends.BatchItem) error {
+	var errs []error
+	pipe := r.client.Pipeline()
+	for _, it := range items {
+		data, err := r.encodeEntry(it.Key, backends.NewEntry(it.Value, it.TTL, 0))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("encode %s: %w", it.Key, err))
+			continue
+		}
+		if r.maxSize > 0 && len(data) > r.maxSize {
+			errs = append(errs, fmt.Errorf("%w: %s (%d > %d bytes)", ErrValueTooLarge, it.Key, len(data), r.maxSize))
+			pipe.Del(ctx, r.prefixed(it.Key))
+			continue
+		}
+		pipe.Set(ctx, r.prefixed(it.Key), data, it.TTL)
+	}
+	if pipe.Len() > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DeleteMulti removes the values stored under keys with a single DEL.
+func (r *redisBackend) DeleteMulti(keys []string) {
+	if err := r.deleteMulti(r.ctx, keys); err != nil {
+		r.onError("delete", err)
+	}
+}
+
+// deleteMulti implements DeleteMulti, returning failures instead of logging them.
+func (r *redisBackend) deleteMulti(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.prefixed(key)
+	}
+	return r.client.Del(ctx, prefixed...).Err()
+}
+
+// DeleteByPrefix removes every key starting with prefix the same way Clear
+// does, and returns how many were removed. DeleteByPrefix("") is Clear with
+// a count.
+func (r *redisBackend) DeleteByPrefix(prefix string) int {
+	n, err := r.unlinkMatching(r.ctx, escapeGlob(r.prefix+prefix)+"*")
+	if err != nil {
+		r.onError("delete by prefix", err)
+	}
+	return n
+}
+
+// escapeGlob escapes the characters SCAN MATCH treats as wildcards.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// DefaultTTL returns the TTL set with WithDefaultTTL, or zero.
+func (r *redisBackend) DefaultTTL() time.Duration {
+	return r.defaultTTL
+}
+
+// Close closes the underlying Redis client, unless it was passed in by the
+// caller through NewWithClient.
+func (r *redisBackend) Close() error {
+	if !r.ownsClient {
+		return nil
+	}
+	return r.client.Close()
+}
+
 func (r *redisBackend) prefixed(key string) string {
 	return r.prefix + key
 }
+
+// -----------------------------------------------------------------------------
+// BackendV2 interface
+// -----------------------------------------------------------------------------
+
+// contextBackend exposes a redisBackend through backends.BackendV2 and
+// backends.BatchBackendV2. Close is promoted from the embedded backend.
+type contextBackend struct {
+	*redisBackend
+}
+
+var (
+	_ backends.BackendV2      = contextBackend{}
+	_ backends.BatchBackendV2 = contextBackend{}
+	_ backends.EntryBackendV2 = contextBackend{}
+	_ backends.ToucherV2      = contextBackend{}
+)
+
+func (c contextBackend) Get(ctx context.Context, key string) (any, bool, error) {
+	entry, ok, err := c.getEntry(ctx, key)
+	if !ok || err != nil {
+		return nil, false, err
+	}
+	return entry.Value, true, nil
+}
+
+func (c contextBackend) GetEntry(ctx context.Context, key string) (backends.CacheEntry, bool, error) {
+	return c.getEntry(ctx, key)
+}
+
+func (c contextBackend) Touch(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	_, ok, err := c.touch(ctx, key, ttl)
+	return ok, err
+}
+
+func (c contextBackend) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return c.set(ctx, key, value, ttl)
+}
+
+func (c contextBackend) Delete(ctx context.Context, key string) error {
+	return c.del(ctx, key)
+}
+
+func (c contextBackend) Clear(ctx context.Context) error {
+	return c.clear(ctx)
+}
+
+func (c contextBackend) GetMulti(ctx context.Context, keys []string) (map[string]any, error) {
+	return c.getMulti(ctx, keys)
+}
+
+func (c contextBackend) SetMulti(ctx context.Context, items []backends.BatchItem) error {
+	return c.setMulti(ctx, items)
+}
+
+func (c contextBackend) DeleteMulti(ctx context.Context, keys []string) error {
+	return c.deleteMulti(ctx, keys)
+}
+
+// -----------------------------------------------------------------------------
+// Serialization
+// -----------------------------------------------------------------------------
+
+// encodeEntry serializes entry with the backend's codec, compresses and
+// encrypts it if configured, and frames it with a wire header naming the
+// codec. key is bound to encrypted entries so they cannot be moved to
+// another key.
+func (r *redisBackend) encodeEntry(key string, entry backends.CacheEntry) ([]byte, error) {
+	payload, err := r.codec.Encode(backends.NewRecord(entry))
+	if err != nil {
+		return nil, err
+	}
+	hdr := wire.Header{Codec: r.codec.ID()}
+	if r.compressor != nil && len(payload) >= r.compressAbove {
+		compressed, err := r.compressor.Compress(payload)
+		if err != nil {
+			return nil, err
+		}
+		// Keep the plain payload when compression does not pay off
+		if len(compressed)+1 < len(payload) {
+			payload = append([]byte{r.compressor.ID()}, compressed...)
+			hdr.Flags |= wire.FlagCompressed
+		}
+	}
+	if r.encrypter != nil {
+		if payload, err = r.encrypter.Encrypt(payload, []byte(key)); err != nil {
+			return nil, err
+		}
+		hdr.Flags |= wire.FlagEncrypted
+	}
+	if r.signKey != nil {
+		hdr.Flags |= wire.FlagSigned
+		data := wire.Encode(hdr, payload)
+		return append(data, r.sign(key, data)...), nil
+	}
+	return wire.Encode(hdr, payload), nil
+}
+
+// onError reports an error that cannot be returned to the caller.
+func (r *redisBackend) onError(op string, err error) {
+	if r.errorHandler != nil {
+		r.errorHandler(op, err)
+		return
+	}
+	log.Printf("[gomemo][redis] %s error: %v\n", op, err)
+}
+
+// sign returns the HMAC-SHA256 of data, bound to key.
+func (r *redisBackend) sign(key string, data []byte) []byte {
+	mac := hmac.New(sha256.New, r.signKey)
+	mac.Write([]byte(key))
+	mac.Write([]byte{0})
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// verify checks the signature appended to data by encodeEntry. Unsigned,
+// truncated and tampered entries are reported as backends.ErrCorruptEntry.
+func (r *redisBackend) verify(key string, data []byte) error {
+	if len(data) < wire.HeaderSize+sha256.Size || data[0] != wire.Magic || wire.Flags(data[3])&wire.FlagSigned == 0 {
+		return fmt.Errorf("%w: %s is not signed", backends.ErrCorruptEntry, key)
+	}
+	body, sig := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if !hmac.Equal(sig, r.sign(key, body)) {
+		return fmt.Errorf("%w: bad signature for %s", backends.ErrCorruptEntry, key)
+	}
+	return nil
+}
+
+// OnCorrupt registers fn to be called with the key of every entry that
+// fails signature verification.
+func (r *redisBackend) OnCorrupt(fn func(key string)) {
+	r.corruptMu.Lock()
+	defer r.corruptMu.Unlock()
+	r.onCorrupt = append(r.onCorrupt, fn)
+}
+
+// reportCorrupt deletes the entry under key on ctx, the context of the read
+// that found it, and notifies the OnCorrupt listeners if err marks it as
+// corrupt.
+func (r *redisBackend) reportCorrupt(ctx context.Context, key string, err error) {
+	if !errors.Is(err, backends.ErrCorruptEntry) {
+		return
+	}
+	if err := r.client.Del(ctx, r.prefixed(key)).Err(); err != nil {
+		r.onError("delete", err)
+	}
+	r.corruptMu.Lock()
+	listeners := r.onCorrupt
+	r.corruptMu.Unlock()
+	for _, fn := range listeners {
+		fn(key)
+	}
+}
+
+// decompress reverses the compression applied by encodeEntry. The payload
+// starts with the ID of the compressor that produced it.
+func (r *redisBackend) decompress(payload []byte) ([]byte, error) {
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("%w: empty compressed payload", wire.ErrUnknownFormat)
+	}
+	c := r.compressor
+	if c == nil || c.ID() != payload[0] {
+		if c = backends.BuiltinCompressor(payload[0]); c == nil {
+			return nil, fmt.Errorf("%w: unsupported compressor %d", wire.ErrUnknownFormat, payload[0])
+		}
+	}
+	return c.Decompress(payload[1:])
+}
+
+// decodeEntry dispatches on the wire header to the matching codec: the
+// backend's own codec, or gob for entries written before a codec was chosen.
+// Unframed data is treated as a legacy gob entry so values written by older
+// versions keep working during a rollout; anything else it does not
+// understand is reported as an error, which reads turn into a miss.
+func (r *redisBackend) decodeEntry(key string, data []byte) (backends.CacheEntry, error) {
+	if r.signKey != nil {
+		if err := r.verify(key, data); err != nil {
+			return backends.CacheEntry{}, err
+		}
+	}
+
+	hdr, payload, err := wire.Decode(data)
+	switch {
+	case errors.Is(err, wire.ErrNoHeader):
+		hdr, payload = wire.Header{Codec: wire.CodecGob}, data
+	case err != nil:
+		return backends.CacheEntry{}, err
+	}
+
+	if hdr.Flags&^(wire.FlagCompressed|wire.FlagEncrypted|wire.FlagSigned) != 0 {
+		return backends.CacheEntry{}, fmt.Errorf("%w: unsupported flags %#x", wire.ErrUnknownFormat, hdr.Flags)
+	}
+	if hdr.Flags&wire.FlagSigned != 0 {
+		if len(payload) < sha256.Size {
+			return backends.CacheEntry{}, fmt.Errorf("%w: truncated signature", backends.ErrCorruptEntry)
+		}
+		payload = payload[:len(payload)-sha256.Size]
+	}
+	if hdr.Flags&wire.FlagEncrypted != 0 {
+		if r.encrypter == nil {
+			return backends.CacheEntry{}, fmt.Errorf("%w: encrypted entry without an encrypter", wire.ErrUnknownFormat)
+		}
+		if payload, err = r.encrypter.Decrypt(payload, []byte(key)); err != nil {
+			return backends.CacheEntry{}, err
+		}
+	}
+	if hdr.Flags&wire.FlagCompressed != 0 {
+		if payload, err = r.decompress(payload); err != nil {
+			return backends.CacheEntry{}, err
+		}
+	}
+
+	codec := r.codec
+	if hdr.Codec != codec.ID() {
+		if hdr.Codec != wire.CodecGob {
+			return backends.CacheEntry{}, fmt.Errorf("%w: unsupported codec %d", wire.ErrUnknownFormat, hdr.Codec)
+		}
+		codec = backends.GobCodec()
+	}
+
+	var rec backends.Record
+	if err := codec.Decode(payload, &rec); err != nil {
+		return backends.CacheEntry{}, err
+	}
+	return rec.Entry(), nil
+}
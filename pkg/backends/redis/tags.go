@@ -0,0 +1,75 @@
+package redis
+
+// Tags are kept in Redis sets next to the entries: one set per tag listing
+// the keys carrying it, and one set per tagged key listing its tags so they
+// can be replaced. Entries that expire natively are not removed from their
+// tag sets; invalidating the tag unlinks whatever is left.
+
+// tagSetKey returns the Redis key of the set listing the keys carrying tag.
+func (r *redisBackend) tagSetKey(tag string) string {
+	return r.prefix + "__tag__:" + tag
+}
+
+// keyTagsKey returns the Redis key of the set listing the tags of key.
+func (r *redisBackend) keyTagsKey(key string) string {
+	return r.prefix + "__tags__:" + key
+}
+
+// SetTags associates tags with the entry stored under key, replacing the tags
+// it had. It does nothing if the key is not present.
+func (r *redisBackend) SetTags(key string, tags []string) {
+	ttl, err := r.client.PTTL(r.ctx, r.prefixed(key)).Result()
+	if err != nil {
+		r.onError("tag", err)
+		return
+	}
+	if ttl == -2 {
+		return // no such key; -1 would mean no expiry
+	}
+	old, err := r.client.SMembers(r.ctx, r.keyTagsKey(key)).Result()
+	if err != nil {
+		r.onError("tag", err)
+		return
+	}
+
+	pipe := r.client.TxPipeline()
+	for _, tag := range old {
+		pipe.SRem(r.ctx, r.tagSetKey(tag), key)
+	}
+	pipe.Del(r.ctx, r.keyTagsKey(key))
+	if len(tags) > 0 {
+		members := make([]any, len(tags))
+		for i, tag := range tags {
+			pipe.SAdd(r.ctx, r.tagSetKey(tag), key)
+			members[i] = tag
+		}
+		pipe.SAdd(r.ctx, r.keyTagsKey(key), members...)
+		if ttl > 0 {
+			pipe.PExpire(r.ctx, r.keyTagsKey(key), ttl)
+		}
+	}
+	if _, err = pipe.Exec(r.ctx); err != nil {
+		r.onError("tag", err)
+	}
+}
+
+// InvalidateTag unlinks every entry carrying tag and returns their keys.
+// Keys whose entries already expired are included.
+func (r *redisBackend) InvalidateTag(tag string) []string {
+	keys, err := r.client.SMembers(r.ctx, r.tagSetKey(tag)).Result()
+	if err != nil {
+		r.onError("invalidate", err)
+		return nil
+	}
+
+	unlink := make([]string, 0, 2*len(keys)+1)
+	for _, key := range keys {
+		unlink = append(unlink, r.prefixed(key), r.keyTagsKey(key))
+	}
+	unlink = append(unlink, r.tagSetKey(tag))
+	if err = r.client.Unlink(r.ctx, unlink...).Err(); err != nil {
+		r.onError("invalidate", err)
+		return nil
+	}
+	return keys
+}
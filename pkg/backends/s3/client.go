@@ -0,0 +1,58 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by Client.GetObject for missing objects. Adapters
+// map S3's NoSuchKey error to it.
+var ErrNotFound = errors.New("object not found")
+
+// Client is the part of the S3 API the backend uses. It is kept small so the
+// backend works with any S3-compatible store and SDK: an adapter over the
+// AWS SDK's s3.Client, MinIO or another client is a few lines per method,
+// and tests can use an in-memory fake.
+type Client interface {
+	// GetObject returns the object stored under key, or ErrNotFound.
+	GetObject(ctx context.Context, bucket, key string) (*Object, error)
+
+	// PutObject stores obj, replacing any object under the same key.
+	PutObject(ctx context.Context, bucket string, obj Object) error
+
+	// DeleteObject removes the object stored under key. Deleting a missing
+	// object is not an error.
+	DeleteObject(ctx context.Context, bucket, key string) error
+
+	// DeleteObjects removes the objects stored under keys. The backend
+	// passes at most 1000 keys, the DeleteObjects limit.
+	DeleteObjects(ctx context.Context, bucket string, keys []string) error
+
+	// ListObjects calls fn with pages of the keys starting with prefix, as
+	// returned by ListObjectsV2.
+	ListObjects(ctx context.Context, bucket, prefix string, fn func(keys []string) error) error
+}
+
+// Object is a cache entry as stored in S3.
+type Object struct {
+	Key  string
+	Body []byte // encoded entry
+
+	// Metadata is stored as user metadata (x-amz-meta-*) and returned by
+	// GetObject. The backend records the expiry in it.
+	Metadata map[string]string
+
+	// Tags are stored as object tags, which lifecycle rules can filter on.
+	// See TTLDaysTag.
+	Tags map[string]string
+
+	// Expires is sent as the Expires header, for HTTP caches in front of
+	// the bucket; S3 itself does not act on it. Zero for none.
+	Expires time.Time
+
+	// ServerSideEncryption and SSEKMSKeyID are passed to PutObject as the
+	// x-amz-server-side-encryption headers; empty uses the bucket default.
+	ServerSideEncryption string
+	SSEKMSKeyID          string
+}
@@ -0,0 +1,332 @@
+// Package s3 provides a cache backend storing entries as objects in an
+// S3-compatible bucket.
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/ldaidone/gomemo/internals/wire"
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+const (
+	// ExpiresMetadata is the user metadata key holding an object's expiry in
+	// unix nanoseconds. Objects without it never expire.
+	ExpiresMetadata = "gomemo-expires"
+
+	// TTLDaysTag is the object tag holding an object's TTL in whole days,
+	// rounded up, or "none" for objects that never expire. S3 cannot expire
+	// objects by metadata, but lifecycle rules can filter on tags: a rule
+	// expiring objects tagged gomemo-ttl-days=1 after one day, one for 7
+	// after seven days and so on removes expired entries from the bucket.
+	TTLDaysTag = "gomemo-ttl-days"
+
+	// maxDeleteObjects is the most keys one DeleteObjects call accepts.
+	maxDeleteObjects = 1000
+)
+
+// S3 is a cache backend for large values that are expensive to recompute and
+// read rarely, such as ML artifacts or big reports. Each entry is one object
+// below a key prefix. Object storage has high latency and per-request cost,
+// so put a faster cache in front of it for hot keys.
+//
+// Expiry is recorded in the object's metadata, checked on every read, and
+// exposed to lifecycle rules through the TTLDaysTag tag, which S3 uses to
+// delete expired objects.
+//
+// The backend talks to S3 through Client, which an application implements
+// on top of its S3 SDK.
+type S3 struct {
+	client       Client
+	bucket       string
+	prefix       string                     // prepended to every key
+	codec        backends.Codec             // serializes entries; gob unless WithCodec is given
+	sse          string                     // server-side encryption algorithm; empty for the bucket default
+	sseKMSKeyID  string                     // KMS key for aws:kms encryption
+	errorHandler func(op string, err error) // receives errors that cannot be returned; nil logs them
+}
+
+var (
+	_ backends.EntryBackend  = (*S3)(nil)
+	_ backends.PrefixDeleter = (*S3)(nil)
+	_ backends.V2Provider    = (*S3)(nil)
+)
+
+// Option configures an S3 backend.
+type Option func(*S3)
+
+// WithPrefix stores objects below prefix instead of "gomemo/", so several
+// caches can share a bucket and lifecycle rules can be scoped to the cache.
+func WithPrefix(prefix string) Option {
+	return func(s *S3) {
+		s.prefix = prefix
+	}
+}
+
+// WithCodec selects how entries are serialized. The default is
+// backends.GobCodec. Entries written with gob stay readable after switching
+// to another codec.
+func WithCodec(c backends.Codec) Option {
+	return func(s *S3) {
+		if c != nil {
+			s.codec = c
+		}
+	}
+}
+
+// WithServerSideEncryption asks S3 to encrypt every object with algorithm,
+// e.g. "AES256" or "aws:kms". kmsKeyID selects the KMS key for "aws:kms";
+// empty uses the account's default key.
+func WithServerSideEncryption(algorithm, kmsKeyID string) Option {
+	return func(s *S3) {
+		s.sse = algorithm
+		s.sseKMSKeyID = kmsKeyID
+	}
+}
+
+// WithErrorHandler passes errors the Backend interface cannot return, such as
+// failed uploads, to fn instead of logging them with the standard log
+// package. op names the failed operation, e.g. "set". The context-aware form
+// returned by V2 returns errors instead.
+func WithErrorHandler(fn func(op string, err error)) Option {
+	return func(s *S3) {
+		s.errorHandler = fn
+	}
+}
+
+// New creates a backend storing entries in bucket through client. The
+// memoizer uses the backend's context-aware form automatically, so the
+// context passed to Memoizer.Get bounds the S3 calls made for it.
+//
+// Example:
+//
+//	// client adapts an S3 SDK to s3.Client
+//	backend := s3.New(client, "ml-artifacts", s3.WithPrefix("models/"),
+//	    s3.WithServerSideEncryption("aws:kms", kmsKeyID))
+//	m := memo.New(memo.WithBackend(backend), memo.WithTTL(7*24*time.Hour))
+func New(client Client, bucket string, opts ...Option) *S3 {
+	s := &S3{
+		client: client,
+		bucket: bucket,
+		prefix: "gomemo/",
+		codec:  backends.GobCodec(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// V2 returns the context-aware form of the backend.
+func (s *S3) V2() backends.BackendV2 {
+	return contextBackend{s}
+}
+
+// -----------------------------------------------------------------------------
+// Backend interface
+// -----------------------------------------------------------------------------
+
+func (s *S3) Get(key string) (any, bool) {
+	entry, ok := s.GetEntry(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// GetEntry retrieves the entry stored under key. Expired objects are misses
+// even before a lifecycle rule deletes them.
+func (s *S3) GetEntry(key string) (backends.CacheEntry, bool) {
+	entry, ok, err := s.get(context.Background(), key)
+	if err != nil {
+		s.onError("get", err)
+		return backends.CacheEntry{}, false
+	}
+	return entry, ok
+}
+
+func (s *S3) Set(key string, value any, ttl time.Duration) {
+	if err := s.set(context.Background(), key, value, ttl); err != nil {
+		s.onError("set", err)
+	}
+}
+
+func (s *S3) Delete(key string) {
+	if err := s.client.DeleteObject(context.Background(), s.bucket, s.prefixed(key)); err != nil {
+		s.onError("delete", err)
+	}
+}
+
+// Clear removes every object below the backend's prefix.
+func (s *S3) Clear() {
+	if _, err := s.deleteByPrefix(context.Background(), ""); err != nil {
+		s.onError("clear", err)
+	}
+}
+
+// DeleteByPrefix removes every entry whose key starts with prefix and returns
+// how many were removed.
+func (s *S3) DeleteByPrefix(prefix string) int {
+	n, err := s.deleteByPrefix(context.Background(), prefix)
+	if err != nil {
+		s.onError("delete by prefix", err)
+	}
+	return n
+}
+
+// -----------------------------------------------------------------------------
+// Operations
+// -----------------------------------------------------------------------------
+
+// get returns the entry stored under key if it is present and not expired.
+func (s *S3) get(ctx context.Context, key string) (backends.CacheEntry, bool, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, s.prefixed(key))
+	if errors.Is(err, ErrNotFound) {
+		return backends.CacheEntry{}, false, nil
+	} else if err != nil {
+		return backends.CacheEntry{}, false, err
+	}
+
+	// Check the metadata first to skip decoding expired objects
+	if exp, err := strconv.ParseInt(obj.Metadata[ExpiresMetadata], 10, 64); err == nil && exp <= time.Now().UnixNano() {
+		return backends.CacheEntry{}, false, nil
+	}
+	entry, err := s.decode(obj.Body)
+	if err != nil {
+		return backends.CacheEntry{}, false, fmt.Errorf("decode %s: %w", key, err)
+	}
+	if entry.IsExpired() {
+		return backends.CacheEntry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+// set uploads value as the object of key.
+func (s *S3) set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	entry := backends.NewEntry(value, ttl, 0)
+	payload, err := s.codec.Encode(backends.NewRecord(entry))
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
+	obj := Object{
+		Key:                  s.prefixed(key),
+		Body:                 wire.Encode(wire.Header{Codec: s.codec.ID()}, payload),
+		Metadata:             map[string]string{},
+		Tags:                 map[string]string{TTLDaysTag: "none"},
+		ServerSideEncryption: s.sse,
+		SSEKMSKeyID:          s.sseKMSKeyID,
+	}
+	if exp := entry.ExpiresAt(); !exp.IsZero() {
+		obj.Metadata[ExpiresMetadata] = strconv.FormatInt(exp.UnixNano(), 10)
+		obj.Tags[TTLDaysTag] = strconv.FormatInt(ttlDays(ttl), 10)
+		obj.Expires = exp
+	}
+	return s.client.PutObject(ctx, s.bucket, obj)
+}
+
+// ttlDays returns ttl in whole days, rounded up.
+func ttlDays(ttl time.Duration) int64 {
+	const day = 24 * time.Hour
+	return int64((ttl + day - 1) / day)
+}
+
+// deleteByPrefix implements DeleteByPrefix and Clear.
+func (s *S3) deleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	removed := 0
+	err := s.client.ListObjects(ctx, s.bucket, s.prefixed(prefix), func(keys []string) error {
+		for start := 0; start < len(keys); start += maxDeleteObjects {
+			batch := keys[start:min(start+maxDeleteObjects, len(keys))]
+			if err := s.client.DeleteObjects(ctx, s.bucket, batch); err != nil {
+				return err
+			}
+			removed += len(batch)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// -----------------------------------------------------------------------------
+// Serialization
+// -----------------------------------------------------------------------------
+
+func (s *S3) prefixed(key string) string {
+	return s.prefix + key
+}
+
+// decode deserializes an encoded entry.
+func (s *S3) decode(data []byte) (backends.CacheEntry, error) {
+	hdr, payload, err := wire.Decode(data)
+	if err != nil {
+		return backends.CacheEntry{}, err
+	}
+	if hdr.Flags != 0 {
+		return backends.CacheEntry{}, fmt.Errorf("%w: unsupported flags %#x", wire.ErrUnknownFormat, hdr.Flags)
+	}
+	codec := s.codec
+	if hdr.Codec != codec.ID() {
+		if hdr.Codec != wire.CodecGob {
+			return backends.CacheEntry{}, fmt.Errorf("%w: unsupported codec %d", wire.ErrUnknownFormat, hdr.Codec)
+		}
+		codec = backends.GobCodec()
+	}
+
+	var rec backends.Record
+	if err := codec.Decode(payload, &rec); err != nil {
+		return backends.CacheEntry{}, err
+	}
+	return rec.Entry(), nil
+}
+
+// onError reports an error that cannot be returned to the caller.
+func (s *S3) onError(op string, err error) {
+	if s.errorHandler != nil {
+		s.errorHandler(op, err)
+		return
+	}
+	log.Printf("[gomemo][s3] %s error: %v\n", op, err)
+}
+
+// -----------------------------------------------------------------------------
+// BackendV2 interface
+// -----------------------------------------------------------------------------
+
+// contextBackend exposes an S3 backend through backends.BackendV2.
+type contextBackend struct {
+	*S3
+}
+
+var (
+	_ backends.BackendV2      = contextBackend{}
+	_ backends.EntryBackendV2 = contextBackend{}
+)
+
+func (c contextBackend) Get(ctx context.Context, key string) (any, bool, error) {
+	entry, ok, err := c.get(ctx, key)
+	if !ok || err != nil {
+		return nil, false, err
+	}
+	return entry.Value, true, nil
+}
+
+func (c contextBackend) GetEntry(ctx context.Context, key string) (backends.CacheEntry, bool, error) {
+	return c.get(ctx, key)
+}
+
+func (c contextBackend) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return c.set(ctx, key, value, ttl)
+}
+
+func (c contextBackend) Delete(ctx context.Context, key string) error {
+	return c.client.DeleteObject(ctx, c.bucket, c.prefixed(key))
+}
+
+func (c contextBackend) Clear(ctx context.Context) error {
+	_, err := c.deleteByPrefix(ctx, "")
+	return err
+}
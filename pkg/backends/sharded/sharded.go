@@ -0,0 +1,419 @@
+// Package sharded provides a cache backend that partitions keys across
+// several backends by consistent hashing.
+package sharded
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ldaidone/gomemo/internals/hashring"
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// defaultReplicas is how many points every shard gets on the hash ring.
+const defaultReplicas = 100
+
+// Sharded spreads keys over several backends, typically independent Redis
+// instances, so the keyspace can outgrow a single node without running
+// Redis Cluster. Every key lives on one shard, chosen by a consistent hash
+// ring: adding or removing a shard only moves the keys next to its points
+// on the ring, which become misses until they are stored again.
+//
+// Shards are identified by name, usually their address, so processes
+// configured with the same names agree on where every key lives whatever
+// the order of their configuration.
+type Sharded struct {
+	ring   *hashring.Ring
+	shards map[string]backends.Backend
+	names  []string // sorted, for deterministic fan-out
+
+	replicas int
+}
+
+var (
+	_ backends.BatchBackend  = (*Sharded)(nil)
+	_ backends.Toucher       = (*Sharded)(nil)
+	_ backends.Expirer       = (*Sharded)(nil)
+	_ backends.PrefixDeleter = (*Sharded)(nil)
+	_ backends.V2Provider    = (*Sharded)(nil)
+	_ backends.Closer        = (*Sharded)(nil)
+)
+
+// Option configures a Sharded backend.
+type Option func(*Sharded)
+
+// WithReplicas sets how many points every shard gets on the hash ring; the
+// default is 100. More points spread keys more evenly. Every process sharing
+// the shards must use the same value.
+func WithReplicas(n int) Option {
+	return func(s *Sharded) {
+		if n > 0 {
+			s.replicas = n
+		}
+	}
+}
+
+// New creates a backend spreading keys over shards, keyed by name.
+//
+// Example:
+//
+//	shards := make(map[string]backends.Backend)
+//	for _, addr := range []string{"cache-1:6379", "cache-2:6379", "cache-3:6379"} {
+//	    shards[addr] = redis.New(addr, "app:", 0)
+//	}
+//	m := memo.New(memo.WithBackend(sharded.New(shards)))
+func New(shards map[string]backends.Backend, opts ...Option) *Sharded {
+	s := &Sharded{
+		shards:   make(map[string]backends.Backend, len(shards)),
+		replicas: defaultReplicas,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for name, b := range shards {
+		s.shards[name] = b
+		s.names = append(s.names, name)
+	}
+	slices.Sort(s.names)
+	s.ring = hashring.New(s.replicas, s.names)
+	return s
+}
+
+// Owner returns the name of the shard holding key, or "" if there are no
+// shards.
+func (s *Sharded) Owner(key string) string {
+	return s.ring.Owner(key)
+}
+
+// Shard returns the backend named name, or nil if there is none.
+func (s *Sharded) Shard(name string) backends.Backend {
+	return s.shards[name]
+}
+
+// shard returns the backend holding key.
+func (s *Sharded) shard(key string) (backends.Backend, bool) {
+	b, ok := s.shards[s.ring.Owner(key)]
+	return b, ok
+}
+
+// group splits keys by the shard holding them.
+func (s *Sharded) group(keys []string) map[string][]string {
+	groups := make(map[string][]string)
+	for _, k := range keys {
+		if owner := s.ring.Owner(k); owner != "" {
+			groups[owner] = append(groups[owner], k)
+		}
+	}
+	return groups
+}
+
+// Close closes every shard that has a Close method.
+func (s *Sharded) Close() error {
+	var errs []error
+	for _, name := range s.names {
+		if c, ok := s.shards[name].(backends.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// V2 returns the context-aware form of the backend, which passes the
+// caller's context to the shards and reports their errors.
+func (s *Sharded) V2() backends.BackendV2 {
+	v2 := make(map[string]backends.BackendV2, len(s.shards))
+	for name, b := range s.shards {
+		v2[name] = backends.ToV2(b)
+	}
+	return contextBackend{s, v2}
+}
+
+// -----------------------------------------------------------------------------
+// Backend interface
+// -----------------------------------------------------------------------------
+
+func (s *Sharded) Get(key string) (any, bool) {
+	b, ok := s.shard(key)
+	if !ok {
+		return nil, false
+	}
+	return b.Get(key)
+}
+
+func (s *Sharded) Set(key string, value any, ttl time.Duration) {
+	if b, ok := s.shard(key); ok {
+		b.Set(key, value, ttl)
+	}
+}
+
+func (s *Sharded) Delete(key string) {
+	if b, ok := s.shard(key); ok {
+		b.Delete(key)
+	}
+}
+
+// Clear clears every shard.
+func (s *Sharded) Clear() {
+	s.each(s.names, func(name string) { s.shards[name].Clear() })
+}
+
+// -----------------------------------------------------------------------------
+// Optional interfaces
+// -----------------------------------------------------------------------------
+
+// Touch resets the TTL of key on its shard. Returns false if the shard
+// cannot touch entries.
+func (s *Sharded) Touch(key string, ttl time.Duration) bool {
+	b, _ := s.shard(key)
+	t, ok := b.(backends.Toucher)
+	return ok && t.Touch(key, ttl)
+}
+
+// Expire expires key on its shard. Returns false if the shard cannot expire
+// entries.
+func (s *Sharded) Expire(key string) bool {
+	b, _ := s.shard(key)
+	e, ok := b.(backends.Expirer)
+	return ok && e.Expire(key)
+}
+
+// DeleteByPrefix removes matching keys from every shard that supports it
+// and returns the total removed.
+func (s *Sharded) DeleteByPrefix(prefix string) int {
+	var mu sync.Mutex
+	total := 0
+	s.each(s.names, func(name string) {
+		if d, ok := s.shards[name].(backends.PrefixDeleter); ok {
+			n := d.DeleteByPrefix(prefix)
+			mu.Lock()
+			total += n
+			mu.Unlock()
+		}
+	})
+	return total
+}
+
+// GetMulti reads keys from their shards in parallel, with one batch per
+// shard where the shard supports batching.
+func (s *Sharded) GetMulti(keys []string) map[string]any {
+	groups := s.group(keys)
+	var mu sync.Mutex
+	out := make(map[string]any, len(keys))
+	s.each(mapKeys(groups), func(name string) {
+		found := getMulti(s.shards[name], groups[name])
+		mu.Lock()
+		for k, v := range found {
+			out[k] = v
+		}
+		mu.Unlock()
+	})
+	return out
+}
+
+// SetMulti writes items to their shards in parallel.
+func (s *Sharded) SetMulti(items []backends.BatchItem) {
+	groups := make(map[string][]backends.BatchItem)
+	for _, it := range items {
+		if owner := s.ring.Owner(it.Key); owner != "" {
+			groups[owner] = append(groups[owner], it)
+		}
+	}
+	s.each(mapKeys(groups), func(name string) {
+		b := s.shards[name]
+		if bb, ok := b.(backends.BatchBackend); ok {
+			bb.SetMulti(groups[name])
+			return
+		}
+		for _, it := range groups[name] {
+			b.Set(it.Key, it.Value, it.TTL)
+		}
+	})
+}
+
+// DeleteMulti removes keys from their shards in parallel.
+func (s *Sharded) DeleteMulti(keys []string) {
+	groups := s.group(keys)
+	s.each(mapKeys(groups), func(name string) {
+		b := s.shards[name]
+		if bb, ok := b.(backends.BatchBackend); ok {
+			bb.DeleteMulti(groups[name])
+			return
+		}
+		for _, k := range groups[name] {
+			b.Delete(k)
+		}
+	})
+}
+
+func getMulti(b backends.Backend, keys []string) map[string]any {
+	if bb, ok := b.(backends.BatchBackend); ok {
+		return bb.GetMulti(keys)
+	}
+	out := make(map[string]any, len(keys))
+	for _, k := range keys {
+		if v, ok := b.Get(k); ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// each runs fn for every shard name concurrently and waits for all of them.
+func (s *Sharded) each(names []string, fn func(name string)) {
+	if len(names) == 1 {
+		fn(names[0])
+		return
+	}
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(name)
+		}()
+	}
+	wg.Wait()
+}
+
+func mapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// -----------------------------------------------------------------------------
+// BackendV2 interface
+// -----------------------------------------------------------------------------
+
+// contextBackend exposes a Sharded backend through backends.BackendV2.
+type contextBackend struct {
+	*Sharded
+	v2 map[string]backends.BackendV2
+}
+
+var (
+	_ backends.BackendV2      = contextBackend{}
+	_ backends.BatchBackendV2 = contextBackend{}
+)
+
+func (c contextBackend) Get(ctx context.Context, key string) (any, bool, error) {
+	b, ok := c.v2[c.ring.Owner(key)]
+	if !ok {
+		return nil, false, nil
+	}
+	return b.Get(ctx, key)
+}
+
+func (c contextBackend) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	b, ok := c.v2[c.ring.Owner(key)]
+	if !ok {
+		return nil
+	}
+	return b.Set(ctx, key, value, ttl)
+}
+
+func (c contextBackend) Delete(ctx context.Context, key string) error {
+	b, ok := c.v2[c.ring.Owner(key)]
+	if !ok {
+		return nil
+	}
+	return b.Delete(ctx, key)
+}
+
+// Clear clears every shard, even if some fail.
+func (c contextBackend) Clear(ctx context.Context) error {
+	return c.eachErr(c.names, func(name string) error { return c.v2[name].Clear(ctx) })
+}
+
+// GetMulti reads keys from their shards in parallel. Keys on a failing shard
+// are absent from the result and its error is returned with the others.
+func (c contextBackend) GetMulti(ctx context.Context, keys []string) (map[string]any, error) {
+	groups := c.group(keys)
+	var mu sync.Mutex
+	out := make(map[string]any, len(keys))
+	err := c.eachErr(mapKeys(groups), func(name string) error {
+		found, err := getMultiV2(ctx, c.v2[name], groups[name])
+		mu.Lock()
+		for k, v := range found {
+			out[k] = v
+		}
+		mu.Unlock()
+		return err
+	})
+	return out, err
+}
+
+// SetMulti writes items to their shards in parallel.
+func (c contextBackend) SetMulti(ctx context.Context, items []backends.BatchItem) error {
+	groups := make(map[string][]backends.BatchItem)
+	for _, it := range items {
+		if owner := c.ring.Owner(it.Key); owner != "" {
+			groups[owner] = append(groups[owner], it)
+		}
+	}
+	return c.eachErr(mapKeys(groups), func(name string) error {
+		b := c.v2[name]
+		if bb, ok := b.(backends.BatchBackendV2); ok {
+			return bb.SetMulti(ctx, groups[name])
+		}
+		var errs []error
+		for _, it := range groups[name] {
+			errs = append(errs, b.Set(ctx, it.Key, it.Value, it.TTL))
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// DeleteMulti removes keys from their shards in parallel.
+func (c contextBackend) DeleteMulti(ctx context.Context, keys []string) error {
+	groups := c.group(keys)
+	return c.eachErr(mapKeys(groups), func(name string) error {
+		b := c.v2[name]
+		if bb, ok := b.(backends.BatchBackendV2); ok {
+			return bb.DeleteMulti(ctx, groups[name])
+		}
+		var errs []error
+		for _, k := range groups[name] {
+			errs = append(errs, b.Delete(ctx, k))
+		}
+		return errors.Join(errs...)
+	})
+}
+
+func getMultiV2(ctx context.Context, b backends.BackendV2, keys []string) (map[string]any, error) {
+	if bb, ok := b.(backends.BatchBackendV2); ok {
+		return bb.GetMulti(ctx, keys)
+	}
+	out := make(map[string]any, len(keys))
+	var errs []error
+	for _, k := range keys {
+		v, ok, err := b.Get(ctx, k)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			out[k] = v
+		}
+	}
+	return out, errors.Join(errs...)
+}
+
+// eachErr runs fn for every shard name concurrently and joins their errors.
+func (c contextBackend) eachErr(names []string, fn func(name string) error) error {
+	var mu sync.Mutex
+	var errs []error
+	c.each(names, func(name string) {
+		if err := fn(name); err != nil {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}
+	})
+	return errors.Join(errs...)
+}
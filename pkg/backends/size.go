@@ -0,0 +1,120 @@
+package backends
+
+import (
+	"reflect"
+)
+
+// Sizer is implemented by values that know their own size in bytes.
+// Size-aware backends prefer it over estimating the size themselves.
+type Sizer interface {
+	// Size returns the approximate number of bytes the value occupies.
+	Size() int64
+}
+
+// SizeReporter is implemented by backends that track how many bytes
+// their entries occupy.
+type SizeReporter interface {
+	// Bytes returns the estimated number of bytes currently in use.
+	Bytes() int64
+}
+
+// LenReporter is implemented by backends that can count their entries.
+type LenReporter interface {
+	// Len returns the number of entries currently stored.
+	Len() int
+}
+
+// Stats describes how big a backend is.
+type Stats struct {
+	// Entries is the number of entries stored, possibly including expired
+	// entries that have not been removed yet.
+	Entries int64
+
+	// Bytes is the approximate number of bytes the entries occupy, or -1
+	// if the backend does not measure it.
+	Bytes int64
+}
+
+// StatsBackend is implemented by backends that can report their size in a
+// single call. The memoizer prefers it over LenReporter and SizeReporter.
+type StatsBackend interface {
+	// Stats returns the backend's current size.
+	Stats() Stats
+}
+
+// maxSizeDepth bounds how deep EstimateSize follows nested values, which also
+// keeps it from looping on cyclic data.
+const maxSizeDepth = 8
+
+// EstimateSize returns the approximate number of bytes v occupies.
+// Values implementing Sizer report their own size; []byte and string count
+// their length; anything else is measured with reflection, following slices,
+// maps, pointers and struct fields a few levels deep.
+func EstimateSize(v any) int64 {
+	switch t := v.(type) {
+	case nil:
+		return 0
+	case Sizer:
+		return t.Size()
+	case []byte:
+		return int64(len(t))
+	case string:
+		return int64(len(t))
+	}
+	return estimateValue(reflect.ValueOf(v), 0)
+}
+
+// estimateValue measures v, including the data it references.
+func estimateValue(v reflect.Value, depth int) int64 {
+	if !v.IsValid() {
+		return 0
+	}
+	size := int64(v.Type().Size())
+	if depth >= maxSizeDepth {
+		return size
+	}
+	return size + estimateReferenced(v, depth)
+}
+
+// estimateReferenced measures the data v points to but does not contain inline.
+func estimateReferenced(v reflect.Value, depth int) int64 {
+	var size int64
+	switch v.Kind() {
+	case reflect.String:
+		size = int64(v.Len())
+	case reflect.Slice:
+		if isFlat(v.Type().Elem().Kind()) {
+			return int64(v.Len()) * int64(v.Type().Elem().Size())
+		}
+		for i := 0; i < v.Len(); i++ {
+			size += estimateValue(v.Index(i), depth+1)
+		}
+	case reflect.Array:
+		if isFlat(v.Type().Elem().Kind()) {
+			return 0
+		}
+		// Elements are inline; only count what they reference
+		for i := 0; i < v.Len(); i++ {
+			size += estimateReferenced(v.Index(i), depth+1)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			size += estimateValue(iter.Key(), depth+1) + estimateValue(iter.Value(), depth+1)
+		}
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			size += estimateValue(v.Elem(), depth+1)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			size += estimateReferenced(v.Field(i), depth+1)
+		}
+	}
+	return size
+}
+
+// isFlat reports whether values of kind k reference no other memory.
+func isFlat(k reflect.Kind) bool {
+	return k >= reflect.Bool && k <= reflect.Complex128
+}
@@ -0,0 +1,135 @@
+package backends
+
+import (
+	"context"
+	"time"
+)
+
+// BackendV2 is a context-aware cache storage interface that reports errors.
+// Unlike Backend, implementations can honor request deadlines and tell the
+// caller about failures (e.g. a lost Redis connection) instead of turning
+// them into silent misses.
+type BackendV2 interface {
+	// Get retrieves a value from the cache by key.
+	// A missing or expired key is not an error: it returns nil, false, nil.
+	Get(ctx context.Context, key string) (value any, ok bool, err error)
+
+	// Set stores a value in the cache with an optional TTL (time-to-live).
+	// If TTL is 0 or negative, the value will not expire.
+	Set(ctx context.Context, key string, value any, ttl time.Duration) error
+
+	// Delete removes a value from the cache. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// Clear removes all values from the cache.
+	Clear(ctx context.Context) error
+}
+
+// BatchBackendV2 is the context-aware form of BatchBackend, implemented by
+// BackendV2s that can read and write many keys in one round trip.
+type BatchBackendV2 interface {
+	// GetMulti retrieves the values stored under keys. Keys that are missing
+	// or expired are absent from the result and are not errors.
+	GetMulti(ctx context.Context, keys []string) (map[string]any, error)
+
+	// SetMulti stores all items, each with its own TTL. Items that fail are
+	// reported in the returned error; the others are still stored.
+	SetMulti(ctx context.Context, items []BatchItem) error
+
+	// DeleteMulti removes the values stored under keys.
+	DeleteMulti(ctx context.Context, keys []string) error
+}
+
+// EntryBackendV2 is the context-aware form of EntryBackend, implemented by
+// BackendV2s that can return a stored entry with its metadata.
+type EntryBackendV2 interface {
+	// GetEntry retrieves the entry stored under key. A missing or expired
+	// key is not an error: it returns a zero entry, false, nil.
+	GetEntry(ctx context.Context, key string) (entry CacheEntry, ok bool, err error)
+}
+
+// ToucherV2 is the context-aware form of Toucher, implemented by BackendV2s
+// whose touches need a round trip to a remote store.
+type ToucherV2 interface {
+	// Touch resets the TTL of the entry stored under key to ttl from now.
+	// A missing or expired key is not an error: it returns false, nil.
+	Touch(ctx context.Context, key string, ttl time.Duration) (ok bool, err error)
+}
+
+// FromV2 adapts a BackendV2 to the Backend interface. Calls run with
+// context.Background() and errors are dropped, so it is only meant for code
+// that cannot use BackendV2 directly.
+func FromV2(b BackendV2) Backend {
+	return v2Adapter{b}
+}
+
+// V2Provider is implemented by Backends that have a context-aware form,
+// such as the Redis backend. ToV2 uses it, so the caller's context reaches
+// such backends even when they are used through the Backend interface.
+type V2Provider interface {
+	// V2 returns the backend as a BackendV2 sharing the same storage.
+	V2() BackendV2
+}
+
+// ToV2 adapts a Backend to the BackendV2 interface. Backends implementing
+// V2Provider are returned in their context-aware form; for others the
+// context is ignored and no errors are ever returned.
+func ToV2(b Backend) BackendV2 {
+	switch t := b.(type) {
+	case v2Adapter:
+		return t.b
+	case V2Provider:
+		return t.V2()
+	}
+	return v1Adapter{b}
+}
+
+// v2Adapter implements Backend on top of a BackendV2.
+type v2Adapter struct {
+	b BackendV2
+}
+
+func (a v2Adapter) Get(key string) (any, bool) {
+	v, ok, err := a.b.Get(context.Background(), key)
+	if err != nil {
+		return nil, false
+	}
+	return v, ok
+}
+
+func (a v2Adapter) Set(key string, value any, ttl time.Duration) {
+	_ = a.b.Set(context.Background(), key, value, ttl)
+}
+
+func (a v2Adapter) Delete(key string) {
+	_ = a.b.Delete(context.Background(), key)
+}
+
+func (a v2Adapter) Clear() {
+	_ = a.b.Clear(context.Background())
+}
+
+// v1Adapter implements BackendV2 on top of a Backend.
+type v1Adapter struct {
+	b Backend
+}
+
+func (a v1Adapter) Get(_ context.Context, key string) (any, bool, error) {
+	v, ok := a.b.Get(key)
+	return v, ok, nil
+}
+
+func (a v1Adapter) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	a.b.Set(key, value, ttl)
+	return nil
+}
+
+func (a v1Adapter) Delete(_ context.Context, key string) error {
+	a.b.Delete(key)
+	return nil
+}
+
+func (a v1Adapter) Clear(_ context.Context) error {
+	a.b.Clear()
+	return nil
+}
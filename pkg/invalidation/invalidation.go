@@ -0,0 +1,171 @@
+// Package invalidation defines the messages and bus interface used to spread
+// cache invalidations between processes, and from outside an application,
+// e.g. from a change-data-capture pipeline.
+//
+// A memoizer created with memo.WithInvalidationBus publishes every Delete,
+// DeleteByPrefix, InvalidateTag, Clear and Namespace.Invalidate to the bus
+// and applies the invalidations other publishers send. Messages travel as
+// JSON, so any producer can publish them:
+//
+//	{"kind":"key","value":"user:42"}
+//	{"kind":"tag","value":"orders"}
+//	{"kind":"all"}
+//
+// Implementations live in the subpackages nats and kafka, and in
+// redis.NewBus for Redis pub/sub.
+package invalidation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Kind is what an invalidation message removes.
+type Kind string
+
+const (
+	// Key removes the entry for the key in Value.
+	Key Kind = "key"
+
+	// Prefix removes every entry whose key starts with Value.
+	Prefix Kind = "prefix"
+
+	// Tag removes every entry tagged with Value.
+	Tag Kind = "tag"
+
+	// Namespace invalidates the namespace named Value.
+	Namespace Kind = "namespace"
+
+	// All clears the whole cache. Value is ignored.
+	All Kind = "all"
+)
+
+// ErrInvalidMessage is returned by Decode for payloads that are not
+// invalidation messages.
+var ErrInvalidMessage = errors.New("invalid invalidation message")
+
+// Message is a single invalidation. Keys, prefixes and tags are those given
+// to the memoizer, without its key prefix.
+type Message struct {
+	Kind  Kind   `json:"kind"`
+	Value string `json:"value,omitempty"`
+
+	// Origin identifies the publisher, so a memoizer can skip the messages
+	// it published itself. External producers leave it empty.
+	Origin string `json:"origin,omitempty"`
+}
+
+// Bus carries invalidation messages between processes. Implementations must
+// be safe for concurrent use.
+type Bus interface {
+	// Publish sends msg to every subscriber, including those in the
+	// publishing process.
+	Publish(ctx context.Context, msg Message) error
+
+	// Subscribe calls fn with every message published from now on, until
+	// the returned subscription is closed or ctx is done. Delivery is at
+	// most once: messages sent while a subscriber is disconnected are lost,
+	// so cached entries should still carry a TTL.
+	Subscribe(ctx context.Context, fn func(Message)) (io.Closer, error)
+}
+
+// Encode returns the JSON form of msg.
+func Encode(msg Message) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+// Decode parses a message produced by Encode, or by any producer following
+// the same format. It returns an error wrapping ErrInvalidMessage for
+// malformed payloads and unknown kinds.
+func Decode(data []byte) (Message, error) {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return Message{}, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	switch msg.Kind {
+	case Key, Prefix, Tag, Namespace:
+		if msg.Value == "" {
+			return Message{}, fmt.Errorf("%w: %s without a value", ErrInvalidMessage, msg.Kind)
+		}
+	case All:
+	default:
+		return Message{}, fmt.Errorf("%w: unknown kind %q", ErrInvalidMessage, msg.Kind)
+	}
+	return msg, nil
+}
+
+// -----------------------------------------------------------------------------
+// In-process bus
+// -----------------------------------------------------------------------------
+
+// MemoryBus is a Bus delivering messages to subscribers in the same
+// process, synchronously from Publish. It connects memoizers that each keep
+// their own backend, and stands in for a network bus in tests.
+type MemoryBus struct {
+	mu     sync.Mutex
+	subs   map[int]func(Message)
+	nextID int
+}
+
+var _ Bus = (*MemoryBus)(nil)
+
+// NewMemoryBus creates an empty in-process bus.
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{subs: make(map[int]func(Message))}
+}
+
+// Publish calls every subscriber with msg before returning.
+func (b *MemoryBus) Publish(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	subs := make([]func(Message), 0, len(b.subs))
+	for _, fn := range b.subs {
+		subs = append(subs, fn)
+	}
+	b.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(msg)
+	}
+	return nil
+}
+
+// Subscribe registers fn until the subscription is closed or ctx is done.
+func (b *MemoryBus) Subscribe(ctx context.Context, fn func(Message)) (io.Closer, error) {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = fn
+	b.mu.Unlock()
+
+	sub := &memorySub{bus: b, id: id, stop: func() bool { return false }}
+	if ctx.Done() != nil {
+		sub.stop = context.AfterFunc(ctx, sub.remove)
+	}
+	return sub, nil
+}
+
+// memorySub is a subscription to a MemoryBus.
+type memorySub struct {
+	bus  *MemoryBus
+	id   int
+	stop func() bool
+}
+
+func (s *memorySub) Close() error {
+	s.stop()
+	s.remove()
+	return nil
+}
+
+func (s *memorySub) remove() {
+	s.bus.mu.Lock()
+	delete(s.bus.subs, s.id)
+	s.bus.mu.Unlock()
+}
@@ -0,0 +1,188 @@
+// Package kafka provides an invalidation bus over a Kafka topic.
+//
+// The bus uses small Writer and Reader interfaces instead of importing a
+// Kafka client, so applications choose the client. With
+// github.com/segmentio/kafka-go, for example:
+//
+//	type writer struct{ *kafka.Writer }
+//
+//	func (w writer) Write(ctx context.Context, key, value []byte) error {
+//	    return w.WriteMessages(ctx, kafka.Message{Key: key, Value: value})
+//	}
+//
+//	type reader struct{ *kafka.Reader }
+//
+//	func (r reader) Read(ctx context.Context) ([]byte, error) {
+//	    m, err := r.ReadMessage(ctx)
+//	    return m.Value, err
+//	}
+//
+//	bus := kafkabus.New(writer{w}, reader{r})
+//	m := memo.New(memo.WithInvalidationBus(bus))
+//
+// Every process must see every message, so give each process its own
+// consumer group, or read without one.
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/invalidation"
+)
+
+// defaultRetryDelay is how long the bus waits after a failed read.
+const defaultRetryDelay = time.Second
+
+// ErrSubscribed is returned by Subscribe while another subscription on the
+// same bus is active.
+var ErrSubscribed = errors.New("kafka bus already has a subscriber")
+
+// Writer publishes records to the invalidation topic.
+type Writer interface {
+	Write(ctx context.Context, key, value []byte) error
+}
+
+// Reader reads the values of records on the invalidation topic, blocking
+// until one arrives or ctx is done.
+type Reader interface {
+	Read(ctx context.Context) (value []byte, err error)
+}
+
+// Bus is an invalidation.Bus publishing to a Kafka topic. Records are keyed
+// by the invalidated key, tag or prefix, so invalidations of the same value
+// stay ordered within a partition.
+//
+// A Bus serves one subscriber at a time, since records read by one
+// subscriber are gone for the others.
+type Bus struct {
+	writer       Writer
+	reader       Reader
+	retryDelay   time.Duration
+	errorHandler func(op string, err error) // receives errors that cannot be returned; nil logs them
+
+	mu         sync.Mutex
+	subscribed bool
+}
+
+var _ invalidation.Bus = (*Bus)(nil)
+
+// Option configures a Kafka bus.
+type Option func(*Bus)
+
+// WithRetryDelay sets how long the bus waits before reading again after a
+// failed read; the default is one second.
+func WithRetryDelay(d time.Duration) Option {
+	return func(b *Bus) {
+		if d > 0 {
+			b.retryDelay = d
+		}
+	}
+}
+
+// WithErrorHandler receives errors that cannot be returned, such as failed
+// reads and undecodable records, instead of logging them.
+func WithErrorHandler(fn func(op string, err error)) Option {
+	return func(b *Bus) {
+		b.errorHandler = fn
+	}
+}
+
+// New creates a bus writing with w and reading with r. Either may be nil
+// for a process that only publishes or only subscribes.
+func New(w Writer, r Reader, opts ...Option) *Bus {
+	b := &Bus{writer: w, reader: r, retryDelay: defaultRetryDelay}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Publish writes msg to the topic.
+func (b *Bus) Publish(ctx context.Context, msg invalidation.Message) error {
+	if b.writer == nil {
+		return errors.New("kafka bus has no writer")
+	}
+	data, err := invalidation.Encode(msg)
+	if err != nil {
+		return err
+	}
+	return b.writer.Write(ctx, []byte(msg.Value), data)
+}
+
+// Subscribe reads the topic in the background and calls fn with every
+// message until the subscription is closed or ctx is done. Failed reads are
+// reported and retried.
+func (b *Bus) Subscribe(ctx context.Context, fn func(invalidation.Message)) (io.Closer, error) {
+	if b.reader == nil {
+		return nil, errors.New("kafka bus has no reader")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribed {
+		return nil, ErrSubscribed
+	}
+	b.subscribed = true
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &subscription{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		defer func() {
+			b.mu.Lock()
+			b.subscribed = false
+			b.mu.Unlock()
+		}()
+		b.read(ctx, fn)
+	}()
+	return s, nil
+}
+
+// read runs the read loop of a subscription until ctx is done.
+func (b *Bus) read(ctx context.Context, fn func(invalidation.Message)) {
+	for {
+		data, err := b.reader.Read(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			b.onError("read", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(b.retryDelay):
+			}
+			continue
+		}
+		msg, err := invalidation.Decode(data)
+		if err != nil {
+			b.onError("decode", err)
+			continue
+		}
+		fn(msg)
+	}
+}
+
+func (b *Bus) onError(op string, err error) {
+	if b.errorHandler != nil {
+		b.errorHandler(op, err)
+		return
+	}
+	log.Printf("[gomemo][kafka] %s error: %v\n", op, err)
+}
+
+// subscription stops a read loop and waits for it to return.
+type subscription struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (s *subscription) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
@@ -0,0 +1,145 @@
+// Package nats provides an invalidation bus over NATS core subjects.
+//
+// The bus uses a small Conn interface instead of importing the NATS client,
+// so applications choose the client version. A *nats.Conn fits it with a
+// one-method shim:
+//
+//	type natsConn struct{ *nats.Conn }
+//
+//	func (c natsConn) Subscribe(subject string, fn func(data []byte)) (natsbus.Subscription, error) {
+//	    return c.Conn.Subscribe(subject, func(m *nats.Msg) { fn(m.Data) })
+//	}
+//
+//	bus := natsbus.New(natsConn{nc})
+//	m := memo.New(memo.WithInvalidationBus(bus))
+package nats
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/ldaidone/gomemo/pkg/invalidation"
+)
+
+// DefaultSubject is the subject invalidations are published on unless
+// WithSubject is given.
+const DefaultSubject = "gomemo.invalidations"
+
+// Conn is the part of a NATS connection the bus uses.
+type Conn interface {
+	// Publish sends data on subject.
+	Publish(subject string, data []byte) error
+
+	// Subscribe calls fn with the payload of every message on subject.
+	Subscribe(subject string, fn func(data []byte)) (Subscription, error)
+}
+
+// Subscription is a subscription returned by Conn.Subscribe. *nats.Subscription
+// implements it.
+type Subscription interface {
+	Unsubscribe() error
+}
+
+// Bus is an invalidation.Bus publishing on a NATS subject. Every subscriber
+// receives every message; core NATS does not store messages, so processes
+// that are disconnected miss them.
+type Bus struct {
+	conn         Conn
+	subject      string
+	errorHandler func(op string, err error) // receives errors that cannot be returned; nil logs them
+}
+
+var _ invalidation.Bus = (*Bus)(nil)
+
+// Option configures a NATS bus.
+type Option func(*Bus)
+
+// WithSubject sets the subject messages are published on. Every process
+// sharing invalidations must use the same subject.
+func WithSubject(subject string) Option {
+	return func(b *Bus) {
+		if subject != "" {
+			b.subject = subject
+		}
+	}
+}
+
+// WithErrorHandler receives errors that cannot be returned, such as
+// undecodable messages, instead of logging them.
+func WithErrorHandler(fn func(op string, err error)) Option {
+	return func(b *Bus) {
+		b.errorHandler = fn
+	}
+}
+
+// New creates a bus publishing on conn.
+func New(conn Conn, opts ...Option) *Bus {
+	b := &Bus{conn: conn, subject: DefaultSubject}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Publish sends msg on the bus subject.
+func (b *Bus) Publish(ctx context.Context, msg invalidation.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := invalidation.Encode(msg)
+	if err != nil {
+		return err
+	}
+	return b.conn.Publish(b.subject, data)
+}
+
+// Subscribe calls fn with every message on the bus subject until the
+// subscription is closed or ctx is done.
+func (b *Bus) Subscribe(ctx context.Context, fn func(invalidation.Message)) (io.Closer, error) {
+	sub, err := b.conn.Subscribe(b.subject, func(data []byte) {
+		msg, err := invalidation.Decode(data)
+		if err != nil {
+			b.onError("decode", err)
+			return
+		}
+		fn(msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := &subscription{sub: sub, stop: func() bool { return false }}
+	if ctx.Done() != nil {
+		s.stop = context.AfterFunc(ctx, func() { _ = s.unsubscribe() })
+	}
+	return s, nil
+}
+
+func (b *Bus) onError(op string, err error) {
+	if b.errorHandler != nil {
+		b.errorHandler(op, err)
+		return
+	}
+	log.Printf("[gomemo][nats] %s error: %v\n", op, err)
+}
+
+// subscription ends a NATS subscription once.
+type subscription struct {
+	sub  Subscription
+	stop func() bool
+	once sync.Once
+	err  error
+}
+
+func (s *subscription) Close() error {
+	s.stop()
+	return s.unsubscribe()
+}
+
+func (s *subscription) unsubscribe() error {
+	s.once.Do(func() {
+		s.err = s.sub.Unsubscribe()
+	})
+	return s.err
+}
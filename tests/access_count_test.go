@@ -0,0 +1,69 @@
+package memo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestAccessCountPerHit tests that the access count increments on every cache hit
+func TestAccessCountPerHit(t *testing.T) {
+	m := memo.New(memo.WithBackend(memory.New(memory.WithAccessCounts())), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+	fn := func() (any, error) { return "v", nil }
+
+	_, _ = m.Get(ctx, "k", fn)
+	base, ok := m.AccessCount("k")
+	if !ok {
+		t.Fatalf("Expected access count to be available")
+	}
+
+	for i := 0; i < 5; i++ {
+		_, _ = m.Get(ctx, "k", fn)
+	}
+	got, _ := m.AccessCount("k")
+	if got != base+5 {
+		t.Fatalf("Expected %d accesses, got: %d", base+5, got)
+	}
+
+	// Introspection does not count as an access
+	again, _ := m.AccessCount("k")
+	if again != got {
+		t.Fatalf("Expected AccessCount not to change the count, got: %d", again)
+	}
+
+	// A rewrite starts a fresh entry
+	m.Delete("k")
+	_, _ = m.Get(ctx, "k", fn)
+	if fresh, _ := m.AccessCount("k"); fresh >= got {
+		t.Fatalf("Expected count to reset for a new entry, got: %d", fresh)
+	}
+}
+
+// TestAccessCountViaEntry tests that the count is visible on entries returned by GetEntry
+func TestAccessCountViaEntry(t *testing.T) {
+	b := memory.New(memory.WithAccessCounts())
+	b.Set("k", "v", 0)
+	b.Get("k")
+	b.Get("k")
+
+	entry, ok := b.GetEntry("k")
+	if !ok || entry.Accesses() != 3 {
+		t.Fatalf("Expected 3 accesses including GetEntry, got: %d", entry.Accesses())
+	}
+}
+
+// TestAccessCountDisabled tests that access counting is off by default
+func TestAccessCountDisabled(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+	_, _ = m.Get(ctx, "k", func() (any, error) { return "v", nil })
+	_, _ = m.Get(ctx, "k", func() (any, error) { return "v", nil })
+
+	if _, ok := m.AccessCount("k"); ok {
+		t.Fatalf("Expected access count to be unavailable by default")
+	}
+}
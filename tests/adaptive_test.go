@@ -0,0 +1,69 @@
+package memo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// concurrentRecompute runs n concurrent Gets for key after expiring it and
+// returns how many times fn ran. fn waits briefly for its peers so that
+// non-deduplicated calls are guaranteed to overlap.
+func concurrentRecompute(m *memo.Memoizer, key string, n int) int32 {
+	m.Expire(key)
+
+	var calls, arrived int32
+	fn := func() (any, error) {
+		atomic.AddInt32(&calls, 1)
+		atomic.AddInt32(&arrived, 1)
+		deadline := time.Now().Add(200 * time.Millisecond)
+		for atomic.LoadInt32(&arrived) < int32(n) && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		return "v", nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			_, _ = m.Get(context.Background(), key, fn)
+		}()
+	}
+	wg.Wait()
+	return atomic.LoadInt32(&calls)
+}
+
+// TestAdaptiveSingleFlightBypass tests that keys with a cheap compute history skip deduplication
+func TestAdaptiveSingleFlightBypass(t *testing.T) {
+	m := memo.New(memo.WithTTL(5*time.Second), memo.WithAdaptiveSingleFlight(time.Second))
+
+	// The first compute is timed and found to be cheap
+	if _, err := m.Get(context.Background(), "cheap", func() (any, error) { return "v", nil }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if calls := concurrentRecompute(m, "cheap", 3); calls != 3 {
+		t.Fatalf("Expected concurrent misses to bypass singleflight, got %d computes", calls)
+	}
+}
+
+// TestAdaptiveSingleFlightKeepsDedupForSlowKeys tests that expensive and unknown keys stay deduplicated
+func TestAdaptiveSingleFlightKeepsDedupForSlowKeys(t *testing.T) {
+	m := memo.New(memo.WithTTL(5*time.Second), memo.WithAdaptiveSingleFlight(time.Millisecond))
+
+	// No history yet: deduplicated
+	if calls := concurrentRecompute(m, "unknown", 3); calls != 1 {
+		t.Fatalf("Expected keys without history to be deduplicated, got %d computes", calls)
+	}
+
+	// The previous compute waited for peers and was slower than the threshold
+	if calls := concurrentRecompute(m, "unknown", 3); calls != 1 {
+		t.Fatalf("Expected slow keys to stay deduplicated, got %d computes", calls)
+	}
+}
@@ -0,0 +1,146 @@
+package memo
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// slowSetBackend wraps the memory backend and delays every Set until released
+type slowSetBackend struct {
+	*memory.Memory
+	delay   time.Duration
+	release chan struct{}
+	sets    int32
+}
+
+func (b *slowSetBackend) Set(key string, value any, ttl time.Duration) {
+	if b.release != nil {
+		<-b.release
+	}
+	time.Sleep(b.delay)
+	b.Memory.Set(key, value, ttl)
+	atomic.AddInt32(&b.sets, 1)
+}
+
+// TestAsyncSetReturnsPromptly tests that Get does not wait for a slow backend write
+// and that the value is served without recomputation until the write lands
+func TestAsyncSetReturnsPromptly(t *testing.T) {
+	backend := &slowSetBackend{Memory: memory.New(), delay: 200 * time.Millisecond}
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(5*time.Second), memo.WithAsyncSet(true))
+	ctx := context.Background()
+
+	var calls int32
+	fn := func() (any, error) {
+		atomic.AddInt32(&calls, 1)
+		return "async", nil
+	}
+
+	start := time.Now()
+	v, err := m.Get(ctx, "async-key", fn)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v != "async" {
+		t.Fatalf("Expected 'async', got: %v", v)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("Expected Get to return before the slow write, took %v", elapsed)
+	}
+
+	// The write has not landed yet, but the value must not be recomputed
+	if _, ok := backend.Memory.Get("async-key"); ok {
+		t.Fatal("Expected backend write to still be in progress")
+	}
+	v, err = m.Get(ctx, "async-key", fn)
+	if err != nil || v != "async" {
+		t.Fatalf("Expected pending value, got: %v (err=%v)", v, err)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("Expected one computation, got %d", calls)
+	}
+
+	// Eventually the value is persisted
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if v, ok := backend.Memory.Get("async-key"); ok && v == "async" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected value to eventually persist in the backend")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestAsyncSetDelete tests that deleting a key cancels its pending write
+func TestAsyncSetDelete(t *testing.T) {
+	backend := &slowSetBackend{Memory: memory.New(), release: make(chan struct{})}
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(5*time.Second), memo.WithAsyncSet(true))
+	ctx := context.Background()
+
+	// Occupy the worker so the next write stays queued
+	_, _ = m.Get(ctx, "blocker", func() (any, error) { return 1, nil })
+	_, _ = m.Get(ctx, "doomed", func() (any, error) { return 2, nil })
+
+	m.Delete("doomed")
+	close(backend.release)
+
+	var calls int32
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&backend.sets) < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := backend.Memory.Get("doomed"); ok {
+		t.Fatal("Expected deleted key not to be written by the async worker")
+	}
+	_, _ = m.Get(ctx, "doomed", func() (any, error) {
+		atomic.AddInt32(&calls, 1)
+		return 3, nil
+	})
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("Expected recompute after Delete, got %d calls", calls)
+	}
+}
+
+// TestAsyncSetQueueFull tests that writes beyond the queue bound are dropped and counted
+func TestAsyncSetQueueFull(t *testing.T) {
+	backend := &slowSetBackend{Memory: memory.New(), release: make(chan struct{})}
+	defer close(backend.release)
+
+	m := memo.New(
+		memo.WithBackend(backend),
+		memo.WithTTL(5*time.Second),
+		memo.WithMetrics(true),
+		memo.WithAsyncSet(true),
+		memo.WithAsyncSetQueueSize(1),
+	)
+	ctx := context.Background()
+
+	// The first write is picked up by the blocked worker, the second fills
+	// the queue and everything after that is dropped
+	_, _ = m.Get(ctx, "k1", func() (any, error) { return 1, nil })
+	time.Sleep(20 * time.Millisecond)
+	_, _ = m.Get(ctx, "k2", func() (any, error) { return 2, nil })
+	_, _ = m.Get(ctx, "k3", func() (any, error) { return 3, nil })
+
+	if drops := m.Metrics().Snapshot().AsyncSetDrops; drops != 1 {
+		t.Fatalf("Expected 1 dropped write, got: %d", drops)
+	}
+}
+
+// TestAsyncSetValidation tests that a non-positive queue size is rejected
+func TestAsyncSetValidation(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected New to panic for a zero async queue size")
+		}
+	}()
+	memo.New(memo.WithAsyncSet(true), memo.WithAsyncSetQueueSize(0))
+}
@@ -0,0 +1,141 @@
+package memo
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/azure"
+)
+
+// fakeAzure is an in-memory azure.Client.
+type fakeAzure struct {
+	mu            sync.Mutex
+	blobs         map[string]azure.Blob // by container/name
+	lastGetCtx    context.Context
+	lastContainer string
+}
+
+func newFakeAzure() *fakeAzure {
+	return &fakeAzure{blobs: make(map[string]azure.Blob)}
+}
+
+func (f *fakeAzure) GetBlob(ctx context.Context, container, name string) (*azure.Blob, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastGetCtx, f.lastContainer = ctx, container
+	blob, ok := f.blobs[container+"/"+name]
+	if !ok {
+		return nil, azure.ErrNotFound
+	}
+	return &blob, nil
+}
+
+func (f *fakeAzure) UploadBlob(_ context.Context, container string, blob azure.Blob) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blobs[container+"/"+blob.Name] = blob
+	return nil
+}
+
+func (f *fakeAzure) DeleteBlob(_ context.Context, container, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.blobs, container+"/"+name)
+	return nil
+}
+
+func (f *fakeAzure) ListBlobs(_ context.Context, container, prefix string, fn func(names []string) error) error {
+	f.mu.Lock()
+	var names []string
+	for key := range f.blobs {
+		if name, ok := strings.CutPrefix(key, container+"/"); ok && strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	f.mu.Unlock()
+	sort.Strings(names)
+	for len(names) > 0 {
+		n := min(len(names), 3)
+		if err := fn(names[:n]); err != nil {
+			return err
+		}
+		names = names[n:]
+	}
+	return nil
+}
+
+// TestAzureBasic tests reads, writes and expiry metadata of the azure backend
+func TestAzureBasic(t *testing.T) {
+	client := newFakeAzure()
+	a := azure.New(client, "cache", azure.WithPrefix("reports/"))
+
+	a.Set("k", "v", time.Hour)
+	if v, ok := a.Get("k"); !ok || v != "v" {
+		t.Fatalf("Expected v, got: %v, %v", v, ok)
+	}
+	if client.lastContainer != "cache" {
+		t.Fatalf("Expected the configured container, got: %s", client.lastContainer)
+	}
+	blob, ok := client.blobs["cache/reports/k"]
+	if !ok {
+		t.Fatal("Expected the blob under the configured prefix")
+	}
+	exp, err := strconv.ParseInt(blob.Metadata[azure.ExpiresMetadata], 10, 64)
+	if err != nil || time.Until(time.Unix(0, exp)) < 59*time.Minute {
+		t.Fatalf("Expected the expiry in the metadata, got: %q", blob.Metadata[azure.ExpiresMetadata])
+	}
+
+	a.Set("short", "v", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := a.Get("short"); ok {
+		t.Fatal("Expected an expired blob to be a miss")
+	}
+
+	a.Delete("k")
+	if _, ok := a.Get("k"); ok {
+		t.Fatal("Expected Delete to remove the blob")
+	}
+}
+
+// TestAzureDeleteByPrefix tests that prefix deletes and Clear stay within the backend's prefix
+func TestAzureDeleteByPrefix(t *testing.T) {
+	client := newFakeAzure()
+	a := azure.New(client, "cache")
+	client.blobs["cache/other/x"] = azure.Blob{Name: "other/x"}
+
+	for i := 0; i < 7; i++ {
+		a.Set("report:"+strconv.Itoa(i), i, time.Hour)
+	}
+	a.Set("model:a", "a", time.Hour)
+
+	if n := a.DeleteByPrefix("report:"); n != 7 {
+		t.Fatalf("Expected 7 blobs removed, got: %d", n)
+	}
+	a.Clear()
+	if len(client.blobs) != 1 {
+		t.Fatalf("Expected Clear to keep only blobs outside the prefix, got: %d blobs", len(client.blobs))
+	}
+}
+
+// TestAzureWithMemoizer tests that the memoizer passes the caller's context to the azure client
+func TestAzureWithMemoizer(t *testing.T) {
+	client := newFakeAzure()
+	m := memo.New(memo.WithBackend(azure.New(client, "cache")), memo.WithTTL(time.Minute))
+
+	ctx := context.WithValue(context.Background(), ctxKey("req"), "request")
+	if v, err := m.Get(ctx, "k", func() (any, error) { return "v", nil }); err != nil || v != "v" {
+		t.Fatalf("Expected v, got: %v, %v", v, err)
+	}
+	if client.lastGetCtx.Value(ctxKey("req")) != "request" {
+		t.Fatal("Expected the client to receive the caller's context")
+	}
+	if v, err := m.Get(ctx, "k", func() (any, error) { return "recomputed", nil }); err != nil || v != "v" {
+		t.Fatalf("Expected the stored value, got: %v, %v", v, err)
+	}
+}
@@ -0,0 +1,177 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+)
+
+// v2Backend is a map-backed backends.BackendV2 that can be made to fail
+type v2Backend struct {
+	mu       sync.Mutex
+	data     map[string]any
+	err      error
+	lastCtx  context.Context
+	closed   bool
+	setCalls int
+}
+
+func newV2Backend() *v2Backend {
+	return &v2Backend{data: make(map[string]any)}
+}
+
+func (b *v2Backend) Get(ctx context.Context, key string) (any, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastCtx = ctx
+	if b.err != nil {
+		return nil, false, b.err
+	}
+	v, ok := b.data[key]
+	return v, ok, nil
+}
+
+func (b *v2Backend) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastCtx = ctx
+	b.setCalls++
+	if b.err != nil {
+		return b.err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b.data[key] = value
+	return nil
+}
+
+func (b *v2Backend) Delete(ctx context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.data, key)
+	return b.err
+}
+
+func (b *v2Backend) Clear(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	clear(b.data)
+	return b.err
+}
+
+func (b *v2Backend) Close() error {
+	b.closed = true
+	return nil
+}
+
+var _ backends.BackendV2 = (*v2Backend)(nil)
+
+// TestBackendV2ReceivesContext tests that the memoizer passes the caller's context to a V2 backend
+func TestBackendV2ReceivesContext(t *testing.T) {
+	b := newV2Backend()
+	m := memo.New(memo.WithBackendV2(b), memo.WithTTL(time.Minute))
+	ctx := context.WithValue(context.Background(), ctxKey("req"), "request")
+
+	calls := 0
+	fn := func() (any, error) { calls++; return "v", nil }
+	for i := 0; i < 2; i++ {
+		if v, err := m.Get(ctx, "k", fn); err != nil || v != "v" {
+			t.Fatalf("Expected v, got: %v, %v", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("Expected a single compute, got: %d", calls)
+	}
+	if b.lastCtx.Value(ctxKey("req")) != "request" {
+		t.Fatalf("Expected backend to receive the request context")
+	}
+
+	if err := m.Close(); err != nil || !b.closed {
+		t.Fatalf("Expected Close to close the V2 backend, got: %v, %v", b.closed, err)
+	}
+}
+
+// TestBackendV2ErrorsAreCounted tests that backend errors become uncached computes and show up in metrics
+func TestBackendV2ErrorsAreCounted(t *testing.T) {
+	b := newV2Backend()
+	b.err = errors.New("connection refused")
+	m := memo.New(memo.WithBackendV2(b), memo.WithTTL(time.Minute), memo.WithMetrics(true))
+
+	calls := 0
+	fn := func() (any, error) { calls++; return "v", nil }
+	for i := 0; i < 2; i++ {
+		if v, err := m.Get(context.Background(), "k", fn); err != nil || v != "v" {
+			t.Fatalf("Expected compute to succeed despite backend errors, got: %v, %v", v, err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("Expected every call to recompute, got: %d", calls)
+	}
+	// One failed read per Get; the backend is not used again after it failed
+	snap := m.Metrics().Snapshot()
+	if snap.BackendErrors != 2 {
+		t.Fatalf("Expected 2 backend errors, got: %d", snap.BackendErrors)
+	}
+	if snap.DegradedReads != 2 {
+		t.Fatalf("Expected 2 degraded reads, got: %d", snap.DegradedReads)
+	}
+	if len(b.data) != 0 {
+		t.Fatalf("Expected no write to the failing backend, got: %v", b.data)
+	}
+}
+
+// TestBackendV2CancelledWrite tests that a done context skips the write unless CacheOnCancel is set
+func TestBackendV2CancelledWrite(t *testing.T) {
+	for _, cacheOnCancel := range []bool{false, true} {
+		b := newV2Backend()
+		m := memo.New(memo.WithBackendV2(b), memo.WithTTL(time.Minute), memo.WithCacheOnCancel(cacheOnCancel))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, _ = m.Get(ctx, "k", func() (any, error) {
+			cancel()
+			return "v", nil
+		})
+
+		_, stored := b.data["k"]
+		if stored != cacheOnCancel {
+			t.Fatalf("Expected stored=%v with CacheOnCancel=%v, got: %v", cacheOnCancel, cacheOnCancel, stored)
+		}
+	}
+}
+
+// TestBackendErrorFailPolicy tests that BackendErrorFail returns read errors instead of computing
+func TestBackendErrorFailPolicy(t *testing.T) {
+	b := newV2Backend()
+	b.err = errors.New("connection refused")
+	var ops []string
+	m := memo.New(memo.WithBackendV2(b), memo.WithBackendErrorPolicy(memo.BackendErrorFail), memo.WithMetrics(true),
+		memo.WithBackendErrorHandler(func(op, key string, err error) {
+			ops = append(ops, op+" "+key)
+		}))
+
+	calls := 0
+	_, err := m.Get(context.Background(), "k", func() (any, error) { calls++; return "v", nil })
+	if !errors.Is(err, memo.ErrBackend) || !errors.Is(err, b.err) {
+		t.Fatalf("Expected ErrBackend wrapping the backend error, got: %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("Expected no compute under BackendErrorFail, got: %d", calls)
+	}
+	if len(ops) == 0 || ops[0] != "get k" {
+		t.Fatalf("Expected the handler to see the failed get, got: %v", ops)
+	}
+	if n := m.Metrics().Snapshot().FailedReads; n != 1 {
+		t.Fatalf("Expected 1 failed read, got: %d", n)
+	}
+
+	b.err = nil
+	if v, err := m.Get(context.Background(), "k", func() (any, error) { return "v", nil }); err != nil || v != "v" {
+		t.Fatalf("Expected compute once the backend recovers, got: %v, %v", v, err)
+	}
+}
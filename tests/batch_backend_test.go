@@ -0,0 +1,176 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+)
+
+// countingBatchBackend counts batched calls made to a memory backend
+type countingBatchBackend struct {
+	*memory.Memory
+	getMultis, setMultis, gets, sets int
+}
+
+func (b *countingBatchBackend) Get(key string) (any, bool) {
+	b.gets++
+	return b.Memory.Get(key)
+}
+
+func (b *countingBatchBackend) Set(key string, value any, ttl time.Duration) {
+	b.sets++
+	b.Memory.Set(key, value, ttl)
+}
+
+func (b *countingBatchBackend) GetMulti(keys []string) map[string]any {
+	b.getMultis++
+	return b.Memory.GetMulti(keys)
+}
+
+func (b *countingBatchBackend) SetMulti(items []backends.BatchItem) {
+	b.setMultis++
+	b.Memory.SetMulti(items)
+}
+
+// TestMemoryBatchOperations tests GetMulti, SetMulti and DeleteMulti on the memory backend
+func TestMemoryBatchOperations(t *testing.T) {
+	b := memory.New()
+	defer b.Close()
+
+	b.SetMulti([]backends.BatchItem{
+		{Key: "a", Value: 1, TTL: time.Minute},
+		{Key: "b", Value: 2},
+		{Key: "c", Value: 3, TTL: time.Nanosecond},
+	})
+	time.Sleep(time.Millisecond)
+
+	got := b.GetMulti([]string{"a", "b", "c", "d"})
+	if want := map[string]any{"a": 1, "b": 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got: %v", want, got)
+	}
+
+	b.DeleteMulti([]string{"a", "d"})
+	if got := b.GetMulti([]string{"a", "b"}); !reflect.DeepEqual(got, map[string]any{"b": 2}) {
+		t.Fatalf("Expected only b to remain, got: %v", got)
+	}
+}
+
+// TestRedisBatchOperations tests GetMulti, SetMulti and DeleteMulti on the redis backend
+func TestRedisBatchOperations(t *testing.T) {
+	srv, _ := newRedis(t)
+	b := redis.New(srv.Addr(), "test:", 0).(backends.BatchBackend)
+
+	b.SetMulti([]backends.BatchItem{
+		{Key: "a", Value: 1, TTL: time.Minute},
+		{Key: "b", Value: "two"},
+	})
+	got := b.GetMulti([]string{"a", "b", "missing"})
+	if want := map[string]any{"a": 1, "b": "two"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got: %v", want, got)
+	}
+
+	b.DeleteMulti([]string{"a", "b"})
+	if got := b.GetMulti([]string{"a", "b"}); len(got) != 0 {
+		t.Fatalf("Expected no values after DeleteMulti, got: %v", got)
+	}
+}
+
+// TestGetForInputsUsesBatchBackend tests that batch lookups and writes go through one call each
+func TestGetForInputsUsesBatchBackend(t *testing.T) {
+	backend := &countingBatchBackend{Memory: memory.New()}
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	loader := func(ctx context.Context, missing []int) (map[int]int, error) {
+		out := make(map[int]int, len(missing))
+		for _, id := range missing {
+			out[id] = id * 10
+		}
+		return out, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		got, err := memo.GetForInputs(ctx, m, []int{1, 2, 3}, loader)
+		if err != nil || !reflect.DeepEqual(got, map[int]int{1: 10, 2: 20, 3: 30}) {
+			t.Fatalf("Expected all inputs to resolve, got: %v, %v", got, err)
+		}
+	}
+
+	if backend.getMultis != 2 || backend.setMultis != 1 {
+		t.Fatalf("Expected 2 GetMulti and 1 SetMulti calls, got: %d and %d", backend.getMultis, backend.setMultis)
+	}
+	if backend.gets != 0 || backend.sets != 0 {
+		t.Fatalf("Expected no single-key calls, got: %d gets, %d sets", backend.gets, backend.sets)
+	}
+}
+
+// TestRedisV2GetManyUsesMGET tests that GetMany on a context-aware redis backend reads all keys in one round trip
+func TestRedisV2GetManyUsesMGET(t *testing.T) {
+	srv, _ := newRedis(t)
+	backend := redis.NewV2(srv.Addr(), "test:", 0)
+	m := memo.New(memo.WithBackendV2(backend), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	keys := make([]string, 50)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("k%d", i)
+	}
+	loader := func(missing []string) (map[string]any, error) {
+		out := make(map[string]any, len(missing))
+		for _, k := range missing {
+			out[k] = k
+		}
+		return out, nil
+	}
+	if _, err := m.GetMany(ctx, keys, loader); err != nil {
+		t.Fatalf("Expected first GetMany to succeed, got: %v", err)
+	}
+
+	before := srv.CommandCount()
+	got, err := m.GetMany(ctx, keys, func([]string) (map[string]any, error) {
+		t.Fatal("Expected every key to be cached")
+		return nil, nil
+	})
+	if err != nil || len(got) != len(keys) {
+		t.Fatalf("Expected %d cached values, got: %d, %v", len(keys), len(got), err)
+	}
+	if n := srv.CommandCount() - before; n != 1 {
+		t.Fatalf("Expected a single MGET, got: %d commands", n)
+	}
+}
+
+// TestRedisV2BatchErrors tests that the context-aware redis batch methods return errors
+func TestRedisV2BatchErrors(t *testing.T) {
+	srv, _ := newRedis(t)
+	b := redis.NewV2(srv.Addr(), "test:", 0, redis.WithMaxValueSize(256)).(backends.BatchBackendV2)
+	ctx := context.Background()
+	if err := b.SetMulti(ctx, []backends.BatchItem{{Key: "big", Value: "old", TTL: time.Minute}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err := b.SetMulti(ctx, []backends.BatchItem{
+		{Key: "small", Value: "x", TTL: time.Minute},
+		{Key: "big", Value: strings.Repeat("x", 512), TTL: time.Minute},
+	})
+	if !errors.Is(err, redis.ErrValueTooLarge) {
+		t.Fatalf("Expected ErrValueTooLarge for the oversized item, got: %v", err)
+	}
+	if got, err := b.GetMulti(ctx, []string{"small", "big"}); err != nil || len(got) != 1 || got["small"] != "x" {
+		t.Fatalf("Expected only the small item to be stored and the old big value gone, got: %v, %v", got, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := b.GetMulti(cancelled, []string{"small"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestGetForInputsBatchesMisses tests that cached inputs are served and the rest are loaded in one call
+func TestGetForInputsBatchesMisses(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	var batches [][]int
+	loader := func(ctx context.Context, missing []int) (map[int]string, error) {
+		batches = append(batches, slices.Clone(missing))
+		out := make(map[int]string, len(missing))
+		for _, id := range missing {
+			out[id] = fmt.Sprintf("user-%d", id)
+		}
+		return out, nil
+	}
+
+	if _, err := memo.GetForInputs(ctx, m, []int{1, 2}, loader); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	got, err := memo.GetForInputs(ctx, m, []int{1, 2, 3, 4, 3}, loader)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	want := map[int]string{1: "user-1", 2: "user-2", 3: "user-3", 4: "user-4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got: %v", want, got)
+	}
+	if len(batches) != 2 || !reflect.DeepEqual(batches[1], []int{3, 4}) {
+		t.Fatalf("Expected one batched load of [3 4] for the misses, got: %v", batches)
+	}
+
+	// Everything is cached now
+	if _, err := memo.GetForInputs(ctx, m, []int{1, 2, 3, 4}, loader); err != nil || len(batches) != 2 {
+		t.Fatalf("Expected no further loads, got: %v, %v", batches, err)
+	}
+}
+
+// TestGetForInputsPartialAndError tests that omitted inputs are not cached and loader errors are returned
+func TestGetForInputsPartialAndError(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	calls := 0
+	partial := func(ctx context.Context, missing []string) (map[string]int, error) {
+		calls++
+		return map[string]int{"a": 1}, nil
+	}
+
+	got, err := memo.GetForInputs(ctx, m, []string{"a", "b"}, partial)
+	if err != nil || !reflect.DeepEqual(got, map[string]int{"a": 1}) {
+		t.Fatalf("Expected only a to resolve, got: %v, %v", got, err)
+	}
+
+	errLoad := errors.New("load failed")
+	failing := func(ctx context.Context, missing []string) (map[string]int, error) {
+		if !reflect.DeepEqual(missing, []string{"b"}) {
+			t.Fatalf("Expected only b to be missing, got: %v", missing)
+		}
+		return nil, errLoad
+	}
+	if _, err := memo.GetForInputs(ctx, m, []string{"a", "b"}, failing); !errors.Is(err, errLoad) {
+		t.Fatalf("Expected loader error, got: %v", err)
+	}
+}
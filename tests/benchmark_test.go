@@ -2,8 +2,12 @@ package memo
 
 import (
 	"context"
+	"fmt"
 	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/bytecache"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
 	"testing"
+	"time"
 )
 
 // BenchmarkMemoizeCold benchmarks the performance of memoization when the cache is cold.
@@ -56,3 +60,146 @@ func BenchmarkMemoizeWarm(b *testing.B) {
 		_, _ = memoized(ctx, i%1000)
 	}
 }
+
+// benchmarkRecordLatency records latencies from many goroutines in parallel.
+func benchmarkRecordLatency(b *testing.B, metrics *memo.Metrics) {
+	b.SetParallelism(8)
+	b.RunParallel(func(pb *testing.PB) {
+		d := time.Microsecond
+		for pb.Next() {
+			metrics.RecordLatency(d)
+			d = (d + time.Microsecond) % time.Millisecond
+		}
+	})
+}
+
+// BenchmarkRecordLatencyCAS benchmarks the default CAS-loop latency recording
+// under heavy concurrent use.
+func BenchmarkRecordLatencyCAS(b *testing.B) {
+	benchmarkRecordLatency(b, memo.NewMetrics(true))
+}
+
+// BenchmarkRecordLatencySharded benchmarks sharded latency recording
+// under heavy concurrent use.
+func BenchmarkRecordLatencySharded(b *testing.B) {
+	benchmarkRecordLatency(b, memo.NewShardedMetrics(true))
+}
+
+// benchmarkRecomputeTrivial repeatedly invalidates and recomputes a handful of
+// keys whose computation is trivial, so every Get is a miss.
+func benchmarkRecomputeTrivial(b *testing.B, m *memo.Memoizer) {
+	ctx := context.Background()
+	keys := []string{"k0", "k1", "k2", "k3"}
+	fn := func() (any, error) { return 1, nil }
+
+	// Establish compute history for every key
+	for _, k := range keys {
+		_, _ = m.Get(ctx, k, fn)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := keys[i%len(keys)]
+		m.Delete(k)
+		_, _ = m.Get(ctx, k, fn)
+	}
+}
+
+// BenchmarkRecomputeTrivial benchmarks misses on a trivial compute with singleflight always on.
+func BenchmarkRecomputeTrivial(b *testing.B) {
+	benchmarkRecomputeTrivial(b, memo.New())
+}
+
+// BenchmarkRecomputeTrivialAdaptive benchmarks the same workload with the adaptive bypass enabled.
+func BenchmarkRecomputeTrivialAdaptive(b *testing.B) {
+	benchmarkRecomputeTrivial(b, memo.New(memo.WithAdaptiveSingleFlight(time.Millisecond)))
+}
+
+// benchmarkMemoryGetParallel measures cache hits on the memory backend from many goroutines.
+func benchmarkMemoryGetParallel(b *testing.B, backend *memory.Memory) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("k%d", i)
+		backend.Set(keys[i], i, time.Hour)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			backend.Get(keys[i&1023])
+			i++
+		}
+	})
+}
+
+// BenchmarkMemoryGetLockFree benchmarks hits on the default lock-free read path.
+func BenchmarkMemoryGetLockFree(b *testing.B) {
+	benchmarkMemoryGetParallel(b, memory.New())
+}
+
+// BenchmarkMemoryGetMutex benchmarks hits on the RWMutex read path for comparison.
+func BenchmarkMemoryGetMutex(b *testing.B) {
+	benchmarkMemoryGetParallel(b, memory.New(memory.WithMutexReads()))
+}
+
+// BenchmarkByteCacheGet benchmarks hits on the byte cache, which decodes the
+// value on every read, to compare with the memory backend.
+func BenchmarkByteCacheGet(b *testing.B) {
+	backend := bytecache.New()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("k%d", i)
+		backend.Set(keys[i], i, time.Hour)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			backend.Get(keys[i&1023])
+			i++
+		}
+	})
+}
+
+// BenchmarkSingleFlightDuplicates measures Do with many goroutines piling onto
+// a few hot keys, where most calls wait on another's computation.
+func BenchmarkSingleFlightDuplicates(b *testing.B) {
+	sf := memo.NewSingleFlight()
+	ctx := context.Background()
+	fn := func(ctx context.Context) (any, error) {
+		time.Sleep(10 * time.Microsecond)
+		return 1, nil
+	}
+
+	b.SetParallelism(64)
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_, _, _ = sf.Do(ctx, hotKeys[i%len(hotKeys)], fn)
+			i++
+		}
+	})
+}
+
+// BenchmarkSingleFlightDistinctKeys measures Do for concurrent calls on
+// different keys, which contend only on the lock stripes of the key map.
+func BenchmarkSingleFlightDistinctKeys(b *testing.B) {
+	sf := memo.NewSingleFlight()
+	ctx := context.Background()
+	fn := func(ctx context.Context) (any, error) { return 1, nil }
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_, _, _ = sf.Do(ctx, keys[i%len(keys)], fn)
+			i++
+		}
+	})
+}
+
+// hotKeys are the keys BenchmarkSingleFlightDuplicates contends on.
+var hotKeys = []string{"hot-0", "hot-1", "hot-2", "hot-3"}
@@ -0,0 +1,143 @@
+package memo
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/bolt"
+)
+
+// newBolt opens a bolt backend in a temporary directory and closes it after the test.
+func newBolt(t *testing.T, path string, opts ...bolt.Option) *bolt.Bolt {
+	t.Helper()
+	b, err := bolt.New(path, opts...)
+	if err != nil {
+		t.Fatalf("Expected bolt backend to open, got: %v", err)
+	}
+	t.Cleanup(func() { _ = b.Close() })
+	return b
+}
+
+// TestBoltSurvivesRestart tests that entries and their expiry are read back after reopening the file
+func TestBoltSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	b := newBolt(t, path)
+	b.Set("kept", "value", time.Hour)
+	b.Set("forever", 42, 0)
+	if err := b.Close(); err != nil {
+		t.Fatalf("Expected Close to succeed, got: %v", err)
+	}
+
+	b = newBolt(t, path)
+	if v, ok := b.Get("kept"); !ok || v != "value" {
+		t.Fatalf("Expected value after restart, got: %v, %v", v, ok)
+	}
+	entry, ok := b.GetEntry("kept")
+	if left := time.Until(entry.ExpiresAt()); !ok || left < 59*time.Minute || left > time.Hour {
+		t.Fatalf("Expected the expiry to survive the restart, got: %v left", left)
+	}
+	if v, ok := b.Get("forever"); !ok || v != 42 {
+		t.Fatalf("Expected entry without TTL after restart, got: %v, %v", v, ok)
+	}
+}
+
+// TestBoltExpiryAndCleanup tests that expired entries are misses and are removed by Cleanup
+func TestBoltExpiryAndCleanup(t *testing.T) {
+	b := newBolt(t, filepath.Join(t.TempDir(), "cache.db"), bolt.WithCleanupInterval(0))
+	b.Set("short", 1, 20*time.Millisecond)
+	b.Set("long", 2, time.Hour)
+	b.Set("none", 3, 0)
+	b.Set("touched", 4, 20*time.Millisecond)
+	if !b.Touch("touched", time.Hour) {
+		t.Fatal("Expected Touch to extend a live entry")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := b.Get("short"); ok {
+		t.Fatal("Expected expired entry to be a miss")
+	}
+	if n := b.Cleanup(); n != 1 {
+		t.Fatalf("Expected Cleanup to remove 1 entry, got: %d", n)
+	}
+	for _, key := range []string{"long", "none", "touched"} {
+		if _, ok := b.Get(key); !ok {
+			t.Fatalf("Expected %s to survive Cleanup", key)
+		}
+	}
+	if n := b.Cleanup(); n != 0 {
+		t.Fatalf("Expected nothing left to clean up, got: %d", n)
+	}
+}
+
+// TestBoltPrefixBatchAndCompact tests prefix deletes, batches and compaction
+func TestBoltPrefixBatchAndCompact(t *testing.T) {
+	b := newBolt(t, filepath.Join(t.TempDir(), "cache.db"))
+	b.SetMulti([]backends.BatchItem{
+		{Key: "user:1", Value: "a", TTL: time.Hour},
+		{Key: "user:2", Value: "b"},
+		{Key: "order:1", Value: "c", TTL: time.Hour},
+	})
+	if got := b.GetMulti([]string{"user:1", "user:2", "order:1", "missing"}); len(got) != 3 {
+		t.Fatalf("Expected 3 values from GetMulti, got: %v", got)
+	}
+	if n := b.DeleteByPrefix("user:"); n != 2 {
+		t.Fatalf("Expected 2 keys removed, got: %d", n)
+	}
+
+	if err := b.Compact(); err != nil {
+		t.Fatalf("Expected Compact to succeed, got: %v", err)
+	}
+	if v, ok := b.Get("order:1"); !ok || v != "c" {
+		t.Fatalf("Expected entries to survive compaction, got: %v, %v", v, ok)
+	}
+	b.Set("after", "compact", time.Hour)
+	if _, ok := b.Get("after"); !ok {
+		t.Fatal("Expected the backend to stay usable after Compact")
+	}
+
+	b.Clear()
+	if _, ok := b.Get("order:1"); ok {
+		t.Fatal("Expected Clear to remove every entry")
+	}
+}
+
+// TestBoltWithMemoizer tests the bolt backend behind a memoizer
+func TestBoltWithMemoizer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	type result struct{ N int }
+
+	calls := 0
+	fn := func() (any, error) { calls++; return result{N: 7}, nil }
+	for i := 0; i < 2; i++ {
+		b, err := bolt.New(path)
+		if err != nil {
+			t.Fatalf("Expected bolt backend to open, got: %v", err)
+		}
+		m := memo.New(memo.WithBackend(b), memo.WithTTL(time.Hour))
+		if v, err := m.Get(context.Background(), "k", fn); err != nil || v.(result).N != 7 {
+			t.Fatalf("Expected result, got: %v, %v", v, err)
+		}
+		if err := m.Close(); err != nil {
+			t.Fatalf("Expected Close to succeed, got: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("Expected the second process to read the cached value, got %d calls", calls)
+	}
+}
+
+// TestBoltStats tests that the bolt backend reports its entry count and page usage
+func TestBoltStats(t *testing.T) {
+	b := newBolt(t, filepath.Join(t.TempDir(), "cache.db"))
+	b.Set("a", "x", time.Hour)
+	b.Set("b", "y", time.Hour)
+
+	st := b.Stats()
+	if st.Entries != 2 || st.Bytes <= 0 {
+		t.Fatalf("Expected 2 entries and some bytes, got: %+v", st)
+	}
+}
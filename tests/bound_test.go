@@ -0,0 +1,178 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+type ctxKey string
+
+// ctxToucherV2 is a v2Backend that also touches entries, recording the
+// context of the last Touch
+type ctxToucherV2 struct {
+	*v2Backend
+	touchCtx context.Context
+}
+
+func (b *ctxToucherV2) Touch(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.touchCtx = ctx
+	_, ok := b.data[key]
+	return ok, nil
+}
+
+// requestOf returns the request ID carried by ctx
+func requestOf(ctx context.Context) any {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Value(ctxKey("request"))
+}
+
+// TestBoundMemoizerGet tests that a bound memoizer caches like the underlying one
+func TestBoundMemoizerGet(t *testing.T) {
+	backend := newV2Backend()
+	m := memo.New(memo.WithBackendV2(backend), memo.WithTTL(5*time.Second))
+	ctx := context.WithValue(context.Background(), ctxKey("request"), "req-1")
+	bm := m.WithContext(ctx)
+
+	if bm.Memoizer() != m {
+		t.Fatal("Expected bound memoizer to wrap the original memoizer")
+	}
+
+	var calls int32
+	fn := func() (any, error) {
+		atomic.AddInt32(&calls, 1)
+		return "bound", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		v, err := bm.Get("bound-key", fn)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if v != "bound" {
+			t.Fatalf("Expected 'bound', got: %v", v)
+		}
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("Expected fn to be called once, was called %d times", calls)
+	}
+	backend.mu.Lock()
+	got := requestOf(backend.lastCtx)
+	backend.mu.Unlock()
+	if got != "req-1" {
+		t.Fatalf("Expected the bound context to reach the backend, got request: %v", got)
+	}
+
+	// Values are shared with the unbound API
+	v, err := m.Get(context.Background(), "bound-key", fn)
+	if err != nil || v != "bound" {
+		t.Fatalf("Expected shared cached value, got: %v (err=%v)", v, err)
+	}
+
+	bm.Delete("bound-key")
+	if _, err := bm.Get("bound-key", fn); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("Expected fn to be called again after Delete, was called %d times", calls)
+	}
+}
+
+// TestBoundMemoizerCancellation tests that cancelling the bound context
+// releases callers waiting on an in-flight computation
+func TestBoundMemoizerCancellation(t *testing.T) {
+	m := memo.New(memo.WithTTL(5 * time.Second))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_, _ = m.Get(context.Background(), "slow-key", func() (any, error) {
+			close(started)
+			<-release
+			return "slow", nil
+		})
+	}()
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bm := m.WithContext(ctx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := bm.Get("slow-key", func() (any, error) {
+			return "unexpected", nil
+		})
+		errCh <- err
+	}()
+
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected bound call to return after cancellation")
+	}
+}
+
+// TestBoundMemoizerMethods tests that every bound method passes the bound context to the backend
+func TestBoundMemoizerMethods(t *testing.T) {
+	backend := &ctxToucherV2{v2Backend: newV2Backend()}
+	m := memo.New(memo.WithBackendV2(backend), memo.WithTTL(5*time.Second))
+	bm := m.WithContext(context.WithValue(context.Background(), ctxKey("request"), "req-2"))
+
+	lastRequest := func() any {
+		backend.mu.Lock()
+		defer backend.mu.Unlock()
+		ctx := backend.lastCtx
+		backend.lastCtx = nil
+		return requestOf(ctx)
+	}
+
+	bm.Set("set-key", "set")
+	if got := lastRequest(); got != "req-2" {
+		t.Fatalf("Expected Set to use the bound context, got request: %v", got)
+	}
+
+	v, err := bm.GetWithOptions("opt-key", func() (any, error) { return "opt", nil }, memo.CallTTL(time.Minute))
+	if err != nil || v != "opt" {
+		t.Fatalf("Expected 'opt', got: %v (err=%v)", v, err)
+	}
+	if got := lastRequest(); got != "req-2" {
+		t.Fatalf("Expected GetWithOptions to use the bound context, got request: %v", got)
+	}
+
+	if v, ok := bm.Peek("set-key"); !ok || v != "set" {
+		t.Fatalf("Expected Peek to find 'set', got: %v, %v", v, ok)
+	}
+	if got := lastRequest(); got != "req-2" {
+		t.Fatalf("Expected Peek to use the bound context, got request: %v", got)
+	}
+	if !bm.Has("opt-key") || bm.Has("missing") {
+		t.Fatal("Expected Has to report only cached keys")
+	}
+	if got := lastRequest(); got != "req-2" {
+		t.Fatalf("Expected Has to use the bound context, got request: %v", got)
+	}
+
+	if !bm.Touch("set-key", time.Minute) {
+		t.Fatal("Expected Touch to find the cached key")
+	}
+	backend.mu.Lock()
+	got := requestOf(backend.touchCtx)
+	backend.mu.Unlock()
+	if got != "req-2" {
+		t.Fatalf("Expected Touch to use the bound context, got request: %v", got)
+	}
+}
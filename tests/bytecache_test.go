@@ -0,0 +1,163 @@
+package memo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/bytecache"
+)
+
+// TestByteCacheBasic tests reads, writes, overwrites and expiry of the byte cache backend
+func TestByteCacheBasic(t *testing.T) {
+	c := bytecache.New(bytecache.WithCapacity(1<<20), bytecache.WithShards(4))
+
+	c.Set("k", "v1", time.Hour)
+	c.Set("k", "v2", time.Hour)
+	if v, ok := c.Get("k"); !ok || v != "v2" {
+		t.Fatalf("Expected v2, got: %v, %v", v, ok)
+	}
+	if entry, ok := c.GetEntry("k"); !ok || time.Until(entry.ExpiresAt()) < 59*time.Minute {
+		t.Fatalf("Expected the entry to expire in an hour, got: %v, %v", entry.ExpiresAt(), ok)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("Expected 1 entry after an overwrite, got: %d", c.Len())
+	}
+
+	c.Set("short", "v", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.Get("short"); ok {
+		t.Fatal("Expected an expired entry to be a miss")
+	}
+
+	c.Delete("k")
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("Expected Delete to remove the entry")
+	}
+	c.Set("a", 1, 0)
+	c.Clear()
+	if _, ok := c.Get("a"); ok || c.Len() != 0 || c.Bytes() != 0 {
+		t.Fatal("Expected Clear to remove every entry")
+	}
+}
+
+// TestByteCacheTouchExpire tests that Touch and Expire rewrite the expiry in place
+func TestByteCacheTouchExpire(t *testing.T) {
+	c := bytecache.New(bytecache.WithCapacity(1 << 20))
+
+	c.Set("k", "v", time.Minute)
+	if !c.Touch("k", time.Hour) {
+		t.Fatal("Expected Touch of a live entry to succeed")
+	}
+	if entry, _ := c.GetEntry("k"); time.Until(entry.ExpiresAt()) < 59*time.Minute {
+		t.Fatalf("Expected Touch to extend the expiry, got: %v", entry.ExpiresAt())
+	}
+	if !c.Touch("k", 0) {
+		t.Fatal("Expected Touch without TTL to succeed")
+	}
+	if entry, _ := c.GetEntry("k"); !entry.ExpiresAt().IsZero() {
+		t.Fatalf("Expected the entry to no longer expire, got: %v", entry.ExpiresAt())
+	}
+	if !c.Expire("k") {
+		t.Fatal("Expected Expire of a live entry to succeed")
+	}
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("Expected an expired entry to be a miss")
+	}
+	if c.Touch("k", time.Hour) || c.Expire("missing") {
+		t.Fatal("Expected Touch and Expire of dead keys to fail")
+	}
+}
+
+// TestByteCacheEviction tests that full slabs overwrite their oldest entries and wrap around correctly
+func TestByteCacheEviction(t *testing.T) {
+	c := bytecache.New(bytecache.WithCapacity(64<<10), bytecache.WithShards(1))
+
+	value := strings.Repeat("x", 1000)
+	for i := 0; i < 500; i++ {
+		c.Set(fmt.Sprintf("k%03d", i), fmt.Sprintf("%03d%s", i, value), 0)
+	}
+	if c.Evictions() == 0 {
+		t.Fatal("Expected writes beyond the capacity to evict entries")
+	}
+	if _, ok := c.Get("k000"); ok {
+		t.Fatal("Expected the oldest entry to be overwritten")
+	}
+	// The newest entries survive, including those written across the end of the ring
+	for i := 490; i < 500; i++ {
+		want := fmt.Sprintf("%03d%s", i, value)
+		if v, ok := c.Get(fmt.Sprintf("k%03d", i)); !ok || v != want {
+			t.Fatalf("Expected k%03d to survive intact, got: %v", i, ok)
+		}
+	}
+	if c.Bytes() > 64<<10 {
+		t.Fatalf("Expected usage within the capacity, got: %d", c.Bytes())
+	}
+
+	c.Set("k499", strings.Repeat("y", 128<<10), 0)
+	if _, ok := c.Get("k499"); ok {
+		t.Fatal("Expected a value larger than the slab to drop the key")
+	}
+}
+
+// TestByteCacheDeleteByPrefix tests prefix deletes across shards
+func TestByteCacheDeleteByPrefix(t *testing.T) {
+	c := bytecache.New(bytecache.WithCapacity(1<<20), bytecache.WithShards(8))
+	for i := 0; i < 20; i++ {
+		c.Set(fmt.Sprintf("user:%d", i), i, 0)
+	}
+	c.Set("order:1", 1, 0)
+
+	if n := c.DeleteByPrefix("user:"); n != 20 {
+		t.Fatalf("Expected 20 entries removed, got: %d", n)
+	}
+	if _, ok := c.Get("order:1"); !ok || c.Len() != 1 {
+		t.Fatal("Expected entries outside the prefix to remain")
+	}
+}
+
+// TestByteCacheConcurrent tests concurrent readers and writers
+func TestByteCacheConcurrent(t *testing.T) {
+	c := bytecache.New(bytecache.WithCapacity(256<<10), bytecache.WithShards(4))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("k%d", i%50)
+				c.Set(key, key, time.Minute)
+				if v, ok := c.Get(key); ok && v != key {
+					t.Errorf("Expected %s, got: %v", key, v)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestByteCacheWithMemoizer tests the byte cache behind a memoizer
+func TestByteCacheWithMemoizer(t *testing.T) {
+	m := memo.New(memo.WithBackend(bytecache.New(bytecache.WithCapacity(1<<20))), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	calls := 0
+	compute := func() (any, error) {
+		calls++
+		return []string{"a", "b"}, nil
+	}
+	for i := 0; i < 3; i++ {
+		v, err := m.Get(ctx, "k", compute)
+		if err != nil || len(v.([]string)) != 2 {
+			t.Fatalf("Expected the computed slice, got: %v, %v", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("Expected one computation, got: %d", calls)
+	}
+}
@@ -130,3 +130,28 @@ func TestCacheEntrySetExpiry(t *testing.T) {
 		t.Fatal("Entry should be expired after TTL duration")
 	}
 }
+
+// TestCacheEntryExpiresAt tests absolute expiry construction and access
+func TestCacheEntryExpiresAt(t *testing.T) {
+	at := time.Now().Add(time.Minute)
+	entry := backends.NewEntryAt("v", at, 7)
+	if !entry.ExpiresAt().Equal(time.Unix(0, at.UnixNano())) {
+		t.Fatalf("Expected expiry %v, got: %v", at, entry.ExpiresAt())
+	}
+	if entry.Version() != 7 {
+		t.Fatalf("Expected version 7, got: %d", entry.Version())
+	}
+	if entry.IsExpired() {
+		t.Fatal("Entry should not be expired")
+	}
+
+	past := backends.NewEntryAt("v", time.Now().Add(-time.Second), 0)
+	if !past.IsExpired() {
+		t.Fatal("Entry with past expiry should be expired")
+	}
+
+	never := backends.NewEntryAt("v", time.Time{}, 0)
+	if !never.ExpiresAt().IsZero() || never.IsExpired() {
+		t.Fatal("Entry with zero expiry should never expire")
+	}
+}
@@ -0,0 +1,109 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestGetWithOptionsTTL tests that a per-call TTL overrides the memoizer's TTL
+func TestGetWithOptionsTTL(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	calls := 0
+	fn := func() (any, error) {
+		calls++
+		return calls, nil
+	}
+
+	_, _ = m.GetWithOptions(ctx, "k", fn, memo.CallTTL(20*time.Millisecond))
+	_, _ = m.GetWithOptions(ctx, "k", fn)
+	if calls != 1 {
+		t.Fatalf("Expected value to be cached, got: %d calls", calls)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	_, _ = m.Get(ctx, "k", fn)
+	if calls != 2 {
+		t.Fatalf("Expected value to expire after the per-call TTL, got: %d calls", calls)
+	}
+}
+
+// TestGetWithOptionsForceRefresh tests that ForceRefresh recomputes and stores the new value
+func TestGetWithOptionsForceRefresh(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	calls := 0
+	fn := func() (any, error) {
+		calls++
+		return calls, nil
+	}
+
+	_, _ = m.Get(ctx, "k", fn)
+	v, _ := m.GetWithOptions(ctx, "k", fn, memo.ForceRefresh())
+	if v != 2 {
+		t.Fatalf("Expected refreshed value 2, got: %v", v)
+	}
+	if v, _ := m.Get(ctx, "k", fn); v != 2 || calls != 2 {
+		t.Fatalf("Expected refreshed value to be cached, got: %v after %d calls", v, calls)
+	}
+}
+
+// TestGetWithOptionsBypassCache tests that BypassCache neither reads nor writes the cache
+func TestGetWithOptionsBypassCache(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	_, _ = m.Get(ctx, "k", func() (any, error) { return "cached", nil })
+
+	v, _ := m.GetWithOptions(ctx, "k", func() (any, error) { return "direct", nil }, memo.BypassCache())
+	if v != "direct" {
+		t.Fatalf("Expected bypass to run fn, got: %v", v)
+	}
+	if v, _ := m.Get(ctx, "k", func() (any, error) { return "unused", nil }); v != "cached" {
+		t.Fatalf("Expected bypass not to overwrite the cache, got: %v", v)
+	}
+}
+
+// TestGetWithOptionsBypassCachePipeline tests that BypassCache still recovers panics and records compute metrics
+func TestGetWithOptionsBypassCachePipeline(t *testing.T) {
+	m := memo.New(memo.WithMetrics(true))
+	ctx := context.Background()
+
+	_, err := m.GetWithOptions(ctx, "k", func() (any, error) { panic("boom") }, memo.BypassCache())
+	var pe *memo.PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Expected a *PanicError, got: %v", err)
+	}
+	if n := m.Metrics().ComputeLatency().Count; n != 1 {
+		t.Fatalf("Expected the bypassed compute to be timed, got: %d samples", n)
+	}
+}
+
+// TestGetWithOptionsBypassCacheLeavesNoState tests that a failed bypassed call is neither cached nor answered from the cache
+func TestGetWithOptionsBypassCacheLeavesNoState(t *testing.T) {
+	m := memo.New(memo.WithTTL(10*time.Millisecond), memo.WithErrorTTL(time.Minute), memo.WithStaleIfError(time.Minute))
+	ctx := context.Background()
+
+	_, _ = m.Get(ctx, "k", func() (any, error) { return "old", nil })
+	time.Sleep(20 * time.Millisecond)
+
+	errBoom := errors.New("boom")
+	if v, err := m.GetWithOptions(ctx, "k", func() (any, error) { return nil, errBoom }, memo.BypassCache()); !errors.Is(err, errBoom) {
+		t.Fatalf("Expected the bypassed error rather than a stale value, got: %v, %v", v, err)
+	}
+
+	calls := 0
+	v, err := m.Get(ctx, "k", func() (any, error) {
+		calls++
+		return "fresh", nil
+	})
+	if err != nil || v != "fresh" || calls != 1 {
+		t.Fatalf("Expected Get to compute after a failed bypass, got: %v, %v, %d calls", v, err, calls)
+	}
+}
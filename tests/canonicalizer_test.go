@@ -0,0 +1,81 @@
+package memo
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// sortedTags canonicalizes a single []string argument by sorting a copy of it
+func sortedTags(args ...any) []any {
+	out := make([]any, len(args))
+	for i, a := range args {
+		if tags, ok := a.([]string); ok {
+			tags = slices.Clone(tags)
+			slices.Sort(tags)
+			a = tags
+		}
+		out[i] = a
+	}
+	return out
+}
+
+// TestCanonicalizerCollapsesEqualInputs tests that differently ordered but
+// value-equal inputs share one cache entry when a canonicalizer is configured
+func TestCanonicalizerCollapsesEqualInputs(t *testing.T) {
+	m := memo.New(memo.WithTTL(5*time.Second), memo.WithCanonicalizer(sortedTags))
+
+	var calls int32
+	memoized := m.MemoizeFunc(func(ctx context.Context, args ...any) (any, error) {
+		atomic.AddInt32(&calls, 1)
+		return len(args[0].([]string)), nil
+	})
+
+	ctx := context.Background()
+	r1, err := memoized(ctx, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	r2, err := memoized(ctx, []string{"c", "a", "b"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if r1 != r2 {
+		t.Fatalf("Expected same result, got %v and %v", r1, r2)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("Expected one computation, got %d", calls)
+	}
+
+	// A genuinely different input still computes
+	if _, err := memoized(ctx, []string{"a", "b"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("Expected two computations, got %d", calls)
+	}
+}
+
+// TestWithoutCanonicalizer tests that ordering matters when no canonicalizer is set
+func TestWithoutCanonicalizer(t *testing.T) {
+	m := memo.New(memo.WithTTL(5 * time.Second))
+
+	var calls int32
+	memoized := m.MemoizeFunc(func(ctx context.Context, args ...any) (any, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, nil
+	})
+
+	ctx := context.Background()
+	_, _ = memoized(ctx, []string{"a", "b"})
+	_, _ = memoized(ctx, []string{"b", "a"})
+
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("Expected two computations without canonicalizer, got %d", calls)
+	}
+}
@@ -0,0 +1,89 @@
+package memo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// tickClock is a memory.Clock whose sweep timer fires when the test sends on ticks.
+type tickClock struct {
+	fakeClock
+	ticks chan time.Time
+}
+
+func (c *tickClock) After(time.Duration) <-chan time.Time {
+	return c.ticks
+}
+
+// TestMemoryCleanupInterval tests that expired entries are swept on the configured interval
+func TestMemoryCleanupInterval(t *testing.T) {
+	b := memory.New(memory.WithCleanupInterval(5 * time.Millisecond))
+	defer b.Close()
+
+	b.Set("k", "v", time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for b.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected expired entry to be swept, got %d entries", b.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestMemoryCloseStopsSweeps tests that Close stops the cleanup goroutine and can be called twice
+func TestMemoryCloseStopsSweeps(t *testing.T) {
+	clock := &tickClock{fakeClock: fakeClock{now: time.Unix(0, 0)}, ticks: make(chan time.Time)}
+	b := memory.New(memory.WithClock(clock))
+
+	b.Set("k", "v", time.Second)
+	clock.Advance(2 * time.Second)
+
+	// The sweep goroutine receives the tick and removes the expired entry
+	clock.ticks <- clock.Now()
+	deadline := time.Now().Add(time.Second)
+	for b.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected tick to trigger a sweep, got %d entries", b.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Expected second Close to be a no-op, got: %v", err)
+	}
+
+	select {
+	case clock.ticks <- clock.Now():
+		t.Fatalf("Expected no sweep goroutine to receive ticks after Close")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if _, ok := b.Get("missing"); ok {
+		t.Fatalf("Expected backend to stay usable after Close")
+	}
+}
+
+// TestMemoryCleanupDisabled tests that a non-positive interval leaves sweeping to the caller
+func TestMemoryCleanupDisabled(t *testing.T) {
+	clock := &tickClock{fakeClock: fakeClock{now: time.Unix(0, 0)}, ticks: make(chan time.Time)}
+	b := memory.New(memory.WithClock(clock), memory.WithCleanupInterval(0))
+	defer b.Close()
+
+	select {
+	case clock.ticks <- clock.Now():
+		t.Fatalf("Expected no sweep goroutine to be started")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	b.Set("k", "v", time.Second)
+	clock.Advance(2 * time.Second)
+	if evicted := b.Sweep(); evicted != 1 {
+		t.Fatalf("Expected manual sweep to evict 1 entry, got: %d", evicted)
+	}
+}
@@ -0,0 +1,422 @@
+package memo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/internals/wire"
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+	goredis "github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// countingCodec wraps gob under its own codec ID and counts encodes.
+type countingCodec struct {
+	backends.Codec
+	encodes int
+}
+
+func (c *countingCodec) ID() wire.CodecID { return 200 }
+
+func (c *countingCodec) Encode(v any) ([]byte, error) {
+	c.encodes++
+	return c.Codec.Encode(v)
+}
+
+// TestRedisWithCodec tests that the configured codec is used and named in the wire header
+func TestRedisWithCodec(t *testing.T) {
+	srv, client := newRedis(t)
+	codec := &countingCodec{Codec: backends.GobCodec()}
+	backend := redis.New(srv.Addr(), "test:", 0, redis.WithCodec(codec))
+
+	backend.Set("key", "value", time.Minute)
+	if codec.encodes != 1 {
+		t.Fatalf("Expected the codec to encode once, got: %d", codec.encodes)
+	}
+	raw, err := client.Get(context.Background(), "test:key").Bytes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if hdr, _, err := wire.Decode(raw); err != nil || hdr.Codec != 200 {
+		t.Fatalf("Expected codec 200 in the header, got: %+v, %v", hdr, err)
+	}
+	if v, ok := backend.Get("key"); !ok || v != "value" {
+		t.Fatalf("Expected 'value', got: %v, %v", v, ok)
+	}
+}
+
+// TestRedisCodecReadsGobEntries tests that entries written with gob stay readable after switching codecs
+func TestRedisCodecReadsGobEntries(t *testing.T) {
+	srv, _ := newRedis(t)
+	redis.New(srv.Addr(), "test:", 0).Set("old", "value", time.Minute)
+
+	backend := redis.New(srv.Addr(), "test:", 0, redis.WithCodec(&countingCodec{Codec: backends.GobCodec()}))
+	if v, ok := backend.Get("old"); !ok || v != "value" {
+		t.Fatalf("Expected the gob entry to decode, got: %v, %v", v, ok)
+	}
+}
+
+// TestRecordRoundTrip tests that Record preserves entry metadata
+func TestRecordRoundTrip(t *testing.T) {
+	entry := backends.NewEntry("value", time.Minute, 3)
+	got := backends.NewRecord(entry).Entry()
+	if got.Value != "value" || got.Version() != 3 || !got.ExpiresAt().Equal(entry.ExpiresAt()) || !got.StoredAt().Equal(entry.StoredAt()) {
+		t.Fatalf("Expected metadata to survive, got: %+v", got)
+	}
+}
+
+// jsonUser is a value shared with non-Go services through the JSON codec.
+type jsonUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// TestJSONCodecInterop tests that JSON entries are plain JSON after the header and readable when written by others
+func TestJSONCodecInterop(t *testing.T) {
+	srv, client := newRedis(t)
+	backend := redis.New(srv.Addr(), "test:", 0, redis.WithCodec(backends.JSONCodec()))
+	ctx := context.Background()
+
+	backend.Set("user", jsonUser{ID: 1, Name: "ada"}, time.Minute)
+	raw, err := client.Get(ctx, "test:user").Bytes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hdr, payload, err := wire.Decode(raw)
+	if err != nil || hdr.Codec != wire.CodecJSON {
+		t.Fatalf("Expected a JSON header, got: %+v, %v", hdr, err)
+	}
+	var doc struct {
+		Value jsonUser `json:"value"`
+	}
+	if err := json.Unmarshal(payload, &doc); err != nil || doc.Value.Name != "ada" {
+		t.Fatalf("Expected plain JSON, got: %s, %v", payload, err)
+	}
+
+	// Without a type, values decode into the generic JSON types
+	v, ok := backend.Get("user")
+	want := map[string]any{"id": float64(1), "name": "ada"}
+	if !ok || !reflect.DeepEqual(v, want) {
+		t.Fatalf("Expected %v, got: %v, %v", want, v, ok)
+	}
+
+	// An entry written by another service
+	foreign := wire.Encode(wire.Header{Codec: wire.CodecJSON}, []byte(`{"value":{"id":2,"name":"bob"}}`))
+	if err := client.Set(ctx, "test:foreign", foreign, 0).Err(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v, ok := backend.Get("foreign"); !ok || v.(map[string]any)["name"] != "bob" {
+		t.Fatalf("Expected the foreign entry to decode, got: %v, %v", v, ok)
+	}
+}
+
+// TestJSONCodecWithType tests that WithJSONType decodes values into the given type
+func TestJSONCodecWithType(t *testing.T) {
+	srv, _ := newRedis(t)
+	backend := redis.New(srv.Addr(), "test:", 0, redis.WithCodec(backends.JSONCodec(backends.WithJSONType[jsonUser]())))
+
+	backend.Set("user", jsonUser{ID: 1, Name: "ada"}, time.Minute)
+	v, ok := backend.Get("user")
+	if !ok || v != (jsonUser{ID: 1, Name: "ada"}) {
+		t.Fatalf("Expected a jsonUser, got: %#v, %v", v, ok)
+	}
+}
+
+// TestMsgpackCodec tests that MessagePack entries round trip, with and without a value type
+func TestMsgpackCodec(t *testing.T) {
+	srv, client := newRedis(t)
+	generic := redis.New(srv.Addr(), "test:", 0, redis.WithCodec(backends.MsgpackCodec()))
+	typed := redis.New(srv.Addr(), "test:", 0, redis.WithCodec(backends.MsgpackCodec(backends.WithMsgpackType[jsonUser]())))
+
+	typed.Set("user", jsonUser{ID: 1, Name: "ada"}, time.Minute)
+	raw, err := client.Get(context.Background(), "test:user").Bytes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if hdr, _, err := wire.Decode(raw); err != nil || hdr.Codec != wire.CodecMsgpack {
+		t.Fatalf("Expected a msgpack header, got: %+v, %v", hdr, err)
+	}
+
+	if v, ok := typed.Get("user"); !ok || v != (jsonUser{ID: 1, Name: "ada"}) {
+		t.Fatalf("Expected a jsonUser, got: %#v, %v", v, ok)
+	}
+	v, ok := generic.Get("user")
+	want := map[string]any{"ID": int64(1), "Name": "ada"}
+	if !ok || !reflect.DeepEqual(v, want) {
+		t.Fatalf("Expected %v, got: %#v, %v", want, v, ok)
+	}
+}
+
+// TestProtoCodec tests that proto messages are restored to their concrete type through the type registry
+func TestProtoCodec(t *testing.T) {
+	srv, _ := newRedis(t)
+	backend := redis.New(srv.Addr(), "test:", 0, redis.WithCodec(backends.ProtoCodec()))
+
+	ts := timestamppb.New(time.Unix(1700000000, 0))
+	backend.Set("ts", ts, time.Minute)
+	backend.Set("name", wrapperspb.String("ada"), time.Minute)
+
+	v, ok := backend.Get("ts")
+	if got, isTS := v.(*timestamppb.Timestamp); !ok || !isTS || !proto.Equal(got, ts) {
+		t.Fatalf("Expected %v, got: %#v, %v", ts, v, ok)
+	}
+	v, ok = backend.Get("name")
+	if got, isStr := v.(*wrapperspb.StringValue); !ok || !isStr || got.GetValue() != "ada" {
+		t.Fatalf("Expected a StringValue, got: %#v, %v", v, ok)
+	}
+
+	backend.Set("plain", "not a message", time.Minute)
+	if _, ok := backend.Get("plain"); ok {
+		t.Fatalf("Expected non-proto values not to be cached")
+	}
+
+	entry, err := backends.ProtoCodec().Encode(backends.NewRecord(backends.NewEntry(ts, time.Minute, 7)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var rec backends.Record
+	if err := backends.ProtoCodec().Decode(entry, &rec); err != nil || rec.Version != 7 || rec.Expiry == 0 {
+		t.Fatalf("Expected metadata to survive, got: %+v, %v", rec, err)
+	}
+	if err := backends.ProtoCodec(backends.WithProtoTypes(new(protoregistry.Types))).Decode(entry, &rec); err == nil {
+		t.Fatalf("Expected an unknown type URL to fail")
+	}
+}
+
+// TestRedisCompression tests that large entries are compressed above the threshold and read back transparently
+func TestRedisCompression(t *testing.T) {
+	srv, client := newRedis(t)
+	backend := redis.New(srv.Addr(), "test:", 0, redis.WithCompression(backends.GzipCompressor(gzip.DefaultCompression), 256))
+	ctx := context.Background()
+
+	large := strings.Repeat("compressible ", 1000)
+	backend.Set("large", large, time.Minute)
+	backend.Set("small", "tiny", time.Minute)
+
+	raw, err := client.Get(ctx, "test:large").Bytes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hdr, _, _ := wire.Decode(raw)
+	if hdr.Flags&wire.FlagCompressed == 0 || len(raw) >= len(large) {
+		t.Fatalf("Expected a compressed entry, got flags %#x and %d bytes", hdr.Flags, len(raw))
+	}
+	raw, _ = client.Get(ctx, "test:small").Bytes()
+	if hdr, _, _ := wire.Decode(raw); hdr.Flags != 0 {
+		t.Fatalf("Expected the small entry to stay uncompressed, got flags %#x", hdr.Flags)
+	}
+
+	if v, ok := backend.Get("large"); !ok || v != large {
+		t.Fatalf("Expected the large value back, got ok=%v", ok)
+	}
+	if v, ok := redis.New(srv.Addr(), "test:", 0).Get("large"); !ok || v != large {
+		t.Fatalf("Expected a backend without compression to read it, got ok=%v", ok)
+	}
+}
+
+// TestCompressors tests that every built-in compressor round trips and that entries stay readable across compressors
+func TestCompressors(t *testing.T) {
+	srv, _ := newRedis(t)
+	large := strings.Repeat("compressible ", 1000)
+	for _, c := range []backends.Compressor{
+		backends.GzipCompressor(gzip.BestSpeed),
+		backends.SnappyCompressor(),
+		backends.ZstdCompressor(3),
+	} {
+		data, err := c.Compress([]byte(large))
+		if err != nil || len(data) >= len(large) {
+			t.Fatalf("Expected compressor %d to shrink the data, got %d bytes, %v", c.ID(), len(data), err)
+		}
+		out, err := c.Decompress(data)
+		if err != nil || string(out) != large {
+			t.Fatalf("Expected compressor %d to round trip, got: %v", c.ID(), err)
+		}
+
+		key := fmt.Sprintf("k%d", c.ID())
+		redis.New(srv.Addr(), "test:", 0, redis.WithCompression(c, 256)).Set(key, large, time.Minute)
+		if v, ok := redis.New(srv.Addr(), "test:", 0, redis.WithCompression(backends.ZstdCompressor(1), 256)).Get(key); !ok || v != large {
+			t.Fatalf("Expected an entry compressed by %d to be readable with zstd configured, got ok=%v", c.ID(), ok)
+		}
+	}
+}
+
+// TestDecompressLimit tests that payloads claiming a huge decompressed size are rejected
+func TestDecompressLimit(t *testing.T) {
+	// A snappy payload starts with the uvarint length of the decoded data
+	payload := binary.AppendUvarint(nil, backends.MaxDecompressedSize+1)
+	if _, err := backends.SnappyCompressor().Decompress(payload); !errors.Is(err, backends.ErrDecompressedTooLarge) {
+		t.Fatalf("Expected ErrDecompressedTooLarge, got: %v", err)
+	}
+}
+
+// TestRedisEncryption tests that entries are encrypted at rest, bound to their key and readable after key rotation
+func TestRedisEncryption(t *testing.T) {
+	srv, client := newRedis(t)
+	ctx := context.Background()
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+
+	v1, err := backends.NewAESGCM(1, map[byte][]byte{1: oldKey})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	old := redis.New(srv.Addr(), "test:", 0, redis.WithEncryption(v1))
+	old.Set("ssn", "123-45-6789", time.Minute)
+
+	raw, _ := client.Get(ctx, "test:ssn").Bytes()
+	if hdr, _, _ := wire.Decode(raw); hdr.Flags&wire.FlagEncrypted == 0 || bytes.Contains(raw, []byte("123-45-6789")) {
+		t.Fatalf("Expected an encrypted entry, got flags %#x", hdr.Flags)
+	}
+
+	v2, err := backends.NewAESGCM(2, map[byte][]byte{1: oldKey, 2: newKey})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rotated := redis.New(srv.Addr(), "test:", 0, redis.WithEncryption(v2))
+	if v, ok := rotated.Get("ssn"); !ok || v != "123-45-6789" {
+		t.Fatalf("Expected the old entry to decrypt after rotation, got: %v, %v", v, ok)
+	}
+	rotated.Set("ssn", "987-65-4321", time.Minute)
+	if _, ok := old.Get("ssn"); ok {
+		t.Fatalf("Expected an entry under an unknown key version to miss")
+	}
+
+	// A ciphertext copied to another key does not authenticate
+	raw, _ = client.Get(ctx, "test:ssn").Bytes()
+	client.Set(ctx, "test:other", raw, 0)
+	if _, ok := rotated.Get("other"); ok {
+		t.Fatalf("Expected a moved ciphertext to miss")
+	}
+	if _, ok := redis.New(srv.Addr(), "test:", 0).Get("ssn"); ok {
+		t.Fatalf("Expected a backend without the key to miss")
+	}
+}
+
+// TestAESGCMErrors tests key validation and decryption failures
+func TestAESGCMErrors(t *testing.T) {
+	if _, err := backends.NewAESGCM(1, map[byte][]byte{2: make([]byte, 16)}); err == nil {
+		t.Fatalf("Expected an error for a missing current key")
+	}
+	if _, err := backends.NewAESGCM(1, map[byte][]byte{1: make([]byte, 7)}); err == nil {
+		t.Fatalf("Expected an error for an invalid key size")
+	}
+
+	e, _ := backends.NewAESGCM(1, map[byte][]byte{1: make([]byte, 16)})
+	ct, err := e.Encrypt([]byte("secret"), []byte("k"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ct[len(ct)-1] ^= 1
+	if _, err := e.Decrypt(ct, []byte("k")); !errors.Is(err, backends.ErrDecrypt) {
+		t.Fatalf("Expected ErrDecrypt for a tampered ciphertext, got: %v", err)
+	}
+}
+
+// TestRedisSigning tests that tampered, truncated and unsigned entries are misses counted as corrupt
+func TestRedisSigning(t *testing.T) {
+	srv, client := newRedis(t)
+	ctx := context.Background()
+	key := []byte("signing-key")
+
+	for name, backend := range map[string]memo.Option{
+		"v1": memo.WithBackend(redis.New(srv.Addr(), "test:", 0, redis.WithSigningKey(key))),
+		"v2": memo.WithBackendV2(redis.NewV2(srv.Addr(), "test:", 0, redis.WithSigningKey(key))),
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv.FlushAll()
+			m := memo.New(backend, memo.WithMetrics(true), memo.WithTTL(time.Minute))
+			m.Set(ctx, "good", "value")
+			if !m.Has("good") {
+				t.Fatalf("Expected the signed entry to verify")
+			}
+
+			raw, _ := client.Get(ctx, "test:good").Bytes()
+			tampered := bytes.Clone(raw)
+			tampered[len(tampered)-40] ^= 1
+			client.Set(ctx, "test:tampered", tampered, 0)
+			client.Set(ctx, "test:truncated", raw[:len(raw)-1], 0)
+			unsigned := wire.Encode(wire.Header{Codec: wire.CodecGob}, gobEntry(t, "value"))
+			client.Set(ctx, "test:unsigned", unsigned, 0)
+			client.Set(ctx, "test:moved", raw, 0)
+
+			for _, k := range []string{"tampered", "truncated", "unsigned", "moved"} {
+				v, err := m.Get(ctx, k, func() (any, error) { return "fresh", nil })
+				if err != nil || v != "fresh" {
+					t.Fatalf("Expected %s to be recomputed, got: %v, %v", k, v, err)
+				}
+			}
+			snap := m.Metrics().Snapshot()
+			if snap.CorruptEntries != 4 || snap.BackendErrors != 0 {
+				t.Fatalf("Expected 4 corrupt entries and no backend errors, got: %d, %d", snap.CorruptEntries, snap.BackendErrors)
+			}
+		})
+	}
+}
+
+// delCtxHook records the context of every DEL sent through a client
+type delCtxHook struct {
+	mu   sync.Mutex
+	ctxs []context.Context
+}
+
+func (h *delCtxHook) DialHook(next goredis.DialHook) goredis.DialHook { return next }
+
+func (h *delCtxHook) ProcessHook(next goredis.ProcessHook) goredis.ProcessHook {
+	return func(ctx context.Context, cmd goredis.Cmder) error {
+		if cmd.Name() == "del" {
+			h.mu.Lock()
+			h.ctxs = append(h.ctxs, ctx)
+			h.mu.Unlock()
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h *delCtxHook) ProcessPipelineHook(next goredis.ProcessPipelineHook) goredis.ProcessPipelineHook {
+	return next
+}
+
+// TestRedisCorruptDeleteContext tests that corrupt entries are deleted with the context of the read that found them
+func TestRedisCorruptDeleteContext(t *testing.T) {
+	_, client := newRedis(t)
+	hook := &delCtxHook{}
+	client.AddHook(hook)
+	backend := redis.NewV2WithClient(client, "test:", redis.WithSigningKey([]byte("signing-key")))
+	ctx := context.WithValue(context.Background(), ctxKey("request"), "req-3")
+
+	unsigned := wire.Encode(wire.Header{Codec: wire.CodecGob}, gobEntry(t, "value"))
+	client.Set(ctx, "test:single", unsigned, 0)
+	client.Set(ctx, "test:batch", unsigned, 0)
+
+	if _, ok, err := backend.Get(ctx, "single"); ok || err == nil {
+		t.Fatalf("Expected the corrupt entry to fail the read, got: %v, %v", ok, err)
+	}
+	if _, err := backend.(backends.BatchBackendV2).GetMulti(ctx, []string{"batch"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if len(hook.ctxs) != 2 {
+		t.Fatalf("Expected both corrupt entries to be deleted, got %d deletes", len(hook.ctxs))
+	}
+	for _, c := range hook.ctxs {
+		if requestOf(c) != "req-3" {
+			t.Fatalf("Expected the delete to use the caller's context, got request: %v", requestOf(c))
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestComputeTimeout tests that a compute function running past the timeout is abandoned with ErrComputeTimeout
+func TestComputeTimeout(t *testing.T) {
+	m := memo.New(memo.WithComputeTimeout(20 * time.Millisecond))
+	release := make(chan struct{})
+	defer close(release)
+
+	start := time.Now()
+	_, err := m.Get(context.Background(), "k", func() (any, error) {
+		<-release
+		return "late", nil
+	})
+	if !errors.Is(err, memo.ErrComputeTimeout) {
+		t.Fatalf("Expected ErrComputeTimeout, got: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Expected Get to return at the timeout, got: %v", d)
+	}
+
+	v, err := m.Get(context.Background(), "k", func() (any, error) { return "fresh", nil })
+	if err != nil || v != "fresh" {
+		t.Fatalf("Expected the key to be computed again, got: %v, %v", v, err)
+	}
+}
+
+// TestComputeTimeoutDetached tests that a caller with a short deadline returns early while the computation is still cached
+func TestComputeTimeoutDetached(t *testing.T) {
+	m := memo.New(memo.WithComputeTimeout(time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	_, err := m.Get(ctx, "k", func() (any, error) {
+		defer close(done)
+		time.Sleep(50 * time.Millisecond)
+		return "v", nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the caller's deadline error, got: %v", err)
+	}
+
+	<-done
+	deadline := time.Now().Add(time.Second)
+	for {
+		if v, ok := m.Peek("k"); ok {
+			if v != "v" {
+				t.Fatalf("Expected detached result to be cached, got: %v", v)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected detached result to be cached")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestComputeTimeoutLoaderContext tests that context-aware loaders get a context bounded by the compute timeout
+func TestComputeTimeoutLoaderContext(t *testing.T) {
+	m := memo.New(memo.WithComputeTimeout(20 * time.Millisecond))
+
+	_, err := memo.GetForInputs(context.Background(), m, []int{1}, func(ctx context.Context, missing []int) (map[int]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected loader context to time out, got: %v", err)
+	}
+}
+
+// TestComputeTimeoutPanic tests that a panic in a detached computation reaches the caller as a *PanicError
+func TestComputeTimeoutPanic(t *testing.T) {
+	m := memo.New(memo.WithComputeTimeout(time.Second))
+
+	_, err := m.Get(context.Background(), "k", func() (any, error) {
+		panic("boom")
+	})
+	var pe *memo.PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatalf("Expected *PanicError, got: %v", err)
+	}
+}
+
+// TestComputeTimeoutNotRetried tests that ErrComputeTimeout is only retried when a policy asks for it
+func TestComputeTimeoutNotRetried(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		opts   []memo.Option
+		expect int32
+	}{
+		{"default", nil, 1},
+		{"policy", []memo.Option{memo.WithErrorPolicy(func(error) memo.ErrorClass { return memo.ErrorRetryable })}, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := memo.New(append(tc.opts, memo.WithComputeTimeout(10*time.Millisecond), memo.WithRetry(3, time.Millisecond))...)
+			release := make(chan struct{})
+			defer close(release)
+
+			var calls atomic.Int32
+			_, err := m.Get(context.Background(), "k", func() (any, error) {
+				calls.Add(1)
+				<-release
+				return "late", nil
+			})
+			if !errors.Is(err, memo.ErrComputeTimeout) {
+				t.Fatalf("Expected ErrComputeTimeout, got: %v", err)
+			}
+			if n := calls.Load(); n != tc.expect {
+				t.Fatalf("Expected %d calls, got: %d", tc.expect, n)
+			}
+		})
+	}
+}
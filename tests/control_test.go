@@ -0,0 +1,87 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestGetControlledNoStore tests that a NoStore result is returned but not cached
+func TestGetControlledNoStore(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	var calls int32
+	fn := func() (any, memo.CacheControl, error) {
+		atomic.AddInt32(&calls, 1)
+		return "fresh", memo.CacheControl{NoStore: true}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		val, err := m.GetControlled(ctx, "k", fn)
+		if err != nil || val != "fresh" {
+			t.Fatalf("Expected fresh value, got: %v, %v", val, err)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("Expected fn to run on every call, got: %d calls", calls)
+	}
+}
+
+// TestGetControlledTTL tests that a custom TTL from fn is used when storing
+func TestGetControlledTTL(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	var calls int32
+	fn := func() (any, memo.CacheControl, error) {
+		atomic.AddInt32(&calls, 1)
+		return "short", memo.CacheControl{TTL: 30 * time.Millisecond}, nil
+	}
+
+	_, _ = m.GetControlled(ctx, "k", fn)
+	_, _ = m.GetControlled(ctx, "k", fn)
+	if calls != 1 {
+		t.Fatalf("Expected value to be cached, got: %d calls", calls)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	_, _ = m.GetControlled(ctx, "k", fn)
+	if calls != 2 {
+		t.Fatalf("Expected value to expire after the custom TTL, got: %d calls", calls)
+	}
+}
+
+// TestGetControlledStaleOK tests that a stale value is served when a recompute fails
+func TestGetControlledStaleOK(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	errDown := errors.New("upstream down")
+	fail := false
+	fn := func() (any, memo.CacheControl, error) {
+		if fail {
+			return nil, memo.CacheControl{}, errDown
+		}
+		return "good", memo.CacheControl{TTL: 20 * time.Millisecond, StaleOK: true}, nil
+	}
+
+	_, _ = m.GetControlled(ctx, "k", fn)
+	time.Sleep(40 * time.Millisecond)
+
+	fail = true
+	val, err := m.GetControlled(ctx, "k", fn)
+	if err != nil || val != "good" {
+		t.Fatalf("Expected stale value on failure, got: %v, %v", val, err)
+	}
+
+	// Without StaleOK the error surfaces
+	m.Delete("k")
+	if _, err := m.GetControlled(ctx, "k", fn); !errors.Is(err, errDown) {
+		t.Fatalf("Expected error once stale value is deleted, got: %v", err)
+	}
+}
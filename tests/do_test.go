@@ -0,0 +1,56 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestDoTyped tests that Do returns a typed value and caches it
+func TestDoTyped(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	calls := 0
+	fn := func() (string, error) {
+		calls++
+		return "alice", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		name, err := memo.Do(ctx, m, "user:1", fn)
+		if err != nil || name != "alice" {
+			t.Fatalf("Expected 'alice', got: %q, %v", name, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("Expected fn to run once, got: %d", calls)
+	}
+}
+
+// TestDoTypeMismatch tests that a cached value of another type is reported as an error
+func TestDoTypeMismatch(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	_, _ = memo.Do(ctx, m, "k", func() (int, error) { return 42, nil })
+
+	s, err := memo.Do(ctx, m, "k", func() (string, error) { return "unused", nil })
+	if !errors.Is(err, memo.ErrUnexpectedType) || s != "" {
+		t.Fatalf("Expected ErrUnexpectedType, got: %q, %v", s, err)
+	}
+}
+
+// TestDoError tests that errors from fn are returned with the zero value
+func TestDoError(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	errFail := errors.New("fail")
+
+	v, err := memo.Do(context.Background(), m, "k", func() (*int, error) { return nil, errFail })
+	if !errors.Is(err, errFail) || v != nil {
+		t.Fatalf("Expected error and nil value, got: %v, %v", v, err)
+	}
+}
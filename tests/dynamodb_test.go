@@ -0,0 +1,231 @@
+package memo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/dynamodb"
+)
+
+// fakeDynamo is an in-memory dynamodb.Client.
+type fakeDynamo struct {
+	mu         sync.Mutex
+	items      map[string]dynamodb.Item
+	batchGets  int
+	batchPuts  int
+	lastTable  dynamodb.Table
+	lastGetCtx context.Context
+}
+
+func newFakeDynamo() *fakeDynamo {
+	return &fakeDynamo{items: make(map[string]dynamodb.Item)}
+}
+
+func (f *fakeDynamo) GetItem(ctx context.Context, t dynamodb.Table, key string) (*dynamodb.Item, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastTable, f.lastGetCtx = t, ctx
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	item, ok := f.items[key]
+	if !ok {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+func (f *fakeDynamo) PutItem(_ context.Context, _ dynamodb.Table, item dynamodb.Item, cond dynamodb.Condition) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cur, exists := f.items[item.Key]
+	if exists && cur.Expires != 0 && cur.Expires < time.Now().Unix() {
+		exists = false
+	}
+	switch {
+	case cond.Absent && exists,
+		cond.Version != nil && (!exists || cur.Version != *cond.Version),
+		cond.Value != nil && (!exists || !bytes.Equal(cur.Value, cond.Value)):
+		return dynamodb.ErrConditionFailed
+	}
+	f.items[item.Key] = item
+	return nil
+}
+
+func (f *fakeDynamo) DeleteItem(_ context.Context, _ dynamodb.Table, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.items, key)
+	return nil
+}
+
+func (f *fakeDynamo) BatchGetItems(_ context.Context, _ dynamodb.Table, keys []string) ([]dynamodb.Item, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(keys) > 100 {
+		return nil, fmt.Errorf("too many keys: %d", len(keys))
+	}
+	f.batchGets++
+	var out []dynamodb.Item
+	for _, k := range keys {
+		if item, ok := f.items[k]; ok {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeDynamo) BatchWriteItems(_ context.Context, _ dynamodb.Table, puts []dynamodb.Item, deletes []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(puts)+len(deletes) > 25 {
+		return fmt.Errorf("too many writes: %d", len(puts)+len(deletes))
+	}
+	f.batchPuts++
+	for _, item := range puts {
+		f.items[item.Key] = item
+	}
+	for _, k := range deletes {
+		delete(f.items, k)
+	}
+	return nil
+}
+
+func (f *fakeDynamo) ScanKeys(_ context.Context, _ dynamodb.Table, prefix string, fn func(keys []string) error) error {
+	f.mu.Lock()
+	var keys []string
+	for k := range f.items {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	f.mu.Unlock()
+	sort.Strings(keys)
+	for len(keys) > 0 {
+		n := min(len(keys), 10)
+		if err := fn(keys[:n]); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+// TestDynamoDBBasic tests reads, writes, expiry and the TTL attribute of the dynamodb backend
+func TestDynamoDBBasic(t *testing.T) {
+	client := newFakeDynamo()
+	d := dynamodb.New(client, "cache", dynamodb.WithPrefix("app:"), dynamodb.WithPartitionKey("pk"), dynamodb.WithConsistentRead())
+
+	d.Set("k", "v", time.Hour)
+	if v, ok := d.Get("k"); !ok || v != "v" {
+		t.Fatalf("Expected v, got: %v, %v", v, ok)
+	}
+	if client.lastTable.Name != "cache" || client.lastTable.PartitionKey != "pk" || !client.lastTable.ConsistentRead {
+		t.Fatalf("Expected the configured table, got: %+v", client.lastTable)
+	}
+	item := client.items["app:k"]
+	if left := time.Until(time.Unix(item.Expires, 0)); left < 59*time.Minute || left > time.Hour+time.Second {
+		t.Fatalf("Expected the TTL attribute an hour ahead, got: %v", left)
+	}
+
+	// DynamoDB deletes expired items late, so reads check the expiry themselves
+	d.Set("short", "v", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := d.Get("short"); ok {
+		t.Fatal("Expected an expired item to be a miss before DynamoDB deletes it")
+	}
+	if !d.SetIfAbsent("short", "again", time.Hour) {
+		t.Fatal("Expected SetIfAbsent to replace an expired item")
+	}
+
+	d.Delete("k")
+	if _, ok := d.Get("k"); ok {
+		t.Fatal("Expected Delete to remove the item")
+	}
+}
+
+// TestDynamoDBConditionalWrites tests versioned writes and touches through conditional puts
+func TestDynamoDBConditionalWrites(t *testing.T) {
+	d := dynamodb.New(newFakeDynamo(), "cache")
+
+	if !d.SetIfAbsent("k", "v0", time.Minute) || d.SetIfAbsent("k", "other", time.Minute) {
+		t.Fatal("Expected only the first SetIfAbsent to succeed")
+	}
+	if !d.CompareAndSet("k", "v1", time.Minute, 0) {
+		t.Fatal("Expected CompareAndSet with the current version to succeed")
+	}
+	if d.CompareAndSet("k", "v2", time.Minute, 0) {
+		t.Fatal("Expected CompareAndSet with a stale version to fail")
+	}
+	if entry, ok := d.GetEntry("k"); !ok || entry.Value != "v1" || entry.Version() != 1 {
+		t.Fatalf("Expected v1 at version 1, got: %v, %d", entry.Value, entry.Version())
+	}
+
+	if v, ok := d.GetAndTouch("k", time.Hour); !ok || v != "v1" {
+		t.Fatalf("Expected GetAndTouch to return v1, got: %v, %v", v, ok)
+	}
+	if entry, _ := d.GetEntry("k"); time.Until(entry.ExpiresAt()) < 59*time.Minute || entry.Version() != 1 {
+		t.Fatalf("Expected Touch to extend the expiry and keep the version, got: %v, %d", entry.ExpiresAt(), entry.Version())
+	}
+	if d.Touch("missing", time.Hour) {
+		t.Fatal("Expected Touch of a missing key to fail")
+	}
+}
+
+// TestDynamoDBBatches tests that batch operations are split at the DynamoDB limits
+func TestDynamoDBBatches(t *testing.T) {
+	client := newFakeDynamo()
+	d := dynamodb.New(client, "cache", dynamodb.WithPrefix("app:"))
+	client.items["other:x"] = dynamodb.Item{Key: "other:x"}
+
+	items := make([]backends.BatchItem, 120)
+	keys := make([]string, len(items))
+	for i := range items {
+		keys[i] = fmt.Sprintf("k%03d", i)
+		items[i] = backends.BatchItem{Key: keys[i], Value: i, TTL: time.Hour}
+	}
+	d.SetMulti(items)
+	if client.batchPuts != 5 {
+		t.Fatalf("Expected 5 batch writes of at most 25 items, got: %d", client.batchPuts)
+	}
+	got := d.GetMulti(append(keys, "missing"))
+	if len(got) != 120 || got["k007"] != 7 || client.batchGets != 2 {
+		t.Fatalf("Expected 120 values from 2 batch reads, got: %d from %d", len(got), client.batchGets)
+	}
+
+	if n := d.DeleteByPrefix("k1"); n != 20 {
+		t.Fatalf("Expected 20 keys removed under k1, got: %d", n)
+	}
+	d.Clear()
+	if len(client.items) != 1 {
+		t.Fatalf("Expected Clear to keep only keys outside the prefix, got: %d items", len(client.items))
+	}
+}
+
+// TestDynamoDBWithMemoizer tests that the memoizer passes the caller's context to the dynamodb client
+func TestDynamoDBWithMemoizer(t *testing.T) {
+	client := newFakeDynamo()
+	m := memo.New(memo.WithBackend(dynamodb.New(client, "cache")), memo.WithTTL(time.Minute))
+
+	ctx := context.WithValue(context.Background(), ctxKey("req"), "request")
+	if v, err := m.Get(ctx, "k", func() (any, error) { return "v", nil }); err != nil || v != "v" {
+		t.Fatalf("Expected v, got: %v, %v", v, err)
+	}
+	if client.lastGetCtx.Value(ctxKey("req")) != "request" {
+		t.Fatal("Expected the client to receive the caller's context")
+	}
+	vals, err := m.GetMany(ctx, []string{"k", "other"}, func(missing []string) (map[string]any, error) {
+		return map[string]any{"other": "o"}, nil
+	})
+	if err != nil || vals["k"] != "v" || vals["other"] != "o" || client.batchGets != 1 {
+		t.Fatalf("Expected a batched lookup, got: %v, %v, %d", vals, err, client.batchGets)
+	}
+}
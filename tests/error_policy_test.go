@@ -0,0 +1,86 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+var errFatal = errors.New("fatal")
+
+// classifyTestErrors is the error policy used by the tests below.
+func classifyTestErrors(err error) memo.ErrorClass {
+	switch {
+	case errors.Is(err, errNotFound):
+		return memo.ErrorCacheable
+	case errors.Is(err, errFatal):
+		return memo.ErrorFatal
+	}
+	return memo.ErrorRetryable
+}
+
+// TestErrorPolicyClasses tests that retries and negative caching follow the error class
+func TestErrorPolicyClasses(t *testing.T) {
+	m := memo.New(
+		memo.WithErrorPolicy(classifyTestErrors),
+		memo.WithRetry(3, time.Millisecond),
+		memo.WithErrorTTL(time.Minute),
+	)
+	ctx := context.Background()
+
+	tests := []struct {
+		err        error
+		wantCalls  int // calls to fn from the first Get
+		wantCached bool
+	}{
+		{errTransient, 3, false},
+		{errNotFound, 1, true},
+		{errFatal, 1, false},
+	}
+	for _, tt := range tests {
+		calls := 0
+		fn := func() (any, error) {
+			calls++
+			return nil, tt.err
+		}
+		key := tt.err.Error()
+
+		if _, err := m.Get(ctx, key, fn); !errors.Is(err, tt.err) {
+			t.Fatalf("Expected %v, got: %v", tt.err, err)
+		}
+		if calls != tt.wantCalls {
+			t.Fatalf("Expected %d calls for %v, got: %d", tt.wantCalls, tt.err, calls)
+		}
+		_, _ = m.Get(ctx, key, fn)
+		if cached := calls == tt.wantCalls; cached != tt.wantCached {
+			t.Fatalf("Expected cached=%v for %v, got: %v", tt.wantCached, tt.err, cached)
+		}
+	}
+}
+
+// TestErrorPolicyStale tests that only retryable errors are answered with the stale value
+func TestErrorPolicyStale(t *testing.T) {
+	m := memo.New(
+		memo.WithTTL(10*time.Millisecond),
+		memo.WithStaleIfError(time.Minute),
+		memo.WithErrorPolicy(classifyTestErrors),
+	)
+	ctx := context.Background()
+
+	_, _ = m.Get(ctx, "k", func() (any, error) { return "old", nil })
+	time.Sleep(20 * time.Millisecond)
+
+	v, err := m.Get(ctx, "k", func() (any, error) { return nil, errTransient })
+	if err != nil || v != "old" {
+		t.Fatalf("Expected stale value for a retryable error, got: %v, %v", v, err)
+	}
+	if _, err := m.Get(ctx, "k", func() (any, error) { return nil, errFatal }); !errors.Is(err, errFatal) {
+		t.Fatalf("Expected fatal error, got: %v", err)
+	}
+	if _, err := m.Get(ctx, "k", func() (any, error) { return nil, errNotFound }); !errors.Is(err, errNotFound) {
+		t.Fatalf("Expected cacheable error, got: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestGetExistingHit tests that a cached value is returned
+func TestGetExistingHit(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+	_, _ = m.Get(ctx, "k", func() (any, error) { return "v", nil })
+
+	val, ok, err := m.GetExisting(ctx, "k", 0)
+	if err != nil || !ok || val != "v" {
+		t.Fatalf("Expected cached value, got: %v, %v, %v", val, ok, err)
+	}
+}
+
+// TestGetExistingWaitsForInFlight tests that an in-flight computation is awaited
+func TestGetExistingWaitsForInFlight(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	go func() {
+		_, _ = m.Get(ctx, "k", func() (any, error) {
+			close(started)
+			time.Sleep(30 * time.Millisecond)
+			return "computed", nil
+		})
+	}()
+	<-started
+
+	val, ok, err := m.GetExisting(ctx, "k", time.Second)
+	if err != nil || !ok || val != "computed" {
+		t.Fatalf("Expected in-flight result, got: %v, %v, %v", val, ok, err)
+	}
+}
+
+// TestGetExistingAbsent tests that an absent key is reported without computing it
+func TestGetExistingAbsent(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	val, ok, err := m.GetExisting(ctx, "missing", 10*time.Millisecond)
+	if err != nil || ok || val != nil {
+		t.Fatalf("Expected absent result, got: %v, %v, %v", val, ok, err)
+	}
+
+	// An in-flight computation that outlives maxWait also reports absence
+	started := make(chan struct{})
+	go func() {
+		_, _ = m.Get(ctx, "slow", func() (any, error) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			return "late", nil
+		})
+	}()
+	<-started
+
+	val, ok, err = m.GetExisting(ctx, "slow", 10*time.Millisecond)
+	if err != nil || ok || val != nil {
+		t.Fatalf("Expected absent result after maxWait, got: %v, %v, %v", val, ok, err)
+	}
+}
+
+// TestGetExistingInFlightError tests that an in-flight failure is returned
+func TestGetExistingInFlightError(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	errFail := errors.New("compute failed")
+	started := make(chan struct{})
+	go func() {
+		_, _ = m.Get(ctx, "k", func() (any, error) {
+			close(started)
+			time.Sleep(20 * time.Millisecond)
+			return nil, errFail
+		})
+	}()
+	<-started
+
+	if _, ok, err := m.GetExisting(ctx, "k", time.Second); ok || !errors.Is(err, errFail) {
+		t.Fatalf("Expected in-flight error, got: %v, %v", ok, err)
+	}
+}
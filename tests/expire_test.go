@@ -0,0 +1,95 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+)
+
+// TestMemoizerTouch tests that Touch extends the expiry of a cached entry
+func TestMemoizerTouch(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	backend := memory.New(memory.WithClock(clock))
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Second))
+	m.Set(context.Background(), "k", "v")
+
+	if !m.Touch("k", time.Hour) {
+		t.Fatalf("Expected Touch to find the entry")
+	}
+	if m.Touch("missing", time.Hour) {
+		t.Fatalf("Expected Touch to report a missing key")
+	}
+
+	clock.Advance(time.Minute)
+	if v, ok := m.Peek("k"); !ok || v != "v" {
+		t.Fatalf("Expected touched entry to outlive its original TTL, got: %v, %v", v, ok)
+	}
+}
+
+// TestMemoizerExpireRecomputes tests that an expired entry is recomputed but kept for stale-if-error
+func TestMemoizerExpireRecomputes(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute), memo.WithStaleIfError(time.Minute))
+	ctx := context.Background()
+
+	_, _ = m.Get(ctx, "k", func() (any, error) { return "v1", nil })
+	m.Expire("k")
+	if m.Has("k") {
+		t.Fatalf("Expected expired entry to be gone from reads")
+	}
+
+	// A failing recompute is answered with the stale value
+	v, err := m.Get(ctx, "k", func() (any, error) { return nil, errors.New("down") })
+	if err != nil || v != "v1" {
+		t.Fatalf("Expected stale v1, got: %v, %v", v, err)
+	}
+
+	m.Expire("k")
+	v, err = m.Get(ctx, "k", func() (any, error) { return "v2", nil })
+	if err != nil || v != "v2" {
+		t.Fatalf("Expected recompute to v2, got: %v, %v", v, err)
+	}
+}
+
+// TestMemoryExpire tests that the memory backend hides expired entries and sweeps them
+func TestMemoryExpire(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := memory.New(memory.WithClock(clock))
+	b.Set("k", "v", 0)
+
+	if !b.Expire("k") || b.Expire("k") {
+		t.Fatalf("Expected Expire to succeed once")
+	}
+	if _, ok := b.Get("k"); ok {
+		t.Fatalf("Expected expired entry to miss")
+	}
+	if b.Len() != 1 {
+		t.Fatalf("Expected entry to stay until the sweep")
+	}
+	if evicted := b.Sweep(); evicted != 1 {
+		t.Fatalf("Expected the sweep to remove the entry, got: %d", evicted)
+	}
+}
+
+// TestRedisExpire tests that the redis backend expires keys with PEXPIREAT
+func TestRedisExpire(t *testing.T) {
+	srv, _ := newRedis(t)
+	b := redis.New(srv.Addr(), "test:", 0)
+	b.Set("k", "v", time.Hour)
+
+	if !b.(backends.Expirer).Expire("k") {
+		t.Fatalf("Expected Expire to find the key")
+	}
+	srv.FastForward(time.Millisecond)
+	if _, ok := b.Get("k"); ok {
+		t.Fatalf("Expected expired key to miss")
+	}
+	if b.(backends.Expirer).Expire("missing") {
+		t.Fatalf("Expected Expire to report a missing key")
+	}
+}
@@ -0,0 +1,64 @@
+package memo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestSweepPurgesOnlyExpiredEntries tests that a sweep removes exactly the entries whose expiry has passed
+func TestSweepPurgesOnlyExpiredEntries(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := memory.New(memory.WithClock(clock))
+
+	for i := 0; i < 100; i++ {
+		b.Set(fmt.Sprintf("short%d", i), i, time.Second)
+		b.Set(fmt.Sprintf("long%d", i), i, time.Hour)
+	}
+	b.Set("forever", "v", 0)
+
+	if evicted := b.Sweep(); evicted != 0 {
+		t.Fatalf("Expected no evictions before expiry, got: %d", evicted)
+	}
+
+	clock.Advance(2 * time.Second)
+	if evicted := b.Sweep(); evicted != 100 {
+		t.Fatalf("Expected 100 evictions, got: %d", evicted)
+	}
+	if got := b.Len(); got != 101 {
+		t.Fatalf("Expected 101 entries left, got: %d", got)
+	}
+
+	clock.Advance(2 * time.Hour)
+	if evicted := b.Sweep(); evicted != 100 {
+		t.Fatalf("Expected 100 evictions, got: %d", evicted)
+	}
+	if _, ok := b.Get("forever"); !ok {
+		t.Fatalf("Expected entry without TTL to survive sweeps")
+	}
+}
+
+// TestSweepSkipsRewrittenEntries tests that rewriting or deleting a key cancels its earlier expiry
+func TestSweepSkipsRewrittenEntries(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := memory.New(memory.WithClock(clock))
+
+	b.Set("rewritten", 1, time.Second)
+	b.Set("rewritten", 2, time.Hour)
+	b.Set("deleted", 1, time.Second)
+	b.Delete("deleted")
+	b.Set("deleted", 2, 0)
+
+	clock.Advance(2 * time.Second)
+	if evicted := b.Sweep(); evicted != 0 {
+		t.Fatalf("Expected stale expiries to be skipped, got: %d evictions", evicted)
+	}
+	if val, ok := b.Get("rewritten"); !ok || val != 2 {
+		t.Fatalf("Expected rewritten value to survive, got: %v, %v", val, ok)
+	}
+	if val, ok := b.Get("deleted"); !ok || val != 2 {
+		t.Fatalf("Expected re-added value to survive, got: %v, %v", val, ok)
+	}
+}
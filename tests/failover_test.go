@@ -0,0 +1,178 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/failover"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+)
+
+// TestFailoverTripsAndRecovers tests that a Redis outage opens the circuit and that a successful probe closes it
+func TestFailoverTripsAndRecovers(t *testing.T) {
+	srv, client := newRedis(t)
+	secondary := memory.New()
+	defer secondary.Close()
+
+	var mu sync.Mutex
+	var changes []string
+	b := failover.New(redis.NewWithClient(client, "test:", redis.WithErrorHandler(func(string, error) {})), secondary,
+		failover.WithFailureThreshold(2),
+		failover.WithCooldown(50*time.Millisecond),
+		failover.OnStateChange(func(from, to failover.State) {
+			mu.Lock()
+			changes = append(changes, from.String()+">"+to.String())
+			mu.Unlock()
+		}))
+
+	b.Set("k", "primary", time.Minute)
+	if v, ok := b.Get("k"); !ok || v != "primary" {
+		t.Fatalf("Expected the value from the primary, got: %v, %v", v, ok)
+	}
+	if _, ok := secondary.Get("k"); ok {
+		t.Fatal("Expected the secondary unused while the primary is healthy")
+	}
+
+	addr := srv.Addr()
+	srv.Close()
+	b.Set("k", "outage", time.Minute)
+	b.Set("k", "outage", time.Minute)
+	if b.State() != failover.Open {
+		t.Fatalf("Expected the circuit open after two failures, got: %v", b.State())
+	}
+	if v, ok := b.Get("k"); !ok || v != "outage" {
+		t.Fatalf("Expected the value from the secondary, got: %v, %v", v, ok)
+	}
+
+	// The probe fails while Redis is still down
+	time.Sleep(60 * time.Millisecond)
+	b.Get("k")
+	if b.State() != failover.Open {
+		t.Fatalf("Expected a failed probe to reopen the circuit, got: %v", b.State())
+	}
+
+	if err := srv.StartAddr(addr); err != nil {
+		t.Fatalf("Expected Redis to restart, got: %v", err)
+	}
+	// The client backs off redialing for a moment, so probe until it reconnects
+	deadline := time.Now().Add(5 * time.Second)
+	for b.State() != failover.Closed && time.Now().Before(deadline) {
+		time.Sleep(60 * time.Millisecond)
+		b.Get("k")
+	}
+	if b.State() != failover.Closed {
+		t.Fatalf("Expected a successful probe to close the circuit, got: %v", b.State())
+	}
+	if v, ok := b.Get("k"); !ok || v != "primary" {
+		t.Fatalf("Expected the value from the recovered primary, got: %v, %v", v, ok)
+	}
+	if secondary.Len() != 0 {
+		t.Fatalf("Expected recovery to clear the secondary, got: %d entries", secondary.Len())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(changes) < 5 || changes[0] != "closed>open" || changes[1] != "open>half-open" || changes[2] != "half-open>open" ||
+		changes[len(changes)-1] != "half-open>closed" {
+		t.Fatalf("Expected the circuit to open, fail a probe and close, got: %v", changes)
+	}
+}
+
+// downBackend is a primary whose context-aware calls block until release is
+// closed and then fail.
+type downBackend struct {
+	*memory.Memory
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (d *downBackend) V2() backends.BackendV2 { return downV2{d} }
+
+type downV2 struct{ d *downBackend }
+
+func (v downV2) Get(ctx context.Context, key string) (any, bool, error) {
+	v.d.calls.Add(1)
+	<-v.d.release
+	return nil, false, errors.New("down")
+}
+
+func (v downV2) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return errors.New("down")
+}
+
+func (v downV2) Delete(ctx context.Context, key string) error { return errors.New("down") }
+
+func (v downV2) Clear(ctx context.Context) error { return errors.New("down") }
+
+// TestFailoverSingleProbe tests that concurrent calls send a single probe to a half-open circuit
+func TestFailoverSingleProbe(t *testing.T) {
+	primary := &downBackend{Memory: memory.New(), release: make(chan struct{})}
+	defer primary.Close()
+	secondary := memory.New()
+	defer secondary.Close()
+	b := failover.New(primary, secondary, failover.WithFailureThreshold(1), failover.WithCooldown(time.Millisecond))
+
+	b.Set("k", "v", time.Minute)
+	if b.State() != failover.Open {
+		t.Fatalf("Expected the circuit open, got: %v", b.State())
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	probe := make(chan struct{})
+	go func() {
+		defer close(probe)
+		b.Get("k")
+	}()
+	for primary.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if b.State() != failover.HalfOpen {
+		t.Fatalf("Expected the circuit half-open during the probe, got: %v", b.State())
+	}
+	for i := 0; i < 5; i++ {
+		if v, ok := b.Get("k"); !ok || v != "v" {
+			t.Fatalf("Expected the secondary to serve reads during the probe, got: %v, %v", v, ok)
+		}
+	}
+	close(primary.release)
+	<-probe
+	if n := primary.calls.Load(); n != 1 {
+		t.Fatalf("Expected a single probe, got: %d", n)
+	}
+	if b.State() != failover.Open {
+		t.Fatalf("Expected the failed probe to reopen the circuit, got: %v", b.State())
+	}
+}
+
+// TestFailoverWithMemoizer tests that a memoizer keeps caching through a Redis outage
+func TestFailoverWithMemoizer(t *testing.T) {
+	srv, client := newRedis(t)
+	secondary := memory.New()
+	defer secondary.Close()
+	b := failover.New(redis.NewWithClient(client, "test:", redis.WithErrorHandler(func(string, error) {})), secondary,
+		failover.WithFailureThreshold(1), failover.WithCooldown(time.Hour))
+	m := memo.New(memo.WithBackend(b), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	srv.Close()
+	calls := 0
+	compute := func() (any, error) {
+		calls++
+		return "v", nil
+	}
+	for i := 0; i < 3; i++ {
+		if v, err := m.Get(ctx, "k", compute); err != nil || v != "v" {
+			t.Fatalf("Expected v, got: %v, %v", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("Expected the secondary to cache during the outage, got: %d computations", calls)
+	}
+}
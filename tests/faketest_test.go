@@ -0,0 +1,147 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/faketest"
+)
+
+// TestFakeBackendStores tests that the fake behaves like a regular backend when nothing is scripted
+func TestFakeBackendStores(t *testing.T) {
+	b := faketest.New()
+
+	b.Set("k", "v", 0)
+	if val, ok := b.Get("k"); !ok || val != "v" {
+		t.Fatalf("Expected stored value, got: %v, %v", val, ok)
+	}
+
+	b.Set("short", "v", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := b.Get("short"); ok {
+		t.Fatalf("Expected expired value to miss")
+	}
+
+	b.Delete("k")
+	if _, ok := b.Get("k"); ok {
+		t.Fatalf("Expected deleted value to miss")
+	}
+}
+
+// TestFakeBackendForcedOutcomes tests ForceHit, ForceMiss, ForceError and Unforce
+func TestFakeBackendForcedOutcomes(t *testing.T) {
+	b := faketest.New()
+	b.Set("k", "stored", 0)
+
+	b.ForceHit("k", "forced")
+	if val, ok := b.Get("k"); !ok || val != "forced" {
+		t.Fatalf("Expected forced hit, got: %v, %v", val, ok)
+	}
+
+	b.ForceMiss("k")
+	if _, ok := b.Get("k"); ok {
+		t.Fatalf("Expected forced miss")
+	}
+
+	errDown := errors.New("backend down")
+	b.ForceError("k", errDown)
+	if _, ok := b.Get("k"); ok {
+		t.Fatalf("Expected forced error to miss")
+	}
+	b.Set("k", "dropped", 0)
+
+	errs := b.Errors()
+	if len(errs) != 2 || !errors.Is(errs[0], errDown) || !errors.Is(errs[1], errDown) {
+		t.Fatalf("Expected two recorded errors, got: %v", errs)
+	}
+
+	b.Unforce("k")
+	if val, ok := b.Get("k"); !ok || val != "stored" {
+		t.Fatalf("Expected failed Set to be dropped, got: %v, %v", val, ok)
+	}
+}
+
+// TestFakeBackendHooks tests that Get and Set hooks are consulted
+func TestFakeBackendHooks(t *testing.T) {
+	b := faketest.New()
+
+	var setKeys []string
+	b.GetHook = func(key string) (any, bool) { return "hooked-" + key, true }
+	b.SetHook = func(key string, value any, ttl time.Duration) { setKeys = append(setKeys, key) }
+
+	if val, ok := b.Get("a"); !ok || val != "hooked-a" {
+		t.Fatalf("Expected hooked value, got: %v, %v", val, ok)
+	}
+
+	b.Set("b", 1, 0)
+	if len(setKeys) != 1 || setKeys[0] != "b" {
+		t.Fatalf("Expected Set hook to be called once for b, got: %v", setKeys)
+	}
+
+	// Scripted outcomes take precedence over the hook
+	b.ForceMiss("a")
+	if _, ok := b.Get("a"); ok {
+		t.Fatalf("Expected forced miss to override the hook")
+	}
+}
+
+// TestFakeBackendRecordsCalls tests call recording through a Memoizer
+func TestFakeBackendRecordsCalls(t *testing.T) {
+	b := faketest.New()
+	m := memo.New(memo.WithBackend(b), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	fn := func() (any, error) { return 42, nil }
+	_, _ = m.Get(ctx, "k", fn)
+	_, _ = m.Get(ctx, "k", fn)
+	m.Delete("k")
+	m.Clear()
+
+	counts := b.Counts()
+	want := faketest.Counts{Gets: 3, Sets: 1, Deletes: 1, Clears: 1}
+	if counts != want {
+		t.Fatalf("Expected counts %+v, got: %+v", want, counts)
+	}
+
+	calls := b.Calls()
+	var set *faketest.Call
+	for i := range calls {
+		if calls[i].Op == faketest.OpSet {
+			set = &calls[i]
+		}
+	}
+	if set == nil || set.Key != "k" || set.Value != 42 || set.TTL != time.Minute {
+		t.Fatalf("Expected recorded Set of k=42 with 1m TTL, got: %+v", set)
+	}
+
+	b.Reset()
+	if len(b.Calls()) != 0 || b.Counts() != (faketest.Counts{}) {
+		t.Fatalf("Expected Reset to clear recorded calls")
+	}
+}
+
+// TestFakeBackendV2Errors tests that errors forced on the fake reach the memoizer's backend error handling
+func TestFakeBackendV2Errors(t *testing.T) {
+	errDown := errors.New("backend down")
+	b := faketest.New()
+	b.ForceError("k", errDown)
+	if _, _, err := b.V2().Get(context.Background(), "k"); !errors.Is(err, errDown) {
+		t.Fatalf("Expected the forced error from V2, got: %v", err)
+	}
+
+	closed := memo.New(memo.WithBackend(b), memo.WithBackendErrorPolicy(memo.BackendFailClosed), memo.WithMetrics(true))
+	if _, err := closed.Get(context.Background(), "k", func() (any, error) { return "v", nil }); !errors.Is(err, errDown) {
+		t.Fatalf("Expected fail-closed Get to return the forced error, got: %v", err)
+	}
+
+	open := memo.New(memo.WithBackend(b), memo.WithBackendErrorPolicy(memo.BackendFailOpen), memo.WithMetrics(true))
+	if v, err := open.Get(context.Background(), "k", func() (any, error) { return "v", nil }); err != nil || v != "v" {
+		t.Fatalf("Expected fail-open Get to compute, got: %v, %v", v, err)
+	}
+	if s := open.Metrics().Snapshot(); s.BackendErrors != 1 || s.DegradedReads != 1 {
+		t.Fatalf("Expected one counted backend error and degraded read, got: %+v", s)
+	}
+}
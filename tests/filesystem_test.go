@@ -0,0 +1,135 @@
+package memo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends/filesystem"
+)
+
+// newFilesystem creates a filesystem backend in dir and closes it after the test.
+func newFilesystem(t *testing.T, dir string, opts ...filesystem.Option) *filesystem.Filesystem {
+	t.Helper()
+	f, err := filesystem.New(dir, opts...)
+	if err != nil {
+		t.Fatalf("Expected filesystem backend to be created, got: %v", err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
+// TestFilesystemBasic tests that entries are stored in sharded files and survive a new backend on the same directory
+func TestFilesystemBasic(t *testing.T) {
+	dir := t.TempDir()
+	f := newFilesystem(t, dir)
+	report := strings.Repeat("row\n", 10000)
+	f.Set("report", report, time.Hour)
+
+	var files []string
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if len(files) != 1 {
+		t.Fatalf("Expected one file, got: %v", files)
+	}
+	if rel, _ := filepath.Rel(dir, files[0]); strings.Count(rel, string(filepath.Separator)) != 2 {
+		t.Fatalf("Expected the file two shard directories deep, got: %s", rel)
+	}
+
+	f2 := newFilesystem(t, dir)
+	if v, ok := f2.Get("report"); !ok || v != report {
+		t.Fatalf("Expected the report from a second backend, got: %v", ok)
+	}
+	entry, _ := f2.GetEntry("report")
+	if left := time.Until(entry.ExpiresAt()); left < 59*time.Minute {
+		t.Fatalf("Expected the TTL to be stored with the entry, got: %v left", left)
+	}
+
+	f2.Delete("report")
+	if _, ok := f.Get("report"); ok {
+		t.Fatal("Expected Delete to remove the file")
+	}
+}
+
+// TestFilesystemExpiryAndSweep tests that expired entries are misses and that Sweep removes them and stale temp files
+func TestFilesystemExpiryAndSweep(t *testing.T) {
+	dir := t.TempDir()
+	f := newFilesystem(t, dir, filesystem.WithSweepInterval(0), filesystem.WithShardDepth(1))
+	f.Set("short", 1, 20*time.Millisecond)
+	f.Set("other", 2, 20*time.Millisecond)
+	f.Set("long", 3, time.Hour)
+	f.Set("touched", 4, 20*time.Millisecond)
+	if !f.Touch("touched", time.Hour) {
+		t.Fatal("Expected Touch to extend a live entry")
+	}
+
+	stale := filepath.Join(dir, ".tmp-crashed")
+	if err := os.WriteFile(stale, []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	_ = os.Chtimes(stale, old, old)
+
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := f.Get("short"); ok {
+		t.Fatal("Expected expired entry to be a miss")
+	}
+	if n := f.Sweep(); n != 1 {
+		t.Fatalf("Expected Sweep to remove the remaining expired entry, got: %d", n)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("Expected Sweep to remove the stale temp file, got: %v", err)
+	}
+	for _, key := range []string{"long", "touched"} {
+		if _, ok := f.Get(key); !ok {
+			t.Fatalf("Expected %s to survive Sweep", key)
+		}
+	}
+}
+
+// TestFilesystemPrefixAndClear tests DeleteByPrefix, Clear and recovery from corrupt files
+func TestFilesystemPrefixAndClear(t *testing.T) {
+	dir := t.TempDir()
+	keep := filepath.Join(dir, "README")
+	_ = os.WriteFile(keep, []byte("not a cache file"), 0o600)
+
+	f := newFilesystem(t, dir)
+	f.Set("user:1", "a", time.Hour)
+	f.Set("user:2", "b", 0)
+	f.Set("order:1", "c", time.Hour)
+	if n := f.DeleteByPrefix("user:"); n != 2 {
+		t.Fatalf("Expected 2 keys removed, got: %d", n)
+	}
+	if _, ok := f.Get("order:1"); !ok {
+		t.Fatal("Expected keys outside the prefix to survive")
+	}
+
+	var errs []string
+	f = newFilesystem(t, dir, filesystem.WithErrorHandler(func(op string, err error) { errs = append(errs, op) }))
+	f.Set("bad", "x", time.Hour)
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && path != keep {
+			if data, _ := os.ReadFile(path); strings.Contains(string(data), "bad") {
+				_ = os.WriteFile(path, data[:len(data)-3], 0o600)
+			}
+		}
+		return nil
+	})
+	if _, ok := f.Get("bad"); ok || len(errs) != 1 {
+		t.Fatalf("Expected a truncated file to be a reported miss, got: %v, %v", ok, errs)
+	}
+
+	f.Clear()
+	if _, ok := f.Get("order:1"); ok {
+		t.Fatal("Expected Clear to remove every entry")
+	}
+	if _, err := os.Stat(keep); err != nil {
+		t.Fatalf("Expected Clear to leave foreign files alone, got: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestFlushDrainsAsyncWrites tests that all queued writes are in the backend when Flush returns
+func TestFlushDrainsAsyncWrites(t *testing.T) {
+	backend := &slowSetBackend{Memory: memory.New(), delay: 5 * time.Millisecond}
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Minute), memo.WithAsyncSet(true))
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		_, _ = m.Get(ctx, fmt.Sprintf("k%d", i), func() (any, error) { return i, nil })
+	}
+
+	if err := m.Flush(ctx); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if v, ok := backend.Memory.Get(fmt.Sprintf("k%d", i)); !ok || v != i {
+			t.Fatalf("Expected k%d to be written before Flush returned, got: %v, %v", i, v, ok)
+		}
+	}
+}
+
+// TestFlushRespectsContext tests that Flush gives up when the context is done
+func TestFlushRespectsContext(t *testing.T) {
+	backend := &slowSetBackend{Memory: memory.New(), release: make(chan struct{})}
+	defer close(backend.release)
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Minute), memo.WithAsyncSet(true))
+
+	_, _ = m.Get(context.Background(), "k", func() (any, error) { return "v", nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline error, got: %v", err)
+	}
+}
+
+// TestFlushNoAsync tests that Flush is a no-op without background writes
+func TestFlushNoAsync(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	if err := m.Flush(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+package memo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/gcs"
+)
+
+// fakeGCSObject is an object stored by fakeGCS.
+type fakeGCSObject struct {
+	body       []byte
+	metadata   map[string]string
+	customTime string
+}
+
+// fakeGCS serves the subset of the Cloud Storage JSON API used by
+// gcs.HTTPClient. The first chunk of every resumable upload is only half
+// persisted, to exercise resending.
+type fakeGCS struct {
+	*httptest.Server
+	mu       sync.Mutex
+	objects  map[string]fakeGCSObject // by bucket/name
+	sessions map[string]*fakeGCSUpload
+	puts     int // resumable upload chunks received
+}
+
+type fakeGCSUpload struct {
+	bucket string
+	meta   map[string]any
+	body   []byte
+	size   int
+}
+
+func newFakeGCS(t *testing.T) *fakeGCS {
+	f := &fakeGCS{objects: make(map[string]fakeGCSObject), sessions: make(map[string]*fakeGCSUpload)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeGCS) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := r.URL.EscapedPath()
+	switch {
+	case strings.HasPrefix(path, "/upload/session/"):
+		f.putChunk(w, r, strings.TrimPrefix(path, "/upload/session/"))
+	case strings.HasPrefix(path, "/upload/storage/v1/b/"):
+		bucket := strings.TrimSuffix(strings.TrimPrefix(path, "/upload/storage/v1/b/"), "/o")
+		f.upload(w, r, bucket)
+	case strings.HasPrefix(path, "/storage/v1/b/"):
+		bucket, name, _ := strings.Cut(strings.TrimPrefix(path, "/storage/v1/b/"), "/o")
+		name, _ = url.PathUnescape(strings.TrimPrefix(name, "/"))
+		key := bucket + "/" + name
+		switch {
+		case r.Method == http.MethodGet && name == "":
+			f.list(w, r, bucket)
+		case r.Method == http.MethodGet:
+			obj, ok := f.objects[key]
+			if !ok {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			for k, v := range obj.metadata {
+				w.Header().Set("X-Goog-Meta-"+k, v)
+			}
+			w.Write(obj.body)
+		case r.Method == http.MethodDelete:
+			if _, ok := f.objects[key]; !ok {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			delete(f.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+// object returns the stored object named key, as bucket/name.
+func (f *fakeGCS) object(key string) (fakeGCSObject, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[key]
+	return obj, ok
+}
+
+func (f *fakeGCS) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.objects)
+}
+
+func (f *fakeGCS) upload(w http.ResponseWriter, r *http.Request, bucket string) {
+	switch r.URL.Query().Get("uploadType") {
+	case "multipart":
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		var meta map[string]any
+		metaPart, _ := mr.NextPart()
+		json.NewDecoder(metaPart).Decode(&meta)
+		bodyPart, _ := mr.NextPart()
+		body, _ := io.ReadAll(bodyPart)
+		f.store(bucket, meta, body)
+	case "resumable":
+		var meta map[string]any
+		json.NewDecoder(r.Body).Decode(&meta)
+		size, _ := strconv.Atoi(r.Header.Get("X-Upload-Content-Length"))
+		id := strconv.Itoa(len(f.sessions))
+		f.sessions[id] = &fakeGCSUpload{bucket: bucket, meta: meta, size: size}
+		w.Header().Set("Location", f.URL+"/upload/session/"+id)
+	}
+}
+
+func (f *fakeGCS) putChunk(w http.ResponseWriter, r *http.Request, id string) {
+	f.puts++
+	up := f.sessions[id]
+	var start, end, total int
+	fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total)
+	chunk, _ := io.ReadAll(r.Body)
+	if start != len(up.body) || total != up.size || len(chunk) != end-start+1 {
+		http.Error(w, "bad range", http.StatusBadRequest)
+		return
+	}
+	if start == 0 {
+		chunk = chunk[:len(chunk)/2]
+	}
+	up.body = append(up.body, chunk...)
+	if len(up.body) < up.size {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(up.body)-1))
+		w.WriteHeader(308)
+		return
+	}
+	f.store(up.bucket, up.meta, up.body)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (f *fakeGCS) store(bucket string, meta map[string]any, body []byte) {
+	obj := fakeGCSObject{body: body, metadata: map[string]string{}}
+	if md, ok := meta["metadata"].(map[string]any); ok {
+		for k, v := range md {
+			obj.metadata[k] = v.(string)
+		}
+	}
+	obj.customTime, _ = meta["customTime"].(string)
+	f.objects[bucket+"/"+meta["name"].(string)] = obj
+}
+
+func (f *fakeGCS) list(w http.ResponseWriter, r *http.Request, bucket string) {
+	var names []string
+	for key := range f.objects {
+		if name, ok := strings.CutPrefix(key, bucket+"/"); ok && strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	// Like real page tokens, the token resumes after a name, so deleting
+	// listed objects does not skip others
+	start := sort.SearchStrings(names, r.URL.Query().Get("pageToken"))
+	if start < len(names) && names[start] == r.URL.Query().Get("pageToken") {
+		start++
+	}
+	end := min(start+2, len(names))
+	page := map[string]any{"items": []map[string]string{}}
+	for _, name := range names[start:end] {
+		page["items"] = append(page["items"].([]map[string]string), map[string]string{"name": name})
+	}
+	if end < len(names) {
+		page["nextPageToken"] = names[end-1]
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+// TestGCSBasic tests reads, writes and expiry metadata of the gcs backend over the JSON API
+func TestGCSBasic(t *testing.T) {
+	srv := newFakeGCS(t)
+	g := gcs.New(gcs.NewHTTPClient(srv.Client(), gcs.WithEndpoint(srv.URL)), "artifacts", gcs.WithPrefix("models/"))
+
+	g.Set("a/b", "v", time.Hour)
+	if v, ok := g.Get("a/b"); !ok || v != "v" {
+		t.Fatalf("Expected v, got: %v, %v", v, ok)
+	}
+	obj, ok := srv.object("artifacts/models/a/b")
+	if !ok {
+		t.Fatal("Expected the object under the configured prefix")
+	}
+	exp, err := strconv.ParseInt(obj.metadata[gcs.ExpiresMetadata], 10, 64)
+	if err != nil {
+		t.Fatalf("Expected the expiry in the metadata, got: %q", obj.metadata[gcs.ExpiresMetadata])
+	}
+	if ct, err := time.Parse(time.RFC3339Nano, obj.customTime); err != nil || !ct.Equal(time.Unix(0, exp)) {
+		t.Fatalf("Expected Custom-Time at the expiry, got: %q", obj.customTime)
+	}
+
+	g.Set("short", "v", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := g.Get("short"); ok {
+		t.Fatal("Expected an expired object to be a miss")
+	}
+
+	g.Delete("a/b")
+	if _, ok := g.Get("a/b"); ok {
+		t.Fatal("Expected Delete to remove the object")
+	}
+	g.Delete("a/b") // deleting a missing object is not an error
+}
+
+// TestGCSResumableUpload tests that large values are uploaded in chunks and unpersisted bytes are resent
+func TestGCSResumableUpload(t *testing.T) {
+	srv := newFakeGCS(t)
+	var errs []error
+	g := gcs.New(gcs.NewHTTPClient(srv.Client(), gcs.WithEndpoint(srv.URL), gcs.WithChunkSize(1)), "artifacts",
+		gcs.WithErrorHandler(func(op string, err error) { errs = append(errs, err) }))
+
+	large := bytes.Repeat([]byte("0123456789"), 60_000) // 600 KB in 256 KiB chunks
+	g.Set("model", large, time.Hour)
+	if len(errs) != 0 {
+		t.Fatalf("Expected the upload to succeed, got: %v", errs)
+	}
+	srv.mu.Lock()
+	puts := srv.puts
+	srv.mu.Unlock()
+	if puts != 3 {
+		t.Fatalf("Expected 3 chunk requests, resuming after the half-persisted first chunk, got: %d", puts)
+	}
+	if v, ok := g.Get("model"); !ok || !bytes.Equal(v.([]byte), large) {
+		t.Fatal("Expected the large value to round trip")
+	}
+}
+
+// TestGCSDeleteByPrefix tests that prefix deletes and Clear page through the listing and stay within the prefix
+func TestGCSDeleteByPrefix(t *testing.T) {
+	srv := newFakeGCS(t)
+	g := gcs.New(gcs.NewHTTPClient(srv.Client(), gcs.WithEndpoint(srv.URL)), "artifacts")
+	srv.objects["artifacts/other/x"] = fakeGCSObject{}
+
+	for i := 0; i < 5; i++ {
+		g.Set("report:"+strconv.Itoa(i), i, time.Hour)
+	}
+	g.Set("model:a", "a", time.Hour)
+
+	if n := g.DeleteByPrefix("report:"); n != 5 {
+		t.Fatalf("Expected 5 objects removed, got: %d", n)
+	}
+	g.Clear()
+	if n := srv.len(); n != 1 {
+		t.Fatalf("Expected Clear to keep only objects outside the prefix, got: %d objects", n)
+	}
+}
+
+// TestGCSFactory tests that the "gcs" backend is registered and uses the emulator from the environment
+func TestGCSFactory(t *testing.T) {
+	srv := newFakeGCS(t)
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(srv.URL, "http://"))
+
+	backend, err := backends.NewBackend("gcs")
+	if err != nil {
+		t.Fatalf("Expected the gcs backend to be registered, got: %v", err)
+	}
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+	if v, err := m.Get(ctx, "k", func() (any, error) { return "v", nil }); err != nil || v != "v" {
+		t.Fatalf("Expected v, got: %v, %v", v, err)
+	}
+	if _, ok := srv.object("gomemo/gomemo/k"); !ok {
+		t.Fatal("Expected the object in the emulator's gomemo bucket")
+	}
+	if v, err := m.Get(ctx, "k", func() (any, error) { return "recomputed", nil }); err != nil || v != "v" {
+		t.Fatalf("Expected the stored value, got: %v, %v", v, err)
+	}
+}
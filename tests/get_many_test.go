@@ -0,0 +1,77 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestGetManyLoadsOnlyMissingKeys tests that hits are served and misses are loaded in a single call
+func TestGetManyLoadsOnlyMissingKeys(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute), memo.WithMetrics(true))
+	ctx := context.Background()
+
+	_, _ = m.Get(ctx, "user:1", func() (any, error) { return "alice", nil })
+
+	var batches [][]string
+	loader := func(missing []string) (map[string]any, error) {
+		batches = append(batches, slices.Clone(missing))
+		out := make(map[string]any, len(missing))
+		for _, key := range missing {
+			if key != "user:404" {
+				out[key] = "loaded-" + key
+			}
+		}
+		return out, nil
+	}
+
+	got, err := m.GetMany(ctx, []string{"user:1", "user:2", "user:3", "user:2", "user:404"}, loader)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := map[string]any{"user:1": "alice", "user:2": "loaded-user:2", "user:3": "loaded-user:3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got: %v", want, got)
+	}
+	if len(batches) != 1 || !reflect.DeepEqual(batches[0], []string{"user:2", "user:3", "user:404"}) {
+		t.Fatalf("Expected one load of the missing keys, got: %v", batches)
+	}
+
+	// Loaded keys are cached, the one the loader left out is not
+	if _, err := m.GetMany(ctx, []string{"user:2", "user:3", "user:404"}, loader); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(batches) != 2 || !reflect.DeepEqual(batches[1], []string{"user:404"}) {
+		t.Fatalf("Expected only user:404 to be reloaded, got: %v", batches)
+	}
+
+	if v, err := m.Get(ctx, "user:2", func() (any, error) { return nil, errors.New("not cached") }); err != nil || v != "loaded-user:2" {
+		t.Fatalf("Expected GetMany results to be visible to Get, got: %v, %v", v, err)
+	}
+}
+
+// TestGetManyLoaderError tests that a failing loader returns its error and caches nothing
+func TestGetManyLoaderError(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	errLoad := errors.New("db down")
+
+	if _, err := m.GetMany(context.Background(), []string{"a"}, func([]string) (map[string]any, error) {
+		return nil, errLoad
+	}); !errors.Is(err, errLoad) {
+		t.Fatalf("Expected loader error, got: %v", err)
+	}
+
+	calls := 0
+	_, _ = m.GetMany(context.Background(), []string{"a"}, func(missing []string) (map[string]any, error) {
+		calls++
+		return map[string]any{"a": 1}, nil
+	})
+	if calls != 1 {
+		t.Fatalf("Expected the failed key to be loaded again, got %d calls", calls)
+	}
+}
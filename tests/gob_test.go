@@ -0,0 +1,82 @@
+package memo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+)
+
+type gobUser struct {
+	ID   int
+	Name string
+}
+
+type gobOrder struct {
+	ID    string
+	Items []string
+	Total float64
+}
+
+type gobUnregistered struct {
+	Secret string
+}
+
+// TestAutoGobRegister tests that values computed through a memory-backed memoizer
+// can later be stored in and decoded from redis without manual gob.Register
+func TestAutoGobRegister(t *testing.T) {
+	m := memo.New(memo.WithBackend(memory.New()), memo.WithTTL(5*time.Second))
+	ctx := context.Background()
+
+	values := map[string]any{
+		"user":  gobUser{ID: 1, Name: "ada"},
+		"order": &gobOrder{ID: "o-1", Items: []string{"a", "b"}, Total: 9.5},
+	}
+	for key, v := range values {
+		if _, err := m.Get(ctx, key, func() (any, error) { return v, nil }); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	srv, _ := newRedis(t)
+	rb := redis.New(srv.Addr(), "gob:", 0)
+	for key, v := range values {
+		rb.Set(key, v, time.Minute)
+	}
+
+	got, ok := rb.Get("user")
+	if !ok {
+		t.Fatal("Expected user to decode from redis")
+	}
+	if u, isUser := got.(gobUser); !isUser || u.Name != "ada" {
+		t.Fatalf("Expected gobUser{Name: ada}, got: %#v", got)
+	}
+
+	got, ok = rb.Get("order")
+	if !ok {
+		t.Fatal("Expected order to decode from redis")
+	}
+	if o, isOrder := got.(*gobOrder); !isOrder || o.Total != 9.5 || len(o.Items) != 2 {
+		t.Fatalf("Expected *gobOrder, got: %#v", got)
+	}
+}
+
+// TestAutoGobRegisterOptOut tests that no registration happens when disabled
+func TestAutoGobRegisterOptOut(t *testing.T) {
+	m := memo.New(memo.WithTTL(5*time.Second), memo.WithAutoGobRegister(false))
+	if _, err := m.Get(context.Background(), "secret", func() (any, error) {
+		return gobUnregistered{Secret: "x"}, nil
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	srv, _ := newRedis(t)
+	rb := redis.New(srv.Addr(), "gob:", 0)
+	rb.Set("secret", gobUnregistered{Secret: "x"}, time.Minute)
+	if _, ok := rb.Get("secret"); ok {
+		t.Fatal("Expected unregistered type to fail to round-trip through redis")
+	}
+}
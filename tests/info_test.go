@@ -0,0 +1,59 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestGetWithInfoReportsMetadata tests that hits, TTL, version and age are reported
+func TestGetWithInfoReportsMetadata(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	backend := memory.New(memory.WithClock(clock), memory.WithAccessCounts())
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+	load := func() (any, error) { return "v", nil }
+
+	v, info, err := m.GetWithInfo(ctx, "k", load)
+	if err != nil || v != "v" {
+		t.Fatalf("Expected v, got: %v, %v", v, err)
+	}
+	if info.Hit || info.Version != 1 {
+		t.Fatalf("Expected a computed first version, got: %+v", info)
+	}
+
+	// Store with a clock running 10s behind, so the entry is 10s old by now
+	clock.Advance(-10 * time.Second)
+	m.Set(ctx, "k", "v2")
+	v, info, err = m.GetWithInfo(ctx, "k", load)
+	if err != nil || v != "v2" {
+		t.Fatalf("Expected v2, got: %v, %v", v, err)
+	}
+	if !info.Hit || info.Version != 2 {
+		t.Fatalf("Expected a hit on the second version, got: %+v", info)
+	}
+	if info.Age < 9*time.Second || info.Age > 11*time.Second {
+		t.Fatalf("Expected an age of about 10s, got: %v", info.Age)
+	}
+	if info.TTL <= 0 || info.TTL > time.Minute {
+		t.Fatalf("Expected a TTL within the minute, got: %v", info.TTL)
+	}
+	if count, _ := backend.AccessCount("k"); count != 1 {
+		t.Fatalf("Expected reading metadata not to count as an access, got: %d", count)
+	}
+}
+
+// TestGetWithInfoError tests that a failed compute is reported as a miss
+func TestGetWithInfoError(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	errLoad := errors.New("load failed")
+
+	_, info, err := m.GetWithInfo(context.Background(), "k", func() (any, error) { return nil, errLoad })
+	if !errors.Is(err, errLoad) || info.Hit {
+		t.Fatalf("Expected a missed error, got: %+v, %v", info, err)
+	}
+}
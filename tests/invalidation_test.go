@@ -0,0 +1,298 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+	"github.com/ldaidone/gomemo/pkg/invalidation"
+	"github.com/ldaidone/gomemo/pkg/invalidation/kafka"
+	"github.com/ldaidone/gomemo/pkg/invalidation/nats"
+)
+
+// newBusMemoizers creates n memoizers with their own memory backends sharing bus.
+func newBusMemoizers(t *testing.T, bus invalidation.Bus, n int) []*memo.Memoizer {
+	t.Helper()
+	ms := make([]*memo.Memoizer, n)
+	for i := range ms {
+		m, err := memo.NewWithError(memo.WithBackend(memory.New()), memo.WithInvalidationBus(bus))
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		t.Cleanup(func() { _ = m.Close() })
+		ms[i] = m
+	}
+	return ms
+}
+
+// waitMiss waits until key is no longer cached in m.
+func waitMiss(t *testing.T, m *memo.Memoizer, key string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for m.Has(key) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s to be invalidated", key)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestInvalidationBusPropagates tests that invalidations on one memoizer reach the others
+func TestInvalidationBusPropagates(t *testing.T) {
+	ms := newBusMemoizers(t, invalidation.NewMemoryBus(), 2)
+	a, b := ms[0], ms[1]
+	ctx := context.Background()
+
+	for _, m := range ms {
+		m.Set(ctx, "k", "v")
+		m.Set(ctx, "user:1:name", "ann")
+		m.SetWithTags(ctx, "order:7", "o", "orders")
+		m.Set(ctx, "other", "v")
+	}
+
+	a.Delete("k")
+	if b.Has("k") {
+		t.Fatal("Expected Delete to reach the other memoizer")
+	}
+	if _, err := a.DeleteByPrefix("user:1:"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if b.Has("user:1:name") {
+		t.Fatal("Expected DeleteByPrefix to reach the other memoizer")
+	}
+	if _, err := a.InvalidateTag("orders"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if b.Has("order:7") {
+		t.Fatal("Expected InvalidateTag to reach the other memoizer")
+	}
+	if !b.Has("other") {
+		t.Fatal("Expected unrelated keys to stay cached")
+	}
+	a.Clear()
+	if b.Has("other") {
+		t.Fatal("Expected Clear to reach the other memoizer")
+	}
+
+	ns := b.Namespace("tenant")
+	ns.Set(ctx, "settings", "s")
+	a.Namespace("tenant").Invalidate(ctx)
+	calls := 0
+	if v, _ := ns.Get(ctx, "settings", func() (any, error) { calls++; return "fresh", nil }); v != "fresh" || calls != 1 {
+		t.Fatalf("Expected the namespace invalidated, got: %v", v)
+	}
+}
+
+// TestInvalidationBusExternalProducer tests that messages from outside the application are applied
+func TestInvalidationBusExternalProducer(t *testing.T) {
+	bus := invalidation.NewMemoryBus()
+	ms := newBusMemoizers(t, bus, 2)
+	ctx := context.Background()
+	for _, m := range ms {
+		m.Set(ctx, "user:42", "ann")
+	}
+
+	msg, err := invalidation.Decode([]byte(`{"kind":"key","value":"user:42"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := bus.Publish(ctx, msg); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for _, m := range ms {
+		if m.Has("user:42") {
+			t.Fatal("Expected every memoizer to drop the key")
+		}
+	}
+
+	for _, bad := range []string{`{"kind":"key"}`, `{"kind":"nope","value":"x"}`, `not json`} {
+		if _, err := invalidation.Decode([]byte(bad)); !errors.Is(err, invalidation.ErrInvalidMessage) {
+			t.Fatalf("Expected ErrInvalidMessage for %s, got: %v", bad, err)
+		}
+	}
+}
+
+// TestRedisBus tests invalidations over Redis pub/sub
+func TestRedisBus(t *testing.T) {
+	_, client := newRedis(t)
+	ms := newBusMemoizers(t, redis.NewBus(client, ""), 2)
+	ctx := context.Background()
+	for _, m := range ms {
+		m.Set(ctx, "k", "v")
+	}
+
+	ms[0].Delete("k")
+	waitMiss(t, ms[1], "k")
+}
+
+// fakeNATS is an in-process stand-in for a NATS connection.
+type fakeNATS struct {
+	mu   sync.Mutex
+	subs map[int]func([]byte)
+	next int
+}
+
+func (c *fakeNATS) Publish(subject string, data []byte) error {
+	c.mu.Lock()
+	var subs []func([]byte)
+	for _, fn := range c.subs {
+		subs = append(subs, fn)
+	}
+	c.mu.Unlock()
+	for _, fn := range subs {
+		fn(data)
+	}
+	return nil
+}
+
+func (c *fakeNATS) Subscribe(subject string, fn func([]byte)) (nats.Subscription, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.subs == nil {
+		c.subs = make(map[int]func([]byte))
+	}
+	c.next++
+	id := c.next
+	c.subs[id] = fn
+	return fakeNATSSub{c, id}, nil
+}
+
+type fakeNATSSub struct {
+	c  *fakeNATS
+	id int
+}
+
+func (s fakeNATSSub) Unsubscribe() error {
+	s.c.mu.Lock()
+	delete(s.c.subs, s.id)
+	s.c.mu.Unlock()
+	return nil
+}
+
+// TestNATSBus tests invalidations over the NATS bus
+func TestNATSBus(t *testing.T) {
+	conn := &fakeNATS{}
+	var errs []string
+	bus := nats.New(conn, nats.WithErrorHandler(func(op string, err error) { errs = append(errs, op) }))
+	ms := newBusMemoizers(t, bus, 2)
+	ctx := context.Background()
+	for _, m := range ms {
+		m.SetWithTags(ctx, "k", "v", "t")
+	}
+
+	if _, err := ms[0].InvalidateTag("t"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if ms[1].Has("k") {
+		t.Fatal("Expected the tag invalidation to reach the other memoizer")
+	}
+	_ = conn.Publish(nats.DefaultSubject, []byte("garbage"))
+	if len(errs) != 2 || errs[0] != "decode" {
+		t.Fatalf("Expected a decode error per subscriber, got: %v", errs)
+	}
+
+	_ = ms[1].Close()
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if len(conn.subs) != 1 {
+		t.Fatalf("Expected Close to unsubscribe, got: %d subscriptions", len(conn.subs))
+	}
+}
+
+// fakeKafka is a single-partition topic with one reader position per reader.
+type fakeKafka struct {
+	mu      sync.Mutex
+	records [][]byte
+	keys    []string
+	fail    int // reads left to fail
+}
+
+func (k *fakeKafka) Write(ctx context.Context, key, value []byte) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.records = append(k.records, value)
+	k.keys = append(k.keys, string(key))
+	return nil
+}
+
+// reader returns a reader starting at the end of the topic.
+func (k *fakeKafka) reader() *fakeKafkaReader {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return &fakeKafkaReader{k: k, pos: len(k.records)}
+}
+
+type fakeKafkaReader struct {
+	k   *fakeKafka
+	pos int
+}
+
+func (r *fakeKafkaReader) Read(ctx context.Context) ([]byte, error) {
+	for {
+		r.k.mu.Lock()
+		if r.k.fail > 0 {
+			r.k.fail--
+			r.k.mu.Unlock()
+			return nil, errors.New("broker unavailable")
+		}
+		if r.pos < len(r.k.records) {
+			v := r.k.records[r.pos]
+			r.pos++
+			r.k.mu.Unlock()
+			return v, nil
+		}
+		r.k.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// TestKafkaBus tests invalidations over the Kafka bus, including a failing read
+func TestKafkaBus(t *testing.T) {
+	topic := &fakeKafka{fail: 1}
+	var mu sync.Mutex
+	var errs []string
+	onError := kafka.WithErrorHandler(func(op string, err error) {
+		mu.Lock()
+		errs = append(errs, op)
+		mu.Unlock()
+	})
+	ctx := context.Background()
+
+	var ms []*memo.Memoizer
+	for i := 0; i < 2; i++ {
+		bus := kafka.New(topic, topic.reader(), onError, kafka.WithRetryDelay(time.Millisecond))
+		ms = append(ms, newBusMemoizers(t, bus, 1)...)
+		if _, err := bus.Subscribe(ctx, func(invalidation.Message) {}); !errors.Is(err, kafka.ErrSubscribed) {
+			t.Fatalf("Expected ErrSubscribed for a second subscriber, got: %v", err)
+		}
+	}
+	for _, m := range ms {
+		m.Set(ctx, "user:1", "ann")
+	}
+
+	ms[0].Delete("user:1")
+	waitMiss(t, ms[1], "user:1")
+	if ms[0].Has("user:1") {
+		t.Fatal("Expected the publisher to drop its own entry")
+	}
+	topic.mu.Lock()
+	key := topic.keys[0]
+	topic.mu.Unlock()
+	if key != "user:1" {
+		t.Fatalf("Expected records keyed by the invalidated key, got: %q", key)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 1 || errs[0] != "read" {
+		t.Fatalf("Expected the failed read to be reported once, got: %v", errs)
+	}
+}
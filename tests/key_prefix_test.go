@@ -0,0 +1,81 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/faketest"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestKeyPrefixIsolatesMemoizers tests that memoizers with different prefixes share a backend without collisions
+func TestKeyPrefixIsolatesMemoizers(t *testing.T) {
+	b := memory.New()
+	orders := memo.New(memo.WithBackend(b), memo.WithTTL(time.Minute), memo.WithKeyPrefix("orders:"))
+	users := memo.New(memo.WithBackend(b), memo.WithTTL(time.Minute), memo.WithKeyPrefix("users:"))
+	ctx := context.Background()
+
+	orders.Set(ctx, "1", "order")
+	users.Set(ctx, "1", "user")
+
+	if v, ok := b.Get("orders:1"); !ok || v != "order" {
+		t.Fatalf("Expected order under orders:1, got: %v, %v", v, ok)
+	}
+	if v, ok := b.Get("users:1"); !ok || v != "user" {
+		t.Fatalf("Expected user under users:1, got: %v, %v", v, ok)
+	}
+
+	v, err := orders.Get(ctx, "1", func() (any, error) { return "recomputed", nil })
+	if err != nil || v != "order" {
+		t.Fatalf("Expected cached order, got: %v, %v", v, err)
+	}
+
+	orders.Clear()
+	if _, ok := b.Get("orders:1"); ok {
+		t.Fatalf("Expected orders:1 to be cleared")
+	}
+	if !users.Has("1") {
+		t.Fatalf("Expected users:1 to survive Clear on another prefix")
+	}
+}
+
+// TestKeyPrefixGetMany tests that batch lookups use prefixed keys and return unprefixed ones
+func TestKeyPrefixGetMany(t *testing.T) {
+	b := memory.New()
+	m := memo.New(memo.WithBackend(b), memo.WithTTL(time.Minute), memo.WithKeyPrefix("p:"))
+	ctx := context.Background()
+
+	m.Set(ctx, "a", 1)
+	got, err := m.GetMany(ctx, []string{"a", "b"}, func(missing []string) (map[string]any, error) {
+		return map[string]any{"b": 2}, nil
+	})
+	if err != nil || got["a"] != 1 || got["b"] != 2 {
+		t.Fatalf("Expected a=1 and b=2, got: %v, %v", got, err)
+	}
+	if _, ok := b.Get("p:b"); !ok {
+		t.Fatalf("Expected loaded value stored under p:b")
+	}
+}
+
+// TestKeyPrefixClearWithoutPrefixDeleter tests that Clear leaves a shared backend alone when it cannot delete by prefix
+func TestKeyPrefixClearWithoutPrefixDeleter(t *testing.T) {
+	b := faketest.New()
+	var clearErr error
+	orders := memo.New(memo.WithBackend(b), memo.WithTTL(time.Minute), memo.WithKeyPrefix("orders:"),
+		memo.WithBackendErrorHandler(func(op, key string, err error) { clearErr = err }))
+	users := memo.New(memo.WithBackend(b), memo.WithTTL(time.Minute), memo.WithKeyPrefix("users:"))
+	ctx := context.Background()
+
+	users.Set(ctx, "1", "user")
+	orders.Clear()
+
+	if !users.Has("1") {
+		t.Fatalf("Expected users:1 to survive Clear on another prefix")
+	}
+	if !errors.Is(clearErr, errors.ErrUnsupported) {
+		t.Fatalf("Expected ErrUnsupported, got: %v", clearErr)
+	}
+}
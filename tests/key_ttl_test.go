@@ -0,0 +1,61 @@
+package memo
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestSetKeyTTL tests that a per-key TTL override applies on recompute while other keys keep the default
+func TestSetKeyTTL(t *testing.T) {
+	m := memo.New(memo.WithTTL(5 * time.Second))
+	ctx := context.Background()
+
+	var shortCalls, defaultCalls int32
+	shortFn := func() (any, error) {
+		atomic.AddInt32(&shortCalls, 1)
+		return "short", nil
+	}
+	defaultFn := func() (any, error) {
+		atomic.AddInt32(&defaultCalls, 1)
+		return "default", nil
+	}
+
+	// The override only affects future writes: the current entry keeps its TTL
+	_, _ = m.Get(ctx, "short", shortFn)
+	m.SetKeyTTL("short", 30*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	_, _ = m.Get(ctx, "short", shortFn)
+	if atomic.LoadInt32(&shortCalls) != 1 {
+		t.Fatalf("Expected existing entry to keep its original TTL, got %d calls", shortCalls)
+	}
+
+	// After a recompute the override takes effect
+	m.Delete("short")
+	_, _ = m.Get(ctx, "short", shortFn)
+	_, _ = m.Get(ctx, "other", defaultFn)
+	time.Sleep(50 * time.Millisecond)
+
+	_, _ = m.Get(ctx, "short", shortFn)
+	_, _ = m.Get(ctx, "other", defaultFn)
+
+	if atomic.LoadInt32(&shortCalls) != 3 {
+		t.Fatalf("Expected overridden key to expire and recompute, got %d calls", shortCalls)
+	}
+	if atomic.LoadInt32(&defaultCalls) != 1 {
+		t.Fatalf("Expected other key to use the default TTL, got %d calls", defaultCalls)
+	}
+
+	// Removing the override restores the default TTL
+	m.SetKeyTTL("short", 0)
+	m.Delete("short")
+	_, _ = m.Get(ctx, "short", shortFn)
+	time.Sleep(50 * time.Millisecond)
+	_, _ = m.Get(ctx, "short", shortFn)
+	if atomic.LoadInt32(&shortCalls) != 4 {
+		t.Fatalf("Expected default TTL after removing override, got %d calls", shortCalls)
+	}
+}
@@ -0,0 +1,213 @@
+package memo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/layered"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+)
+
+// TestLayeredPromotion tests that L2 hits are promoted into L1 with the shorter L1 TTL
+func TestLayeredPromotion(t *testing.T) {
+	srv, client := newRedis(t)
+	l1 := memory.New()
+	defer l1.Close()
+	l2 := redis.NewWithClient(client, "test:")
+	b := layered.New(l1, l2, layered.WithL1TTL(time.Second))
+
+	l2.Set("k", "v", time.Hour)
+	if v, ok := b.Get("k"); !ok || v != "v" {
+		t.Fatalf("Expected v from L2, got: %v, %v", v, ok)
+	}
+	entry, ok := l1.GetEntry("k")
+	if !ok || entry.TTLRemaining() > time.Second {
+		t.Fatalf("Expected the value promoted into L1 for at most a second, got: %v, %v", entry.TTLRemaining(), ok)
+	}
+
+	// Served from L1 even once L2 lost the key
+	srv.Del("test:k")
+	if v, ok := b.Get("k"); !ok || v != "v" {
+		t.Fatalf("Expected v from L1, got: %v, %v", v, ok)
+	}
+	if _, ok := b.Get("missing"); ok {
+		t.Fatal("Expected a miss from both layers")
+	}
+	if s := b.Stats(); s.L1Hits != 1 || s.L2Hits != 1 || s.Misses != 1 {
+		t.Fatalf("Expected one read per layer and one miss, got: %+v", s)
+	}
+}
+
+// TestLayeredWrites tests that writes and deletes reach both layers
+func TestLayeredWrites(t *testing.T) {
+	_, client := newRedis(t)
+	l1 := memory.New()
+	defer l1.Close()
+	l2 := redis.NewWithClient(client, "test:")
+	b := layered.New(l1, l2, layered.WithL1TTL(time.Minute))
+
+	b.Set("short", "v", 10*time.Second)
+	b.Set("long", "v", time.Hour)
+	if _, ok := l2.Get("long"); !ok {
+		t.Fatal("Expected Set to write L2")
+	}
+	if entry, _ := l1.GetEntry("short"); entry.TTLRemaining() > 10*time.Second {
+		t.Fatalf("Expected L1 to keep a shorter TTL, got: %v", entry.TTLRemaining())
+	}
+	if entry, _ := l1.GetEntry("long"); entry.TTLRemaining() > time.Minute {
+		t.Fatalf("Expected L1 to cap the TTL, got: %v", entry.TTLRemaining())
+	}
+
+	b.Delete("long")
+	if _, ok := l1.Get("long"); ok {
+		t.Fatal("Expected Delete to remove the L1 copy")
+	}
+	if _, ok := l2.Get("long"); ok {
+		t.Fatal("Expected Delete to remove the L2 copy")
+	}
+	b.Clear()
+	if _, ok := b.Get("short"); ok {
+		t.Fatal("Expected Clear to empty both layers")
+	}
+}
+
+// TestLayeredV2Errors tests that the context-aware form reports L2 failures and keeps L1 consistent
+func TestLayeredV2Errors(t *testing.T) {
+	srv, client := newRedis(t)
+	l1 := memory.New()
+	defer l1.Close()
+	b := layered.New(l1, redis.NewWithClient(client, "test:", redis.WithErrorHandler(func(string, error) {})))
+	ctx := context.Background()
+
+	srv.Close()
+	if err := b.V2().Set(ctx, "k", "v", time.Minute); err == nil {
+		t.Fatal("Expected the L2 write error")
+	}
+	if _, ok := l1.Get("k"); ok {
+		t.Fatal("Expected a failed L2 write to leave L1 alone")
+	}
+	if _, _, err := b.V2().Get(ctx, "k"); err == nil {
+		t.Fatal("Expected the L2 read error")
+	}
+}
+
+// TestLayeredWithMemoizer tests a memoizer over a layered backend
+func TestLayeredWithMemoizer(t *testing.T) {
+	_, client := newRedis(t)
+	l2 := redis.NewWithClient(client, "test:")
+	ctx := context.Background()
+
+	calls := 0
+	compute := func() (any, error) {
+		calls++
+		return "v", nil
+	}
+	// Two processes sharing L2 with their own L1
+	for i := 0; i < 2; i++ {
+		l1 := memory.New()
+		defer l1.Close()
+		b := layered.New(l1, l2)
+		m := memo.New(memo.WithBackend(b), memo.WithTTL(time.Minute))
+		for j := 0; j < 2; j++ {
+			if v, err := m.Get(ctx, "k", compute); err != nil || v != "v" {
+				t.Fatalf("Expected v, got: %v, %v", v, err)
+			}
+		}
+		if i == 1 && b.Stats().L2Hits != 1 {
+			t.Fatalf("Expected the second process to read L2 once, got: %+v", b.Stats())
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("Expected one computation, got: %d", calls)
+	}
+}
+
+// TestLayeredDefaultTTLs tests that each layer applies its own default TTL while explicit TTLs reach L2
+func TestLayeredDefaultTTLs(t *testing.T) {
+	srv, client := newRedis(t)
+	l1 := memory.New(memory.WithDefaultTTL(time.Second))
+	defer l1.Close()
+	l2 := redis.NewWithClient(client, "test:", redis.WithDefaultTTL(time.Hour))
+	b := layered.New(l1, l2)
+
+	b.Set("k", "v", 0)
+	if ttl := srv.TTL("test:k"); ttl != time.Hour {
+		t.Fatalf("Expected L2 to use its default TTL, got: %v", ttl)
+	}
+	if entry, ok := l1.GetEntry("k"); !ok || entry.TTLRemaining() > time.Second {
+		t.Fatalf("Expected L1 to use its shorter TTL, got: %v, %v", entry.TTLRemaining(), ok)
+	}
+
+	// Back-fill after L1 lost the key
+	l1.Delete("k")
+	if v, ok := b.Get("k"); !ok || v != "v" {
+		t.Fatalf("Expected v from L2, got: %v, %v", v, ok)
+	}
+	if entry, ok := l1.GetEntry("k"); !ok || entry.TTLRemaining() > time.Second {
+		t.Fatalf("Expected the back-filled value to use the L1 TTL, got: %v, %v", entry.TTLRemaining(), ok)
+	}
+	if ttl := srv.TTL("test:k"); ttl <= time.Minute {
+		t.Fatalf("Expected back-fill to leave the L2 TTL alone, got: %v", ttl)
+	}
+
+	b.Set("explicit", "v", time.Minute)
+	if ttl := srv.TTL("test:explicit"); ttl != time.Minute {
+		t.Fatalf("Expected L2 to keep an explicit TTL, got: %v", ttl)
+	}
+}
+
+// TestLayeredMemoizerTTLs tests that the memoizer stores with L2's default TTL unless a more specific TTL applies
+func TestLayeredMemoizerTTLs(t *testing.T) {
+	srv, client := newRedis(t)
+	l1 := memory.New()
+	defer l1.Close()
+	l2 := redis.NewWithClient(client, "test:", redis.WithDefaultTTL(time.Hour))
+	m := memo.New(memo.WithBackend(layered.New(l1, l2)), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	_, _ = m.Get(ctx, "default", func() (any, error) { return "v", nil })
+	if ttl := srv.TTL("test:default"); ttl != time.Hour {
+		t.Fatalf("Expected L2's default TTL, got: %v", ttl)
+	}
+
+	m.SetKeyTTL("short", 50*time.Millisecond)
+	_, _ = m.Get(ctx, "short", func() (any, error) { return "old", nil })
+	if ttl := srv.TTL("test:short"); ttl != 50*time.Millisecond {
+		t.Fatalf("Expected the per-key TTL on L2, got: %v", ttl)
+	}
+	srv.FastForward(100 * time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if v, _ := m.Get(ctx, "short", func() (any, error) { return "new", nil }); v != "new" {
+		t.Fatalf("Expected the short-lived value to be recomputed, got: %v", v)
+	}
+}
+
+// TestLayeredPromotionKeepsL2Expiry tests that an L2 hit is promoted into L1 no longer than it has left in L2
+func TestLayeredPromotionKeepsL2Expiry(t *testing.T) {
+	_, client := newRedis(t)
+	l1 := memory.New()
+	defer l1.Close()
+	l2 := redis.NewWithClient(client, "test:")
+	b := layered.New(l1, l2, layered.WithL1TTL(time.Hour))
+
+	l2.Set("k", "v", 2*time.Second)
+	if v, ok := b.Get("k"); !ok || v != "v" {
+		t.Fatalf("Expected v from L2, got: %v, %v", v, ok)
+	}
+	entry, ok := l1.GetEntry("k")
+	if !ok || entry.TTLRemaining() > 2*time.Second {
+		t.Fatalf("Expected the value promoted into L1 for at most its L2 TTL, got: %v, %v", entry.TTLRemaining(), ok)
+	}
+
+	l2.Set("k2", "v2", 2*time.Second)
+	if v, ok, err := b.V2().Get(context.Background(), "k2"); !ok || err != nil || v != "v2" {
+		t.Fatalf("Expected v2 from L2, got: %v, %v, %v", v, ok, err)
+	}
+	entry, ok = l1.GetEntry("k2")
+	if !ok || entry.TTLRemaining() > 2*time.Second {
+		t.Fatalf("Expected the V2 promotion to keep the L2 TTL, got: %v, %v", entry.TTLRemaining(), ok)
+	}
+}
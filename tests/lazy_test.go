@@ -0,0 +1,84 @@
+package memo
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestGetLazyComputesOnce tests that the thunk defers computation until it is
+// called and then computes exactly once, caching in both the backend and the thunk
+func TestGetLazyComputesOnce(t *testing.T) {
+	backend := memory.New()
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(5*time.Second), memo.WithMetrics(true))
+
+	var calls int32
+	thunk := m.GetLazy(context.Background(), "lazy-key", func() (any, error) {
+		atomic.AddInt32(&calls, 1)
+		return "lazy-value", nil
+	})
+
+	// Nothing should be computed before the first deref
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Fatalf("Expected no computation before deref, got %d calls", calls)
+	}
+	if _, ok := backend.Get("lazy-key"); ok {
+		t.Fatal("Expected backend to be empty before deref")
+	}
+
+	for i := 0; i < 3; i++ {
+		v, err := thunk()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if v != "lazy-value" {
+			t.Fatalf("Expected 'lazy-value', got: %v", v)
+		}
+	}
+
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("Expected fn to be called once, was called %d times", calls)
+	}
+
+	// The value must have been stored in the backend
+	v, ok := backend.Get("lazy-key")
+	if !ok || v != "lazy-value" {
+		t.Fatalf("Expected backend to hold 'lazy-value', got: %v (ok=%v)", v, ok)
+	}
+
+	// Repeated derefs are served locally and never reach the memoizer again
+	if requests := m.Metrics().Snapshot().Requests; requests != 1 {
+		t.Fatalf("Expected 1 memoizer request, got: %d", requests)
+	}
+}
+
+// TestGetLazySharesBackendCache tests that a thunk reuses a value already cached by Get
+func TestGetLazySharesBackendCache(t *testing.T) {
+	m := memo.New(memo.WithTTL(5 * time.Second))
+	ctx := context.Background()
+
+	var calls int32
+	fn := func() (any, error) {
+		atomic.AddInt32(&calls, 1)
+		return 42, nil
+	}
+
+	if _, err := m.Get(ctx, "shared-lazy", fn); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	v, err := m.GetLazy(ctx, "shared-lazy", fn)()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v != 42 {
+		t.Fatalf("Expected 42, got: %v", v)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("Expected fn to be called once, was called %d times", calls)
+	}
+}
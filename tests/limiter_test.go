@@ -0,0 +1,156 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestMaxConcurrentLoads tests that no more than the configured number of compute functions run at once
+func TestMaxConcurrentLoads(t *testing.T) {
+	m := memo.New(memo.WithMaxConcurrentLoads(2))
+	ctx := context.Background()
+
+	var running, peak atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := m.Get(ctx, fmt.Sprintf("k%d", i), func() (any, error) {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+				return i, nil
+			})
+			if err != nil {
+				t.Errorf("Expected queued load to succeed, got: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p > 2 {
+		t.Fatalf("Expected at most 2 concurrent loads, got: %d", p)
+	}
+}
+
+// TestMaxConcurrentLoadsFailFast tests that misses beyond the limit fail with ErrTooManyLoads and are not negatively cached
+func TestMaxConcurrentLoadsFailFast(t *testing.T) {
+	m := memo.New(
+		memo.WithMaxConcurrentLoads(1),
+		memo.WithMaxQueuedLoads(-1),
+		memo.WithErrorTTL(time.Minute),
+		memo.WithMetrics(true),
+	)
+	ctx := context.Background()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		_, _ = m.Get(ctx, "slow", func() (any, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+	}()
+	<-started
+
+	fn := func() (any, error) { return 2, nil }
+	if _, err := m.Get(ctx, "other", fn); !errors.Is(err, memo.ErrTooManyLoads) {
+		close(release)
+		t.Fatalf("Expected ErrTooManyLoads, got: %v", err)
+	}
+	close(release)
+	if n := m.Metrics().Snapshot().LoadRejections; n != 1 {
+		t.Fatalf("Expected 1 load rejection, got: %d", n)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		v, err := m.Get(ctx, "other", fn)
+		if err == nil {
+			if v != 2 {
+				t.Fatalf("Expected computed value, got: %v", v)
+			}
+			break
+		}
+		if !errors.Is(err, memo.ErrTooManyLoads) || time.Now().After(deadline) {
+			t.Fatalf("Expected rejection not to be cached, got: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestMaxQueuedLoads tests that only the configured number of misses wait for a load slot
+func TestMaxQueuedLoads(t *testing.T) {
+	m := memo.New(memo.WithMaxConcurrentLoads(1), memo.WithMaxQueuedLoads(1))
+	ctx := context.Background()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		_, _ = m.Get(ctx, "slow", func() (any, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+	}()
+	<-started
+
+	queued := make(chan error, 1)
+	go func() {
+		_, err := m.Get(ctx, "queued", func() (any, error) { return 2, nil })
+		queued <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	_, err := m.Get(ctx, "rejected", func() (any, error) { return 3, nil })
+	close(release)
+	if !errors.Is(err, memo.ErrTooManyLoads) {
+		t.Fatalf("Expected ErrTooManyLoads beyond the queue, got: %v", err)
+	}
+	if err := <-queued; err != nil {
+		t.Fatalf("Expected queued load to succeed, got: %v", err)
+	}
+}
+
+// TestMaxConcurrentLoadsContextErrorNotCached tests that a caller giving up while queued for a load slot does not cache its context error
+func TestMaxConcurrentLoadsContextErrorNotCached(t *testing.T) {
+	m := memo.New(memo.WithMaxConcurrentLoads(1), memo.WithErrorTTL(time.Minute))
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = m.Get(context.Background(), "busy", func() (any, error) {
+			close(started)
+			<-release
+			return "v", nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := m.Get(ctx, "k", func() (any, error) { return "v", nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the caller's deadline error, got: %v", err)
+	}
+	close(release)
+	<-done
+
+	v, err := m.Get(context.Background(), "k", func() (any, error) { return "v", nil })
+	if err != nil || v != "v" {
+		t.Fatalf("Expected the key to be computed once a slot is free, got: %v, %v", v, err)
+	}
+}
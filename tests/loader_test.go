@@ -0,0 +1,144 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// recordingBatch is a batch function that records the keys of every call
+type recordingBatch struct {
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (r *recordingBatch) load(ctx context.Context, keys []string) (map[string]any, error) {
+	r.mu.Lock()
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	r.batches = append(r.batches, sorted)
+	r.mu.Unlock()
+
+	out := make(map[string]any, len(keys))
+	for _, k := range keys {
+		if k != "missing" {
+			out[k] = "v-" + k
+		}
+	}
+	return out, nil
+}
+
+// loadAll loads keys concurrently and returns the results by key
+func loadAll(t *testing.T, l *memo.Loader, keys []string) map[string]any {
+	t.Helper()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	out := make(map[string]any, len(keys))
+	for _, k := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := l.Load(context.Background(), k)
+			if err != nil {
+				t.Errorf("Expected no error for %s, got: %v", k, err)
+				return
+			}
+			mu.Lock()
+			out[k] = v
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+// TestLoaderCoalescesKeys tests that loads within the window are dispatched as one batch and then cached
+func TestLoaderCoalescesKeys(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	rb := &recordingBatch{}
+	l := m.NewLoader(rb.load, memo.BatchWindow(50*time.Millisecond))
+
+	keys := []string{"a", "b", "c", "a", "b"}
+	got := loadAll(t, l, keys)
+	for _, k := range keys {
+		if got[k] != "v-"+k {
+			t.Fatalf("Expected v-%s, got: %v", k, got[k])
+		}
+	}
+	if len(rb.batches) != 1 || fmt.Sprint(rb.batches[0]) != "[a b c]" {
+		t.Fatalf("Expected a single batch of [a b c], got: %v", rb.batches)
+	}
+
+	// Cached now: no further batches
+	loadAll(t, l, []string{"a", "b", "c"})
+	if len(rb.batches) != 1 {
+		t.Fatalf("Expected cached keys to skip the batch loader, got: %v", rb.batches)
+	}
+}
+
+// TestLoaderMaxBatchSize tests that full batches are dispatched without waiting for the window
+func TestLoaderMaxBatchSize(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	rb := &recordingBatch{}
+	l := m.NewLoader(rb.load, memo.BatchWindow(time.Hour), memo.MaxBatchSize(2))
+
+	start := time.Now()
+	loadAll(t, l, []string{"a", "b"})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected a full batch to dispatch immediately, took %v", elapsed)
+	}
+	if len(rb.batches) != 1 || len(rb.batches[0]) != 2 {
+		t.Fatalf("Expected one batch of two keys, got: %v", rb.batches)
+	}
+}
+
+// TestLoaderErrors tests that batch failures and omitted keys are reported to callers
+func TestLoaderErrors(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	rb := &recordingBatch{}
+	l := m.NewLoader(rb.load)
+
+	if _, err := l.Load(context.Background(), "missing"); !errors.Is(err, memo.ErrNotLoaded) {
+		t.Fatalf("Expected ErrNotLoaded, got: %v", err)
+	}
+
+	errBatch := errors.New("batch failed")
+	failing := m.NewLoader(func(ctx context.Context, keys []string) (map[string]any, error) {
+		return nil, errBatch
+	})
+	if _, err := failing.Load(context.Background(), "x"); !errors.Is(err, errBatch) {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+}
+
+// TestLoaderBatchPanic tests that a panicking batch function fails its callers with a PanicError
+func TestLoaderBatchPanic(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	l := m.NewLoader(func(ctx context.Context, keys []string) (map[string]any, error) {
+		panic("boom")
+	})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, key := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = l.Load(context.Background(), key)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		var pe *memo.PanicError
+		if !errors.As(err, &pe) || pe.Value != "boom" {
+			t.Fatalf("Expected PanicError, got: %v", err)
+		}
+	}
+}
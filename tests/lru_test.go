@@ -0,0 +1,77 @@
+package memo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestMaxEntriesEvictsLRU tests that the least recently used entry is evicted when full
+func TestMaxEntriesEvictsLRU(t *testing.T) {
+	b := memory.New(memory.WithMaxEntries(3))
+
+	b.Set("a", 1, 0)
+	b.Set("b", 2, 0)
+	b.Set("c", 3, 0)
+	b.Get("a") // a is now more recent than b
+
+	var evicted []string
+	b.OnEvict(func(key string) { evicted = append(evicted, key) })
+	b.Set("d", 4, 0)
+
+	if _, ok := b.Get("b"); ok {
+		t.Fatal("Expected least recently used entry b to be evicted")
+	}
+	for _, k := range []string{"a", "c", "d"} {
+		if _, ok := b.Get(k); !ok {
+			t.Fatalf("Expected %s to be kept", k)
+		}
+	}
+	if b.Len() != 3 || b.Evictions() != 1 || len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("Expected 3 entries and one eviction of b, got: len=%d evictions=%d evicted=%v", b.Len(), b.Evictions(), evicted)
+	}
+
+	// Overwriting an existing key does not evict
+	b.Set("a", 10, 0)
+	if b.Len() != 3 || b.Evictions() != 1 {
+		t.Fatalf("Expected overwrite not to evict, got: len=%d evictions=%d", b.Len(), b.Evictions())
+	}
+
+	b.Delete("a")
+	b.Clear()
+	if b.Len() != 0 {
+		t.Fatalf("Expected empty backend, got: %d", b.Len())
+	}
+}
+
+// TestMaxEntriesMetrics tests that evictions are recorded in the memoizer's metrics
+func TestMaxEntriesMetrics(t *testing.T) {
+	m := memo.New(
+		memo.WithBackend(memory.New(memory.WithMaxEntries(2))),
+		memo.WithTTL(time.Minute),
+		memo.WithMetrics(true),
+	)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		_, _ = m.Get(ctx, fmt.Sprintf("k%d", i), func() (any, error) { return i, nil })
+	}
+	if n := m.Metrics().Snapshot().Evictions; n != 3 {
+		t.Fatalf("Expected 3 evictions, got: %d", n)
+	}
+}
+
+// TestMemoryUnbounded tests that the backend is unbounded by default
+func TestMemoryUnbounded(t *testing.T) {
+	b := memory.New()
+	for i := 0; i < 100; i++ {
+		b.Set(fmt.Sprintf("k%d", i), i, 0)
+	}
+	if b.Len() != 100 || b.Evictions() != 0 {
+		t.Fatalf("Expected 100 entries and no evictions, got: len=%d evictions=%d", b.Len(), b.Evictions())
+	}
+}
@@ -0,0 +1,93 @@
+package memo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// sizedValue reports an explicit size through backends.Sizer
+type sizedValue struct{ n int64 }
+
+func (v sizedValue) Size() int64 { return v.n }
+
+// TestEstimateSize tests the size estimator for Sizer, bytes, strings and reflected values
+func TestEstimateSize(t *testing.T) {
+	if got := backends.EstimateSize(sizedValue{n: 1234}); got != 1234 {
+		t.Fatalf("Expected Sizer size 1234, got: %d", got)
+	}
+	if got := backends.EstimateSize(make([]byte, 100)); got != 100 {
+		t.Fatalf("Expected 100 bytes, got: %d", got)
+	}
+	if got := backends.EstimateSize(strings.Repeat("x", 50)); got != 50 {
+		t.Fatalf("Expected 50 bytes, got: %d", got)
+	}
+
+	type record struct {
+		Name string
+		Tags []string
+	}
+	small := backends.EstimateSize(record{Name: "a"})
+	large := backends.EstimateSize(record{Name: strings.Repeat("a", 1000), Tags: []string{"x", "y"}})
+	if small <= 0 || large < small+1000 {
+		t.Fatalf("Expected reflection to account for referenced data, got: small=%d large=%d", small, large)
+	}
+}
+
+// TestMaxBytesEvictsBySize tests that entries are evicted to stay within the byte budget
+func TestMaxBytesEvictsBySize(t *testing.T) {
+	b := memory.New(memory.WithMaxBytes(1000))
+
+	for i := 0; i < 5; i++ {
+		b.Set(fmt.Sprintf("k%d", i), make([]byte, 300), 0)
+	}
+	if b.Bytes() > 1000 || b.Len() != 3 {
+		t.Fatalf("Expected 3 entries within 1000 bytes, got: len=%d bytes=%d", b.Len(), b.Bytes())
+	}
+	if _, ok := b.Get("k0"); ok {
+		t.Fatal("Expected oldest entry to be evicted")
+	}
+
+	// A value larger than the whole budget is not stored
+	b.Set("huge", make([]byte, 2000), 0)
+	if _, ok := b.Get("huge"); ok {
+		t.Fatal("Expected oversized value to be rejected")
+	}
+
+	b.Delete("k4")
+	if b.Bytes() != 600 {
+		t.Fatalf("Expected 600 bytes after delete, got: %d", b.Bytes())
+	}
+	b.Clear()
+	if b.Bytes() != 0 {
+		t.Fatalf("Expected 0 bytes after clear, got: %d", b.Bytes())
+	}
+}
+
+// TestMaxBytesCustomEstimator tests a custom size estimator and the BytesInUse metric
+func TestMaxBytesCustomEstimator(t *testing.T) {
+	backend := memory.New(
+		memory.WithMaxBytes(100),
+		memory.WithSizeEstimator(func(v any) int64 { return 40 }),
+	)
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Minute), memo.WithMetrics(true))
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		_, _ = m.Get(ctx, fmt.Sprintf("k%d", i), func() (any, error) { return i, nil })
+	}
+
+	snap := m.Metrics().Snapshot()
+	if snap.BytesInUse != 80 {
+		t.Fatalf("Expected 80 bytes in use, got: %d", snap.BytesInUse)
+	}
+	if snap.Evictions != 2 {
+		t.Fatalf("Expected 2 evictions, got: %d", snap.Evictions)
+	}
+}
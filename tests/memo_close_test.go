@@ -0,0 +1,60 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// closingBackend counts Close calls and fails them with err
+type closingBackend struct {
+	*memory.Memory
+	closes int
+	err    error
+}
+
+func (b *closingBackend) Close() error {
+	b.closes++
+	return b.err
+}
+
+// TestMemoizerCloseFlushesAndClosesBackend tests that Close drains async writes before closing the backend
+func TestMemoizerCloseFlushesAndClosesBackend(t *testing.T) {
+	backend := &slowSetBackend{Memory: memory.New(), delay: 5 * time.Millisecond}
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Minute), memo.WithAsyncSet(true))
+
+	for i := 0; i < 5; i++ {
+		_, _ = m.Get(context.Background(), fmt.Sprintf("k%d", i), func() (any, error) { return i, nil })
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := backend.Memory.Get(fmt.Sprintf("k%d", i)); !ok {
+			t.Fatalf("Expected k%d to be written before Close returned", i)
+		}
+	}
+}
+
+// TestMemoizerCloseOnce tests that the backend is closed once and its error is reported on every call
+func TestMemoizerCloseOnce(t *testing.T) {
+	errClose := errors.New("close failed")
+	backend := &closingBackend{Memory: memory.New(), err: errClose}
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Minute))
+
+	if err := m.Close(); !errors.Is(err, errClose) {
+		t.Fatalf("Expected backend close error, got: %v", err)
+	}
+	if err := m.Close(); !errors.Is(err, errClose) {
+		t.Fatalf("Expected the same error from a second Close, got: %v", err)
+	}
+	if backend.closes != 1 {
+		t.Fatalf("Expected backend to be closed once, got: %d", backend.closes)
+	}
+}
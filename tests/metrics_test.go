@@ -1,10 +1,15 @@
 package memo
 
 import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
 )
 
 // TestMetricsCreation tests creating metrics
@@ -260,3 +265,307 @@ func TestMetricsSnapshot(t *testing.T) {
 		t.Fatalf("Expected 1 eviction in snapshot, got: %d", snapshot.Evictions)
 	}
 }
+
+// TestShardedMetricsLatency tests that sharded latency recording keeps Min/Max/Avg/Last semantics
+func TestShardedMetricsLatency(t *testing.T) {
+	metrics := memo.NewShardedMetrics(true)
+
+	metrics.RecordLatency(10 * time.Millisecond)
+	metrics.RecordLatency(20 * time.Millisecond)
+	metrics.RecordLatency(5 * time.Millisecond)
+	metrics.RecordLatency(30 * time.Millisecond)
+
+	if metrics.AvgLatency() != 16250.0 {
+		t.Fatalf("Expected avg latency 16250 microseconds, got: %f", metrics.AvgLatency())
+	}
+	if metrics.MinLatency() != 5*time.Millisecond {
+		t.Fatalf("Expected min latency 5ms, got: %v", metrics.MinLatency())
+	}
+	if metrics.MaxLatency() != 30*time.Millisecond {
+		t.Fatalf("Expected max latency 30ms, got: %v", metrics.MaxLatency())
+	}
+	if metrics.LastLatency() != 30*time.Millisecond {
+		t.Fatalf("Expected last latency 30ms, got: %v", metrics.LastLatency())
+	}
+
+	// The snapshot carries the merged values
+	snapshot := metrics.Snapshot()
+	if snapshot.AvgLatency() != 16250.0 || snapshot.MinLatency() != 5*time.Millisecond || snapshot.MaxLatency() != 30*time.Millisecond {
+		t.Fatalf("Expected snapshot to carry merged latencies, got avg=%f min=%v max=%v",
+			snapshot.AvgLatency(), snapshot.MinLatency(), snapshot.MaxLatency())
+	}
+	if snapshot.LastLatency() != 30*time.Millisecond {
+		t.Fatalf("Expected snapshot last latency 30ms, got: %v", snapshot.LastLatency())
+	}
+
+	// Histograms and percentiles merge the shards too
+	var samples uint64
+	for _, n := range metrics.LatencyHistogram() {
+		samples += n
+	}
+	if samples != 4 {
+		t.Fatalf("Expected 4 samples in the histogram, got: %d", samples)
+	}
+	if got := metrics.Percentile(1); got != 30*time.Millisecond {
+		t.Fatalf("Expected p100 of 30ms, got: %v", got)
+	}
+	if got := metrics.Percentile(0.25); got < 5*time.Millisecond || got > 6*time.Millisecond {
+		t.Fatalf("Expected p25 of about 5ms, got: %v", got)
+	}
+}
+
+// TestShardedMetricsConcurrent tests that concurrent sharded recording counts every sample
+func TestShardedMetricsConcurrent(t *testing.T) {
+	metrics := memo.NewShardedMetrics(true)
+
+	const goroutines, samples = 8, 1000
+	done := make(chan struct{})
+	for g := 0; g < goroutines; g++ {
+		go func() {
+			for i := 1; i <= samples; i++ {
+				metrics.RecordLatency(time.Duration(i) * time.Microsecond)
+			}
+			done <- struct{}{}
+		}()
+	}
+	for g := 0; g < goroutines; g++ {
+		<-done
+	}
+
+	if metrics.AvgLatency() != float64(samples+1)/2 {
+		t.Fatalf("Expected avg latency %f, got: %f", float64(samples+1)/2, metrics.AvgLatency())
+	}
+	if metrics.MinLatency() != time.Microsecond {
+		t.Fatalf("Expected min latency 1µs, got: %v", metrics.MinLatency())
+	}
+	if metrics.MaxLatency() != samples*time.Microsecond {
+		t.Fatalf("Expected max latency %dµs, got: %v", samples, metrics.MaxLatency())
+	}
+}
+
+// TestMetricsSingleFlight tests the deduplication counter and the in-flight and waiter gauges
+func TestMetricsSingleFlight(t *testing.T) {
+	m := memo.New(memo.WithMetrics(true))
+	ctx := context.Background()
+	release := make(chan struct{})
+	fn := func() (any, error) {
+		<-release
+		return "v", nil
+	}
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = m.Get(ctx, key, fn)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = m.Get(ctx, "a", fn)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	s := m.Metrics().Snapshot()
+	if s.InFlight != 2 || s.Deduplicated != 3 || s.MaxWaiters != 3 {
+		t.Fatalf("Expected 2 keys in flight with 3 waiters on one, got: %d, %d, %d", s.InFlight, s.Deduplicated, s.MaxWaiters)
+	}
+	close(release)
+	wg.Wait()
+
+	s = m.Metrics().Snapshot()
+	if s.InFlight != 0 || s.Deduplicated != 3 || s.MaxWaiters != 3 {
+		t.Fatalf("Expected nothing in flight and the counts kept, got: %d, %d, %d", s.InFlight, s.Deduplicated, s.MaxWaiters)
+	}
+}
+
+// TestMetricsPublishExpvar tests that published metrics are readable through expvar
+func TestMetricsPublishExpvar(t *testing.T) {
+	m := memo.New(memo.WithMetrics(true))
+	m.Metrics().PublishExpvar("gomemo.test.expvar")
+
+	ctx := context.Background()
+	fn := func() (any, error) { return "v", nil }
+	_, _ = m.Get(ctx, "k", fn)
+	_, _ = m.Get(ctx, "k", fn)
+
+	v := expvar.Get("gomemo.test.expvar")
+	if v == nil {
+		t.Fatalf("Expected the metrics to be published")
+	}
+	var stats struct {
+		Hits, Misses, Requests uint64
+		HitRatio               float64
+	}
+	if err := json.Unmarshal([]byte(v.String()), &stats); err != nil {
+		t.Fatalf("Expected JSON, got: %v", err)
+	}
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Requests != 2 || stats.HitRatio != 0.5 {
+		t.Fatalf("Expected live stats, got: %+v", stats)
+	}
+}
+
+// TestMetricsPercentile tests that latency percentiles are reported within the histogram's precision
+func TestMetricsPercentile(t *testing.T) {
+	metrics := memo.NewMetrics(true)
+	if p := metrics.Percentile(0.5); p != 0 {
+		t.Fatalf("Expected zero percentile without samples, got: %v", p)
+	}
+	for i := 1; i <= 100; i++ {
+		metrics.RecordLatency(time.Duration(i) * time.Millisecond)
+	}
+
+	s := metrics.Snapshot()
+	for _, tt := range []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.5, 50 * time.Millisecond},
+		{0.95, 95 * time.Millisecond},
+		{0.99, 99 * time.Millisecond},
+	} {
+		got := s.Percentile(tt.q)
+		if got < tt.want || got > tt.want+tt.want/8 {
+			t.Fatalf("Expected p%v within 1/8 above %v, got: %v", tt.q*100, tt.want, got)
+		}
+	}
+	if p := s.Percentile(1); p != 100*time.Millisecond {
+		t.Fatalf("Expected p100 to be the max latency, got: %v", p)
+	}
+}
+
+// TestMetricsLatencyBreakdown tests that hit, backend and compute latencies are tracked separately
+func TestMetricsLatencyBreakdown(t *testing.T) {
+	m := memo.New(memo.WithMetrics(true), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+	fn := func() (any, error) {
+		time.Sleep(5 * time.Millisecond)
+		return "v", nil
+	}
+	_, _ = m.Get(ctx, "k", fn)
+	_, _ = m.Get(ctx, "k", fn)
+
+	s := m.Metrics().Snapshot()
+	compute := s.ComputeLatency()
+	if compute.Count != 1 || compute.Min < 5*time.Millisecond {
+		t.Fatalf("Expected one compute of at least 5ms, got: %+v", compute)
+	}
+	if hit := s.HitLatency(); hit.Count != 1 || hit.Max >= 5*time.Millisecond {
+		t.Fatalf("Expected one fast hit, got: %+v", hit)
+	}
+	// A read before and inside single-flight for the miss, one for the hit
+	if n := s.BackendGetLatency().Count; n != 3 {
+		t.Fatalf("Expected 3 backend reads, got: %d", n)
+	}
+	if n := s.BackendSetLatency().Count; n != 1 {
+		t.Fatalf("Expected 1 backend write, got: %d", n)
+	}
+}
+
+// TestMetricsHitLatencyResolution tests that hits faster than a microsecond are not reported as zero
+func TestMetricsHitLatencyResolution(t *testing.T) {
+	m := memo.New(memo.WithMetrics(true), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+	_, _ = m.Get(ctx, "k", func() (any, error) { return "v", nil })
+	for range 100 {
+		_, _ = m.Get(ctx, "k", func() (any, error) { return "v", nil })
+	}
+
+	s := m.Metrics().Snapshot()
+	hit := s.HitLatency()
+	if hit.Count != 100 || hit.Min <= 0 || hit.Avg <= 0 || hit.P50 <= 0 {
+		t.Fatalf("Expected nonzero hit latencies, got: %+v", hit)
+	}
+	if hit.P50 < hit.Min || hit.P50 > hit.Max {
+		t.Fatalf("Expected p50 between min and max, got: %+v", hit)
+	}
+}
+
+// TestMetricsBackendSize tests that the snapshot reports the backend's entry count and bytes in use
+func TestMetricsBackendSize(t *testing.T) {
+	m := memo.New(memo.WithMetrics(true), memo.WithBackend(memory.New(memory.WithMaxBytes(1<<20))))
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c"} {
+		_, _ = m.Get(ctx, key, func() (any, error) { return "value", nil })
+	}
+
+	s := m.Metrics().Snapshot()
+	if s.Entries != 3 {
+		t.Fatalf("Expected 3 entries, got: %d", s.Entries)
+	}
+	if s.BytesInUse <= 0 {
+		t.Fatalf("Expected bytes in use to be measured, got: %d", s.BytesInUse)
+	}
+	if n, ok := m.Len(); !ok || n != 3 {
+		t.Fatalf("Expected Len to report 3 entries, got: %d, %v", n, ok)
+	}
+
+	if _, ok := memo.New(memo.WithBackend(memory.New())).Bytes(); ok {
+		t.Fatalf("Expected bytes to be unknown without a byte budget")
+	}
+}
+
+// TestMetricsReset tests that Reset zeroes counters and latency statistics
+func TestMetricsReset(t *testing.T) {
+	metrics := memo.NewShardedMetrics(true)
+	metrics.RecordHit()
+	metrics.RecordMiss()
+	metrics.RecordLatency(5 * time.Millisecond)
+	metrics.RecordHitLatency(time.Millisecond)
+	metrics.AddInFlight(1)
+
+	metrics.Reset()
+
+	s := metrics.Snapshot()
+	if s.Hits != 0 || s.Misses != 0 || s.Requests != 0 {
+		t.Fatalf("Expected counters to be zero after Reset, got: %+v", s)
+	}
+	if s.AvgLatency() != 0 || s.MaxLatency() != 0 || metrics.HitLatency().Count != 0 {
+		t.Fatalf("Expected latencies to be zero after Reset, got avg %v max %v", s.AvgLatency(), s.MaxLatency())
+	}
+	if s.InFlight != 1 {
+		t.Fatalf("Expected the in-flight gauge to survive Reset, got: %d", s.InFlight)
+	}
+
+	metrics.RecordLatency(2 * time.Millisecond)
+	if got := metrics.MinLatency(); got != 2*time.Millisecond {
+		t.Fatalf("Expected min latency to restart after Reset, got: %v", got)
+	}
+}
+
+// TestMetricsWindow tests that the rolling window only reports recent requests
+func TestMetricsWindow(t *testing.T) {
+	m := memo.New(memo.WithMetrics(true), memo.WithMetricsWindow(100*time.Millisecond))
+	ctx := context.Background()
+	compute := func() (any, error) { return "value", nil }
+
+	_, _ = m.Get(ctx, "a", compute)
+	_, _ = m.Get(ctx, "b", compute)
+	w := m.Metrics().Window()
+	if w.Requests != 2 || w.Misses != 2 || w.HitRatio() != 0 {
+		t.Fatalf("Expected 2 misses in the window, got: %+v", w)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	_, _ = m.Get(ctx, "a", compute)
+	w = m.Metrics().Window()
+	if w.Requests != 1 || w.Hits != 1 || w.HitRatio() != 1 {
+		t.Fatalf("Expected only the recent hit in the window, got: %+v", w)
+	}
+	if s := m.Metrics().Snapshot(); s.Requests != 3 {
+		t.Fatalf("Expected totals to keep every request, got: %d", s.Requests)
+	}
+
+	m.Metrics().Reset()
+	if w = m.Metrics().Window(); w.Requests != 0 {
+		t.Fatalf("Expected Reset to clear the window, got: %+v", w)
+	}
+	if w := memo.New(memo.WithMetrics(true)).Metrics().Window(); w.Window != 0 {
+		t.Fatalf("Expected no window unless configured, got: %+v", w)
+	}
+}
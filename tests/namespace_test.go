@@ -0,0 +1,56 @@
+package memo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestNamespaceInvalidate tests that bumping the generation misses every key in the namespace only
+func TestNamespaceInvalidate(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+	tenantA := m.Namespace("tenant:a")
+	tenantB := m.Namespace("tenant:b")
+
+	calls := 0
+	load := func() (any, error) { calls++; return calls, nil }
+
+	a1, _ := tenantA.Get(ctx, "settings", load)
+	b1, _ := tenantB.Get(ctx, "settings", load)
+	if a1 == b1 {
+		t.Fatalf("Expected namespaces to keep separate keys, got: %v and %v", a1, b1)
+	}
+	if v, _ := tenantA.Get(ctx, "settings", load); v != a1 {
+		t.Fatalf("Expected a cached value, got: %v", v)
+	}
+
+	tenantA.Invalidate(ctx)
+	if v, _ := tenantA.Get(ctx, "settings", load); v == a1 {
+		t.Fatalf("Expected a recompute after Invalidate, got: %v", v)
+	}
+	if v, _ := tenantB.Get(ctx, "settings", load); v != b1 {
+		t.Fatalf("Expected other namespaces to be unaffected, got: %v", v)
+	}
+}
+
+// TestNamespaceSharedAcrossMemoizers tests that memoizers sharing a backend see each other's invalidations
+func TestNamespaceSharedAcrossMemoizers(t *testing.T) {
+	backend := memory.New()
+	m1 := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Minute))
+	m2 := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	m1.Namespace("ns").Set(ctx, "k", "v1")
+	if v, err := m2.Namespace("ns").Get(ctx, "k", func() (any, error) { return "computed", nil }); err != nil || v != "v1" {
+		t.Fatalf("Expected the value set through the other memoizer, got: %v, %v", v, err)
+	}
+
+	m2.Namespace("ns").Invalidate(ctx)
+	if v, _ := m1.Namespace("ns").Get(ctx, "k", func() (any, error) { return "v2", nil }); v != "v2" {
+		t.Fatalf("Expected the invalidation to be visible, got: %v", v)
+	}
+}
@@ -0,0 +1,130 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+var errNotFound = errors.New("not found")
+
+// TestErrorTTLCachesErrors tests that a failing fn is not called again until the error TTL passes
+func TestErrorTTLCachesErrors(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute), memo.WithErrorTTL(30*time.Millisecond), memo.WithMetrics(true))
+	ctx := context.Background()
+
+	calls := 0
+	fn := func() (any, error) {
+		calls++
+		return nil, errNotFound
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := m.Get(ctx, "k", fn); !errors.Is(err, errNotFound) {
+			t.Fatalf("Expected cached error, got: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("Expected fn to run once, got: %d calls", calls)
+	}
+	if n := m.Metrics().Snapshot().NegativeHits; n != 2 {
+		t.Fatalf("Expected 2 negative hits, got: %d", n)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	_, _ = m.Get(ctx, "k", fn)
+	if calls != 2 {
+		t.Fatalf("Expected fn to run again after the error TTL, got: %d calls", calls)
+	}
+}
+
+// TestCacheableErrorPredicate tests that only selected errors are cached
+func TestCacheableErrorPredicate(t *testing.T) {
+	m := memo.New(
+		memo.WithTTL(time.Minute),
+		memo.WithErrorTTL(time.Minute),
+		memo.WithCacheableError(func(err error) bool { return errors.Is(err, errNotFound) }),
+	)
+	ctx := context.Background()
+
+	timeouts := 0
+	timeoutFn := func() (any, error) {
+		timeouts++
+		return nil, context.DeadlineExceeded
+	}
+	_, _ = m.Get(ctx, "slow", timeoutFn)
+	_, _ = m.Get(ctx, "slow", timeoutFn)
+	if timeouts != 2 {
+		t.Fatalf("Expected uncacheable errors to be retried, got: %d calls", timeouts)
+	}
+
+	missing := 0
+	missingFn := func() (any, error) {
+		missing++
+		return nil, errNotFound
+	}
+	_, _ = m.Get(ctx, "missing", missingFn)
+	_, _ = m.Get(ctx, "missing", missingFn)
+	if missing != 1 {
+		t.Fatalf("Expected cacheable error to be cached, got: %d calls", missing)
+	}
+
+	// Delete drops the cached error
+	m.Delete("missing")
+	_, _ = m.Get(ctx, "missing", missingFn)
+	if missing != 2 {
+		t.Fatalf("Expected Delete to drop the cached error, got: %d calls", missing)
+	}
+}
+
+// TestErrorTTLJitter tests that errors cached together expire at different times
+func TestErrorTTLJitter(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute), memo.WithErrorTTL(400*time.Millisecond))
+	ctx := context.Background()
+
+	calls := 0
+	fn := func() (any, error) {
+		calls++
+		return nil, errNotFound
+	}
+	const keys = 200
+	for i := 0; i < keys; i++ {
+		_, _ = m.Get(ctx, fmt.Sprintf("k%d", i), fn)
+	}
+
+	// Errors expire between 300ms and 400ms; halfway, only some have.
+	time.Sleep(350 * time.Millisecond)
+	calls = 0
+	for i := 0; i < keys; i++ {
+		_, _ = m.Get(ctx, fmt.Sprintf("k%d", i), fn)
+	}
+	if calls == 0 || calls == keys {
+		t.Fatalf("Expected only some errors to have expired, got: %d of %d", calls, keys)
+	}
+}
+
+// TestErrorTTLMaxTrackedKeys tests that errors are cached for at most MaxTrackedKeys keys
+func TestErrorTTLMaxTrackedKeys(t *testing.T) {
+	m := memo.New(memo.WithErrorTTL(time.Minute), memo.WithMaxTrackedKeys(2))
+	ctx := context.Background()
+
+	errDown := errors.New("upstream down")
+	keys := []string{"a", "b", "c"}
+	for _, k := range keys {
+		_, _ = m.Get(ctx, k, func() (any, error) { return nil, errDown })
+	}
+
+	cached := 0
+	for _, k := range keys {
+		if _, err := m.Get(ctx, k, func() (any, error) { return "ok", nil }); errors.Is(err, errDown) {
+			cached++
+		}
+	}
+	if cached != 2 {
+		t.Fatalf("Expected cached errors for 2 keys, got: %d", cached)
+	}
+}
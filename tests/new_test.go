@@ -0,0 +1,38 @@
+package memo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestNewWithErrorInvalid tests that invalid options are returned as errors
+func TestNewWithErrorInvalid(t *testing.T) {
+	m, err := memo.NewWithError(memo.WithTTL(-time.Second))
+	if err == nil || m != nil {
+		t.Fatalf("Expected an error for a negative TTL, got: %v, %v", m, err)
+	}
+
+	if _, err := memo.NewWithError(memo.WithBackend(nil)); err == nil {
+		t.Fatal("Expected an error for a nil backend")
+	}
+}
+
+// TestNewWithErrorValid tests that valid options produce a usable memoizer
+func TestNewWithErrorValid(t *testing.T) {
+	m, err := memo.NewWithError(memo.WithTTL(time.Minute))
+	if err != nil || m == nil {
+		t.Fatalf("Expected a memoizer, got: %v, %v", m, err)
+	}
+}
+
+// TestNewPanicsOnInvalid tests that New still panics on invalid options
+func TestNewPanicsOnInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected New to panic for a negative TTL")
+		}
+	}()
+	memo.New(memo.WithTTL(-time.Second))
+}
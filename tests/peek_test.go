@@ -0,0 +1,62 @@
+package memo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestPeekDoesNotRecordMetrics tests that Peek and Has read the cache without counting hits or misses
+func TestPeekDoesNotRecordMetrics(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute), memo.WithMetrics(true))
+	m.Set(context.Background(), "k", "v")
+
+	if v, ok := m.Peek("k"); !ok || v != "v" {
+		t.Fatalf("Expected to peek v, got: %v, %v", v, ok)
+	}
+	if !m.Has("k") || m.Has("missing") {
+		t.Fatalf("Expected Has to report only cached keys")
+	}
+
+	snap := m.Metrics().Snapshot()
+	if snap.Hits != 0 || snap.Misses != 0 || snap.Requests != 0 {
+		t.Fatalf("Expected no recorded requests, got: %+v", snap)
+	}
+}
+
+// TestPeekLeavesEvictionOrder tests that peeking does not protect an entry from LRU eviction
+func TestPeekLeavesEvictionOrder(t *testing.T) {
+	backend := memory.New(memory.WithMaxEntries(2), memory.WithAccessCounts())
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	m.Set(ctx, "a", 1)
+	m.Set(ctx, "b", 2)
+	m.Peek("a") // a Get here would make b the eviction victim
+	m.Set(ctx, "c", 3)
+
+	if m.Has("a") {
+		t.Fatalf("Expected a to be evicted as least recently used")
+	}
+	if count, _ := m.AccessCount("b"); count != 0 {
+		t.Fatalf("Expected Has to leave access counts alone, got: %d", count)
+	}
+}
+
+// TestPeekLeavesSlidingTTL tests that peeking does not extend a sliding TTL
+func TestPeekLeavesSlidingTTL(t *testing.T) {
+	backend := memory.New()
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Minute), memo.WithSlidingTTL(true))
+	m.Set(context.Background(), "k", "v")
+
+	before, _ := backend.GetEntry("k")
+	time.Sleep(5 * time.Millisecond)
+	m.Peek("k")
+	after, _ := backend.GetEntry("k")
+	if !after.ExpiresAt().Equal(before.ExpiresAt()) {
+		t.Fatalf("Expected expiry to stay at %v, got: %v", before.ExpiresAt(), after.ExpiresAt())
+	}
+}
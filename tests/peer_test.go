@@ -0,0 +1,229 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+	"github.com/ldaidone/gomemo/pkg/backends/peer"
+)
+
+// peerNode is a peer.Node served by a test server, with its local backend.
+type peerNode struct {
+	*peer.Node
+	local *memory.Memory
+	url   string
+}
+
+// newPeerRing starts n nodes that know about each other.
+func newPeerRing(t *testing.T, n int, opts ...peer.Option) []peerNode {
+	nodes := make([]peerNode, n)
+	urls := make([]string, n)
+	for i := range nodes {
+		var node *peer.Node
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			node.ServeHTTP(w, r)
+		}))
+		t.Cleanup(srv.Close)
+
+		local := memory.New()
+		t.Cleanup(func() { local.Close() })
+		node = peer.New(srv.URL, append([]peer.Option{peer.WithLocal(local)}, opts...)...)
+		nodes[i] = peerNode{Node: node, local: local, url: srv.URL}
+		urls[i] = srv.URL
+	}
+	for _, node := range nodes {
+		node.SetPeers(urls...)
+	}
+	return nodes
+}
+
+// peerOwner returns the node owning key.
+func peerOwner(nodes []peerNode, key string) peerNode {
+	url := nodes[0].Owner(key)
+	for _, n := range nodes {
+		if n.url == url {
+			return n
+		}
+	}
+	panic("no owner for " + key)
+}
+
+// TestPeerOwnership tests that every key is stored on its owner and readable from every node
+func TestPeerOwnership(t *testing.T) {
+	nodes := newPeerRing(t, 3)
+
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("k%d", i)
+		nodes[i%3].Set(key, i, time.Minute)
+	}
+	perNode := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("k%d", i)
+		o := peerOwner(nodes, key)
+		if _, ok := o.local.Get(key); !ok {
+			t.Fatalf("Expected %s on its owner %s", key, o.url)
+		}
+		perNode[o.url]++
+		for _, n := range nodes {
+			if v, ok := n.Get(key); !ok || v != i {
+				t.Fatalf("Expected %d for %s from every node, got: %v, %v", i, key, v, ok)
+			}
+		}
+	}
+	if len(perNode) != 3 {
+		t.Fatalf("Expected keys spread over all nodes, got: %v", perNode)
+	}
+	if total := nodes[0].local.Len() + nodes[1].local.Len() + nodes[2].local.Len(); total != 30 {
+		t.Fatalf("Expected every key held once, got: %d", total)
+	}
+
+	nodes[0].Delete("k1")
+	if _, ok := nodes[1].Get("k1"); ok {
+		t.Fatal("Expected Delete to remove the key on its owner")
+	}
+	nodes[2].Clear()
+	for _, n := range nodes {
+		if n.local.Len() != 0 {
+			t.Fatalf("Expected Clear to empty every node, got: %d entries on %s", n.local.Len(), n.url)
+		}
+	}
+}
+
+// TestPeerGetter tests that the owner computes a missing value once for concurrent requests from every node
+func TestPeerGetter(t *testing.T) {
+	var calls atomic.Int32
+	nodes := newPeerRing(t, 3, peer.WithGetter(func(ctx context.Context, key string) (any, time.Duration, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return "loaded:" + key, time.Minute, nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go func(n peerNode) {
+			defer wg.Done()
+			if v, ok := n.Get("report"); !ok || v != "loaded:report" {
+				t.Errorf("Expected the loaded value, got: %v, %v", v, ok)
+			}
+		}(nodes[i%3])
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("Expected one load on the owner, got: %d", calls.Load())
+	}
+	if _, ok := peerOwner(nodes, "report").local.Get("report"); !ok {
+		t.Fatal("Expected the owner to keep the loaded value")
+	}
+}
+
+// TestPeerWithMemoizer tests that memoizers on different nodes share computed values
+func TestPeerWithMemoizer(t *testing.T) {
+	nodes := newPeerRing(t, 2)
+	ctx := context.Background()
+
+	calls := 0
+	compute := func() (any, error) {
+		calls++
+		return "v", nil
+	}
+	for _, n := range nodes {
+		m := memo.New(memo.WithBackend(n.Node), memo.WithTTL(time.Minute))
+		if v, err := m.Get(ctx, "shared", compute); err != nil || v != "v" {
+			t.Fatalf("Expected v, got: %v, %v", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("Expected the second node to reuse the value, got: %d computations", calls)
+	}
+}
+
+// TestPeerUnreachable tests that an unreachable owner is reported as an error
+func TestPeerUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	down := srv.URL
+	srv.Close()
+
+	var errs []string
+	node := peer.New("http://127.0.0.1:1", peer.WithErrorHandler(func(op string, err error) {
+		errs = append(errs, op)
+	}))
+	node.SetPeers(down)
+
+	if _, ok := node.Get("k"); ok {
+		t.Fatal("Expected a miss from an unreachable owner")
+	}
+	if _, _, err := node.V2().Get(context.Background(), "k"); err == nil {
+		t.Fatal("Expected the context-aware form to return the error")
+	}
+	node.Set("k", "v", time.Minute)
+	if len(errs) != 2 || errs[0] != "get" || errs[1] != "set" {
+		t.Fatalf("Expected get and set errors to be reported, got: %v", errs)
+	}
+}
+
+// TestPeerGetterPanic tests that a panicking Getter fails its callers without blocking later loads of the key
+func TestPeerGetterPanic(t *testing.T) {
+	var calls atomic.Int32
+	nodes := newPeerRing(t, 1, peer.WithGetter(func(ctx context.Context, key string) (any, time.Duration, error) {
+		if calls.Add(1) == 1 {
+			panic("boom")
+		}
+		return "loaded", time.Minute, nil
+	}))
+	b := nodes[0].V2()
+
+	if _, _, err := b.Get(context.Background(), "k"); !errors.Is(err, peer.ErrGetterPanic) {
+		t.Fatalf("Expected ErrGetterPanic, got: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if v, ok, err := b.Get(ctx, "k"); err != nil || !ok || v != "loaded" {
+		t.Fatalf("Expected the key to load again, got: %v, %v, %v", v, ok, err)
+	}
+}
+
+// TestPeerGetterLeaderCancel tests that the caller starting a load cancelling does not fail the callers waiting for it
+func TestPeerGetterLeaderCancel(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	nodes := newPeerRing(t, 1, peer.WithGetter(func(ctx context.Context, key string) (any, time.Duration, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		return "loaded", time.Minute, nil
+	}))
+	b := nodes[0].V2()
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	go func() { _, _, _ = b.Get(leaderCtx, "k") }()
+	<-started
+
+	type result struct {
+		v   any
+		err error
+	}
+	waiter := make(chan result, 1)
+	go func() {
+		v, _, err := b.Get(context.Background(), "k")
+		waiter <- result{v, err}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	close(release)
+
+	if r := <-waiter; r.err != nil || r.v != "loaded" {
+		t.Fatalf("Expected the waiter to get the loaded value, got: %v, %v", r.v, r.err)
+	}
+}
@@ -0,0 +1,90 @@
+package memo
+
+import (
+	"context"
+	"encoding/gob"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+type pointerArg struct {
+	N int
+}
+
+// HashArgs can only hash the pointed-to value of types gob knows about
+func init() {
+	gob.Register(&pointerArg{})
+}
+
+// memoizedDouble returns a memoized function doubling the N of a *pointerArg and a call counter
+func memoizedDouble(m *memo.Memoizer) (func(context.Context, ...any) (any, error), *int32) {
+	var calls int32
+	return m.MemoizeFunc(func(ctx context.Context, args ...any) (any, error) {
+		atomic.AddInt32(&calls, 1)
+		return args[0].(*pointerArg).N * 2, nil
+	}), &calls
+}
+
+// TestPointerArgsValueKeys tests the default behavior of keying pointer args by pointed-to value
+func TestPointerArgsValueKeys(t *testing.T) {
+	m := memo.New(memo.WithTTL(5 * time.Second))
+	fn, calls := memoizedDouble(m)
+	ctx := context.Background()
+
+	a, b := &pointerArg{N: 1}, &pointerArg{N: 1}
+	_, _ = fn(ctx, a)
+	_, _ = fn(ctx, b)
+	if atomic.LoadInt32(calls) != 1 {
+		t.Fatalf("Expected equal values behind different pointers to share an entry, got %d calls", *calls)
+	}
+
+	// Mutating the value yields a new key
+	a.N = 2
+	v, _ := fn(ctx, a)
+	if v != 4 {
+		t.Fatalf("Expected 4 after mutation, got: %v", v)
+	}
+	if atomic.LoadInt32(calls) != 2 {
+		t.Fatalf("Expected recompute after mutation, got %d calls", *calls)
+	}
+}
+
+// TestPointerArgsIdentityKeys tests that identity mode keys each pointer separately
+func TestPointerArgsIdentityKeys(t *testing.T) {
+	m := memo.New(memo.WithTTL(5*time.Second), memo.WithPointerIdentityKeys(true))
+	fn, calls := memoizedDouble(m)
+	ctx := context.Background()
+
+	a, b := &pointerArg{N: 1}, &pointerArg{N: 1}
+	_, _ = fn(ctx, a)
+	_, _ = fn(ctx, b)
+	if atomic.LoadInt32(calls) != 2 {
+		t.Fatalf("Expected distinct pointers to get distinct entries, got %d calls", *calls)
+	}
+
+	// The same pointer stays cached even after its value changes
+	a.N = 5
+	v, _ := fn(ctx, a)
+	if v != 2 {
+		t.Fatalf("Expected cached per-instance result 2, got: %v", v)
+	}
+	if atomic.LoadInt32(calls) != 2 {
+		t.Fatalf("Expected same pointer to hit the cache, got %d calls", *calls)
+	}
+
+	// Non-pointer arguments are still keyed by value
+	m2 := memo.New(memo.WithTTL(5*time.Second), memo.WithPointerIdentityKeys(true))
+	var plain int32
+	fn2 := m2.MemoizeFunc(func(ctx context.Context, args ...any) (any, error) {
+		atomic.AddInt32(&plain, 1)
+		return args[0], nil
+	})
+	_, _ = fn2(ctx, 7)
+	_, _ = fn2(ctx, 7)
+	if atomic.LoadInt32(&plain) != 1 {
+		t.Fatalf("Expected non-pointer args to be keyed by value, got %d calls", plain)
+	}
+}
@@ -0,0 +1,63 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/faketest"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+)
+
+// TestDeleteByPrefix tests that only keys under the prefix are invalidated
+func TestDeleteByPrefix(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+	for _, key := range []string{"user:42:name", "user:42:email", "user:420:name", "team:42"} {
+		m.Set(ctx, key, key)
+	}
+
+	n, err := m.DeleteByPrefix("user:42:")
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 deletions, got: %d, %v", n, err)
+	}
+	if m.Has("user:42:name") || m.Has("user:42:email") {
+		t.Fatalf("Expected user:42 keys to be gone")
+	}
+	if !m.Has("user:420:name") || !m.Has("team:42") {
+		t.Fatalf("Expected other keys to remain")
+	}
+}
+
+// TestDeleteByPrefixUnsupported tests that backends without prefix support report an error
+func TestDeleteByPrefixUnsupported(t *testing.T) {
+	m := memo.New(memo.WithBackend(faketest.New()), memo.WithTTL(time.Minute))
+	if _, err := m.DeleteByPrefix("user:"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("Expected ErrUnsupported, got: %v", err)
+	}
+}
+
+// TestRedisDeleteByPrefix tests that the redis backend removes matching keys and escapes wildcards
+func TestRedisDeleteByPrefix(t *testing.T) {
+	srv, _ := newRedis(t)
+	b := redis.New(srv.Addr(), "test:", 0)
+	for _, key := range []string{"user:1:a", "user:1:b", "user:10:a", "user*:x", "other"} {
+		b.Set(key, 1, time.Minute)
+	}
+
+	if n := b.(backends.PrefixDeleter).DeleteByPrefix("user:1:"); n != 2 {
+		t.Fatalf("Expected 2 deletions, got: %d", n)
+	}
+	if n := b.(backends.PrefixDeleter).DeleteByPrefix("user*"); n != 1 {
+		t.Fatalf("Expected the wildcard to be matched literally, got: %d deletions", n)
+	}
+	if _, ok := b.Get("user:10:a"); !ok {
+		t.Fatalf("Expected user:10:a to remain")
+	}
+	if _, ok := b.Get("other"); !ok {
+		t.Fatalf("Expected other to remain")
+	}
+}
@@ -0,0 +1,79 @@
+package memo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/ldaidone/gomemo/memo"
+	memoprom "github.com/ldaidone/gomemo/memo/prometheus"
+)
+
+// gather collects the metrics of reg by name.
+func gather(t *testing.T, reg *prom.Registry) map[string]*dto.Metric {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Expected gather to succeed, got: %v", err)
+	}
+	metrics := make(map[string]*dto.Metric)
+	for _, f := range families {
+		metrics[f.GetName()] = f.GetMetric()[0]
+	}
+	return metrics
+}
+
+// TestPrometheusCollector tests that the collector exports the memoizer's metrics with its name label
+func TestPrometheusCollector(t *testing.T) {
+	m := memo.New(memo.WithMetrics(true), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+	fn := func() (any, error) { return "v", nil }
+	_, _ = m.Get(ctx, "a", fn)
+	_, _ = m.Get(ctx, "a", fn)
+	_, _ = m.Get(ctx, "b", fn)
+
+	reg := prom.NewRegistry()
+	reg.MustRegister(memoprom.NewCollector(m, memoprom.WithName("users")))
+	metrics := gather(t, reg)
+
+	counters := map[string]float64{
+		"gomemo_hits_total":     1,
+		"gomemo_misses_total":   2,
+		"gomemo_requests_total": 3,
+	}
+	for name, want := range counters {
+		metric, ok := metrics[name]
+		if !ok {
+			t.Fatalf("Expected metric %s, got: %v", name, metrics)
+		}
+		if got := metric.GetCounter().GetValue(); got != want {
+			t.Fatalf("Expected %s to be %v, got: %v", name, want, got)
+		}
+		if l := metric.GetLabel(); len(l) != 1 || l[0].GetName() != memoprom.NameLabel || l[0].GetValue() != "users" {
+			t.Fatalf("Expected memoizer label on %s, got: %v", name, l)
+		}
+	}
+
+	if n := metrics["gomemo_compute_duration_seconds"].GetHistogram().GetSampleCount(); n != 2 {
+		t.Fatalf("Expected 2 latency samples, got: %d", n)
+	}
+	if n := metrics["gomemo_entries"].GetGauge().GetValue(); n != 2 {
+		t.Fatalf("Expected 2 entries, got: %v", n)
+	}
+}
+
+// TestPrometheusCollectorNames tests that two memoizers can share a registry under different names
+func TestPrometheusCollectorNames(t *testing.T) {
+	reg := prom.NewRegistry()
+	a := memo.New(memo.WithMetrics(true))
+	b := memo.New(memo.WithMetrics(true))
+	if err := reg.Register(memoprom.NewCollector(a, memoprom.WithName("a"))); err != nil {
+		t.Fatalf("Expected first collector to register, got: %v", err)
+	}
+	if err := reg.Register(memoprom.NewCollector(b, memoprom.WithName("b"))); err != nil {
+		t.Fatalf("Expected second collector to register, got: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestRecomputeRateLimit tests that a key invalidated repeatedly is recomputed no more often than allowed
+func TestRecomputeRateLimit(t *testing.T) {
+	m := memo.New(memo.WithRecomputeRateLimit(2, 50*time.Millisecond), memo.WithMetrics(true))
+	ctx := context.Background()
+
+	calls := 0
+	fn := func() (any, error) {
+		calls++
+		return calls, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.Get(ctx, "k", fn); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		m.Delete("k")
+	}
+	if _, err := m.Get(ctx, "k", fn); !errors.Is(err, memo.ErrRateLimited) {
+		t.Fatalf("Expected ErrRateLimited, got: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("Expected 2 calls, got: %d", calls)
+	}
+	if n := m.Metrics().Snapshot().RateLimited; n != 1 {
+		t.Fatalf("Expected 1 rate limited recompute, got: %d", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if v, err := m.Get(ctx, "k", fn); err != nil || v != 3 {
+		t.Fatalf("Expected recompute after the interval, got: %v, %v", v, err)
+	}
+}
+
+// TestServeLastWhenRateLimited tests that the last value is served while recomputation is held back
+func TestServeLastWhenRateLimited(t *testing.T) {
+	m := memo.New(memo.WithRecomputeRateLimit(1, time.Minute), memo.WithServeLastWhenRateLimited(true))
+	ctx := context.Background()
+
+	calls := 0
+	fn := func() (any, error) {
+		calls++
+		return calls, nil
+	}
+
+	_, _ = m.Get(ctx, "k", fn)
+	m.Delete("k")
+	v, err := m.Get(ctx, "k", fn)
+	if err != nil || v != 1 {
+		t.Fatalf("Expected last value, got: %v, %v", v, err)
+	}
+	if calls != 1 {
+		t.Fatalf("Expected 1 call, got: %d", calls)
+	}
+}
@@ -0,0 +1,73 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/faketest"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestReadOnlyReadsWithoutWriting tests that a read-only memoizer serves cached values but never stores computed ones
+func TestReadOnlyReadsWithoutWriting(t *testing.T) {
+	b := faketest.New()
+	b.ForceHit("cached", "shared")
+	m := memo.New(memo.WithBackend(b), memo.WithReadOnly(true))
+	ctx := context.Background()
+
+	if v, err := m.Get(ctx, "cached", func() (any, error) { return "computed", nil }); err != nil || v != "shared" {
+		t.Fatalf("Expected the cached value, got: %v, %v", v, err)
+	}
+	calls := 0
+	for i := 0; i < 2; i++ {
+		v, err := m.Get(ctx, "k", func() (any, error) {
+			calls++
+			return "computed", nil
+		})
+		if err != nil || v != "computed" {
+			t.Fatalf("Expected the computed value, got: %v, %v", v, err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("Expected every miss to compute, got: %d", calls)
+	}
+	m.Set(ctx, "direct", "v")
+	_, _ = m.GetMany(ctx, []string{"a", "b"}, func(missing []string) (map[string]any, error) {
+		return map[string]any{"a": 1, "b": 2}, nil
+	})
+
+	if c := b.Counts(); c.Sets != 0 {
+		t.Fatalf("Expected no writes, got: %+v", c)
+	}
+}
+
+// TestReadOnlyInvalidations tests that invalidations leave the backend alone
+func TestReadOnlyInvalidations(t *testing.T) {
+	b := memory.New()
+	defer b.Close()
+	b.Set("k", "v", time.Minute)
+	m := memo.New(memo.WithBackend(b), memo.WithReadOnly(true), memo.WithSlidingTTL(true))
+
+	m.Delete("k")
+	m.Expire("k")
+	m.Clear()
+	if m.Touch("k", time.Hour) {
+		t.Fatal("Expected Touch to report nothing touched")
+	}
+	if _, err := m.DeleteByPrefix("k"); !errors.Is(err, memo.ErrReadOnly) {
+		t.Fatalf("Expected ErrReadOnly, got: %v", err)
+	}
+	if _, err := m.InvalidateTag("t"); !errors.Is(err, memo.ErrReadOnly) {
+		t.Fatalf("Expected ErrReadOnly, got: %v", err)
+	}
+
+	if v, err := m.Get(context.Background(), "k", func() (any, error) { return "computed", nil }); err != nil || v != "v" {
+		t.Fatalf("Expected the entry to survive, got: %v, %v", v, err)
+	}
+	if entry, _ := b.GetEntry("k"); entry.TTLRemaining() > time.Minute {
+		t.Fatalf("Expected the TTL left alone, got: %v", entry.TTLRemaining())
+	}
+}
@@ -0,0 +1,80 @@
+package memo
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestMemoryReadPaths tests that the lock-free and mutex read paths behave the same
+func TestMemoryReadPaths(t *testing.T) {
+	modes := map[string][]memory.Option{
+		"lock-free": nil,
+		"mutex":     {memory.WithMutexReads()},
+	}
+	for name, opts := range modes {
+		t.Run(name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			b := memory.New(append(opts, memory.WithClock(clock))...)
+
+			b.Set("k", "v1", time.Minute)
+			b.Set("k", "v2", time.Minute)
+			if v, ok := b.Get("k"); !ok || v != "v2" {
+				t.Fatalf("Expected latest value, got: %v, %v", v, ok)
+			}
+
+			clock.Advance(30 * time.Second)
+			b.Touch("k", time.Minute)
+			clock.Advance(45 * time.Second)
+			if _, ok := b.Get("k"); !ok {
+				t.Fatal("Expected touched entry to be alive")
+			}
+
+			clock.Advance(time.Minute)
+			if _, ok := b.GetEntry("k"); ok {
+				t.Fatal("Expected expired entry to miss")
+			}
+			b.Sweep()
+
+			b.Set("a", 1, 0)
+			b.Delete("a")
+			b.Set("b", 2, 0)
+			b.Clear()
+			if _, ok := b.Get("a"); ok {
+				t.Fatal("Expected deleted entry to miss")
+			}
+			if _, ok := b.Get("b"); ok {
+				t.Fatal("Expected cleared entry to miss")
+			}
+		})
+	}
+}
+
+// TestMemoryLockFreeConcurrent tests concurrent reads and writes on the lock-free read path
+func TestMemoryLockFreeConcurrent(t *testing.T) {
+	b := memory.New()
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				b.Set(fmt.Sprintf("k%d", i%50), i, time.Minute)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				b.Get(fmt.Sprintf("k%d", i%50))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if b.Len() != 50 {
+		t.Fatalf("Expected 50 entries, got: %d", b.Len())
+	}
+}
@@ -0,0 +1,108 @@
+package memo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// closeTracker wraps a reader and records whether it was closed
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+// TestMemoizeReader tests that the producer runs once and every caller gets a full, independent reader
+func TestMemoizeReader(t *testing.T) {
+	m := memo.New(memo.WithTTL(5 * time.Second))
+	ctx := context.Background()
+
+	var calls int32
+	var src *closeTracker
+	producer := func() (io.Reader, error) {
+		atomic.AddInt32(&calls, 1)
+		src = &closeTracker{Reader: strings.NewReader("streamed payload")}
+		return src, nil
+	}
+
+	r1, err := m.MemoizeReader(ctx, "reader-key", producer)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	r2, err := m.MemoizeReader(ctx, "reader-key", producer)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("Expected producer to run once, ran %d times", calls)
+	}
+	if !src.closed {
+		t.Fatal("Expected source reader to be closed after buffering")
+	}
+
+	// Partially consuming one reader must not affect the other
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(r1, buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	all2, err := io.ReadAll(r2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(all2) != "streamed payload" {
+		t.Fatalf("Expected full payload, got: %q", all2)
+	}
+
+	rest1, _ := io.ReadAll(r1)
+	if string(buf)+string(rest1) != "streamed payload" {
+		t.Fatalf("Expected full payload from first reader, got: %q", string(buf)+string(rest1))
+	}
+}
+
+// TestMemoizeReaderMaxSize tests that oversized streams are rejected and not cached
+func TestMemoizeReaderMaxSize(t *testing.T) {
+	m := memo.New(memo.WithTTL(5*time.Second), memo.WithMaxReaderSize(4))
+	ctx := context.Background()
+
+	var calls int32
+	producer := func() (io.Reader, error) {
+		atomic.AddInt32(&calls, 1)
+		return bytes.NewReader([]byte("too large")), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := m.MemoizeReader(ctx, "big-reader", producer)
+		if !errors.Is(err, memo.ErrReaderTooLarge) {
+			t.Fatalf("Expected ErrReaderTooLarge, got: %v", err)
+		}
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("Expected oversized result not to be cached, producer ran %d times", calls)
+	}
+
+	// Exactly at the limit is fine
+	r, err := m.MemoizeReader(ctx, "fits", func() (io.Reader, error) {
+		return strings.NewReader("abcd"), nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	if string(data) != "abcd" {
+		t.Fatalf("Expected 'abcd', got: %q", data)
+	}
+}
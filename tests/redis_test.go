@@ -0,0 +1,516 @@
+package memo
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/ldaidone/gomemo/internals/wire"
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// newRedis starts an in-process redis server and returns it with a raw client.
+func newRedis(t *testing.T) (*miniredis.Miniredis, *goredis.Client) {
+	t.Helper()
+	srv := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return srv, client
+}
+
+// gobEntry gob-encodes a cache entry the way the redis backend does.
+func gobEntry(t *testing.T, value any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(backends.NewEntry(value, 0, 0)); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestRedisBackendBasic tests Set, Get and Delete against the redis backend
+func TestRedisBackendBasic(t *testing.T) {
+	srv, _ := newRedis(t)
+	backend := redis.New(srv.Addr(), "test:", 0)
+
+	backend.Set("key", "value", time.Minute)
+	v, ok := backend.Get("key")
+	if !ok || v != "value" {
+		t.Fatalf("Expected 'value', got: %v (ok=%v)", v, ok)
+	}
+
+	backend.Delete("key")
+	if _, ok := backend.Get("key"); ok {
+		t.Fatal("Expected key to be deleted")
+	}
+}
+
+// TestRedisFormatHeader tests that stored values carry the wire header
+// and that Get dispatches on it, treating unknown formats as misses
+func TestRedisFormatHeader(t *testing.T) {
+	srv, client := newRedis(t)
+	backend := redis.New(srv.Addr(), "test:", 0)
+	ctx := context.Background()
+
+	backend.Set("framed", "value", time.Minute)
+	raw, err := client.Get(ctx, "test:framed").Bytes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hdr, _, err := wire.Decode(raw)
+	if err != nil {
+		t.Fatalf("Expected framed value, got error: %v", err)
+	}
+	if hdr.Version != wire.Version || hdr.Codec != wire.CodecGob || hdr.Flags != 0 {
+		t.Fatalf("Unexpected header: %+v", hdr)
+	}
+
+	payload := gobEntry(t, "value")
+	cases := []struct {
+		name string
+		data []byte
+		hit  bool
+	}{
+		{"legacy-unframed", payload, true},
+		{"current", wire.Encode(wire.Header{Codec: wire.CodecGob}, payload), true},
+		{"future-version", wire.Encode(wire.Header{Version: wire.Version + 1, Codec: wire.CodecGob}, payload), false},
+		{"unknown-codec", wire.Encode(wire.Header{Codec: 99}, payload), false},
+		{"unsupported-flags", wire.Encode(wire.Header{Codec: wire.CodecGob, Flags: wire.FlagEncrypted}, payload), false},
+		{"garbage", []byte{wire.Magic, wire.Version, byte(wire.CodecGob), 0, 0xde, 0xad}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := client.Set(ctx, "test:"+tc.name, tc.data, 0).Err(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			v, ok := backend.Get(tc.name)
+			if ok != tc.hit {
+				t.Fatalf("Expected hit=%v, got: %v (value=%v)", tc.hit, ok, v)
+			}
+			if tc.hit && v != "value" {
+				t.Fatalf("Expected 'value', got: %v", v)
+			}
+
+			// The memoizer reads through V2: unreadable entries are misses
+			// there too, and get replaced by the recomputed value
+			if err := client.Set(ctx, "test:"+tc.name, tc.data, 0).Err(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			m := memo.New(memo.WithBackend(backend), memo.WithBackendErrorPolicy(memo.BackendErrorFail))
+			calls := 0
+			fn := func() (any, error) {
+				calls++
+				return "computed", nil
+			}
+			want, wantCalls := "computed", 1
+			if tc.hit {
+				want, wantCalls = "value", 0
+			}
+			for range 2 {
+				if v, err := m.Get(ctx, tc.name, fn); err != nil || v != want {
+					t.Fatalf("Expected %q from the memoizer, got: %v, %v", want, v, err)
+				}
+			}
+			if calls != wantCalls {
+				t.Fatalf("Expected %d computes, got: %d", wantCalls, calls)
+			}
+		})
+	}
+}
+
+// TestRedisExpiryConsistency tests that a logically expired entry still present
+// in redis is treated as absent under Strong and returned under Lazy
+func TestRedisExpiryConsistency(t *testing.T) {
+	srv, _ := newRedis(t)
+
+	strong := redis.New(srv.Addr(), "strong:", 0, redis.WithExpiryConsistency(redis.Strong))
+	lazy := redis.New(srv.Addr(), "lazy:", 0, redis.WithExpiryConsistency(redis.Lazy))
+
+	strong.Set("key", "value", 20*time.Millisecond)
+	lazy.Set("key", "value", 20*time.Millisecond)
+
+	// miniredis only expires keys when its clock is fast-forwarded, so both
+	// keys are still physically present after the logical TTL has passed
+	time.Sleep(30 * time.Millisecond)
+	if !srv.Exists("strong:key") || !srv.Exists("lazy:key") {
+		t.Fatal("Expected keys to still be present in redis")
+	}
+
+	if v, ok := strong.Get("key"); ok {
+		t.Fatalf("Expected logically expired entry to be a miss under Strong, got: %v", v)
+	}
+	if srv.Exists("strong:key") {
+		t.Fatal("Expected Strong mode to delete the expired key eagerly")
+	}
+
+	v, ok := lazy.Get("key")
+	if !ok || v != "value" {
+		t.Fatalf("Expected Lazy mode to return the stored value, got: %v (ok=%v)", v, ok)
+	}
+
+	// Once redis reclaims the key both modes report a miss
+	srv.FastForward(time.Second)
+	if _, ok := lazy.Get("key"); ok {
+		t.Fatal("Expected miss after native TTL elapsed")
+	}
+}
+
+// TestRedisMaxValueSize tests that the size limit applies to the serialized entry, not the in-memory value
+func TestRedisMaxValueSize(t *testing.T) {
+	srv, _ := newRedis(t)
+	backend := redis.New(srv.Addr(), "test:", 0, redis.WithMaxValueSize(2048))
+
+	// A string is a 16-byte header in memory but serializes to all its bytes
+	long := strings.Repeat("x", 4096)
+	if unsafe.Sizeof(long) > 2048 {
+		t.Fatalf("Expected string header to be small in memory")
+	}
+	backend.Set("long", "short", time.Minute)
+	backend.Set("long", long, time.Minute)
+	if v, ok := backend.Get("long"); ok {
+		t.Fatalf("Expected value that is large serialized to be rejected and the old value deleted, got: %v", v)
+	}
+
+	// 8KB of zeros in memory serializes to about one byte per element
+	zeros := make([]int64, 1024)
+	backend.Set("zeros", zeros, time.Minute)
+	if v, ok := backend.Get("zeros"); !ok || len(v.([]int64)) != 1024 {
+		t.Fatalf("Expected value that is small serialized to be stored, got: %v", ok)
+	}
+}
+
+// TestRedisErrorHandler tests that the redis backend passes errors to WithErrorHandler instead of logging them
+func TestRedisErrorHandler(t *testing.T) {
+	srv, _ := newRedis(t)
+	var ops []string
+	var errs []error
+	backend := redis.New(srv.Addr(), "test:", 0, redis.WithMaxValueSize(256),
+		redis.WithErrorHandler(func(op string, err error) {
+			ops = append(ops, op)
+			errs = append(errs, err)
+		}))
+
+	backend.Set("big", strings.Repeat("x", 512), time.Minute)
+	if len(errs) != 1 || ops[0] != "set" || !errors.Is(errs[0], redis.ErrValueTooLarge) {
+		t.Fatalf("Expected one ErrValueTooLarge from set, got: %v %v", ops, errs)
+	}
+}
+
+// TestRedisV2ReportsErrors tests that the context-aware redis backend returns errors instead of logging them
+func TestRedisV2ReportsErrors(t *testing.T) {
+	srv, _ := newRedis(t)
+	backend := redis.NewV2(srv.Addr(), "test:", 0, redis.WithMaxValueSize(256))
+	ctx := context.Background()
+
+	if _, ok, err := backend.Get(ctx, "missing"); ok || err != nil {
+		t.Fatalf("Expected a plain miss, got: %v, %v", ok, err)
+	}
+	if err := backend.Set(ctx, "key", "value", time.Minute); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if v, ok, err := backend.Get(ctx, "key"); !ok || err != nil || v != "value" {
+		t.Fatalf("Expected 'value', got: %v, %v, %v", v, ok, err)
+	}
+	if err := backend.Set(ctx, "big", strings.Repeat("x", 512), time.Minute); !errors.Is(err, redis.ErrValueTooLarge) {
+		t.Fatalf("Expected ErrValueTooLarge, got: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err := backend.Get(cancelled, "key"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context error, got: %v", err)
+	}
+
+	srv.Close()
+	if _, _, err := backend.Get(ctx, "key"); err == nil {
+		t.Fatalf("Expected an error with the server down")
+	}
+	if err := backend.(backends.Closer).Close(); err != nil {
+		t.Fatalf("Expected no error closing the client, got: %v", err)
+	}
+}
+
+// TestRedisWithClient tests that a backend built on a caller's client shares it and leaves it open
+func TestRedisWithClient(t *testing.T) {
+	_, client := newRedis(t)
+	backend := redis.NewWithClient(client, "app:")
+
+	backend.Set("key", "value", time.Minute)
+	if n, err := client.Exists(context.Background(), "app:key").Result(); err != nil || n != 1 {
+		t.Fatalf("Expected the entry under app:key, got: %v, %v", n, err)
+	}
+	if v, ok := backend.Get("key"); !ok || v != "value" {
+		t.Fatalf("Expected 'value', got: %v, %v", v, ok)
+	}
+
+	if err := backend.(backends.Closer).Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("Expected the caller's client to stay open, got: %v", err)
+	}
+
+	v2 := redis.NewV2WithClient(client, "app:")
+	if v, ok, err := v2.Get(context.Background(), "key"); err != nil || !ok || v != "value" {
+		t.Fatalf("Expected 'value' through V2, got: %v, %v, %v", v, ok, err)
+	}
+}
+
+// TestRedisCluster tests that a cluster backend hash-tags its prefix and clears through every master
+func TestRedisCluster(t *testing.T) {
+	srv, client := newRedis(t)
+	backend := redis.NewCluster([]string{srv.Addr()}, "orders:")
+	t.Cleanup(func() { _ = backend.(backends.Closer).Close() })
+	ctx := context.Background()
+
+	backend.Set("1", "a", time.Minute)
+	backend.Set("2", "b", time.Minute)
+	if n, err := client.Exists(ctx, "{orders:}1").Result(); err != nil || n != 1 {
+		t.Fatalf("Expected the entry under {orders:}1, got: %v, %v", n, err)
+	}
+
+	got := backend.(backends.BatchBackend).GetMulti([]string{"1", "2"})
+	if len(got) != 2 {
+		t.Fatalf("Expected both entries from GetMulti, got: %v", got)
+	}
+
+	client.Set(ctx, "other", "kept", 0)
+	backend.Clear()
+	if _, ok := backend.Get("1"); ok {
+		t.Fatalf("Expected Clear to remove the entries")
+	}
+	if n, _ := client.Exists(ctx, "other").Result(); n != 1 {
+		t.Fatalf("Expected keys outside the prefix to survive Clear")
+	}
+}
+
+// TestRedisClearLargeKeyspace tests that Clear and DeleteByPrefix remove keyspaces spanning many scan batches
+func TestRedisClearLargeKeyspace(t *testing.T) {
+	srv, client := newRedis(t)
+	ctx := context.Background()
+	backend := redis.New(srv.Addr(), "test:", 0, redis.WithScanCount(10))
+
+	items := make([]backends.BatchItem, 500)
+	for i := range items {
+		items[i] = backends.BatchItem{Key: fmt.Sprintf("k%d", i), Value: i, TTL: time.Minute}
+	}
+	backend.(backends.BatchBackend).SetMulti(items)
+	client.Set(ctx, "other", "kept", 0)
+
+	if n := backend.(backends.PrefixDeleter).DeleteByPrefix("k1"); n != 111 {
+		t.Fatalf("Expected 111 keys removed under k1, got: %d", n)
+	}
+	if n := backend.(backends.PrefixDeleter).DeleteByPrefix(""); n != 389 {
+		t.Fatalf("Expected the remaining 389 keys removed, got: %d", n)
+	}
+
+	backend.(backends.BatchBackend).SetMulti(items)
+	backend.Clear()
+	if keys := srv.Keys(); len(keys) != 1 || keys[0] != "other" {
+		t.Fatalf("Expected only keys outside the prefix to survive Clear, got: %d keys", len(keys))
+	}
+}
+
+// newSentinel starts a fake sentinel that reports primary as the master of "mymaster".
+func newSentinel(t *testing.T, primary *miniredis.Miniredis) *miniredis.Miniredis {
+	t.Helper()
+	sentinel := miniredis.RunT(t)
+	host, port, _ := net.SplitHostPort(primary.Addr())
+	err := sentinel.Server().Register("SENTINEL", func(c *server.Peer, cmd string, args []string) {
+		switch {
+		case len(args) == 2 && strings.EqualFold(args[0], "get-master-addr-by-name") && args[1] == "mymaster":
+			c.WriteLen(2)
+			c.WriteBulk(host)
+			c.WriteBulk(port)
+		default:
+			c.WriteLen(0)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return sentinel
+}
+
+// TestRedisSentinel tests that a sentinel backend finds the primary through the sentinels and authenticates
+func TestRedisSentinel(t *testing.T) {
+	primary, _ := newRedis(t)
+	primary.RequireAuth("secret")
+	sentinel := newSentinel(t, primary)
+
+	backend := redis.NewSentinel("mymaster", []string{sentinel.Addr()}, "test:", 0, redis.WithPassword("secret"))
+	t.Cleanup(func() { _ = backend.(backends.Closer).Close() })
+
+	backend.Set("key", "value", time.Minute)
+	if v, ok := backend.Get("key"); !ok || v != "value" {
+		t.Fatalf("Expected 'value', got: %v, %v", v, ok)
+	}
+	if !primary.Exists("test:key") {
+		t.Fatalf("Expected the entry on the primary")
+	}
+}
+
+// TestRedisConnectionOptions tests that connection options reach the client the backend creates
+func TestRedisConnectionOptions(t *testing.T) {
+	srv, _ := newRedis(t)
+	srv.RequireUserAuth("app", "secret")
+
+	var applied bool
+	backend := redis.New(srv.Addr(), "test:", 0,
+		redis.WithUsername("app"),
+		redis.WithPassword("secret"),
+		redis.WithDialTimeout(time.Second),
+		redis.WithReadTimeout(time.Second),
+		redis.WithWriteTimeout(time.Second),
+		redis.WithPoolSize(4),
+		redis.WithMinIdleConns(1),
+		redis.WithClientOptions(func(o *goredis.UniversalOptions) { applied = o.PoolSize == 4 }),
+	)
+	t.Cleanup(func() { _ = backend.(backends.Closer).Close() })
+
+	backend.Set("key", "value", time.Minute)
+	if v, ok := backend.Get("key"); !ok || v != "value" {
+		t.Fatalf("Expected an authenticated round trip, got: %v, %v", v, ok)
+	}
+	if !applied {
+		t.Fatalf("Expected WithClientOptions to see the earlier options")
+	}
+
+	anonymous := redis.NewV2(srv.Addr(), "test:", 0)
+	if _, _, err := anonymous.Get(context.Background(), "key"); err == nil {
+		t.Fatalf("Expected an unauthenticated client to fail")
+	}
+}
+
+// TestRedisHonorsCallerContext tests that a backend created with New receives the caller's context through the memoizer
+func TestRedisHonorsCallerContext(t *testing.T) {
+	srv, _ := newRedis(t)
+	backend := redis.New(srv.Addr(), "test:", 0)
+	backend.Set("key", "value", time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := backends.ToV2(backend).Get(ctx, "key"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+
+	m := memo.New(memo.WithBackend(backend), memo.WithMetrics(true))
+	v, err := m.Get(ctx, "key", func() (any, error) { return "computed", nil })
+	if err != nil || v != "computed" {
+		t.Fatalf("Expected the cancelled lookup to miss, got: %v, %v", v, err)
+	}
+	if n := m.Metrics().Snapshot().BackendErrors; n == 0 {
+		t.Fatalf("Expected the cancelled call to count as a backend error")
+	}
+}
+
+// TestRedisInvalidate tests that keyspace notifications for the backend's keys evict them from a local cache
+func TestRedisInvalidate(t *testing.T) {
+	srv, _ := newRedis(t)
+	remote := redis.New(srv.Addr(), "test:", 0)
+	local := memory.New()
+	local.Set("a", 1, time.Minute)
+	local.Set("b", 2, time.Minute)
+	local.Set("c", 3, time.Minute)
+
+	inv, err := redis.Invalidate(context.Background(), remote, local)
+	if err != nil {
+		t.Fatalf("Expected subscription to succeed, got: %v", err)
+	}
+	defer inv.Close()
+
+	// miniredis does not emit keyspace events, so publish them as Redis would
+	srv.Publish("__keyspace@0__:test:a", "expired")
+	srv.Publish("__keyspace@0__:test:b", "set")
+	srv.Publish("__keyspace@0__:other:c", "del")
+	srv.Publish("__keyspace@0__:test:c", "del")
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, okA := local.Get("a")
+		_, okC := local.Get("c")
+		if !okA && !okC {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected expired and deleted keys to be evicted locally, got: %v, %v", okA, okC)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, ok := local.Get("b"); !ok {
+		t.Fatalf("Expected writes not to evict local entries")
+	}
+
+	if err := inv.Close(); err != nil {
+		t.Fatalf("Expected Close to succeed, got: %v", err)
+	}
+	if _, err := redis.Invalidate(context.Background(), local, local); !errors.Is(err, redis.ErrNotRedis) {
+		t.Fatalf("Expected ErrNotRedis for a memory backend, got: %v", err)
+	}
+}
+
+// TestRedisAtomicOperations tests compare-and-set, set-if-absent and get-and-touch on the redis backend
+func TestRedisAtomicOperations(t *testing.T) {
+	srv, _ := newRedis(t)
+	backend := redis.New(srv.Addr(), "test:", 0)
+	ops := backend.(backends.AtomicBackend)
+	entries := backend.(backends.EntryBackend)
+
+	if !ops.SetIfAbsent("k", "v0", time.Minute) {
+		t.Fatal("Expected SetIfAbsent to store a missing key")
+	}
+	if ops.SetIfAbsent("k", "other", time.Minute) {
+		t.Fatal("Expected SetIfAbsent to keep an existing key")
+	}
+
+	if ops.CompareAndSet("k", "stale", time.Minute, 1) {
+		t.Fatal("Expected CompareAndSet with the wrong version to fail")
+	}
+	if !ops.CompareAndSet("k", "v1", time.Minute, 0) {
+		t.Fatal("Expected CompareAndSet with the current version to succeed")
+	}
+	entry, ok := entries.GetEntry("k")
+	if !ok || entry.Value != "v1" || entry.Version() != 1 {
+		t.Fatalf("Expected v1 at version 1, got: %v, %d", entry.Value, entry.Version())
+	}
+	if ops.CompareAndSet("k", "v2", time.Minute, 0) {
+		t.Fatal("Expected a second update of version 0 to fail")
+	}
+	if ops.CompareAndSet("missing", "v", time.Minute, 0) {
+		t.Fatal("Expected CompareAndSet on a missing key to fail")
+	}
+
+	srv.FastForward(50 * time.Second)
+	if v, ok := ops.GetAndTouch("k", time.Minute); !ok || v != "v1" {
+		t.Fatalf("Expected GetAndTouch to return v1, got: %v, %v", v, ok)
+	}
+	if ttl := srv.TTL("test:k"); ttl != time.Minute {
+		t.Fatalf("Expected the native TTL to be reset, got: %v", ttl)
+	}
+	entry, _ = entries.GetEntry("k")
+	if left := time.Until(entry.ExpiresAt()); left < 55*time.Second {
+		t.Fatalf("Expected the logical expiry to move along, got: %v left", left)
+	}
+
+	backend.Delete("k")
+	if backend.(backends.Toucher).Touch("k", time.Minute) {
+		t.Fatal("Expected Touch of a deleted key to fail")
+	}
+	if _, ok := backend.Get("k"); ok {
+		t.Fatal("Expected Touch not to resurrect a deleted key")
+	}
+}
@@ -0,0 +1,86 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+var errTransient = errors.New("transient")
+
+// TestRetryRecovers tests that a transient failure is retried and the eventual value cached
+func TestRetryRecovers(t *testing.T) {
+	m := memo.New(memo.WithRetry(3, time.Millisecond), memo.WithMetrics(true))
+	ctx := context.Background()
+
+	calls := 0
+	fn := func() (any, error) {
+		calls++
+		if calls < 3 {
+			return nil, errTransient
+		}
+		return "ok", nil
+	}
+
+	v, err := m.Get(ctx, "k", fn)
+	if err != nil || v != "ok" {
+		t.Fatalf("Expected value after retries, got: %v, %v", v, err)
+	}
+	if calls != 3 {
+		t.Fatalf("Expected 3 calls, got: %d", calls)
+	}
+	if n := m.Metrics().Snapshot().Retries; n != 2 {
+		t.Fatalf("Expected 2 retries, got: %d", n)
+	}
+	if v, _ := m.Get(ctx, "k", fn); v != "ok" || calls != 3 {
+		t.Fatalf("Expected cached value, got: %v after %d calls", v, calls)
+	}
+}
+
+// TestRetryExhausted tests that the last error is returned and negatively cached once attempts run out
+func TestRetryExhausted(t *testing.T) {
+	m := memo.New(memo.WithRetry(3, time.Millisecond), memo.WithErrorTTL(time.Minute))
+	ctx := context.Background()
+
+	calls := 0
+	fn := func() (any, error) {
+		calls++
+		return nil, errTransient
+	}
+
+	if _, err := m.Get(ctx, "k", fn); !errors.Is(err, errTransient) {
+		t.Fatalf("Expected transient error, got: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("Expected 3 calls, got: %d", calls)
+	}
+	if _, err := m.Get(ctx, "k", fn); !errors.Is(err, errTransient) || calls != 3 {
+		t.Fatalf("Expected negatively cached error without retries, got: %v after %d calls", err, calls)
+	}
+}
+
+// TestRetryHonorsContext tests that retries stop when the caller's context is done
+func TestRetryHonorsContext(t *testing.T) {
+	m := memo.New(memo.WithRetry(10, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	_, err := m.Get(ctx, "k", func() (any, error) {
+		calls++
+		return nil, errTransient
+	})
+	if !errors.Is(err, errTransient) {
+		t.Fatalf("Expected last fn error, got: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("Expected no retry before the context ended, got: %d calls", calls)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Expected backoff to stop with the context, got: %v", d)
+	}
+}
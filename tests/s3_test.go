@@ -0,0 +1,171 @@
+package memo
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/s3"
+)
+
+// fakeS3 is an in-memory s3.Client.
+type fakeS3 struct {
+	mu         sync.Mutex
+	objects    map[string]s3.Object
+	deletes    int
+	lastBucket string
+	lastGetCtx context.Context
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string]s3.Object)}
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, bucket, key string) (*s3.Object, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastBucket, f.lastGetCtx = bucket, ctx
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	obj, ok := f.objects[key]
+	if !ok {
+		return nil, s3.ErrNotFound
+	}
+	return &obj, nil
+}
+
+func (f *fakeS3) PutObject(_ context.Context, bucket string, obj s3.Object) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastBucket = bucket
+	f.objects[obj.Key] = obj
+	return nil
+}
+
+func (f *fakeS3) DeleteObject(_ context.Context, _, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.objects, key)
+	return nil
+}
+
+func (f *fakeS3) DeleteObjects(_ context.Context, _ string, keys []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deletes++
+	for _, k := range keys {
+		delete(f.objects, k)
+	}
+	return nil
+}
+
+func (f *fakeS3) ListObjects(_ context.Context, _, prefix string, fn func(keys []string) error) error {
+	f.mu.Lock()
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	f.mu.Unlock()
+	sort.Strings(keys)
+	for len(keys) > 0 {
+		n := min(len(keys), 1500)
+		if err := fn(keys[:n]); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+// TestS3Basic tests reads, writes, expiry metadata and encryption settings of the s3 backend
+func TestS3Basic(t *testing.T) {
+	client := newFakeS3()
+	b := s3.New(client, "artifacts", s3.WithPrefix("models/"), s3.WithServerSideEncryption("aws:kms", "key-1"))
+
+	b.Set("k", []byte("weights"), 36*time.Hour)
+	if v, ok := b.Get("k"); !ok || string(v.([]byte)) != "weights" {
+		t.Fatalf("Expected weights, got: %v, %v", v, ok)
+	}
+	if client.lastBucket != "artifacts" {
+		t.Fatalf("Expected the configured bucket, got: %s", client.lastBucket)
+	}
+	obj, ok := client.objects["models/k"]
+	if !ok {
+		t.Fatal("Expected the object under the configured prefix")
+	}
+	if obj.ServerSideEncryption != "aws:kms" || obj.SSEKMSKeyID != "key-1" {
+		t.Fatalf("Expected the encryption settings to be passed on, got: %q, %q", obj.ServerSideEncryption, obj.SSEKMSKeyID)
+	}
+	if obj.Tags[s3.TTLDaysTag] != "2" {
+		t.Fatalf("Expected the TTL tag to round up to 2 days, got: %q", obj.Tags[s3.TTLDaysTag])
+	}
+	exp, err := strconv.ParseInt(obj.Metadata[s3.ExpiresMetadata], 10, 64)
+	if err != nil || !time.Unix(0, exp).Equal(obj.Expires) {
+		t.Fatalf("Expected the expiry in the metadata, got: %q", obj.Metadata[s3.ExpiresMetadata])
+	}
+
+	b.Set("forever", "v", 0)
+	if tag := client.objects["models/forever"].Tags[s3.TTLDaysTag]; tag != "none" {
+		t.Fatalf("Expected entries without TTL to be tagged none, got: %q", tag)
+	}
+
+	// Lifecycle rules delete expired objects late, so reads check the metadata
+	b.Set("short", "v", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := b.Get("short"); ok {
+		t.Fatal("Expected an expired object to be a miss")
+	}
+
+	b.Delete("k")
+	if _, ok := b.Get("k"); ok {
+		t.Fatal("Expected Delete to remove the object")
+	}
+}
+
+// TestS3DeleteByPrefix tests that prefix deletes and Clear stay within the backend's prefix
+func TestS3DeleteByPrefix(t *testing.T) {
+	client := newFakeS3()
+	b := s3.New(client, "artifacts")
+	client.objects["other/x"] = s3.Object{Key: "other/x"}
+
+	for i := 0; i < 1200; i++ {
+		b.Set("report:"+strconv.Itoa(i), i, time.Hour)
+	}
+	b.Set("model:a", "a", time.Hour)
+
+	if n := b.DeleteByPrefix("report:"); n != 1200 {
+		t.Fatalf("Expected 1200 objects removed, got: %d", n)
+	}
+	if client.deletes != 2 {
+		t.Fatalf("Expected 2 DeleteObjects calls of at most 1000 keys, got: %d", client.deletes)
+	}
+	b.Clear()
+	if len(client.objects) != 1 {
+		t.Fatalf("Expected Clear to keep only objects outside the prefix, got: %d objects", len(client.objects))
+	}
+}
+
+// TestS3WithMemoizer tests that the memoizer passes the caller's context to the s3 client
+func TestS3WithMemoizer(t *testing.T) {
+	client := newFakeS3()
+	m := memo.New(memo.WithBackend(s3.New(client, "artifacts")), memo.WithTTL(time.Minute))
+
+	ctx := context.WithValue(context.Background(), ctxKey("req"), "request")
+	if v, err := m.Get(ctx, "k", func() (any, error) { return "v", nil }); err != nil || v != "v" {
+		t.Fatalf("Expected v, got: %v, %v", v, err)
+	}
+	if client.lastGetCtx.Value(ctxKey("req")) != "request" {
+		t.Fatal("Expected the client to receive the caller's context")
+	}
+	if v, err := m.Get(ctx, "k", func() (any, error) { return "recomputed", nil }); err != nil || v != "v" {
+		t.Fatalf("Expected the stored value, got: %v, %v", v, err)
+	}
+}
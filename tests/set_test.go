@@ -0,0 +1,67 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestMemoizerSetSeedsCache tests that a value stored with Set is served by Get without computing
+func TestMemoizerSetSeedsCache(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	_, _ = m.Get(ctx, "user:1", func() (any, error) { return "old", nil })
+	m.Set(ctx, "user:1", "new")
+	m.Set(ctx, "user:2", "seeded")
+
+	fail := func() (any, error) { return nil, errors.New("should not compute") }
+	if v, err := m.Get(ctx, "user:1", fail); err != nil || v != "new" {
+		t.Fatalf("Expected overwritten value, got: %v, %v", v, err)
+	}
+	if v, err := m.Get(ctx, "user:2", fail); err != nil || v != "seeded" {
+		t.Fatalf("Expected seeded value, got: %v, %v", v, err)
+	}
+}
+
+// TestMemoizerSetHonorsTTLFunc tests that Set picks the TTL like computed values do
+func TestMemoizerSetHonorsTTLFunc(t *testing.T) {
+	backend := memory.New()
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Hour), memo.WithTTLFunc(func(key string, value any) time.Duration {
+		if value == "" {
+			return time.Second
+		}
+		return 0
+	}))
+
+	m.Set(context.Background(), "empty", "")
+	m.Set(context.Background(), "full", "x")
+
+	empty, _ := backend.GetEntry("empty")
+	full, _ := backend.GetEntry("full")
+	if got := empty.TTLRemaining(); got > time.Second {
+		t.Fatalf("Expected TTLFunc to shorten the TTL, got: %v", got)
+	}
+	if got := full.TTLRemaining(); got < 59*time.Minute {
+		t.Fatalf("Expected the default TTL, got: %v", got)
+	}
+}
+
+// TestMemoizerSetClearsCachedError tests that Set replaces a negatively cached error
+func TestMemoizerSetClearsCachedError(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute), memo.WithErrorTTL(time.Minute))
+	ctx := context.Background()
+
+	errLoad := errors.New("load failed")
+	if _, err := m.Get(ctx, "k", func() (any, error) { return nil, errLoad }); !errors.Is(err, errLoad) {
+		t.Fatalf("Expected load error, got: %v", err)
+	}
+	m.Set(ctx, "k", "v")
+	if v, err := m.Get(ctx, "k", func() (any, error) { return nil, errLoad }); err != nil || v != "v" {
+		t.Fatalf("Expected the set value, got: %v, %v", v, err)
+	}
+}
@@ -0,0 +1,176 @@
+package memo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+	"github.com/ldaidone/gomemo/pkg/backends/sharded"
+)
+
+// newRedisShards starts n Redis servers and returns them with backends keyed by address.
+func newRedisShards(t *testing.T, n int, opts ...redis.Option) (map[string]*miniredis.Miniredis, map[string]backends.Backend) {
+	t.Helper()
+	servers := make(map[string]*miniredis.Miniredis, n)
+	shards := make(map[string]backends.Backend, n)
+	for i := 0; i < n; i++ {
+		srv, client := newRedis(t)
+		servers[srv.Addr()] = srv
+		shards[srv.Addr()] = redis.NewWithClient(client, "test:", opts...)
+	}
+	return servers, shards
+}
+
+// TestShardedPlacement tests that every key is stored on the one shard owning it
+func TestShardedPlacement(t *testing.T) {
+	servers, shards := newRedisShards(t, 3)
+	b := sharded.New(shards)
+
+	for i := 0; i < 300; i++ {
+		b.Set(fmt.Sprintf("k%d", i), i, time.Minute)
+	}
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("k%d", i)
+		if v, ok := b.Get(key); !ok || v != i {
+			t.Fatalf("Expected %d for %s, got: %v, %v", i, key, v, ok)
+		}
+		for addr, srv := range servers {
+			if srv.Exists("test:"+key) != (addr == b.Owner(key)) {
+				t.Fatalf("Expected %s only on its owner %s", key, b.Owner(key))
+			}
+		}
+	}
+	for addr, srv := range servers {
+		if n := len(srv.Keys()); n < 50 {
+			t.Fatalf("Expected keys spread evenly, got: %d on %s", n, addr)
+		}
+	}
+
+	b.Delete("k1")
+	if _, ok := b.Get("k1"); ok {
+		t.Fatal("Expected Delete to remove the key from its shard")
+	}
+	if n := b.DeleteByPrefix("k2"); n != 111 {
+		t.Fatalf("Expected DeleteByPrefix to reach every shard, got: %d", n)
+	}
+	b.Clear()
+	for addr, srv := range servers {
+		if n := len(srv.Keys()); n != 0 {
+			t.Fatalf("Expected Clear to empty every shard, got: %d keys on %s", n, addr)
+		}
+	}
+}
+
+// TestShardedRebalance tests that adding a shard moves only part of the keyspace
+func TestShardedRebalance(t *testing.T) {
+	_, shards := newRedisShards(t, 4)
+	names := make([]string, 0, len(shards))
+	for name := range shards {
+		names = append(names, name)
+	}
+	three := make(map[string]backends.Backend)
+	for _, name := range names[:3] {
+		three[name] = shards[name]
+	}
+	before, after := sharded.New(three), sharded.New(shards)
+
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("k%d", i)
+		if before.Owner(key) != after.Owner(key) {
+			moved++
+			if after.Owner(key) != names[3] {
+				t.Fatalf("Expected moved keys to go to the new shard, got: %s", after.Owner(key))
+			}
+		}
+	}
+	if moved == 0 || moved > 400 {
+		t.Fatalf("Expected about a quarter of the keys to move, got: %d of 1000", moved)
+	}
+}
+
+// TestShardedBatch tests that batch calls are split across shards
+func TestShardedBatch(t *testing.T) {
+	_, shards := newRedisShards(t, 3)
+	b := sharded.New(shards)
+	ctx := context.Background()
+
+	var items []backends.BatchItem
+	var keys []string
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("k%d", i)
+		items = append(items, backends.BatchItem{Key: key, Value: i, TTL: time.Minute})
+		keys = append(keys, key)
+	}
+	b.SetMulti(items)
+	if got := b.GetMulti(append(keys, "missing")); len(got) != 30 || got["k7"] != 7 {
+		t.Fatalf("Expected all 30 values, got: %v", got)
+	}
+
+	v2 := b.V2().(backends.BatchBackendV2)
+	if err := v2.DeleteMulti(ctx, keys[:10]); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	got, err := v2.GetMulti(ctx, keys)
+	if err != nil || len(got) != 20 {
+		t.Fatalf("Expected 20 values left, got: %d, %v", len(got), err)
+	}
+}
+
+// TestShardedV2Errors tests that a failing shard only affects its own keys
+func TestShardedV2Errors(t *testing.T) {
+	servers, shards := newRedisShards(t, 2, redis.WithErrorHandler(func(string, error) {}))
+	b := sharded.New(shards)
+	ctx := context.Background()
+
+	var down, up string
+	for i := 0; down == "" || up == ""; i++ {
+		key := fmt.Sprintf("k%d", i)
+		if down == "" {
+			down = key
+		} else if b.Owner(key) != b.Owner(down) {
+			up = key
+		}
+	}
+	servers[b.Owner(down)].Close()
+
+	if err := b.V2().Set(ctx, down, "v", time.Minute); err == nil {
+		t.Fatal("Expected the write to the stopped shard to fail")
+	}
+	if err := b.V2().Set(ctx, up, "v", time.Minute); err != nil {
+		t.Fatalf("Expected the other shard to keep working, got: %v", err)
+	}
+	got, err := b.V2().(backends.BatchBackendV2).GetMulti(ctx, []string{down, up})
+	if err == nil || got[up] != "v" {
+		t.Fatalf("Expected the healthy shard's value and the failing shard's error, got: %v, %v", got, err)
+	}
+}
+
+// TestShardedWithMemoizer tests a memoizer over sharded Redis backends
+func TestShardedWithMemoizer(t *testing.T) {
+	_, shards := newRedisShards(t, 3)
+	m := memo.New(memo.WithBackend(sharded.New(shards)), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	calls := 0
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 10; j++ {
+			key := fmt.Sprintf("k%d", j)
+			v, err := m.Get(ctx, key, func() (any, error) {
+				calls++
+				return key, nil
+			})
+			if err != nil || v != key {
+				t.Fatalf("Expected %s, got: %v, %v", key, v, err)
+			}
+		}
+	}
+	if calls != 10 {
+		t.Fatalf("Expected one computation per key, got: %d", calls)
+	}
+}
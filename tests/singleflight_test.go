@@ -2,6 +2,8 @@ package memo
 
 import (
 	"context"
+	"errors"
+	"runtime"
 	"testing"
 	"time"
 
@@ -155,3 +157,216 @@ func TestSingleFlightRace(t *testing.T) {
 		t.Fatalf("Expected exactly 1 execution, got %d", executedCount)
 	}
 }
+
+// TestSingleFlightPanic tests that a panicking call releases its waiters with a PanicError and panics only in its own goroutine
+func TestSingleFlightPanic(t *testing.T) {
+	sf := memo.NewSingleFlight()
+	ctx := context.Background()
+	release := make(chan struct{})
+	boom := errors.New("boom")
+
+	recovered := make(chan any, 1)
+	go func() {
+		defer func() { recovered <- recover() }()
+		sf.Do(ctx, "key", func(ctx context.Context) (any, error) {
+			<-release
+			panic(boom)
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	const waiters = 3
+	errs := make(chan error, waiters)
+	for i := 0; i < waiters; i++ {
+		go func() {
+			_, err, _ := sf.Do(ctx, "key", func(ctx context.Context) (any, error) {
+				return "second", nil
+			})
+			errs <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	var pe *memo.PanicError
+	if r := <-recovered; !errors.As(r.(error), &pe) || pe.Value != boom || len(pe.Stack) == 0 {
+		t.Fatalf("Expected the executing goroutine to panic with a PanicError, got: %v", r)
+	}
+	for i := 0; i < waiters; i++ {
+		select {
+		case err := <-errs:
+			if !errors.As(err, &pe) || !errors.Is(err, boom) {
+				t.Fatalf("Expected a PanicError wrapping boom, got: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected waiters to be released")
+		}
+	}
+
+	v, err, executed := sf.Do(ctx, "key", func(ctx context.Context) (any, error) { return "fresh", nil })
+	if err != nil || v != "fresh" || !executed {
+		t.Fatalf("Expected a fresh call after the panic, got: %v, %v, %v", v, err, executed)
+	}
+}
+
+// TestSingleFlightGoexit tests that waiters are released when the call exits its goroutine
+func TestSingleFlightGoexit(t *testing.T) {
+	sf := memo.NewSingleFlight()
+	ctx := context.Background()
+	release := make(chan struct{})
+
+	go func() {
+		sf.Do(ctx, "key", func(ctx context.Context) (any, error) {
+			<-release
+			runtime.Goexit()
+			return nil, nil
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err, _ := sf.Do(ctx, "key", func(ctx context.Context) (any, error) { return "second", nil })
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("Expected an error for the waiter")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the waiter to be released")
+	}
+}
+
+// TestSingleFlightWaitTimeout tests that duplicate callers stop waiting after the wait timeout
+func TestSingleFlightWaitTimeout(t *testing.T) {
+	sf := memo.NewSingleFlight(memo.WaitTimeout(20 * time.Millisecond))
+	ctx := context.Background()
+	release := make(chan struct{})
+	defer close(release)
+
+	go sf.Do(ctx, "key", func(ctx context.Context) (any, error) {
+		<-release
+		return "slow", nil
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	start := time.Now()
+	_, err, executed := sf.Do(ctx, "key", func(ctx context.Context) (any, error) { return "second", nil })
+	if !errors.Is(err, memo.ErrWaitTimeout) || executed {
+		t.Fatalf("Expected ErrWaitTimeout, got: %v, %v", err, executed)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Expected the wait to be cut short, took: %v", elapsed)
+	}
+}
+
+// TestSingleFlightMaxWaiters tests that callers beyond the waiter limit are turned away at once
+func TestSingleFlightMaxWaiters(t *testing.T) {
+	sf := memo.NewSingleFlight(memo.MaxWaiters(2))
+	ctx := context.Background()
+	release := make(chan struct{})
+
+	go sf.Do(ctx, "key", func(ctx context.Context) (any, error) {
+		<-release
+		return "slow", nil
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err, _ := sf.Do(ctx, "key", nil)
+			results <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err, _ := sf.Do(ctx, "key", nil); !errors.Is(err, memo.ErrTooManyWaiters) {
+		t.Fatalf("Expected ErrTooManyWaiters, got: %v", err)
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("Expected waiters within the limit to get the result, got: %v", err)
+		}
+	}
+}
+
+// TestMemoizerWaitLimitsServeStale tests that a rejected waiter gets the stale value when there is one
+func TestMemoizerWaitLimitsServeStale(t *testing.T) {
+	ctx := context.Background()
+	for _, staleIfError := range []time.Duration{0, time.Minute} {
+		m := memo.New(memo.WithTTL(20*time.Millisecond), memo.WithStaleIfError(staleIfError),
+			memo.WithSingleFlightWaitTimeout(20*time.Millisecond), memo.WithMetrics(true))
+		_, _ = m.Get(ctx, "k", func() (any, error) { return "old", nil })
+		time.Sleep(30 * time.Millisecond)
+
+		release := make(chan struct{})
+		go m.Get(ctx, "k", func() (any, error) {
+			<-release
+			return "new", nil
+		})
+		time.Sleep(10 * time.Millisecond)
+
+		v, err := m.Get(ctx, "k", func() (any, error) { return "duplicate", nil })
+		close(release)
+		if staleIfError == 0 && !errors.Is(err, memo.ErrWaitTimeout) {
+			t.Fatalf("Expected ErrWaitTimeout without a stale value, got: %v, %v", v, err)
+		}
+		if staleIfError > 0 && (err != nil || v != "old") {
+			t.Fatalf("Expected the stale value, got: %v, %v", v, err)
+		}
+		if n := m.Metrics().Snapshot().WaitRejections; n != 1 {
+			t.Fatalf("Expected one wait rejection, got: %d", n)
+		}
+	}
+}
+
+// TestMemoizerRecoversPanic tests that a panicking fn passed to Get returns a *PanicError to every caller
+func TestMemoizerRecoversPanic(t *testing.T) {
+	m := memo.New(memo.WithMetrics(true), memo.WithRetry(3, time.Millisecond), memo.WithErrorTTL(time.Minute))
+	ctx := context.Background()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	waiter := make(chan error, 1)
+	go func() {
+		<-started
+		_, err := m.Get(ctx, "k", func() (any, error) { return "unused", nil })
+		waiter <- err
+	}()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	calls := 0
+	_, err := m.Get(ctx, "k", func() (any, error) {
+		calls++
+		close(started)
+		<-release
+		panic("boom")
+	})
+	var pe *memo.PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatalf("Expected *PanicError, got: %v", err)
+	}
+	if err := <-waiter; !errors.As(err, &pe) {
+		t.Fatalf("Expected waiter to get *PanicError, got: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("Expected panic not to be retried, got: %d calls", calls)
+	}
+	if n := m.Metrics().Snapshot().Panics; n != 1 {
+		t.Fatalf("Expected 1 panic recorded, got: %d", n)
+	}
+
+	v, err := m.Get(ctx, "k", func() (any, error) { return "ok", nil })
+	if err != nil || v != "ok" {
+		t.Fatalf("Expected panic not to be cached, got: %v, %v", v, err)
+	}
+}
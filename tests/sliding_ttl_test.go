@@ -0,0 +1,110 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+)
+
+// TestSlidingTTLExtendsOnHit tests that hits keep an entry alive past its original TTL
+func TestSlidingTTLExtendsOnHit(t *testing.T) {
+	m := memo.New(memo.WithTTL(40*time.Millisecond), memo.WithSlidingTTL(true))
+	ctx := context.Background()
+
+	calls := 0
+	fn := func() (any, error) {
+		calls++
+		return "v", nil
+	}
+
+	_, _ = m.Get(ctx, "k", fn)
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = m.Get(ctx, "k", fn)
+	}
+	if calls != 1 {
+		t.Fatalf("Expected hits to extend the TTL, got: %d calls", calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	_, _ = m.Get(ctx, "k", fn)
+	if calls != 2 {
+		t.Fatalf("Expected entry to expire without hits, got: %d calls", calls)
+	}
+}
+
+// TestSlidingTTLDisabled tests that hits do not extend the TTL by default
+func TestSlidingTTLDisabled(t *testing.T) {
+	m := memo.New(memo.WithTTL(40 * time.Millisecond))
+	ctx := context.Background()
+
+	calls := 0
+	fn := func() (any, error) {
+		calls++
+		return "v", nil
+	}
+
+	_, _ = m.Get(ctx, "k", fn)
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = m.Get(ctx, "k", fn)
+	}
+	if calls < 2 {
+		t.Fatalf("Expected entry to expire at its fixed TTL, got: %d calls", calls)
+	}
+}
+
+// TestRedisTouch tests that Touch moves both the native and the logical expiry
+func TestRedisTouch(t *testing.T) {
+	srv, _ := newRedis(t)
+	backend := redis.New(srv.Addr(), "test:", 0)
+	toucher := backend.(backends.Toucher)
+
+	if toucher.Touch("missing", time.Minute) {
+		t.Fatal("Expected Touch of a missing key to fail")
+	}
+
+	backend.Set("k", "v", 100*time.Millisecond)
+	if !toucher.Touch("k", time.Hour) {
+		t.Fatal("Expected Touch to succeed")
+	}
+	if ttl := srv.TTL("test:k"); ttl != time.Hour {
+		t.Fatalf("Expected native TTL of 1h, got: %v", ttl)
+	}
+
+	// The logical expiry moved too: the value survives past the original TTL
+	srv.FastForward(time.Second)
+	time.Sleep(150 * time.Millisecond)
+	if v, ok := backend.Get("k"); !ok || v != "v" {
+		t.Fatalf("Expected touched value to survive, got: %v, %v", v, ok)
+	}
+}
+
+// TestRedisTouchV2 tests that the context-aware Touch runs on the caller's context
+func TestRedisTouchV2(t *testing.T) {
+	srv, _ := newRedis(t)
+	backend := redis.New(srv.Addr(), "test:", 0)
+	toucher := backend.(backends.V2Provider).V2().(backends.ToucherV2)
+
+	backend.Set("k", "v", time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if ok, err := toucher.Touch(ctx, "k", time.Hour); ok || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a cancelled touch to fail, got: %v, %v", ok, err)
+	}
+	if ttl := srv.TTL("test:k"); ttl != time.Minute {
+		t.Fatalf("Expected native TTL to stay 1m, got: %v", ttl)
+	}
+
+	if ok, err := toucher.Touch(context.Background(), "k", time.Hour); !ok || err != nil {
+		t.Fatalf("Expected Touch to succeed, got: %v, %v", ok, err)
+	}
+	if ttl := srv.TTL("test:k"); ttl != time.Hour {
+		t.Fatalf("Expected native TTL of 1h, got: %v", ttl)
+	}
+}
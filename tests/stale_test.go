@@ -0,0 +1,118 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestStaleIfErrorServesLastGood tests that a stale value within the window is returned when fn fails
+func TestStaleIfErrorServesLastGood(t *testing.T) {
+	m := memo.New(
+		memo.WithTTL(20*time.Millisecond),
+		memo.WithStaleIfError(time.Minute),
+		memo.WithMetrics(true),
+	)
+	ctx := context.Background()
+
+	_, _ = m.Get(ctx, "k", func() (any, error) { return "good", nil })
+	time.Sleep(40 * time.Millisecond)
+
+	errDown := errors.New("upstream down")
+	v, err := m.Get(ctx, "k", func() (any, error) { return nil, errDown })
+	if err != nil || v != "good" {
+		t.Fatalf("Expected stale value, got: %v, %v", v, err)
+	}
+	if n := m.Metrics().Snapshot().StaleServed; n != 1 {
+		t.Fatalf("Expected 1 stale served, got: %d", n)
+	}
+
+	// The stale value is not written back: a successful compute still runs
+	v, _ = m.Get(ctx, "k", func() (any, error) { return "fresh", nil })
+	if v != "fresh" {
+		t.Fatalf("Expected fresh value after recovery, got: %v", v)
+	}
+}
+
+// TestStaleIfErrorWindow tests that the error surfaces once the stale window has passed
+func TestStaleIfErrorWindow(t *testing.T) {
+	m := memo.New(memo.WithTTL(10*time.Millisecond), memo.WithStaleIfError(10*time.Millisecond))
+	ctx := context.Background()
+
+	_, _ = m.Get(ctx, "k", func() (any, error) { return "good", nil })
+	time.Sleep(40 * time.Millisecond)
+
+	errDown := errors.New("upstream down")
+	if _, err := m.Get(ctx, "k", func() (any, error) { return nil, errDown }); !errors.Is(err, errDown) {
+		t.Fatalf("Expected error past the stale window, got: %v", err)
+	}
+}
+
+// TestStaleIfErrorDisabled tests that errors surface without the option
+func TestStaleIfErrorDisabled(t *testing.T) {
+	m := memo.New(memo.WithTTL(10 * time.Millisecond))
+	ctx := context.Background()
+
+	_, _ = m.Get(ctx, "k", func() (any, error) { return "good", nil })
+	time.Sleep(20 * time.Millisecond)
+
+	errDown := errors.New("upstream down")
+	if _, err := m.Get(ctx, "k", func() (any, error) { return nil, errDown }); !errors.Is(err, errDown) {
+		t.Fatalf("Expected error without stale-if-error, got: %v", err)
+	}
+}
+
+// TestStaleIfErrorMaxTrackedKeys tests that stale values are kept for at most MaxTrackedKeys keys
+func TestStaleIfErrorMaxTrackedKeys(t *testing.T) {
+	m := memo.New(
+		memo.WithTTL(10*time.Millisecond),
+		memo.WithStaleIfError(time.Minute),
+		memo.WithMaxTrackedKeys(2),
+	)
+	ctx := context.Background()
+
+	keys := []string{"a", "b", "c"}
+	for _, k := range keys {
+		_, _ = m.Get(ctx, k, func() (any, error) { return "good", nil })
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	errDown := errors.New("upstream down")
+	served := 0
+	for _, k := range keys {
+		if v, err := m.Get(ctx, k, func() (any, error) { return nil, errDown }); err == nil && v == "good" {
+			served++
+		}
+	}
+	if served != 2 {
+		t.Fatalf("Expected stale values for 2 keys, got: %d", served)
+	}
+}
+
+// TestStaleIfErrorPrunesExpiredWindows tests that stale values past their window make room before live ones are dropped
+func TestStaleIfErrorPrunesExpiredWindows(t *testing.T) {
+	m := memo.New(
+		memo.WithTTL(10*time.Millisecond),
+		memo.WithStaleIfError(10*time.Millisecond),
+		memo.WithMaxTrackedKeys(2),
+	)
+	ctx := context.Background()
+
+	_, _ = m.Get(ctx, "old1", func() (any, error) { return "old", nil })
+	_, _ = m.Get(ctx, "old2", func() (any, error) { return "old", nil })
+	time.Sleep(40 * time.Millisecond)
+
+	_, _ = m.Get(ctx, "new1", func() (any, error) { return "good", nil })
+	_, _ = m.Get(ctx, "new2", func() (any, error) { return "good", nil })
+	time.Sleep(15 * time.Millisecond)
+
+	errDown := errors.New("upstream down")
+	for _, k := range []string{"new1", "new2"} {
+		if v, err := m.Get(ctx, k, func() (any, error) { return nil, errDown }); err != nil || v != "good" {
+			t.Fatalf("Expected stale value for %s, got: %v, %v", k, v, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package memo
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// fakeClock is a manually advanced memory.Clock.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After never fires; tests drive sweeps explicitly.
+func (c *fakeClock) After(time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// TestSweepIntervalBacksOffWhenIdle tests that sweeps evicting nothing lengthen the interval up to the max
+func TestSweepIntervalBacksOffWhenIdle(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := memory.New(memory.WithClock(clock), memory.WithSweepBounds(time.Second, 8*time.Second))
+	b.Set("k", "v", 0)
+
+	if got := b.SweepInterval(); got != time.Second {
+		t.Fatalf("Expected initial interval of 1s, got: %v", got)
+	}
+
+	for i := 0; i < 30; i++ {
+		clock.Advance(b.SweepInterval())
+		b.Sweep()
+	}
+	if got := b.SweepInterval(); got != 8*time.Second {
+		t.Fatalf("Expected interval to back off to the max, got: %v", got)
+	}
+}
+
+// TestSweepIntervalTightensUnderHeavyExpiry tests that heavy eviction shortens the interval down to the min
+func TestSweepIntervalTightensUnderHeavyExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := memory.New(memory.WithClock(clock), memory.WithSweepBounds(time.Second, 8*time.Second))
+
+	// Idle first so the interval has room to shrink
+	for i := 0; i < 30; i++ {
+		clock.Advance(b.SweepInterval())
+		b.Sweep()
+	}
+	before := b.SweepInterval()
+
+	var intervals []time.Duration
+	for round := 0; round < 4; round++ {
+		for i := 0; i < 10; i++ {
+			b.Set(fmt.Sprintf("k%d", i), i, 500*time.Millisecond)
+		}
+		clock.Advance(b.SweepInterval())
+		if evicted := b.Sweep(); evicted != 10 {
+			t.Fatalf("Expected 10 evictions, got: %d", evicted)
+		}
+		intervals = append(intervals, b.SweepInterval())
+	}
+
+	for i := 1; i < len(intervals); i++ {
+		if intervals[i] > intervals[i-1] {
+			t.Fatalf("Expected interval to keep shrinking, got: %v", intervals)
+		}
+	}
+	if intervals[0] >= before || intervals[len(intervals)-1] != time.Second {
+		t.Fatalf("Expected interval to shrink from %v to the min, got: %v", before, intervals)
+	}
+}
+
+// TestSweepUsesClockForExpiry tests that entries expire according to the configured clock
+func TestSweepUsesClockForExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := memory.New(memory.WithClock(clock))
+
+	b.Set("k", "v", time.Minute)
+	if _, ok := b.Get("k"); !ok {
+		t.Fatalf("Expected entry before expiry")
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, ok := b.Get("k"); ok {
+		t.Fatalf("Expected entry to expire on the fake clock")
+	}
+}
@@ -0,0 +1,99 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends"
+	"github.com/ldaidone/gomemo/pkg/backends/faketest"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+)
+
+// TestInvalidateTag tests that entries tagged through Set and Get options are invalidated together
+func TestInvalidateTag(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	m.SetWithTags(ctx, "user:42:profile", "p", "user:42")
+	_, _ = m.GetWithOptions(ctx, "orders:42", func() (any, error) { return "o", nil }, memo.CallTags("user:42", "orders"))
+	m.SetWithTags(ctx, "user:7:profile", "p", "user:7")
+
+	n, err := m.InvalidateTag("user:42")
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 invalidated keys, got: %d, %v", n, err)
+	}
+	if m.Has("user:42:profile") || m.Has("orders:42") {
+		t.Fatalf("Expected tagged keys to be gone")
+	}
+	if !m.Has("user:7:profile") {
+		t.Fatalf("Expected untagged key to remain")
+	}
+	if n, _ := m.InvalidateTag("orders"); n != 0 {
+		t.Fatalf("Expected removed keys to leave their other tags, got: %d", n)
+	}
+}
+
+// TestMemoryTagsFollowEviction tests that evicted and replaced entries leave the tag index
+func TestMemoryTagsFollowEviction(t *testing.T) {
+	b := memory.New(memory.WithMaxEntries(1))
+	b.Set("a", 1, 0)
+	b.SetTags("a", []string{"t"})
+	b.Set("b", 2, 0) // evicts a
+
+	if keys := b.InvalidateTag("t"); len(keys) != 0 {
+		t.Fatalf("Expected evicted key to be untagged, got: %v", keys)
+	}
+
+	b.SetTags("b", []string{"old"})
+	b.SetTags("b", []string{"new"})
+	if keys := b.InvalidateTag("old"); len(keys) != 0 {
+		t.Fatalf("Expected replaced tags to be dropped, got: %v", keys)
+	}
+	if keys := b.InvalidateTag("new"); len(keys) != 1 || keys[0] != "b" {
+		t.Fatalf("Expected b under its new tag, got: %v", keys)
+	}
+}
+
+// TestRedisTags tests tagging and invalidation on the redis backend
+func TestRedisTags(t *testing.T) {
+	srv, _ := newRedis(t)
+	b := redis.New(srv.Addr(), "test:", 0)
+	tagger := b.(backends.Tagger)
+
+	b.Set("a", 1, time.Minute)
+	b.Set("b", 2, 0)
+	b.Set("c", 3, 0)
+	tagger.SetTags("a", []string{"t1", "t2"})
+	tagger.SetTags("b", []string{"t1"})
+	tagger.SetTags("c", []string{"t2"})
+	tagger.SetTags("c", []string{"t3"})
+	tagger.SetTags("missing", []string{"t1"})
+
+	keys := tagger.InvalidateTag("t1")
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Fatalf("Expected a and b, got: %v", keys)
+	}
+	if _, ok := b.Get("a"); ok {
+		t.Fatalf("Expected a to be invalidated")
+	}
+	if _, ok := b.Get("c"); !ok {
+		t.Fatalf("Expected c to remain after its tags were replaced")
+	}
+	if keys := tagger.InvalidateTag("t3"); len(keys) != 1 {
+		t.Fatalf("Expected c under t3, got: %v", keys)
+	}
+}
+
+// TestInvalidateTagUnsupported tests that backends without tag support report an error
+func TestInvalidateTagUnsupported(t *testing.T) {
+	m := memo.New(memo.WithBackend(faketest.New()), memo.WithTTL(time.Minute))
+	if _, err := m.InvalidateTag("t"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("Expected ErrUnsupported, got: %v", err)
+	}
+}
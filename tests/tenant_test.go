@@ -0,0 +1,91 @@
+package memo
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestTenantIsolation tests that tenants do not see each other's entries
+func TestTenantIsolation(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	m.Tenant("a").Set(ctx, "k", "value-a")
+	v, err := m.Tenant("b").Get(ctx, "k", func() (any, error) { return "value-b", nil })
+	if err != nil || v != "value-b" {
+		t.Fatalf("Expected tenant b to compute its own value, got: %v, %v", v, err)
+	}
+
+	tctx := memo.ContextWithTenant(ctx, "a")
+	v, err = m.TenantOf(tctx).Get(tctx, "k", func() (any, error) { return "recomputed", nil })
+	if err != nil || v != "value-a" {
+		t.Fatalf("Expected tenant a from context to hit its value, got: %v, %v", v, err)
+	}
+
+	stats := m.Tenant("a").Stats()
+	if stats.Hits != 1 || stats.Misses != 0 || stats.Entries != 1 {
+		t.Fatalf("Expected 1 hit and 1 entry for tenant a, got: %+v", stats)
+	}
+	if stats := m.Tenant("b").Stats(); stats.Misses != 1 || stats.Entries != 1 {
+		t.Fatalf("Expected 1 miss and 1 entry for tenant b, got: %+v", stats)
+	}
+}
+
+// TestTenantQuotaEvictsOwnEntries tests that a tenant over its quota only evicts its own oldest entries
+func TestTenantQuotaEvictsOwnEntries(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute), memo.WithTenantQuota(memo.TenantQuota{MaxEntries: 2}))
+	ctx := context.Background()
+
+	quiet := m.Tenant("quiet")
+	quiet.Set(ctx, "k", 1)
+
+	noisy := m.Tenant("noisy")
+	for _, k := range []string{"1", "2", "3", "4"} {
+		noisy.Set(ctx, k, k)
+	}
+
+	if stats := noisy.Stats(); stats.Entries != 2 || stats.Evictions != 2 {
+		t.Fatalf("Expected 2 entries and 2 evictions for noisy, got: %+v", stats)
+	}
+	if m.Has("tenant:5:noisy:1") || !m.Has("tenant:5:noisy:4") {
+		t.Fatalf("Expected the oldest noisy entries to be evicted")
+	}
+	if !m.Has("tenant:5:quiet:k") {
+		t.Fatalf("Expected the quiet tenant's entry to survive")
+	}
+}
+
+// TestTenantByteQuota tests that the byte quota counts estimated value sizes
+func TestTenantByteQuota(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	tenant := m.Tenant("t")
+	tenant.SetQuota(memo.TenantQuota{MaxBytes: 100})
+	tenant.Set(ctx, "a", strings.Repeat("x", 60))
+	tenant.Set(ctx, "b", strings.Repeat("y", 60))
+
+	stats := tenant.Stats()
+	if stats.Entries != 1 || stats.Bytes != 60 {
+		t.Fatalf("Expected one 60-byte entry, got: %+v", stats)
+	}
+	if m.Has("tenant:1:t:a") {
+		t.Fatalf("Expected a to be evicted")
+	}
+}
+
+// TestTenantKeysWithColons tests that tenant ids containing colons cannot reach another tenant's keys
+func TestTenantKeysWithColons(t *testing.T) {
+	m := memo.New(memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	m.Tenant("a").Set(ctx, "b:c", "value-a")
+	v, err := m.Tenant("a:b").Get(ctx, "c", func() (any, error) { return "value-ab", nil })
+	if err != nil || v != "value-ab" {
+		t.Fatalf("Expected tenant a:b to compute its own value, got: %v, %v", v, err)
+	}
+}
@@ -0,0 +1,70 @@
+package memo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// hotKeysAfterScan fills b with hot keys, reads them repeatedly, then writes a
+// scan of one-off keys and returns how many hot keys survived.
+func hotKeysAfterScan(b *memory.Memory) int {
+	const hot = 50
+	for i := 0; i < hot; i++ {
+		b.Set(fmt.Sprintf("hot%d", i), i, 0)
+	}
+	for r := 0; r < 10; r++ {
+		for i := 0; i < hot; i++ {
+			b.Get(fmt.Sprintf("hot%d", i))
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		b.Set(fmt.Sprintf("scan%d", i), i, 0)
+	}
+
+	kept := 0
+	for i := 0; i < hot; i++ {
+		if _, ok := b.Get(fmt.Sprintf("hot%d", i)); ok {
+			kept++
+		}
+	}
+	return kept
+}
+
+// TestTinyLFUResistsScans tests that one-hit-wonder keys do not evict hot entries under TinyLFU
+func TestTinyLFUResistsScans(t *testing.T) {
+	tiny := memory.New(memory.WithMaxEntries(100), memory.WithEvictionPolicy(memory.TinyLFU))
+	if kept := hotKeysAfterScan(tiny); kept < 45 {
+		t.Fatalf("Expected TinyLFU to keep most hot keys, got: %d of 50", kept)
+	}
+	if tiny.Len() != 100 {
+		t.Fatalf("Expected backend to stay at capacity, got: %d", tiny.Len())
+	}
+
+	lru := memory.New(memory.WithMaxEntries(100))
+	if kept := hotKeysAfterScan(lru); kept != 0 {
+		t.Fatalf("Expected LRU to lose the hot keys to the scan, got: %d of 50", kept)
+	}
+}
+
+// TestTinyLFUAdmitsFrequentKeys tests that a new key that becomes popular displaces a cold one
+func TestTinyLFUAdmitsFrequentKeys(t *testing.T) {
+	b := memory.New(memory.WithMaxEntries(10), memory.WithEvictionPolicy(memory.TinyLFU))
+	for i := 0; i < 10; i++ {
+		b.Set(fmt.Sprintf("cold%d", i), i, 0)
+	}
+
+	// Repeated writes raise the new key's estimated frequency until it is admitted
+	for i := 0; i < 5; i++ {
+		b.Set("rising", i, 0)
+	}
+	b.Set("next", 0, 0) // pushes rising out of the window into the main area
+
+	if _, ok := b.Get("rising"); !ok {
+		t.Fatal("Expected frequently written key to be admitted")
+	}
+	if b.Len() != 10 {
+		t.Fatalf("Expected backend to stay at capacity, got: %d", b.Len())
+	}
+}
@@ -0,0 +1,64 @@
+package memo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
+)
+
+// TestTopKeys tests that the hottest keys are reported with their hits and misses
+func TestTopKeys(t *testing.T) {
+	m := memo.New(memo.WithTopKeys(4), memo.WithTTL(time.Minute))
+	ctx := context.Background()
+	fn := func() (any, error) { return "v", nil }
+
+	for i := 0; i < 30; i++ {
+		_, _ = m.Get(ctx, "a", fn)
+	}
+	for i := 0; i < 20; i++ {
+		_, _ = m.Get(ctx, "b", fn)
+	}
+	for i := 0; i < 10; i++ {
+		_, _ = m.Get(ctx, fmt.Sprintf("cold%d", i), fn)
+	}
+
+	top := m.TopKeys(2)
+	if len(top) != 2 || top[0].Key != "a" || top[1].Key != "b" {
+		t.Fatalf("Expected a and b to be the hottest keys, got: %+v", top)
+	}
+	if top[0].Count != 30 || top[0].Hits != 29 || top[0].Misses != 1 {
+		t.Fatalf("Expected 30 Gets of a with 1 miss, got: %+v", top[0])
+	}
+	if all := m.TopKeys(-1); len(all) != 4 {
+		t.Fatalf("Expected the table to stay at 4 keys, got: %d", len(all))
+	}
+}
+
+// TestTopKeysDisabled tests that TopKeys returns nil unless enabled
+func TestTopKeysDisabled(t *testing.T) {
+	m := memo.New()
+	_, _ = m.Get(context.Background(), "k", func() (any, error) { return "v", nil })
+	if top := m.TopKeys(10); top != nil {
+		t.Fatalf("Expected nil without WithTopKeys, got: %+v", top)
+	}
+}
+
+// TestTopKeysAccessCounts tests that the backend's access counts are included in the key stats
+func TestTopKeysAccessCounts(t *testing.T) {
+	m := memo.New(memo.WithTopKeys(4), memo.WithBackend(memory.New(memory.WithAccessCounts())))
+	ctx := context.Background()
+	fn := func() (any, error) { return "v", nil }
+
+	for i := 0; i < 10; i++ {
+		_, _ = m.Get(ctx, "a", fn)
+	}
+	top := m.TopKeys(1)
+	want, _ := m.AccessCount("a")
+	if len(top) != 1 || want == 0 || top[0].Accesses != want {
+		t.Fatalf("Expected %d accesses of a, got: %+v", want, top)
+	}
+}
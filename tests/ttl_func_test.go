@@ -0,0 +1,76 @@
+package memo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+// TestTTLFuncByValue tests that the TTL policy can depend on the computed value
+func TestTTLFuncByValue(t *testing.T) {
+	m := memo.New(
+		memo.WithTTL(time.Minute),
+		memo.WithTTLFunc(func(key string, value any) time.Duration {
+			if items, ok := value.([]string); ok && len(items) == 0 {
+				return 20 * time.Millisecond
+			}
+			return 0 // default TTL
+		}),
+	)
+	ctx := context.Background()
+
+	emptyCalls, fullCalls := 0, 0
+	empty := func() (any, error) {
+		emptyCalls++
+		return []string{}, nil
+	}
+	full := func() (any, error) {
+		fullCalls++
+		return []string{"a"}, nil
+	}
+
+	_, _ = m.Get(ctx, "empty", empty)
+	_, _ = m.Get(ctx, "full", full)
+	time.Sleep(40 * time.Millisecond)
+	_, _ = m.Get(ctx, "empty", empty)
+	_, _ = m.Get(ctx, "full", full)
+
+	if emptyCalls != 2 {
+		t.Fatalf("Expected empty result to expire quickly, got: %d calls", emptyCalls)
+	}
+	if fullCalls != 1 {
+		t.Fatalf("Expected full result to use the default TTL, got: %d calls", fullCalls)
+	}
+}
+
+// TestTTLFuncByKeyAndOverride tests key-based policies and that SetKeyTTL takes precedence
+func TestTTLFuncByKeyAndOverride(t *testing.T) {
+	m := memo.New(
+		memo.WithTTL(time.Minute),
+		memo.WithTTLFunc(func(key string, value any) time.Duration {
+			return 20 * time.Millisecond
+		}),
+	)
+	m.SetKeyTTL("pinned", time.Minute)
+	ctx := context.Background()
+
+	calls := map[string]int{}
+	fn := func(key string) func() (any, error) {
+		return func() (any, error) {
+			calls[key]++
+			return key, nil
+		}
+	}
+
+	_, _ = m.Get(ctx, "policy", fn("policy"))
+	_, _ = m.Get(ctx, "pinned", fn("pinned"))
+	time.Sleep(40 * time.Millisecond)
+	_, _ = m.Get(ctx, "policy", fn("policy"))
+	_, _ = m.Get(ctx, "pinned", fn("pinned"))
+
+	if calls["policy"] != 2 || calls["pinned"] != 1 {
+		t.Fatalf("Expected policy TTL for 'policy' and override for 'pinned', got: %v", calls)
+	}
+}
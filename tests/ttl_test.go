@@ -3,6 +3,7 @@ package memo
 import (
 	"context"
 	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/memory"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -53,3 +54,15 @@ func TestTTLExpiration(t *testing.T) {
 		t.Fatalf("expected function to be called again after TTL expiration, calls=%d", calls)
 	}
 }
+
+// TestBackendDefaultTTL tests that a backend's default TTL replaces the memoizer's TTL
+func TestBackendDefaultTTL(t *testing.T) {
+	backend := memory.New(memory.WithDefaultTTL(time.Second))
+	defer backend.Close()
+	m := memo.New(memo.WithBackend(backend), memo.WithTTL(time.Hour))
+
+	_, info, err := m.GetWithInfo(context.Background(), "k", func() (any, error) { return "v", nil })
+	if err != nil || info.TTL <= 0 || info.TTL > time.Second {
+		t.Fatalf("Expected the backend's one second TTL, got: %v, %v", info.TTL, err)
+	}
+}
@@ -0,0 +1,58 @@
+package memo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+)
+
+type typedPoint struct{ X, Y int }
+
+// TestTypedMemoizerGet tests typed caching with struct keys
+func TestTypedMemoizerGet(t *testing.T) {
+	m := memo.NewTyped[typedPoint, int](memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	calls := 0
+	sum := func(p typedPoint) func() (int, error) {
+		return func() (int, error) {
+			calls++
+			return p.X + p.Y, nil
+		}
+	}
+
+	a := typedPoint{1, 2}
+	b := typedPoint{3, 4}
+	for i := 0; i < 2; i++ {
+		if v, err := m.Get(ctx, a, sum(a)); err != nil || v != 3 {
+			t.Fatalf("Expected 3, got: %v, %v", v, err)
+		}
+		if v, err := m.Get(ctx, b, sum(b)); err != nil || v != 7 {
+			t.Fatalf("Expected 7, got: %v, %v", v, err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("Expected one compute per key, got: %d", calls)
+	}
+
+	m.Delete(a)
+	_, _ = m.Get(ctx, a, sum(a))
+	if calls != 3 {
+		t.Fatalf("Expected recompute after Delete, got: %d", calls)
+	}
+}
+
+// TestTypedMemoizerStringKeys tests that string keys are shared with the untyped Memoizer
+func TestTypedMemoizerStringKeys(t *testing.T) {
+	m := memo.NewTyped[string, string](memo.WithTTL(time.Minute))
+	ctx := context.Background()
+
+	_, _ = m.Get(ctx, "greeting", func() (string, error) { return "hello", nil })
+
+	v, err := m.Memoizer().Get(ctx, "greeting", func() (any, error) { return "unused", nil })
+	if err != nil || v != "hello" {
+		t.Fatalf("Expected 'hello' via the untyped API, got: %v, %v", v, err)
+	}
+}
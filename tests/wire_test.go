@@ -0,0 +1,57 @@
+package memo
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ldaidone/gomemo/internals/wire"
+)
+
+// TestWireRoundTrip tests that a framed payload decodes back to the same header and payload
+func TestWireRoundTrip(t *testing.T) {
+	payload := []byte("payload")
+	framed := wire.Encode(wire.Header{Codec: wire.CodecGob, Flags: wire.FlagCompressed}, payload)
+
+	if len(framed) != wire.HeaderSize+len(payload) {
+		t.Fatalf("Expected %d bytes, got: %d", wire.HeaderSize+len(payload), len(framed))
+	}
+	if framed[0] != wire.Magic {
+		t.Fatalf("Expected magic byte %#x, got: %#x", wire.Magic, framed[0])
+	}
+
+	hdr, body, err := wire.Decode(framed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if hdr.Version != wire.Version {
+		t.Fatalf("Expected version %d, got: %d", wire.Version, hdr.Version)
+	}
+	if hdr.Codec != wire.CodecGob {
+		t.Fatalf("Expected gob codec, got: %d", hdr.Codec)
+	}
+	if hdr.Flags != wire.FlagCompressed {
+		t.Fatalf("Expected compressed flag, got: %#x", hdr.Flags)
+	}
+	if !bytes.Equal(body, payload) {
+		t.Fatalf("Expected payload %q, got: %q", payload, body)
+	}
+}
+
+// TestWireDecodeErrors tests the errors reported for unframed and unsupported data
+func TestWireDecodeErrors(t *testing.T) {
+	if _, _, err := wire.Decode([]byte{0x0c, 0xff}); !errors.Is(err, wire.ErrNoHeader) {
+		t.Fatalf("Expected ErrNoHeader for unframed data, got: %v", err)
+	}
+	if _, _, err := wire.Decode(nil); !errors.Is(err, wire.ErrNoHeader) {
+		t.Fatalf("Expected ErrNoHeader for empty data, got: %v", err)
+	}
+	if _, _, err := wire.Decode([]byte{wire.Magic, wire.Version}); !errors.Is(err, wire.ErrUnknownFormat) {
+		t.Fatalf("Expected ErrUnknownFormat for truncated header, got: %v", err)
+	}
+
+	future := wire.Encode(wire.Header{Version: wire.Version + 1, Codec: wire.CodecGob}, []byte("x"))
+	if _, _, err := wire.Decode(future); !errors.Is(err, wire.ErrUnknownFormat) {
+		t.Fatalf("Expected ErrUnknownFormat for future version, got: %v", err)
+	}
+}
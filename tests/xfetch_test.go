@@ -0,0 +1,145 @@
+package memo
+
+import (
+	"context"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/gomemo/memo"
+	"github.com/ldaidone/gomemo/pkg/backends/redis"
+)
+
+// TestEarlyExpiryScalesWithCost tests that expensive keys are refreshed ahead of expiry while cheap keys are not
+func TestEarlyExpiryScalesWithCost(t *testing.T) {
+	newMemo := func() *memo.Memoizer {
+		return memo.New(
+			memo.WithTTL(100*time.Millisecond),
+			memo.WithProbabilisticEarlyExpiry(10),
+			memo.WithRandSource(rand.NewPCG(1, 2)),
+			memo.WithMetrics(true),
+		)
+	}
+	ctx := context.Background()
+
+	cheap := newMemo()
+	cheapFn := func() (any, error) { return "cheap", nil }
+	for i := 0; i < 30; i++ {
+		_, _ = cheap.Get(ctx, "k", cheapFn)
+	}
+	if n := cheap.Metrics().Snapshot().EarlyRefreshes; n != 0 {
+		t.Fatalf("Expected no early refreshes for a cheap key, got: %d", n)
+	}
+
+	expensive := newMemo()
+	expensiveFn := func() (any, error) {
+		time.Sleep(10 * time.Millisecond)
+		return "expensive", nil
+	}
+	for i := 0; i < 30; i++ {
+		_, _ = expensive.Get(ctx, "k", expensiveFn)
+	}
+	if n := expensive.Metrics().Snapshot().EarlyRefreshes; n == 0 {
+		t.Fatalf("Expected early refreshes for an expensive key, got: %d", n)
+	}
+}
+
+// TestEarlyExpiryNearExpiry tests that entries close to expiry are refreshed early while distant ones are not
+func TestEarlyExpiryNearExpiry(t *testing.T) {
+	ctx := context.Background()
+	fn := func() (any, error) {
+		time.Sleep(5 * time.Millisecond)
+		return "value", nil
+	}
+	run := func(ttl time.Duration) uint64 {
+		m := memo.New(
+			memo.WithTTL(ttl),
+			memo.WithProbabilisticEarlyExpiry(2),
+			memo.WithRandSource(rand.NewPCG(1, 2)),
+			memo.WithMetrics(true),
+		)
+		for i := 0; i < 50; i++ {
+			_, _ = m.Get(ctx, "k", fn)
+		}
+		return m.Metrics().Snapshot().EarlyRefreshes
+	}
+
+	if n := run(10 * time.Second); n != 0 {
+		t.Fatalf("Expected no early refreshes far from expiry, got: %d", n)
+	}
+	if n := run(15 * time.Millisecond); n == 0 {
+		t.Fatalf("Expected early refreshes near expiry, got: %d", n)
+	}
+}
+
+// TestEarlyExpiryDisabled tests that no early refreshes happen without the option
+func TestEarlyExpiryDisabled(t *testing.T) {
+	m := memo.New(memo.WithTTL(15*time.Millisecond), memo.WithMetrics(true))
+	ctx := context.Background()
+	fn := func() (any, error) {
+		time.Sleep(5 * time.Millisecond)
+		return "value", nil
+	}
+	for i := 0; i < 50; i++ {
+		_, _ = m.Get(ctx, "k", fn)
+	}
+	if n := m.Metrics().Snapshot().EarlyRefreshes; n != 0 {
+		t.Fatalf("Expected no early refreshes when disabled, got: %d", n)
+	}
+}
+
+// TestEarlyRecomputeAlias tests that WithEarlyRecompute enables early expiration
+func TestEarlyRecomputeAlias(t *testing.T) {
+	m := memo.New(
+		memo.WithTTL(15*time.Millisecond),
+		memo.WithEarlyRecompute(2),
+		memo.WithRandSource(rand.NewPCG(1, 2)),
+		memo.WithMetrics(true),
+	)
+	ctx := context.Background()
+	fn := func() (any, error) {
+		time.Sleep(5 * time.Millisecond)
+		return "value", nil
+	}
+	for i := 0; i < 50; i++ {
+		_, _ = m.Get(ctx, "k", fn)
+	}
+	if n := m.Metrics().Snapshot().EarlyRefreshes; n == 0 {
+		t.Fatalf("Expected early refreshes, got: %d", n)
+	}
+}
+
+// TestEarlyExpiryBackendV2 tests that early expiry reads entries through a context-aware backend and reports its errors
+func TestEarlyExpiryBackendV2(t *testing.T) {
+	srv, client := newRedis(t)
+	m := memo.New(
+		memo.WithBackendV2(redis.NewV2WithClient(client, "test:")),
+		memo.WithTTL(100*time.Millisecond),
+		memo.WithProbabilisticEarlyExpiry(10),
+		memo.WithRandSource(rand.NewPCG(1, 2)),
+		memo.WithBackendErrorPolicy(memo.BackendFailClosed),
+		memo.WithMetrics(true),
+	)
+	ctx := context.Background()
+	fn := func() (any, error) {
+		time.Sleep(10 * time.Millisecond)
+		return "value", nil
+	}
+
+	for i := 0; i < 30; i++ {
+		if _, err := m.Get(ctx, "k", fn); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if n := m.Metrics().Snapshot().EarlyRefreshes; n == 0 {
+		t.Fatalf("Expected early refreshes through the V2 backend, got: %d", n)
+	}
+
+	srv.Close()
+	if _, err := m.Get(ctx, "k", fn); err == nil {
+		t.Fatal("Expected the backend error under BackendFailClosed")
+	}
+	if n := m.Metrics().Snapshot().FailedReads; n != 1 {
+		t.Fatalf("Expected one failed read, got: %d", n)
+	}
+}